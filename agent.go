@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/pflag"
+	"neobench/pkg/neobench"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry point for `neobench agent`; only returns if the agent can't listen, with exit code 1, or on invalid usage
+func runAgent(args []string) int {
+	flags := pflag.NewFlagSet("agent", pflag.ContinueOnError)
+	listen := flags.String("listen", ":7700", "`address` to wait for a coordinator on")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Runs a share of a benchmark for a coordinator, a neobench started with --agents, when one machine can't
+generate enough load on its own.
+
+Usage:
+  neobench agent [OPTION]...
+
+Set NEOBENCH_AGENT_TOKEN to the same value on the agents and the coordinator, so only it can start runs.
+
+Options:
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		logger.Errorf("can't find the neobench executable to run benchmarks with: %s", err)
+		return 1
+	}
+	token := neobench.AgentToken()
+	if token == "" {
+		logger.Warningf("NEOBENCH_AGENT_TOKEN is not set, anyone who can reach %s can run benchmarks, and commands through --hook", *listen)
+	}
+	logger.Infof("waiting for a coordinator on %s", *listen)
+	if err := http.ListenAndServe(*listen, neobench.NewAgentHandler(executable, token, logger)); err != nil {
+		logger.Errorf("%s", err)
+	}
+	return 1
+}
+
+// Time agents get to connect and check the workload before the benchmark starts on all of them at once
+const agentStartDelay = 10 * time.Second
+
+// Runs the benchmark on the agents of --agents, each with its share of the clients and rate, and merges their results
+func runOnAgents(ctx context.Context, cfg neobench.BenchmarkConfig, seed int64) (neobench.Result, error) {
+	rate := 0.0
+	if fLatencyMode && !pflag.CommandLine.Changed("rate-per-client") {
+		rate = fRate
+	}
+	shares, err := neobench.SplitLoad(len(fAgents), fClients, rate, seed)
+	if err != nil {
+		return neobench.Result{}, err
+	}
+	// Agents start by their own clocks and report times by them, so both are corrected by how far off they are
+	offsets := make([]time.Duration, len(fAgents))
+	for i, agent := range fAgents {
+		offset, roundTrip, err := neobench.ProbeClockOffset(ctx, agent)
+		if err != nil {
+			return neobench.Result{}, fmt.Errorf("failed to read the clock of agent %s: %s", agent, err)
+		}
+		offsets[i] = offset
+		if offset > roundTrip || -offset > roundTrip {
+			logger.Infof("clock of agent %s is off by %s, correcting for it", agent, offset.Round(time.Millisecond))
+		}
+	}
+	startAt := time.Now().Add(agentStartDelay)
+	logger.Infof("running on %d agents, starting at %s", len(fAgents), startAt.Format(time.RFC3339))
+	results, err := neobench.RunOnAgents(ctx, fAgents, neobench.AgentToken(), func(i int) []string {
+		return agentArgs(os.Args[1:], shares[i], startAt.Add(offsets[i]))
+	})
+	if err != nil {
+		return neobench.Result{}, err
+	}
+	merged := neobench.NewResult(cfg.DatabaseName, cfg.Scenario)
+	for i := range results {
+		results[i].ShiftClock(-offsets[i])
+		merged.Merge(results[i])
+	}
+	for _, interval := range neobench.MergeIntervals(results) {
+		for _, sink := range cfg.Sinks {
+			if err := sink.WriteInterval(interval.Start, interval.End, interval.Result(cfg.DatabaseName, cfg.Scenario)); err != nil {
+				logger.Errorf("failed to record interval: %s", err)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// Writes result in full, for --agent-result and --save-result
+func saveResult(path string, result neobench.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to save result: %s", err)
+	}
+	if err := neobench.EncodeResult(f, result); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to save result to %s: %s", path, err)
+	}
+	return f.Close()
+}
+
+// Flags only the coordinator acts on: it reports and stores the merged result, runs --before and --after once,
+// and gives each agent its share of the clients and rate
+var coordinatorFlags = map[string]bool{
+	"agents": true, "output": true, "quiet": true, "clients": true, "rate": true, "seed": true,
+	"client-offset": true, "client-count": true,
+	"results-url": true, "results-user": true, "results-password": true, "results-db": true, "results-sqlite": true,
+	"baseline": true, "threshold": true, "thresholds": true, "fail-if": true, "hgrm-dir": true, "save-result": true, "artifacts": true,
+	"before": true, "after": true, "check-invariants": true,
+}
+
+// The args to run an agent with: ours without the coordinator's flags, plus the agents share and when to start
+func agentArgs(args []string, share neobench.AgentShare, startAt time.Time) []string {
+	out := make([]string, 0, len(args)+10)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			out = append(out, args[i:]...)
+			break
+		}
+		var flag *pflag.Flag
+		inlineValue := false
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name := strings.TrimPrefix(arg, "--")
+			if eq := strings.Index(name, "="); eq >= 0 {
+				name, inlineValue = name[:eq], true
+			}
+			flag = pflag.CommandLine.Lookup(name)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			flag = pflag.CommandLine.ShorthandLookup(arg[1:2])
+			inlineValue = len(arg) > 2
+			if flag != nil && flag.NoOptDefVal != "" && inlineValue {
+				// Combined boolean shorthands, like -lq, are passed on as they are
+				flag = nil
+			}
+		}
+		if flag == nil || !coordinatorFlags[flag.Name] {
+			out = append(out, arg)
+			continue
+		}
+		if flag.NoOptDefVal == "" && !inlineValue {
+			// Skip the value too
+			i++
+		}
+	}
+	out = append(out,
+		"--clients", strconv.Itoa(share.Clients),
+		"--client-offset", strconv.Itoa(share.FirstClient),
+		"--client-count", strconv.Itoa(fClients),
+		"--seed", strconv.FormatInt(share.Seed, 10),
+		"--start-at", startAt.Format(time.RFC3339Nano),
+		"--quiet")
+	if share.Rate > 0 {
+		out = append(out, "--rate", strconv.FormatFloat(share.Rate, 'f', -1, 64))
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"neobench/pkg/neobench"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Files of a run kept for --artifacts, gathered in a staging directory as the run goes and stored at its end
+type artifacts struct {
+	store neobench.ArtifactStore
+	dir   string
+	log   *os.File
+}
+
+// Opens the store of --artifacts and a staging directory with the log of the run in it
+func newArtifacts(dest string) (*artifacts, error) {
+	store, err := neobench.NewArtifactStore(dest)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "neobench-artifacts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a staging directory for artifacts: %s", err)
+	}
+	log, err := os.Create(filepath.Join(dir, "neobench.log"))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create the artifact log: %s", err)
+	}
+	return &artifacts{store: store, dir: dir, log: log}, nil
+}
+
+// Where to log to, so the log ends up among the artifacts as well
+func (a *artifacts) logTo(w io.Writer) io.Writer {
+	return io.MultiWriter(w, a.log)
+}
+
+// Stores the report, histograms and log of result, and the files the run wrote, under a name unique to the run
+func (a *artifacts) storeResult(start time.Time, mode string, result neobench.Result) error {
+	defer os.RemoveAll(a.dir)
+	if err := neobench.WriteArtifacts(a.dir, mode, result, fPercentiles, fStatementLatencies); err != nil {
+		return fmt.Errorf("failed to write artifacts: %s", err)
+	}
+	for _, path := range runOutputFiles() {
+		if err := copyArtifact(path, filepath.Join(a.dir, "files", filepath.Base(path))); err != nil {
+			logger.Errorf("failed to keep %s among the artifacts: %s", path, err)
+		}
+	}
+	// Closed last so the log has everything up to here in it
+	if err := a.log.Close(); err != nil {
+		return err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	runName := start.UTC().Format("20060102T150405Z") + "-" + hostname
+	stored, err := neobench.PublishArtifacts(a.store, a.dir, runName)
+	if err != nil {
+		return err
+	}
+	logger.Infof("stored %d artifacts in %s/%s", stored, a.store, runName)
+	return nil
+}
+
+// Files the flags of this run had it write, that are worth keeping with its artifacts
+func runOutputFiles() []string {
+	var paths []string
+	for _, path := range []string{fTimeSeriesPath, fSaveResult, fSamples} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if fSlowLog > 0 {
+		paths = append(paths, fSlowLogFile)
+	}
+	for _, part := range strings.Split(fOutputFormat, ",") {
+		if eq := strings.Index(part, "="); eq >= 0 {
+			paths = append(paths, strings.TrimSpace(part[eq+1:]))
+		}
+	}
+	return paths
+}
+
+func copyArtifact(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"neobench/pkg/neobench"
+	"os"
+)
+
+// Entry point for `neobench compare`; returns the exit code: 0 if nothing regressed, 1 if something did
+// and 2 for invalid usage
+func runCompare(args []string) int {
+	flags := pflag.NewFlagSet("compare", pflag.ContinueOnError)
+	threshold := flags.Float64("threshold", 5, "flag percentiles that change by more than this many `percent`")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Compares two saved results, printing the change in throughput and latency for each script.
+
+Usage:
+  neobench compare [OPTION]... BASE NEW
+
+BASE and NEW are files written by -o json, or --results-sqlite files, optionally followed by #<run id>
+to pick a run other than the latest one, eg. results.db#3.
+
+Options:
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	base, err := neobench.LoadReport(flags.Arg(0))
+	if err != nil {
+		logger.Errorf("%s", err)
+		return 2
+	}
+	other, err := neobench.LoadReport(flags.Arg(1))
+	if err != nil {
+		logger.Errorf("%s", err)
+		return 2
+	}
+	regressed, err := neobench.WriteComparison(os.Stdout, flags.Arg(0), base, flags.Arg(1), other, *threshold)
+	if err != nil {
+		logger.Errorf("%s", err)
+		return 1
+	}
+	if regressed {
+		return 1
+	}
+	return 0
+}
+
+// Writes how each --target fared against the first one to stderr, like neobench compare does
+func writeTargetComparisons(targets []neobench.Target, results []neobench.Result) {
+	mode := "throughput"
+	if fLatencyMode {
+		mode = "latency"
+	}
+	percentiles := fPercentiles
+	if percentiles == nil {
+		percentiles = []float64{50, 95, 99, 99.9}
+	}
+	base := neobench.NewJsonReport(mode, results[0], percentiles, false)
+	for i := 1; i < len(results); i++ {
+		other := neobench.NewJsonReport(mode, results[i], percentiles, false)
+		fmt.Fprintln(os.Stderr)
+		if _, err := neobench.WriteComparison(os.Stderr, targets[0].Name, base, targets[i].Name, other, 5); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"io/ioutil"
+	"neobench/pkg/neobench"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Entry point for `neobench from-log`; returns the exit code: 0 if the scripts were written, 1 if they
+// couldn't be and 2 for invalid usage
+func runFromLog(args []string) int {
+	flags := pflag.NewFlagSet("from-log", pflag.ContinueOnError)
+	outDir := flags.String("out-dir", "replay", "`directory` to write the scripts to, created if missing")
+	top := flags.Int("top", 20, "write scripts for this many of the most frequent queries, 0 for all")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Writes a workload script for each distinct query in Neo4j query logs, to replay a production workload.
+
+Usage:
+  neobench from-log [OPTION]... QUERYLOG...
+
+QUERYLOG is a query.log, in the text or json format. Parameters are drawn from the range of numbers the log
+shows for them; other parameters are written into the query as their most common value. The command to run
+the scripts, weighted by how often each query ran, is printed once they are written.
+
+Options:
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() == 0 || *top < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	log := neobench.NewQueryLog()
+	for _, path := range flags.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			logger.Errorf("%s", err)
+			return 2
+		}
+		err = log.Read(f)
+		f.Close()
+		if err != nil {
+			logger.Errorf("failed to read %s: %s", path, err)
+			return 1
+		}
+	}
+	queries := log.Queries()
+	if len(queries) == 0 {
+		logger.Errorf("no completed queries found in %s", strings.Join(flags.Args(), ", "))
+		return 1
+	}
+	if *top > 0 && len(queries) > *top {
+		queries = queries[:*top]
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		logger.Errorf("%s", err)
+		return 1
+	}
+	command := strings.Builder{}
+	command.WriteString("neobench")
+	var covered int64
+	for i, query := range queries {
+		path := filepath.Join(*outDir, fmt.Sprintf("query-%03d.script", i+1))
+		if err := ioutil.WriteFile(path, []byte(query.Script(log.Total)), 0644); err != nil {
+			logger.Errorf("%s", err)
+			return 1
+		}
+		covered += query.Count
+		command.WriteString(fmt.Sprintf(" \\\n    -w %s@%d", path, query.Count))
+	}
+	logger.Infof("wrote %d scripts to %s, covering %d of the %d queries in the log", len(queries), *outDir, covered, log.Total)
+	fmt.Println(command.String())
+	return 0
+}
@@ -1,77 +1,621 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/spf13/pflag"
+	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"neobench/pkg/neobench"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
 var fInitMode bool
+var fCleanup bool
+var fCheck bool
+var fCheckInvariants bool
+var fInitWorkers int
+var fInitBatchSize int64
+var fNoInitSchema bool
+var fInitSchema string
+var fInitContent string
+var fInitSeed int64
+var fInitGenerator string
 var fLatencyMode bool
+var fConnectPerTransaction bool
 var fScale int64
 var fClients int
 var fRate float64
+var fRatePerClient float64
 var fAddress string
+var fDatabase string
+var fImpersonate string
+var fRoutingContext map[string]string
+var fForceWriteRouting bool
+var fBookmarks string
+var fConsume string
+var fSessionReuse string
 var fUser string
 var fPassword string
+var fAuthScheme string
+var fAuthRealm string
+var fAuthParams map[string]string
+var fKerberosTicket string
+var fBearerToken string
+var fAuthRefresh time.Duration
+var fMaxPoolSize int
+var fMaxConnectionLifetime time.Duration
+var fAcquisitionTimeout time.Duration
+var fConnectTimeout time.Duration
+var fSocketKeepalive bool
+var fMaxRetryTime time.Duration
 var fEncryptionMode string
+var fProtocol string
+var fTlsCa string
+var fTlsInsecure bool
+var fTlsKnownHosts string
+var fTlsVerify bool
+var fTlsCert string
+var fTlsKey string
 var fDuration int
 var fProgress int
 var fVariables map[string]string
+var fFunctions []string
 var fWorkloads []string
 var fOutputFormat string
+var fTags map[string]string
+var fHgrmDir string
+var fBaseline string
+var fFailIf string
+var fThresholds []string
+var fAgents []string
+var fSeed int64
+var fStartAt string
+var fAgentResult string
+var fSaveResult string
+var fArtifacts string
+var fTargets []string
+var fClientOffset int
+var fClientCount int
+var fThresholdsFile string
+var fTimeSeriesPath string
+var fInfluxUrl string
+var fResultsUrl string
+var fResultsUser string
+var fResultsPassword string
+var fResultsDb string
+var fResultsSqlite string
+var fStatsdAddr string
+var fStatsdPrefix string
+var fTransactionLog string
+var fSamples string
+var fSlowLog time.Duration
+var fHooks []string
+var fBefore []string
+var fAfter []string
+var fMeasureRecovery time.Duration
+var fSlowLogFile string
+var fOtlpEndpoint string
+var fOtlpSampleRate float64
+var fSamplingRate float64
+var fLogAggregate int
+var fPercentiles []float64
+var fStatementLatencies bool
+var fProfileSampleRate float64
+var fLatencyUnit string
+var fArrival string
+var fThinkTime string
+var fExecutors int
+var fSchedule string
+var fMaxBacklog int64
+var fNoDeadlockRetry bool
+var fFindMaxRate bool
+var fLatencyTarget string
+var fSearchSteps int
+var fSettle int
+var fRateSteps []float64
+var fStepDuration time.Duration
+var fCalibrate []int
+var fShutdownTimeout time.Duration
+var fLatencyPrecision int
+var fControlStdin bool
+var fCompletion string
+var fVerbose bool
+var fQuiet bool
+var fLogFormat string
+var fPprofAddr string
+var fPrometheusAddr string
+var fControlAddr string
+var fCpuProfile string
+var fMemProfile string
 
 func init() {
-	pflag.BoolVarP(&fInitMode, "init", "i", false, "run in initialization mode; if using built-in workloads this creates the initial dataset")
+	pflag.BoolVar(&fCheck, "check", false, "before running, check that the datasets of the built-in workloads given with -w are complete for the scale given with -s and have their indexes, failing if not; after -i when combined with it")
+	pflag.BoolVar(&fCheckInvariants, "check-invariants", false, "with builtin:tpcb-like, check after the run that balances and history changed consistently with the transactions that committed, failing if not")
+	pflag.BoolVar(&fCleanup, "cleanup", false, "remove the dataset, indexes and constraints that -i created for the built-in workloads given with -w, then exit")
+	pflag.BoolVarP(&fInitMode, "init", "i", false, "run in initialization mode; this creates the initial dataset of built-in workloads, and runs the \\init section of workload scripts")
+	pflag.IntVar(&fInitWorkers, "init-workers", neobench.DefaultInitOptions.Workers, "with -i, number of concurrent sessions creating the datasets of built-in workloads")
+	pflag.Int64Var(&fInitBatchSize, "init-batch-size", neobench.DefaultInitOptions.BatchSize, "with -i, number of nodes created per transaction by built-in workloads")
+	pflag.BoolVar(&fNoInitSchema, "no-init-schema", false, "with -i, don't create the constraints and indexes of built-in workloads, to benchmark without them; full-text and vector indexes are still created")
+	pflag.StringVar(&fInitSchema, "init-schema", "", "with -i, create the constraints and indexes in this `file` instead of those of built-in workloads; statements end with ;")
+	pflag.StringVar(&fInitContent, "init-content", "minimal", "with -i, `minimal` to give nodes of built-in workloads only the properties their queries need, or realistic for names, text and amounts sized like production data")
+	pflag.Int64Var(&fInitSeed, "init-seed", 1, "with --init-content realistic or --init-generator, seed of the generated content; the same seed and scale always produce the same values")
+	pflag.StringVar(&fInitGenerator, "init-generator", "", "with -i, also create the synthetic graph described in this `file`: node counts, relationship degrees and property generators, see README")
 	pflag.Int64VarP(&fScale, "scale", "s", 1, "sets the `scale` variable, impact depends on workload")
 	pflag.IntVarP(&fClients, "clients", "c", 1, "number of concurrent clients / sessions")
+	pflag.IntVar(&fClientOffset, "client-offset", 0, "number clients, $client_id in scripts, from this `number`, for runs that are one share of a benchmark across machines")
+	pflag.IntVar(&fClientCount, "client-count", 0, "`number` of clients across all machines, $client_count in scripts, for runs that are one share of a benchmark; defaults to --clients")
 	pflag.Float64VarP(&fRate, "rate", "r", 1, "in latency mode (see -l) this sets transactions per second, total across all clients")
-	pflag.StringVarP(&fAddress, "address", "a", "neo4j://localhost:7687", "address to connect to, eg. neo4j://mydb:7687")
+	pflag.Float64Var(&fRatePerClient, "rate-per-client", 0, "in latency mode (see -l) this sets transactions per second for each client, may be fractional, eg. 0.01; alternative to -r")
+	pflag.StringVarP(&fAddress, "address", "a", "neo4j://localhost:7687", "address to connect to, eg. neo4j://mydb:7687; give a comma-separated list to spread clients across servers and fail over between them, eg. bolt://core1:7687,core2:7687")
+	pflag.StringVar(&fDatabase, "database", "", "`database` to run against, default is the servers default database; alternative to the DBNAME argument")
+	pflag.StringVar(&fImpersonate, "impersonate", "", "run every transaction as this `user`, with their privileges rather than those of the user we authenticate as; needs Neo4j 4.4 or later")
+	pflag.StringToStringVar(&fRoutingContext, "routing-context", nil, "routing context to send with neo4j:// addresses, eg. --routing-context region=eu")
+	pflag.BoolVar(&fForceWriteRouting, "force-write-routing", false, "send read-only scripts to the cluster leader too, rather than to read replicas, for comparison")
+	pflag.StringVar(&fBookmarks, "bookmarks", "client", "which earlier writes transactions wait for the server to have applied: `client` for each client's own, none for eventual reads, or shared for those of every client")
+	pflag.StringVar(&fSessionReuse, "session-reuse", "lifetime", "how long each client keeps a session: its `lifetime`, one transaction, or a number of transactions; the report shows how many sessions were opened")
+	pflag.StringVar(&fConsume, "consume", "summary", "how much of the rows of each statement to read: `summary` only, the first row, count every row, or all, keeping every row until the statement is done")
 	pflag.StringVarP(&fUser, "user", "u", "neo4j", "username")
 	pflag.StringVarP(&fPassword, "password", "p", "neo4j", "password")
+	pflag.StringVar(&fAuthScheme, "auth-scheme", "basic", "auth `scheme`: basic, kerberos, none, or the name of a custom scheme, which is sent with -u, -p, --auth-realm and --auth-param")
+	pflag.StringVar(&fAuthRealm, "auth-realm", "", "realm to authenticate against, for basic and custom auth")
+	pflag.StringToStringVar(&fAuthParams, "auth-param", nil, "parameters for a custom auth scheme, eg. --auth-param tenant=acme")
+	pflag.StringVar(&fKerberosTicket, "kerberos-ticket", "", "base64 encoded kerberos `ticket`, or a file containing it; implies --auth-scheme kerberos")
+	pflag.StringVar(&fBearerToken, "bearer-token", "", "SSO bearer `token`, or a file containing it, default is $NEO4J_BEARER_TOKEN; implies --auth-scheme bearer")
+	pflag.IntVar(&fMaxPoolSize, "max-pool-size", 0, "maximum driver connections per server, -1 for no limit; default is the drivers 100, which caps concurrency when more clients share the driver")
+	pflag.DurationVar(&fMaxConnectionLifetime, "max-connection-lifetime", 0, "close pooled connections older than this rather than reusing them, negative to never do so; default is the drivers 1h")
+	pflag.DurationVar(&fAcquisitionTimeout, "acquisition-timeout", 0, "how long a transaction waits for a pooled connection before failing, negative to wait forever; default is the drivers 1m")
+	pflag.DurationVar(&fConnectTimeout, "connect-timeout", 5*time.Second, "timeout for opening a connection, 0 for none")
+	pflag.BoolVar(&fSocketKeepalive, "socket-keepalive", true, "enable TCP keepalive on connections, --socket-keepalive=false to turn it off")
+	pflag.DurationVar(&fMaxRetryTime, "max-retry-time", 30*time.Second, "how long the driver keeps retrying transactions that fail with transient errors, 0 to not retry")
+	pflag.DurationVar(&fAuthRefresh, "auth-refresh", 0, "re-read the --bearer-token or --kerberos-ticket file at this `interval`, and reconnect with the new token when it has changed; for runs that outlast the token")
+	pflag.StringVar(&fProtocol, "protocol", "bolt", "`protocol` to run transactions over, bolt or http; http uses the transactional endpoint at an http:// or https:// --address, default http://localhost:7474")
 	pflag.StringVarP(&fEncryptionMode, "encryption", "e", "auto", "whether to use encryption, `auto`, `true` or `false`")
+	pflag.StringVar(&fTlsCa, "tls-ca", "", "PEM `file` with the CA certificates to verify the server against, instead of the system CAs; implies -e true")
+	pflag.BoolVar(&fTlsInsecure, "tls-insecure", false, "accept any server certificate without verifying it; implies -e true")
+	pflag.StringVar(&fTlsKnownHosts, "tls-known-hosts", "", "trust the certificate a server presents on first connect and pin it in this `file`, refusing to connect if it later changes; implies -e true")
+	pflag.BoolVar(&fTlsVerify, "tls-verify", false, "verify the server certificate and hostname against the system CAs, or --tls-ca; by default bolt connections are encrypted without verifying the certificate; implies -e true")
+	pflag.StringVar(&fTlsCert, "tls-cert", "", "PEM `file` with the client certificate to present to servers that require mutual TLS, with --tls-key; implies -e true")
+	pflag.StringVar(&fTlsKey, "tls-key", "", "PEM `file` with the private key of --tls-cert; implies -e true")
 	pflag.IntVarP(&fDuration, "duration", "d", 60, "seconds to run")
 	pflag.IntVar(&fProgress, "progress", 10, "interval, in seconds, to report progress")
 	pflag.StringToStringVarP(&fVariables, "define", "D", nil, "defines variables for workload scripts and query parameters")
-	pflag.StringSliceVarP(&fWorkloads, "workload", "w", []string{"builtin:tpcb-like"}, "workload to run, either a builtin: one or a path to a workload script")
+	pflag.StringArrayVar(&fFunctions, "function", nil, "adds a function for workload scripts, answered by a command over stdin and stdout, eg. \"customer_id=./customer-ids.py\"; repeatable")
+	pflag.StringSliceVarP(&fWorkloads, "workload", "w", []string{"builtin:tpcb-like"}, "workload to run, either a builtin: one, a path to a workload script or exec:<command> to get units of work from a process, optionally followed by @weight and :rate=<tx/s>")
 	pflag.BoolVarP(&fLatencyMode, "latency", "l", false, "run in latency testing more rather than throughput mode")
-	pflag.StringVarP(&fOutputFormat, "output", "o", "auto", "output format, `auto`, `interactive` or `csv`")
+	pflag.BoolVarP(&fConnectPerTransaction, "connect", "C", false, "establish a new connection for each transaction, rather than one per client")
+	pflag.StringVarP(&fOutputFormat, "output", "o", "auto", "output `formats`, comma separated, each auto, interactive, dashboard, csv, json or html, optionally written to a file, eg. interactive,csv=results.csv")
+	pflag.Float64SliceVar(&fPercentiles, "percentiles", nil, "latency percentiles to report, eg. 50,90,99,99.9; defaults depend on output format")
+	pflag.StringVar(&fThinkTime, "think-time", "", "pause between transactions on each client outside of latency mode, to model interactive users; a `duration` like 500ms, exp:<mean> or uniform:<min>-<max>")
+	pflag.IntVar(&fExecutors, "executors", 0, "run the clients as virtual users, taking turns on this `number` of executors rather than a goroutine and session each, for tens of thousands of clients with --think-time or a low rate; 0 runs every client on its own")
+	pflag.StringVar(&fSchedule, "schedule", "", "run phases with varying clients and rate instead of -c, -d and -r, eg. \"0-5m: 10 clients @100tps; 5m-15m: 50 clients @500tps\"; either a `schedule` or a file containing one")
+	pflag.Int64Var(&fMaxBacklog, "max-backlog", 0, "in latency mode (see -l), the most transactions allowed to queue on each client when the database falls behind; older ones are dropped and reported, 0 means no limit")
+	pflag.BoolVar(&fNoDeadlockRetry, "no-deadlock-retry", false, "fail transactions that hit a deadlock at once, rather than letting the driver retry them")
+	pflag.BoolVar(&fFindMaxRate, "find-max-rate", false, "search for the highest rate that meets --latency-target, starting from -r and running each step for -d seconds")
+	pflag.StringVar(&fLatencyTarget, "latency-target", "", "with --find-max-rate, the latency objective each step must meet, eg. p99=20ms")
+	pflag.IntVar(&fSearchSteps, "search-steps", 12, "with --find-max-rate, the most rates to try")
+	pflag.IntVar(&fSettle, "settle", 10, "with --find-max-rate, `seconds` to run at each rate before measuring")
+	pflag.Float64SliceVar(&fRateSteps, "rate-steps", nil, "run at each of these rates in turn, eg. 100,200,400,800, and summarize each step; alternative to -r and -d")
+	pflag.DurationVar(&fStepDuration, "step-duration", time.Minute, "with --rate-steps or --calibrate, how long to run each step, eg. 2m")
+	pflag.IntSliceVar(&fCalibrate, "calibrate", nil, "rather than connecting to a database, run the workload against a no-op driver with each of these `numbers` of clients in turn, eg. 1,16,256, and summarize the time neobench itself spends on each transaction")
+	pflag.DurationVar(&fShutdownTimeout, "shutdown-timeout", 10*time.Second, "how long to wait for in-flight transactions once the run ends or is interrupted, before cancelling them and reporting without them")
+	pflag.StringVar(&fArrival, "arrival", "uniform", "in latency mode (see -l), how transaction start times are spaced, `uniform` or `poisson`")
+	pflag.StringVar(&fLatencyUnit, "latency-unit", "ms", "unit for latencies in reports, `ms` or `us`; json output is always in milliseconds")
+	pflag.IntVar(&fLatencyPrecision, "latency-precision", 3, "number of decimals for latencies in reports")
+	pflag.BoolVar(&fStatementLatencies, "statement-latencies", false, "report latencies and failures for each statement within each script")
+	pflag.Float64Var(&fProfileSampleRate, "profile-sample-rate", 0, "`fraction` of transactions to run with PROFILE, reporting db hits, rows and plans of each statement; eg. 0.01")
+	pflag.StringVar(&fTimeSeriesPath, "timeseries", "", "append a CSV row per script for each progress interval (see --progress) to this `file`")
+	pflag.StringVar(&fInfluxUrl, "influx-url", "", "push progress interval metrics as line protocol to this `url`, eg. http://localhost:8086/write?db=neobench")
+	pflag.StringVar(&fResultsUrl, "results-url", "", "store the run as a graph of Run, Workload, Interval, Histogram and Event nodes in the neo4j database at this `url`")
+	pflag.StringVar(&fResultsUser, "results-user", "neo4j", "username for --results-url")
+	pflag.StringVar(&fResultsPassword, "results-password", "neo4j", "password for --results-url")
+	pflag.StringVar(&fResultsDb, "results-db", "", "database to store results in with --results-url, default is the servers default database")
+	pflag.StringVar(&fResultsSqlite, "results-sqlite", "", "store the run, its progress intervals and per-statement metrics in this SQLite `file`, created if needed")
+	pflag.StringVar(&fStatsdAddr, "statsd-addr", "", "send per-transaction metrics to a StatsD / DogStatsD agent at this `address`, eg. localhost:8125")
+	pflag.StringVar(&fStatsdPrefix, "statsd-prefix", "neobench.", "prefix for metric names sent to StatsD")
+	pflag.StringVar(&fTransactionLog, "log", "", "write a line per transaction to `prefix`.<worker id>, one file per client")
+	pflag.IntVar(&fLogAggregate, "log-aggregate", 0, "with --log, write one summary line per worker every `seconds` rather than a line per transaction")
+	pflag.StringVar(&fSamples, "samples", "", "write the time, latency, script and outcome of each transaction to this tab separated `file` with a header, for analysis in pandas, duckdb and the like")
+	pflag.Float64Var(&fSamplingRate, "sampling-rate", 1, "fraction of transactions to write to the --log files and --samples, eg. 0.01 for 1%")
+	pflag.DurationVar(&fSlowLog, "slow-log", 0, "write each transaction slower than this `duration` to --slow-log-file, with its queries and parameters, eg. 100ms")
+	pflag.StringVar(&fSlowLogFile, "slow-log-file", "neobench-slow.log", "`file` for --slow-log, one json object per line")
+	pflag.StringVar(&fOtlpEndpoint, "otlp-endpoint", "", "send an OpenTelemetry span for each transaction to this collector `url` as OTLP/HTTP json, eg. http://localhost:4318")
+	pflag.Float64Var(&fOtlpSampleRate, "otlp-sample-rate", 1, "fraction of transactions to send spans for with --otlp-endpoint, 0-1")
+	pflag.DurationVar(&fMeasureRecovery, "measure-recovery", 0, "report how long the workload takes to recover from leader switches and failed servers, counting it as recovered once transactions have kept succeeding for this `duration`, eg. 2s")
+	pflag.StringArrayVar(&fBefore, "before", nil, "run this cypher `file`, or \"exec <command>\", once before the benchmark starts, eg. to reset counters or clear caches; repeatable")
+	pflag.StringArrayVar(&fAfter, "after", nil, "run this cypher `file`, or \"exec <command>\", once after the benchmark stops, eg. to snapshot statistics; repeatable")
+	pflag.StringArrayVar(&fHooks, "hook", nil, "run a command or call a url during the run and mark it in the results, eg. \"2m: exec ./kill-leader.sh\" or \"every 5m: http POST http://chaos/partition\"; repeatable")
+	pflag.StringVar(&fBaseline, "baseline", "", "compare the run against this saved result, a -o json or --results-sqlite `file`, and exit 1 if it regresses, see --fail-if")
+	pflag.StringArrayVar(&fThresholds, "threshold", nil, "pass/fail `condition` on the results, eg. p95<50ms, error_rate<0.1%, tps>1000 or p99{<script>}<100ms; when given, the thresholds decide the exit code; repeatable")
+	pflag.StringVar(&fThresholdsFile, "thresholds", "", "`file` with --threshold conditions, one per line")
+	pflag.StringVar(&fFailIf, "fail-if", "", "with --baseline, comma separated `conditions` that count as a regression, eg. p99>+10%,tps<-5%")
+	pflag.StringVar(&fHgrmDir, "hgrm-dir", "", "write HdrHistogram percentile distribution files (.hgrm), one per workload script, to this `directory`")
+	pflag.StringToStringVar(&fTags, "tag", nil, "tags to attach to the results, eg. --tag heap=8g, included in json output")
+	pflag.BoolVarP(&fVerbose, "verbose", "v", false, "log per-worker lifecycle and driver events, such as retries")
+	pflag.BoolVarP(&fQuiet, "quiet", "q", false, "only print errors and the final report")
+	pflag.StringVar(&fLogFormat, "log-format", "text", "format of log messages, `text` or `json`")
+	pflag.BoolVar(&fControlStdin, "control-stdin", false, "read pause/resume commands from stdin while running; SIGUSR1 toggles pause as well")
+	pflag.StringVar(&fCompletion, "completion", "", "print a shell completion script, `bash`, `zsh` or `fish`, and exit")
+	_ = pflag.CommandLine.MarkHidden("completion")
+	pflag.StringVar(&fPprofAddr, "pprof-addr", "", "serve net/http/pprof on this `address`, eg. localhost:6060, to profile neobench itself")
+	pflag.StringVar(&fPrometheusAddr, "prometheus-addr", "", "serve live metrics for Prometheus to scrape on this `address`, eg. :9100, at /metrics")
+	pflag.StringSliceVar(&fAgents, "agents", nil, "split the benchmark across these `agents`, comma separated host:port of machines running neobench agent, and report their merged results")
+	pflag.Int64Var(&fSeed, "seed", 0, "`seed` for the random choices of the workload; defaults to the current time")
+	pflag.StringArrayVar(&fTargets, "target", nil, "benchmark this `target`, NAME=ADDRESS[,database=DB][,clients=N], at the same time as the other targets and under the same generated load; repeat for each target")
+	pflag.StringVar(&fSaveResult, "save-result", "", "save the full result, histograms included, to this `file`, to combine with results of runs on other machines with neobench merge")
+	pflag.StringVar(&fArtifacts, "artifacts", "", "at the end of the run, store its json report, histograms, log and output files in this `destination`, a directory, s3://bucket/prefix or gs://bucket/prefix")
+	// Set by coordinators on the runs of their agents
+	pflag.StringVar(&fStartAt, "start-at", "", "start the benchmark at this RFC3339 `time`")
+	pflag.StringVar(&fAgentResult, "agent-result", "", "write the result for a coordinator to this `file`")
+	_ = pflag.CommandLine.MarkHidden("start-at")
+	_ = pflag.CommandLine.MarkHidden("agent-result")
+	pflag.StringVar(&fControlAddr, "control-addr", "", "serve an HTTP API on this `address`, eg. :9200, to read live stats, change the rate, pause, resume and stop the run")
+	pflag.StringVar(&fCpuProfile, "cpu-profile", "", "write a CPU profile of neobench itself to this `file`")
+	pflag.StringVar(&fMemProfile, "mem-profile", "", "write a heap profile of neobench itself to this `file` at the end of the run")
 }
 
+// Diagnostics go here, while results and progress go to the neobench.Output
+var logger *neobench.Logger
+
+// Counts connection pool events from the drivers log, for the pool section of the report
+var poolMetrics *neobench.PoolMetrics
+
+// Parsed from --bookmarks
+var bookmarkMode neobench.BookmarkMode
+
 func main() {
 	pflag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), `neobench is a benchmarking tool for Neo4j.
 
 Usage:
   neobench [OPTION]... [DBNAME]
+  neobench compare [OPTION]... BASE NEW
+  neobench from-log [OPTION]... QUERYLOG...
+  neobench agent [OPTION]...
+  neobench merge [OPTION]... RESULT...
 
 Options:
 `)
 		pflag.PrintDefaults()
 	}
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		logger, _ = neobench.NewLogger(neobench.LogNormal, "text", os.Stderr)
+		os.Exit(runCompare(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "from-log" {
+		logger, _ = neobench.NewLogger(neobench.LogNormal, "text", os.Stderr)
+		os.Exit(runFromLog(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		logger, _ = neobench.NewLogger(neobench.LogNormal, "text", os.Stderr)
+		os.Exit(runAgent(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		logger, _ = neobench.NewLogger(neobench.LogNormal, "text", os.Stderr)
+		os.Exit(runMerge(os.Args[2:]))
+	}
 	pflag.Parse()
 	if len(os.Args) == 1 {
 		pflag.Usage()
 		os.Exit(1)
 	}
 
+	logLevel := neobench.LogNormal
+	if fQuiet {
+		logLevel = neobench.LogQuiet
+	}
+	if fVerbose {
+		logLevel = neobench.LogVerbose
+	}
+	var err error
+	var logOut io.Writer = os.Stderr
+	var runArtifacts *artifacts
+	if fArtifacts != "" {
+		if runArtifacts, err = newArtifacts(fArtifacts); err != nil {
+			log.Fatal(err)
+		}
+		logOut = runArtifacts.logTo(logOut)
+	}
+	logger, err = neobench.NewLogger(logLevel, fLogFormat, logOut)
+	if err != nil {
+		log.Fatal(err)
+	}
+	poolMetrics = neobench.NewPoolMetrics(logger.DriverLogging())
+
+	if fCompletion != "" {
+		if err := neobench.WriteCompletion(os.Stdout, pflag.CommandLine, fCompletion); err != nil {
+			logger.Fatalf("%s", err)
+		}
+		os.Exit(0)
+	}
+
+	if pflag.CommandLine.Changed("rate-per-client") {
+		if pflag.CommandLine.Changed("rate") {
+			logger.Fatalf("-r / --rate and --rate-per-client are mutually exclusive, please specify only one")
+		}
+		fRate = fRatePerClient * float64(fClients)
+	}
+	var schedule []neobench.Phase
+	if fSchedule != "" {
+		for _, flag := range []string{"clients", "duration", "rate", "rate-per-client", "latency"} {
+			if pflag.CommandLine.Changed(flag) {
+				logger.Fatalf("--schedule can't be combined with --%s, clients, duration and rate are set per phase", flag)
+			}
+		}
+		schedule, err = neobench.ParseSchedule(fSchedule)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		// Phases with a rate run in latency mode; the transaction log needs room for the busiest phase
+		fLatencyMode = schedule[0].Rate > 0
+		for _, phase := range schedule {
+			if phase.Clients > fClients {
+				fClients = phase.Clients
+			}
+		}
+	}
+	if len(fRateSteps) > 0 {
+		for _, flag := range []string{"schedule", "duration", "rate", "rate-per-client", "find-max-rate"} {
+			if pflag.CommandLine.Changed(flag) {
+				logger.Fatalf("--rate-steps can't be combined with --%s", flag)
+			}
+		}
+		schedule, err = neobench.RateSteps(fRateSteps, fClients, fStepDuration)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		fLatencyMode = true
+	}
+	if len(fCalibrate) > 0 {
+		for _, flag := range []string{"schedule", "rate-steps", "find-max-rate", "clients", "duration", "rate", "rate-per-client", "latency",
+			"init", "check", "cleanup", "check-invariants", "connect", "agents", "target"} {
+			if pflag.CommandLine.Changed(flag) {
+				logger.Fatalf("--calibrate can't be combined with --%s", flag)
+			}
+		}
+		schedule, err = neobench.CalibrationSteps(fCalibrate, fStepDuration)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		fClients = 0
+		for _, n := range fCalibrate {
+			if n > fClients {
+				fClients = n
+			}
+		}
+	}
+	var latencyTarget neobench.LatencyTarget
+	if fFindMaxRate || fLatencyTarget != "" {
+		if !fFindMaxRate || fLatencyTarget == "" {
+			logger.Fatalf("--find-max-rate and --latency-target must be used together")
+		}
+		if schedule != nil {
+			logger.Fatalf("--find-max-rate can't be combined with --schedule")
+		}
+		latencyTarget, err = neobench.ParseLatencyTarget(fLatencyTarget)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		if fSearchSteps < 1 || fSettle < 0 {
+			logger.Fatalf("--search-steps must be at least 1 and --settle 0 or more")
+		}
+		fLatencyMode = true
+	}
+	arrival, err := neobench.ParseArrival(fArrival)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	bookmarkMode, err = neobench.ParseBookmarks(fBookmarks)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	consumeMode, err := neobench.ParseConsumeMode(fConsume)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	sessionReuse, err := neobench.ParseSessionReuse(fSessionReuse)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	if sessionReuse != 0 && (fConnectPerTransaction || (fExecutors > 0 && fExecutors < fClients) || bookmarkMode != neobench.BookmarksClient) {
+		logger.Fatalf("--session-reuse only applies when clients keep sessions, which they don't with -C, --executors or --bookmarks other than client; those already open a session per transaction")
+	}
+	if fLatencyMode && fRate <= 0 && schedule == nil {
+		logger.Fatalf("rate must be greater than 0, got %f", fRate)
+	}
+	var thinkTime neobench.ThinkTime
+	if fThinkTime != "" {
+		if fLatencyMode {
+			logger.Fatalf("--think-time can't be combined with -l / --latency, the rate already sets when transactions start")
+		}
+		thinkTime, err = neobench.ParseThinkTime(fThinkTime)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+	}
+	if fMaxBacklog < 0 {
+		logger.Fatalf("--max-backlog must be 0 or more, got %d", fMaxBacklog)
+	}
+	if fExecutors < 0 {
+		logger.Fatalf("--executors must be 0 or more, got %d", fExecutors)
+	}
+	if fSlowLog < 0 {
+		logger.Fatalf("--slow-log must be 0 or more, got %s", fSlowLog)
+	}
+	if fMeasureRecovery < 0 {
+		logger.Fatalf("--measure-recovery must be 0 or more, got %s", fMeasureRecovery)
+	}
+	var functions []*neobench.ExternalFunction
+	for _, spec := range fFunctions {
+		f, err := neobench.ParseExternalFunction(spec)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		functions = append(functions, f)
+	}
+	var hooks []neobench.Hook
+	for _, spec := range fHooks {
+		hook, err := neobench.ParseHook(spec)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		hooks = append(hooks, hook)
+	}
+	var before, after []neobench.StageHook
+	for _, spec := range fBefore {
+		hook, err := neobench.ParseStageHook(spec)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		before = append(before, hook)
+	}
+	for _, spec := range fAfter {
+		hook, err := neobench.ParseStageHook(spec)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		after = append(after, hook)
+	}
+	if fProfileSampleRate < 0 || fProfileSampleRate > 1 {
+		logger.Fatalf("--profile-sample-rate must be between 0 and 1, got %f", fProfileSampleRate)
+	}
+	if fInitWorkers < 1 || fInitBatchSize < 1 {
+		logger.Fatalf("--init-workers and --init-batch-size must be at least 1, got %d and %d", fInitWorkers, fInitBatchSize)
+	}
+	if fNoInitSchema && fInitSchema != "" {
+		logger.Fatalf("--no-init-schema and --init-schema can't be combined")
+	}
+	var initContent *neobench.ContentGenerator
+	switch fInitContent {
+	case "minimal":
+	case "realistic":
+		initContent = &neobench.ContentGenerator{Seed: fInitSeed}
+	default:
+		logger.Fatalf("--init-content must be minimal or realistic, got %s", fInitContent)
+	}
+	if len(fAgents) > 0 {
+		for _, flag := range []string{"schedule", "rate-steps", "find-max-rate", "control-addr"} {
+			if pflag.CommandLine.Changed(flag) {
+				logger.Fatalf("--agents can't be combined with --%s", flag)
+			}
+		}
+		if fClients < len(fAgents) {
+			logger.Fatalf("--agents needs at least one client per agent, got %d clients for %d agents", fClients, len(fAgents))
+		}
+	}
+	if fClientOffset < 0 || (fClientCount > 0 && fClientOffset+fClients > fClientCount) {
+		logger.Fatalf("--client-offset %d and --clients %d need to fit within --client-count %d", fClientOffset, fClients, fClientCount)
+	}
+	var targets []neobench.Target
+	for _, spec := range fTargets {
+		target, err := neobench.ParseTarget(spec)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		for _, other := range targets {
+			if other.Name == target.Name {
+				logger.Fatalf("--target %s is given twice", target.Name)
+			}
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) > 0 {
+		// Anything that follows the run as a whole, rather than each target, would mix up the targets
+		for _, flag := range []string{"address", "agents", "schedule", "rate-steps", "find-max-rate", "connect", "control-addr",
+			"prometheus-addr", "timeseries", "influx-url", "statsd-addr", "log", "slow-log", "otlp-endpoint",
+			"measure-recovery", "check-invariants"} {
+			if pflag.CommandLine.Changed(flag) {
+				logger.Fatalf("--target can't be combined with --%s", flag)
+			}
+		}
+	}
+	var startAt time.Time
+	if fStartAt != "" {
+		if startAt, err = time.Parse(time.RFC3339Nano, fStartAt); err != nil {
+			logger.Fatalf("invalid --start-at: %s", err)
+		}
+	}
+	if fCheckInvariants {
+		if fFindMaxRate {
+			logger.Fatalf("--check-invariants can't be combined with --find-max-rate, which only reports the transactions of its best run")
+		}
+		hasTPCB := false
+		for _, spec := range fWorkloads {
+			if path, _, _, err := parseWorkloadSpec(spec); err == nil && path == "builtin:tpcb-like" {
+				hasTPCB = true
+			}
+		}
+		if !hasTPCB {
+			logger.Fatalf("--check-invariants checks the dataset of builtin:tpcb-like, add it with -w")
+		}
+	}
+	if fMaxBacklog > 0 && !fLatencyMode {
+		logger.Fatalf("--max-backlog only applies in latency mode, see -l")
+	}
+
+	var baseline neobench.JsonReport
+	var regressionThresholds []neobench.RegressionThreshold
+	var thresholds []neobench.Threshold
+	if fThresholdsFile != "" {
+		thresholds, err = neobench.ReadThresholds(fThresholdsFile)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+	}
+	for _, spec := range fThresholds {
+		threshold, err := neobench.ParseThreshold(spec)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	if fBaseline != "" || fFailIf != "" {
+		if fBaseline == "" || fFailIf == "" {
+			logger.Fatalf("--baseline and --fail-if must be used together")
+		}
+		regressionThresholds, err = neobench.ParseRegressionThresholds(fFailIf)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		baseline, err = neobench.LoadReport(fBaseline)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		if err := neobench.ValidateRegressionBaseline(baseline, regressionThresholds); err != nil {
+			logger.Fatalf("%s", err)
+		}
+	}
+
 	seed := time.Now().Unix()
+	if fSeed != 0 {
+		seed = fSeed
+	}
 	runtime := time.Duration(fDuration) * time.Second
 	scenario := describeScenario()
 
-	out, err := neobench.NewOutput(fOutputFormat)
+	if err := neobench.ValidatePercentiles(fPercentiles); err != nil {
+		logger.Fatalf("%s", err)
+	}
+	latencyFormat, err := neobench.NewLatencyFormat(fLatencyUnit, fLatencyPrecision)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatalf("%s", err)
+	}
+	out, err := neobench.NewOutputs(fOutputFormat, neobench.OutputOptions{
+		Percentiles:        fPercentiles,
+		StatementLatencies: fStatementLatencies,
+		Latency:            latencyFormat,
+		Quiet:              fQuiet,
+	})
+	if err != nil {
+		logger.Fatalf("%s", err)
 	}
 
 	var encryptionMode neobench.EncryptionMode
@@ -83,17 +627,150 @@ Options:
 	case "false", "no", "n", "0":
 		encryptionMode = neobench.EncryptionOff
 	default:
-		log.Fatalf("Invalid encryption mode '%s', needs to be one of 'auto', 'true' or 'false'", fEncryptionMode)
+		logger.Fatalf("Invalid encryption mode '%s', needs to be one of 'auto', 'true' or 'false'", fEncryptionMode)
+	}
+	tlsOptions := neobench.TlsOptions{CaFile: fTlsCa, Insecure: fTlsInsecure, KnownHostsFile: fTlsKnownHosts,
+		Verify: fTlsVerify, CertFile: fTlsCert, KeyFile: fTlsKey}
+	if tlsOptions != (neobench.TlsOptions{}) {
+		if encryptionMode == neobench.EncryptionOff {
+			logger.Fatalf("the --tls-* flags need encryption, but -e is %s", fEncryptionMode)
+		}
+		encryptionMode = neobench.EncryptionOn
 	}
 
-	dbName := ""
+	dbName := fDatabase
 	if pflag.NArg() > 0 {
+		if fDatabase != "" && fDatabase != pflag.Arg(0) {
+			logger.Fatalf("--database %s and the DBNAME argument %s disagree, please give only one", fDatabase, pflag.Arg(0))
+		}
 		dbName = pflag.Arg(0)
 	}
 
-	driver, err := neobench.NewDriver(fAddress, fUser, fPassword, encryptionMode)
+	if fProtocol == "http" && !pflag.CommandLine.Changed("address") {
+		fAddress = "http://localhost:7474"
+	}
+	for i := range targets {
+		if targets[i].DatabaseName == "" {
+			targets[i].DatabaseName = dbName
+		}
+	}
+	if len(targets) > 0 {
+		// The first target is connected to like -a, the others below
+		fAddress, dbName = targets[0].Address, targets[0].DatabaseName
+	}
+	addresses, err := neobench.ParseAddresses(fAddress)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatalf("%s", err)
+	}
+	if fBearerToken == "" {
+		fBearerToken = os.Getenv("NEO4J_BEARER_TOKEN")
+	}
+	if !pflag.CommandLine.Changed("auth-scheme") {
+		switch {
+		case fKerberosTicket != "":
+			fAuthScheme = "kerberos"
+		case pflag.CommandLine.Changed("bearer-token"):
+			fAuthScheme = "bearer"
+		}
+	}
+	authOptions := neobench.AuthOptions{
+		Scheme:      fAuthScheme,
+		User:        fUser,
+		Password:    fPassword,
+		Realm:       fAuthRealm,
+		Ticket:      fKerberosTicket,
+		BearerToken: fBearerToken,
+		Parameters:  fAuthParams,
+	}
+	conn := neobench.ConnectionOptions{
+		MaxPoolSize:        fMaxPoolSize,
+		MaxLifetime:        fMaxConnectionLifetime,
+		AcquisitionTimeout: fAcquisitionTimeout,
+		NoKeepalive:        !fSocketKeepalive,
+	}
+	// Zero means the driver default in ConnectionOptions, but none at all on the command line
+	if pflag.CommandLine.Changed("connect-timeout") {
+		conn.ConnectTimeout = fConnectTimeout
+		if fConnectTimeout == 0 {
+			conn.ConnectTimeout = -1
+		}
+	}
+	if pflag.CommandLine.Changed("max-retry-time") {
+		conn.MaxRetryTime = fMaxRetryTime
+		if fMaxRetryTime == 0 {
+			conn.MaxRetryTime = -1
+		}
+	}
+	if poolSize := conn.MaxPoolSize; !fConnectPerTransaction && len(fCalibrate) == 0 && poolSize >= 0 {
+		if poolSize == 0 {
+			poolSize = neobench.DefaultMaxPoolSize
+		}
+		if fClients > poolSize {
+			logger.Warningf("%d clients share a pool of %d connections per server, so clients will queue for connections; consider --max-pool-size", fClients, poolSize)
+		}
+	}
+	if fProtocol != "bolt" && fProtocol != "http" {
+		logger.Fatalf("Invalid protocol '%s', needs to be one of 'bolt' or 'http'", fProtocol)
+	}
+	if fProtocol == "http" && (len(fRoutingContext) > 0 || fAuthRefresh > 0) {
+		logger.Fatalf("--routing-context and --auth-refresh only apply to --protocol bolt")
+	}
+	if fProtocol == "http" && fImpersonate != "" {
+		logger.Fatalf("--impersonate only applies to --protocol bolt")
+	}
+	if fProtocol == "http" && fProfileSampleRate > 0 {
+		logger.Fatalf("--profile-sample-rate only applies to --protocol bolt, the http api doesn't return profiled plans")
+	}
+	if fAuthRefresh > 0 && fAuthScheme != "kerberos" && fAuthScheme != "bearer" {
+		logger.Fatalf("--auth-refresh only applies to kerberos and bearer auth, not %s", fAuthScheme)
+	}
+	if fProtocol == "bolt" && strings.HasPrefix(addresses[0], "neo4j") && len(addresses) > 1 {
+		// The routing driver fails over between cluster members itself, it just needs to know all of them
+		conn.Routers = addresses
+		addresses = addresses[:1]
+	}
+	var drivers []neo4j.Driver
+	var factories []neobench.DriverFactory
+	if len(fCalibrate) > 0 {
+		// Nothing leaves the process, so all the time a transaction takes is neobench's own
+		drivers, factories = []neo4j.Driver{neobench.NewNoopDriver()}, []neobench.DriverFactory{nil}
+		addresses = nil
+	}
+	for _, address := range addresses {
+		driver, factory, err := connect(address, authOptions, encryptionMode, tlsOptions, conn)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		drivers = append(drivers, driver)
+		factories = append(factories, factory)
+	}
+	driver, dial := drivers[0], factories[0]
+	if len(drivers) > 1 {
+		driver, dial = neobench.NewFailoverDriver(drivers), neobench.RoundRobinDriverFactory(factories)
+	}
+	if !fConnectPerTransaction {
+		dial = nil
+	}
+	// What setup, cleanup and hooks run against: the targets, or the one database of the run
+	databases := []neobench.Target{{DatabaseName: dbName, Driver: driver}}
+	if len(targets) > 0 {
+		targets[0].Driver = driver
+		for i := 1; i < len(targets); i++ {
+			if targets[i].Driver, _, err = connect(targets[i].Address, authOptions, encryptionMode, tlsOptions, conn); err != nil {
+				logger.Fatalf("target %s: %s", targets[i].Name, err)
+			}
+		}
+		databases = targets
+	}
+	// Kept with the results, so they still say what they were measured against once the servers are long gone
+	var serverInfo *neobench.ServerInfo
+	if len(fCalibrate) == 0 && len(targets) == 0 {
+		info, err := neobench.ReadServerInfo(driver, dbName)
+		if err != nil {
+			logger.Warningf("%s, results won't record the server version", err)
+		} else {
+			serverInfo = &info
+		}
 	}
 
 	variables := make(map[string]interface{})
@@ -109,67 +786,522 @@ Options:
 			variables[k] = floatVal
 			continue
 		}
-		log.Fatalf("-D and --define values must be integers or floats, failing to parse '%s': %s", v, err)
+		logger.Fatalf("-D and --define values must be integers or floats, failing to parse '%s': %s", v, err)
 	}
 
-	scripts := make([]neobench.Script, 0)
-	for _, path := range fWorkloads {
-		parts := strings.Split(path, "@")
-		weight := 1
-		if len(parts) > 1 {
-			weight, err = strconv.Atoi(parts[1])
-			if err != nil {
-				log.Fatalf("Failed to parse weight; value after @ symbol for workload weight must be an integer: %s", path)
+	for _, f := range functions {
+		if err := f.Start(); err != nil {
+			logger.Fatalf("%s", err)
+		}
+	}
+
+	if fCleanup {
+		if fInitMode {
+			logger.Fatalf("--cleanup and -i can't be combined; run the cleanup, then -i")
+		}
+		for _, db := range databases {
+			if err = cleanupWorkload(fWorkloads, db.DatabaseName, db.Driver, out); err != nil {
+				logger.Fatalf("%s", err)
 			}
-			path = parts[0]
 		}
-		script, err := createScript(driver, dbName, variables, path, uint(weight))
+		os.Exit(0)
+	}
+
+	scripts := make([]neobench.Script, 0)
+	var providers []*neobench.ExternalWorkload
+	for _, spec := range fWorkloads {
+		path, weight, rate, err := parseWorkloadSpec(spec)
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatalf("%s", err)
+		}
+		var specScripts []neobench.Script
+		if strings.HasPrefix(path, "exec:") {
+			provider := neobench.NewExternalWorkload(strings.TrimPrefix(path, "exec:"))
+			if err = provider.Start(); err != nil {
+				logger.Fatalf("%s", err)
+			}
+			providers = append(providers, provider)
+			specScripts = []neobench.Script{provider.Script(path, weight)}
+		} else if specScripts, err = createScripts(driver, dbName, variables, path, weight); err != nil {
+			logger.Fatalf("%s", err)
+		}
+		// A rate covers the whole spec, so builtins made of several scripts split it by weight
+		totalWeight := uint(0)
+		for _, script := range specScripts {
+			totalWeight += script.Weight
+		}
+		for _, script := range specScripts {
+			script.Rate = rate
+			if len(specScripts) > 1 && totalWeight > 0 {
+				script.Rate = rate * float64(script.Weight) / float64(totalWeight)
+			}
+			scripts = append(scripts, script)
 		}
-		scripts = append(scripts, script)
 	}
 
 	wrk := neobench.Workload{
-		Variables: variables,
-		Scripts:   neobench.NewScripts(scripts...),
-		Rand:      rand.New(rand.NewSource(seed)),
+		Variables:    variables,
+		Scripts:      neobench.NewScripts(scripts...),
+		Clients:      fClients,
+		ClientOffset: fClientOffset,
+		ClientCount:  fClientCount,
+		Rand:         rand.New(rand.NewSource(seed)),
 	}
 
 	if fInitMode {
-		err = initWorkload(fWorkloads, dbName, fScale, driver, out)
+		initOpts := neobench.InitOptions{Workers: fInitWorkers, BatchSize: fInitBatchSize, SkipSchema: fNoInitSchema || fInitSchema != "", Content: initContent}
+		for _, db := range databases {
+			// Every target gets the same dataset
+			err = initWorkload(fWorkloads, db.DatabaseName, fScale, variables, initOpts, fInitSchema, fInitGenerator, fInitSeed, rand.New(rand.NewSource(seed)), db.Driver, out)
+			if err != nil {
+				logger.Fatalf("%s", err)
+			}
+		}
+	}
+	if fCheck {
+		checkOpts := neobench.InitOptions{SkipSchema: fNoInitSchema || fInitSchema != ""}
+		for _, db := range databases {
+			if err = checkWorkload(fWorkloads, db.DatabaseName, fScale, checkOpts, db.Driver, out); err != nil {
+				logger.Fatalf("%s", err)
+			}
+		}
+	}
+
+	stopProfiling, err := neobench.StartProfiling(fPprofAddr, fCpuProfile, fMemProfile, logger)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+
+	start := time.Now()
+	sinks := make([]neobench.IntervalSink, 0)
+	if fTimeSeriesPath != "" {
+		timeSeries, err := neobench.NewTimeSeriesFile(fTimeSeriesPath, start)
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatalf("%s", err)
+		}
+		sinks = append(sinks, timeSeries)
+	}
+	if fInfluxUrl != "" {
+		sinks = append(sinks, neobench.NewInfluxSink(fInfluxUrl, intervalTags(scenario)))
+	}
+	// The result store also gets the final result, so it's closed separately from the other sinks
+	var resultStore *neobench.ResultStore
+	if fResultsUrl != "" {
+		resultsDriver, err := neobench.NewDriver(fResultsUrl, neo4j.BasicAuth(fResultsUser, fResultsPassword, ""), neobench.EncryptionAuto, neobench.TlsOptions{}, neobench.ConnectionOptions{}, logger.DriverLogging())
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		resultStore, err = neobench.NewResultStore(resultsDriver, fResultsDb, start, seed, scenario, fTags)
+		if err != nil {
+			logger.Fatalf("%s", err)
 		}
+		logger.Infof("storing results in %s as run %s", fResultsUrl, resultStore.RunId)
+	}
+	var sqliteStore *neobench.SqliteStore
+	if fResultsSqlite != "" {
+		mode := "throughput"
+		if fLatencyMode {
+			mode = "latency"
+		}
+		sqliteStore, err = neobench.NewSqliteStore(fResultsSqlite, mode, start, seed, dbName, strings.TrimSpace(scenario), fTags)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		logger.Infof("storing results in %s as run %d", fResultsSqlite, sqliteStore.RunId)
 	}
 
-	progressInterval := time.Duration(fProgress) * time.Second
+	observers := make([]neobench.TransactionObserver, 0)
+	var statsd *neobench.StatsdEmitter
+	if fStatsdAddr != "" {
+		statsd, err = neobench.NewStatsdEmitter(fStatsdAddr, fStatsdPrefix, intervalTags(scenario))
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		observers = append(observers, statsd)
+	}
+	var txLog *neobench.TransactionLog
+	if fTransactionLog != "" {
+		txLog, err = neobench.NewTransactionLog(fTransactionLog, fClients, fSamplingRate, time.Duration(fLogAggregate)*time.Second, seed)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		observers = append(observers, txLog)
+	}
+	var samples *neobench.SampleFile
+	if fSamples != "" {
+		samples, err = neobench.NewSampleFile(fSamples, fClients, fSamplingRate, seed)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		observers = append(observers, samples)
+	}
+	var slowLog *neobench.SlowLog
+	if fSlowLog > 0 {
+		slowLog, err = neobench.NewSlowLog(fSlowLogFile, fSlowLog)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		observers = append(observers, slowLog)
+	}
+	var tracer *neobench.OtlpTracer
+	if fOtlpEndpoint != "" {
+		tracer, err = neobench.NewOtlpTracer(fOtlpEndpoint, dbName, fOtlpSampleRate, intervalTags(scenario))
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		observers = append(observers, tracer)
+	}
+	var recovery *neobench.RecoveryTracker
+	if fMeasureRecovery > 0 {
+		recovery = neobench.NewRecoveryTracker(fMeasureRecovery)
+		poolMetrics.OnRoutingChange(recovery.RoutingChanged)
+		observers = append(observers, recovery)
+	}
 
+	intervalSinks := sinks
+	if resultStore != nil {
+		intervalSinks = append(intervalSinks, resultStore)
+	}
+	if sqliteStore != nil {
+		intervalSinks = append(intervalSinks, sqliteStore)
+	}
+	var intervalRecorder *neobench.IntervalRecorder
+	if fSaveResult != "" || fAgentResult != "" || runArtifacts != nil {
+		intervalRecorder = &neobench.IntervalRecorder{}
+		intervalSinks = append(intervalSinks, intervalRecorder)
+	}
+	var totalsBefore neobench.TPCBTotals
+	if fCheckInvariants {
+		if totalsBefore, err = neobench.ReadTPCBTotals(dbName, driver); err != nil {
+			logger.Fatalf("failed to read tpcb-like totals before the run: %s", err)
+		}
+	}
+	var hookRunner *neobench.HookRunner
+	if len(hooks) > 0 {
+		eventSinks := []neobench.EventSink{hookLogger{}}
+		for _, sink := range intervalSinks {
+			if eventSink, ok := sink.(neobench.EventSink); ok {
+				eventSinks = append(eventSinks, eventSink)
+			}
+		}
+		hookRunner = neobench.StartHooks(hooks, start, eventSinks, func(err error) { logger.Errorf("%s", err) })
+	}
+	cfg := neobench.BenchmarkConfig{
+		Driver:            driver,
+		Dial:              dial,
+		DatabaseName:      dbName,
+		Scenario:          scenario,
+		Workload:          wrk,
+		Clients:           fClients,
+		Executors:         fExecutors,
+		Duration:          runtime,
+		Arrival:           arrival,
+		ThinkTime:         thinkTime,
+		Schedule:          schedule,
+		MaxBacklog:        fMaxBacklog,
+		FailOnDeadlock:    fNoDeadlockRetry,
+		ForceWriteRouting: fForceWriteRouting,
+		Bookmarks:         bookmarkMode,
+		Consume:           consumeMode,
+		SessionReuse:      sessionReuse,
+		ProfileSampleRate: fProfileSampleRate,
+		ShutdownTimeout:   fShutdownTimeout,
+		PrometheusAddr:    fPrometheusAddr,
+		ProgressInterval:  time.Duration(fProgress) * time.Second,
+		OnProgress:        out.ReportWorkloadProgress,
+		Sinks:             intervalSinks,
+		Observers:         observers,
+		PoolMetrics:       poolMetrics,
+		Logger:            logger,
+	}
 	if fLatencyMode {
-		result, err := runBenchmark(driver, fAddress, dbName, scenario, out, wrk, runtime, fLatencyMode, fClients, fRate, progressInterval)
-		if err != nil {
-			out.Errorf(err.Error())
-			os.Exit(1)
+		cfg.Rate = fRate
+	}
+	if fFindMaxRate {
+		cfg.LatencyTarget, cfg.SearchSteps, cfg.Settle = &latencyTarget, fSearchSteps, time.Duration(fSettle)*time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopCh, stop := neobench.SetupSignalHandler()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	cfg.Pause = neobench.NewPauseControl(func(paused bool) {
+		step := "resumed"
+		if paused {
+			step = "paused"
+		}
+		out.ReportProgress(neobench.ProgressReport{Section: "workload", Step: step, Completeness: 0})
+	})
+	neobench.SetupPauseSignalHandler(cfg.Pause, ctx.Done())
+	if fControlStdin {
+		go neobench.WatchControlInput(os.Stdin, cfg.Pause)
+	}
+	if fControlAddr != "" {
+		cfg.Control = neobench.NewRunControl(cfg.Pause, cancel)
+		go func() {
+			if err := http.ListenAndServe(fControlAddr, neobench.NewControlHandler(cfg.Control, fPercentiles)); err != nil {
+				logger.Errorf("control endpoint failed: %s", err)
+			}
+		}()
+	}
+	hookContext := neobench.ScriptContext{Stderr: os.Stderr, Vars: variables, Rand: rand.New(rand.NewSource(seed))}
+	for _, hook := range before {
+		for _, db := range databases {
+			if err := hook.Run(hookContext, db.DatabaseName, db.Driver); err != nil {
+				logger.Fatalf("%s", err)
+			}
+		}
+	}
+	if len(targets) > 0 {
+		described := make([]string, 0, len(targets))
+		for _, target := range targets {
+			described = append(described, fmt.Sprintf("%s (%s on %s)", target.Name, target.DatabaseName, target.Address))
+		}
+		out.BenchmarkStart(dbName, strings.Join(described, ", "))
+	} else if len(fCalibrate) > 0 {
+		out.BenchmarkStart(dbName, "a no-op driver")
+	} else {
+		out.BenchmarkStart(dbName, fAddress)
+	}
+	if !startAt.IsZero() {
+		// Agents start together, however long each took to get ready
+		select {
+		case <-time.After(time.Until(startAt)):
+		case <-ctx.Done():
+		}
+	}
+	var result neobench.Result
+	var targetResults []neobench.Result
+	switch {
+	case len(fAgents) > 0:
+		result, err = runOnAgents(ctx, cfg, seed)
+	case len(targets) > 0:
+		targetResults, err = neobench.RunTargets(ctx, cfg, seed, targets)
+		result = neobench.CombineTargets(dbName, scenario, targets, targetResults)
+	default:
+		result, err = neobench.Run(ctx, cfg)
+	}
+	stop()
+	for _, hook := range after {
+		for _, db := range databases {
+			if err := hook.Run(hookContext, db.DatabaseName, db.Driver); err != nil {
+				logger.Errorf("%s", err)
+			}
+		}
+	}
+	stopProfiling()
+	var events []neobench.RunEvent
+	if hookRunner != nil {
+		events = hookRunner.Stop()
+	}
+	// Closed here rather than deferred, as we exit through os.Exit, which skips deferred calls
+	if statsd != nil {
+		if err := statsd.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if txLog != nil {
+		if err := txLog.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if samples != nil {
+		if err := samples.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if slowLog != nil {
+		if err := slowLog.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	for _, provider := range providers {
+		if err := provider.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if tracer != nil {
+		if err := tracer.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	for _, f := range functions {
+		if err := f.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	result.Seed = seed
+	result.Start = start
+	result.End = time.Now()
+	result.Tags = fTags
+	result.Server = serverInfo
+	result.Events = events
+	if intervalRecorder != nil {
+		result.Intervals = intervalRecorder.Samples()
+	}
+	if recovery != nil {
+		result.Recovery = recovery.Finish()
+	}
+	var thresholdsErr error
+	if len(thresholds) > 0 {
+		result.Thresholds, thresholdsErr = neobench.CheckThresholds(result, thresholds)
+	}
+	for _, path := range []string{fAgentResult, fSaveResult} {
+		if path == "" {
+			continue
+		}
+		if err := saveResult(path, result); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if resultStore != nil {
+		if err := resultStore.WriteResult(result); err != nil {
+			logger.Errorf("%s", err)
+		}
+		if err := resultStore.Close(); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if sqliteStore != nil {
+		if err := sqliteStore.WriteResult(result); err != nil {
+			logger.Errorf("%s", err)
+		}
+		if err := sqliteStore.Close(); err != nil {
+			logger.Errorf("%s", err)
 		}
+	}
+	if fHgrmDir != "" {
+		if err := neobench.WriteHgrmFiles(fHgrmDir, result); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if fLatencyMode {
 		out.ReportLatency(result)
-		if result.TotalFailed() == 0 {
-			os.Exit(0)
-		} else {
+	} else {
+		out.ReportThroughput(result)
+	}
+	if err := out.Close(); err != nil {
+		logger.Errorf("%s", err)
+	}
+	if runArtifacts != nil {
+		mode := "throughput"
+		if fLatencyMode {
+			mode = "latency"
+		}
+		if err := runArtifacts.storeResult(start, mode, result); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if len(fRateSteps) > 0 && !fQuiet {
+		if err := neobench.WriteStepSummary(os.Stderr, schedule, result, latencyFormat); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if len(fCalibrate) > 0 && !fQuiet {
+		if err := neobench.WriteCalibrationSummary(os.Stderr, schedule, result, latencyFormat); err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+	if len(targetResults) > 1 && !fQuiet {
+		writeTargetComparisons(targets, targetResults)
+	}
+	if regressionThresholds != nil {
+		mode := "throughput"
+		if fLatencyMode {
+			mode = "latency"
+		}
+		current := neobench.NewJsonReport(mode, result, neobench.RegressionPercentiles(regressionThresholds), false)
+		violations, err := neobench.CheckRegressions(baseline, current, regressionThresholds)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		for _, violation := range violations {
+			logger.Errorf("regression against %s: %s", fBaseline, violation)
+		}
+		if len(violations) > 0 {
 			os.Exit(1)
 		}
-	} else {
-		result, err := runBenchmark(driver, fAddress, dbName, scenario, out, wrk, runtime, fLatencyMode, fClients, fRate, progressInterval)
+	}
+	if fCheckInvariants {
+		totalsAfter, err := neobench.ReadTPCBTotals(dbName, driver)
 		if err != nil {
-			out.Errorf(err.Error())
+			logger.Fatalf("failed to read tpcb-like totals after the run: %s", err)
+		}
+		committed, failed := neobench.TPCBTransactions(result)
+		violations := neobench.TPCBViolations(totalsBefore, totalsAfter, committed, failed)
+		for _, violation := range violations {
+			logger.Errorf("tpcb-like invariant violated: %s", violation)
+		}
+		if len(violations) > 0 {
 			os.Exit(1)
 		}
-		out.ReportThroughput(result)
-		if result.TotalFailed() == 0 {
-			os.Exit(0)
-		} else {
+	}
+	if dataErrors := result.TotalDataErrors(); dataErrors > 0 {
+		logger.Errorf("%d transactions returned results their scripts didn't expect, see the data errors in the report", dataErrors)
+	}
+	if len(thresholds) > 0 {
+		// Thresholds say how many failures are acceptable, so they decide rather than any failure failing the run
+		if thresholdsErr != nil {
+			logger.Errorf("%s", thresholdsErr)
 			os.Exit(1)
 		}
+		failedThresholds := 0
+		for _, t := range result.Thresholds {
+			if !t.Passed {
+				failedThresholds++
+			}
+		}
+		if failedThresholds > 0 {
+			logger.Errorf("%d of %d thresholds not met", failedThresholds, len(thresholds))
+			os.Exit(1)
+		}
+		if _, client := result.FailuresByOrigin(); client > 0 {
+			logger.Errorf("%d transactions failed without an answer from the database, the results may not reflect its performance", client)
+			os.Exit(neobench.ExitCodeClientFailures)
+		}
+		os.Exit(0)
+	}
+	if result.TotalFailed() == 0 && result.TotalDataErrors() == 0 {
+		os.Exit(0)
+	}
+	if _, client := result.FailuresByOrigin(); client > 0 {
+		logger.Errorf("%d transactions failed without an answer from the database, the results may not reflect its performance", client)
+		os.Exit(neobench.ExitCodeClientFailures)
+	}
+	os.Exit(1)
+}
+
+// Logs hooks as they fire, so they show up alongside the progress of the run
+type hookLogger struct{}
+
+func (hookLogger) WriteEvent(event neobench.RunEvent) error {
+	if event.Err != "" {
+		logger.Warningf("hook %s failed: %s", event.Name, event.Err)
+	} else {
+		logger.Infof("hook %s fired", event.Name)
+	}
+	return nil
+}
+
+// Tags to attach to metrics sent to external systems while the benchmark runs
+func intervalTags(scenario string) map[string]string {
+	tags := map[string]string{
+		"scenario": strings.TrimSpace(scenario),
+		"clients":  strconv.Itoa(fClients),
+	}
+	for k, v := range fTags {
+		tags[k] = v
 	}
+	return tags
 }
 
 func describeScenario() string {
@@ -177,12 +1309,123 @@ func describeScenario() string {
 	for _, path := range fWorkloads {
 		out.WriteString(fmt.Sprintf(" -w %s", path))
 	}
-	out.WriteString(fmt.Sprintf(" -c %d", fClients))
+	if fSchedule != "" {
+		out.WriteString(fmt.Sprintf(" --schedule %q", fSchedule))
+	} else if len(fCalibrate) > 0 {
+		counts := make([]string, 0, len(fCalibrate))
+		for _, n := range fCalibrate {
+			counts = append(counts, strconv.Itoa(n))
+		}
+		out.WriteString(fmt.Sprintf(" --calibrate %s --step-duration %s", strings.Join(counts, ","), fStepDuration))
+	} else {
+		out.WriteString(fmt.Sprintf(" -c %d", fClients))
+	}
 	out.WriteString(fmt.Sprintf(" -s %d", fScale))
-	out.WriteString(fmt.Sprintf(" -d %d", fDuration))
+	if fSchedule == "" && len(fRateSteps) == 0 && len(fCalibrate) == 0 {
+		out.WriteString(fmt.Sprintf(" -d %d", fDuration))
+	}
 	out.WriteString(fmt.Sprintf(" -e %s", fEncryptionMode))
+	if fTlsInsecure {
+		out.WriteString(" --tls-insecure")
+	}
+	if fTlsVerify {
+		out.WriteString(" --tls-verify")
+	}
 	if fLatencyMode {
-		out.WriteString(fmt.Sprintf(" -l -r %.3f", fRate))
+		switch {
+		case fSchedule != "":
+			// Rates are part of the schedule
+		case len(fRateSteps) > 0:
+			steps := make([]string, 0, len(fRateSteps))
+			for _, rate := range fRateSteps {
+				steps = append(steps, strconv.FormatFloat(rate, 'f', -1, 64))
+			}
+			out.WriteString(fmt.Sprintf(" -l --rate-steps %s --step-duration %s", strings.Join(steps, ","), fStepDuration))
+		case fFindMaxRate:
+			out.WriteString(fmt.Sprintf(" -l --find-max-rate --latency-target %s -r %.3f", fLatencyTarget, fRate))
+		case pflag.CommandLine.Changed("rate-per-client"):
+			out.WriteString(fmt.Sprintf(" -l --rate-per-client %g", fRatePerClient))
+		default:
+			out.WriteString(fmt.Sprintf(" -l -r %.3f", fRate))
+		}
+		if fArrival != "uniform" {
+			out.WriteString(fmt.Sprintf(" --arrival %s", fArrival))
+		}
+		if fMaxBacklog > 0 {
+			out.WriteString(fmt.Sprintf(" --max-backlog %d", fMaxBacklog))
+		}
+	}
+	if fThinkTime != "" {
+		out.WriteString(fmt.Sprintf(" --think-time %s", fThinkTime))
+	}
+	if fExecutors > 0 {
+		out.WriteString(fmt.Sprintf(" --executors %d", fExecutors))
+	}
+	if fNoDeadlockRetry {
+		out.WriteString(" --no-deadlock-retry")
+	}
+	if fForceWriteRouting {
+		out.WriteString(" --force-write-routing")
+	}
+	if fBookmarks != "client" {
+		out.WriteString(fmt.Sprintf(" --bookmarks %s", fBookmarks))
+	}
+	if fConsume != "summary" {
+		out.WriteString(fmt.Sprintf(" --consume %s", fConsume))
+	}
+	if fSessionReuse != "lifetime" {
+		out.WriteString(fmt.Sprintf(" --session-reuse %s", fSessionReuse))
+	}
+	if fProfileSampleRate > 0 {
+		out.WriteString(fmt.Sprintf(" --profile-sample-rate %g", fProfileSampleRate))
+	}
+	if fMeasureRecovery > 0 {
+		out.WriteString(fmt.Sprintf(" --measure-recovery %s", fMeasureRecovery))
+	}
+	for _, function := range fFunctions {
+		out.WriteString(fmt.Sprintf(" --function %q", function))
+	}
+	if len(fAgents) > 0 {
+		out.WriteString(fmt.Sprintf(" --agents %s", strings.Join(fAgents, ",")))
+	}
+	for _, target := range fTargets {
+		out.WriteString(fmt.Sprintf(" --target %s", target))
+	}
+	for _, hook := range fBefore {
+		out.WriteString(fmt.Sprintf(" --before %q", hook))
+	}
+	for _, hook := range fAfter {
+		out.WriteString(fmt.Sprintf(" --after %q", hook))
+	}
+	for _, hook := range fHooks {
+		out.WriteString(fmt.Sprintf(" --hook %q", hook))
+	}
+	if fProtocol != "bolt" {
+		out.WriteString(fmt.Sprintf(" --protocol %s", fProtocol))
+	}
+	if fImpersonate != "" {
+		out.WriteString(fmt.Sprintf(" --impersonate %s", fImpersonate))
+	}
+	if fConnectPerTransaction {
+		out.WriteString(" -C")
+	}
+	if fMaxPoolSize != 0 {
+		out.WriteString(fmt.Sprintf(" --max-pool-size %d", fMaxPoolSize))
+	}
+	if fMaxConnectionLifetime != 0 {
+		out.WriteString(fmt.Sprintf(" --max-connection-lifetime %s", fMaxConnectionLifetime))
+	}
+	if fAcquisitionTimeout != 0 {
+		out.WriteString(fmt.Sprintf(" --acquisition-timeout %s", fAcquisitionTimeout))
+	}
+	if pflag.CommandLine.Changed("connect-timeout") {
+		out.WriteString(fmt.Sprintf(" --connect-timeout %s", fConnectTimeout))
+	}
+	if !fSocketKeepalive {
+		out.WriteString(" --socket-keepalive=false")
+	}
+	if pflag.CommandLine.Changed("max-retry-time") {
+		out.WriteString(fmt.Sprintf(" --max-retry-time %s", fMaxRetryTime))
 	}
 	if fInitMode {
 		out.WriteString(" -i")
@@ -190,130 +1433,301 @@ func describeScenario() string {
 	return out.String()
 }
 
-func runBenchmark(driver neo4j.Driver, url, databaseName, scenario string, out neobench.Output, wrk neobench.Workload,
-	runtime time.Duration, latencyMode bool, numClients int, rate float64, progressInterval time.Duration) (neobench.Result, error) {
-	stopCh, stop := neobench.SetupSignalHandler()
-	defer stop()
-
-	ratePerWorkerDuration := time.Duration(0)
-	if latencyMode {
-		ratePerWorkerDuration = neobench.TotalRatePerSecondToDurationPerClient(numClients, rate)
+// Creates the driver for one server address, and a factory for more drivers to it for connect-per-transaction runs
+func connect(address string, authOptions neobench.AuthOptions, encryptionMode neobench.EncryptionMode,
+	tlsOptions neobench.TlsOptions, conn neobench.ConnectionOptions) (neo4j.Driver, neobench.DriverFactory, error) {
+	if fProtocol == "http" {
+		dial := func() (neo4j.Driver, error) {
+			return neobench.NewHttpDriver(address, authOptions, tlsOptions, conn)
+		}
+		driver, err := dial()
+		return driver, dial, err
 	}
 
-	out.BenchmarkStart(databaseName, url)
-
-	resultChan := make(chan neobench.WorkerResult, numClients)
-	resultRecorders := make([]*neobench.ResultRecorder, 0)
-	var wg sync.WaitGroup
-	for i := 0; i < numClients; i++ {
-		wg.Add(1)
-		recorder := neobench.NewResultRecorder(int64(i))
-		resultRecorders = append(resultRecorders, recorder)
-		worker := neobench.NewWorker(driver, int64(i))
-		workerId := i
-		clientWork := wrk.NewClient()
-		go func() {
-			defer wg.Done()
-			result := worker.RunBenchmark(clientWork, databaseName, ratePerWorkerDuration, 0, stopCh, recorder)
-			resultChan <- result
-			if result.Error != nil {
-				out.Errorf("worker %d crashed: %s", workerId, result.Error)
-				stop()
-			}
-		}()
+	address, err := neobench.WithRoutingContext(address, fRoutingContext)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	deadline := time.Now().Add(runtime)
-	awaitCompletion(stopCh, deadline, out, databaseName, scenario, progressInterval, resultRecorders)
-	stop()
-	wg.Wait()
-
-	return collectResults(databaseName, scenario, out, numClients, resultChan)
+	if fAuthRefresh > 0 {
+		refreshing, err := neobench.NewRefreshingDriver(address, authOptions, fAuthRefresh, encryptionMode, tlsOptions,
+			conn, poolMetrics, func(err error) {
+				logger.Warningf("failed to refresh auth token, keeping the current one: %s", err)
+			})
+		if err != nil {
+			return nil, nil, err
+		}
+		if fImpersonate != "" {
+			return neobench.NewImpersonatingDriver(refreshing, fImpersonate), neobench.ImpersonatingDriverFactory(refreshing.Dial, fImpersonate), nil
+		}
+		return refreshing, refreshing.Dial, nil
+	}
+	auth, err := authOptions.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	dial, err := neobench.NewDriverFactory(address, auth, encryptionMode, tlsOptions, conn, poolMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fImpersonate != "" {
+		dial = neobench.ImpersonatingDriverFactory(dial, fImpersonate)
+	}
+	driver, err := dial()
+	return driver, dial, err
 }
 
-func collectResults(databaseName, scenario string, out neobench.Output, concurrency int, resultChan chan neobench.WorkerResult) (neobench.Result, error) {
-	// Collect results
-	results := make([]neobench.WorkerResult, 0, concurrency)
-	for i := 0; i < concurrency; i++ {
-		results = append(results, <-resultChan)
+// Runs the init of each builtin in the -w specs, and the \init section of each custom script, once. If schemaPath
+// is set, the constraints and indexes in it are created first, and opts should skip those of the builtins. If
+// generatorPath is set, the graph it describes is created next, from seed.
+func initWorkload(specs []string, dbName string, scale int64, vars map[string]interface{}, opts neobench.InitOptions, schemaPath, generatorPath string, seed int64, random *rand.Rand, driver neo4j.Driver, out neobench.Output) error {
+	if schemaPath != "" {
+		schemaContent, err := ioutil.ReadFile(schemaPath)
+		if err != nil {
+			return fmt.Errorf("failed to read schema file at %s: %s", schemaPath, err)
+		}
+		err = neobench.RunSchemaFile(schemaPath, string(schemaContent), neobench.ScriptContext{
+			Stderr: os.Stderr,
+			Vars:   vars,
+			Rand:   random,
+		}, dbName, driver, out)
+		if err != nil {
+			return err
+		}
 	}
-
-	total := neobench.NewResult(databaseName, scenario)
-	// Process results into one histogram and check for errors
-	for _, res := range results {
-		if res.Error != nil {
-			out.Errorf("Worker failed: %v", res.Error)
+	if generatorPath != "" {
+		generatorContent, err := ioutil.ReadFile(generatorPath)
+		if err != nil {
+			return fmt.Errorf("failed to read generator file at %s: %s", generatorPath, err)
+		}
+		generator, err := neobench.ParseGenerator(generatorPath, string(generatorContent))
+		if err != nil {
+			return err
+		}
+		if err = generator.Init(vars, seed, dbName, driver, opts, out); err != nil {
+			return err
+		}
+	}
+	done := make(map[string]bool)
+	for _, spec := range specs {
+		path, _, _, err := parseWorkloadSpec(spec)
+		if err != nil {
+			return err
+		}
+		if done[path] {
+			continue
+		}
+		done[path] = true
+		if init, ok := neobench.BuiltinInits[path]; ok {
+			// createScripts has already filled in defaults, like builtin:supernode's hotspots
+			if err = init(scale, vars, dbName, driver, opts, out); err != nil {
+				return err
+			}
+			continue
+		}
+		// Workloads from a process have no \init section
+		if strings.HasPrefix(path, "builtin:") || strings.HasPrefix(path, "exec:") {
 			continue
 		}
-		total.Add(res)
+		scriptContent, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read workload file at %s: %s", path, err)
+		}
+		script, err := neobench.Parse(path, string(scriptContent), 1)
+		if err != nil {
+			return err
+		}
+		err = script.RunInit(neobench.ScriptContext{
+			Stderr: os.Stderr,
+			Vars:   vars,
+			Rand:   random,
+		}, dbName, driver, opts, out)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return total, nil
+// Runs the check of each builtin in the -w specs once; custom scripts can't be checked, so they are skipped
+func checkWorkload(specs []string, dbName string, scale int64, opts neobench.InitOptions, driver neo4j.Driver, out neobench.Output) error {
+	done := make(map[string]bool)
+	for _, spec := range specs {
+		path, _, _, err := parseWorkloadSpec(spec)
+		if err != nil {
+			return err
+		}
+		if done[path] {
+			continue
+		}
+		done[path] = true
+		check, ok := neobench.BuiltinChecks[path]
+		if !ok {
+			logger.Warningf("--check only checks the data of built-in workloads, skipping %s; custom scripts can check theirs with \\verify", path)
+			continue
+		}
+		if err = check(scale, dbName, driver, opts, out); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func initWorkload(paths []string, dbName string, scale int64, driver neo4j.Driver, out neobench.Output) error {
-	for _, path := range paths {
-		if path == "builtin:tpcb-like" {
-			return neobench.InitTPCBLike(scale, dbName, driver, out)
+// Runs the cleanup of each builtin in the -w specs once; custom scripts have no cleanup, so they are skipped
+func cleanupWorkload(specs []string, dbName string, driver neo4j.Driver, out neobench.Output) error {
+	done := make(map[string]bool)
+	for _, spec := range specs {
+		path, _, _, err := parseWorkloadSpec(spec)
+		if err != nil {
+			return err
+		}
+		if done[path] {
+			continue
+		}
+		done[path] = true
+		cleanup, ok := neobench.BuiltinCleanups[path]
+		if !ok {
+			logger.Warningf("--cleanup only removes the data of built-in workloads, skipping %s", path)
+			continue
 		}
-		if path == "builtin:match-only" {
-			return neobench.InitTPCBLike(scale, dbName, driver, out)
+		if err = cleanup(dbName, driver, out); err != nil {
+			return fmt.Errorf("failed to clean up %s: %s", path, err)
 		}
 	}
 	return nil
 }
 
-func createScript(driver neo4j.Driver, dbName string, vars map[string]interface{}, path string, weight uint) (neobench.Script, error) {
-	if path == "builtin:tpcb-like" {
-		return neobench.Parse("builtin:tpcp-like", neobench.TPCBLike, weight)
+// Parses a -w value: path[@weight[:rate=<tx/s>]]
+func parseWorkloadSpec(spec string) (path string, weight uint, rate float64, err error) {
+	parts := strings.Split(spec, "@")
+	path, weight = parts[0], 1
+	if len(parts) == 1 {
+		return path, weight, 0, nil
+	}
+	options := strings.Split(parts[1], ":")
+	w, err := strconv.Atoi(options[0])
+	if err != nil || w < 0 {
+		return "", 0, 0, fmt.Errorf("failed to parse weight; value after @ symbol for workload weight must be an integer: %s", spec)
 	}
+	weight = uint(w)
+	for _, option := range options[1:] {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 || kv[0] != "rate" {
+			return "", 0, 0, fmt.Errorf("unknown workload option '%s' in %s, expected eg. %s@1:rate=50", option, spec, path)
+		}
+		rate, err = strconv.ParseFloat(kv[1], 64)
+		if err != nil || rate <= 0 {
+			return "", 0, 0, fmt.Errorf("workload rate must be a positive number of transactions per second: %s", spec)
+		}
+	}
+	return path, weight, rate, nil
+}
 
-	if path == "builtin:match-only" {
-		return neobench.Parse("builtin:match-only", neobench.MatchOnly, weight)
+// Most workloads are one script, but some builtins, like builtin:ldbc-snb, are a mix of several
+func createScripts(driver neo4j.Driver, dbName string, vars map[string]interface{}, path string, weight uint) ([]neobench.Script, error) {
+	var script neobench.Script
+	var err error
+	switch path {
+	case "builtin:tpcb-like":
+		// Builtins are known to parse, and tpcb-like writes, so they skip preflight
+		script, err = neobench.Parse(neobench.TPCBLikeScriptName, neobench.TPCBLike, weight)
+		return []neobench.Script{script}, err
+	case "builtin:match-only":
+		script, err = neobench.Parse("builtin:match-only", neobench.MatchOnly, weight)
+		script.Readonly = true
+		return []neobench.Script{script}, err
+	case "builtin:fulltext":
+		script, err = neobench.Parse("builtin:fulltext", neobench.Fulltext, weight)
+		script.Readonly = true
+		return []neobench.Script{script}, err
+	case "builtin:vector":
+		script, err = neobench.Parse("builtin:vector", neobench.Vector, weight)
+		script.Readonly = true
+		return []neobench.Script{script}, err
+	case "builtin:insert-heavy":
+		if _, ok := vars["batch"]; !ok {
+			vars["batch"] = int64(neobench.DefaultInsertBatch)
+		}
+		if batch, ok := vars["batch"].(int64); !ok || batch < 1 {
+			return nil, fmt.Errorf("builtin:insert-heavy needs -D batch to be a positive integer, got %v", vars["batch"])
+		}
+		script, err = neobench.Parse("builtin:insert-heavy", neobench.InsertHeavy, weight)
+		return []neobench.Script{script}, err
+	case "builtin:supernode":
+		if err = defaultFraction(vars, "hot", neobench.DefaultSupernodeHot); err != nil {
+			return nil, fmt.Errorf("builtin:supernode needs -D hot to be the fraction of traffic to supernodes: %s", err)
+		}
+		if _, ok := vars["hotspots"]; !ok {
+			vars["hotspots"] = int64(neobench.DefaultSupernodeHotspots)
+		}
+		if hotspots, ok := vars["hotspots"].(int64); !ok || hotspots < 1 || hotspots >= 100000*vars["scale"].(int64) {
+			return nil, fmt.Errorf("builtin:supernode needs -D hotspots to be a positive integer below 100000 * scale, got %v", vars["hotspots"])
+		}
+		script, err = neobench.Parse("builtin:supernode", neobench.Supernode, weight)
+		return []neobench.Script{script}, err
+	case "builtin:merge":
+		if err = defaultFraction(vars, "collision", neobench.DefaultMergeCollision); err != nil {
+			return nil, fmt.Errorf("builtin:merge needs -D collision to be the probability of upserting a shared key: %s", err)
+		}
+		script, err = neobench.Parse("builtin:merge", neobench.Merge, weight)
+		return []neobench.Script{script}, err
+	case "builtin:ldbc-snb":
+		return parseBuiltinQueries(path, neobench.LdbcSnb, weight)
+	case "builtin:gds":
+		return parseBuiltinQueries(path, neobench.Gds, weight)
+	case "builtin:traversal":
+		return parseBuiltinQueries(path, neobench.Traversal, weight)
+	case "builtin:aggregation":
+		return parseBuiltinQueries(path, neobench.Aggregation, weight)
+	case "builtin:pagination":
+		if _, ok := vars["pageSize"]; !ok {
+			vars["pageSize"] = int64(neobench.DefaultPageSize)
+		}
+		if pageSize, ok := vars["pageSize"].(int64); !ok || pageSize < 1 || pageSize > 1000*vars["scale"].(int64) {
+			return nil, fmt.Errorf("builtin:pagination needs -D pageSize to be a positive integer no larger than 1000 * scale, got %v", vars["pageSize"])
+		}
+		return parseBuiltinQueries(path, neobench.Pagination, weight)
 	}
 
 	scriptContent, err := ioutil.ReadFile(path)
 	if err != nil {
-		return neobench.Script{}, fmt.Errorf("failed to read workload file at %s: %s", path, err)
+		return nil, fmt.Errorf("failed to read workload file at %s: %s", path, err)
 	}
 
-	script, err := neobench.Parse(path, string(scriptContent), weight)
+	script, err = neobench.Parse(path, string(scriptContent), weight)
 	if err != nil {
-		return neobench.Script{}, err
+		return nil, err
 	}
 
 	readonly, err := neobench.WorkloadPreflight(driver, dbName, script, vars)
 	script.Readonly = readonly
-	return script, err
+	return []neobench.Script{script}, err
 }
 
-func awaitCompletion(stopCh chan struct{}, deadline time.Time, out neobench.Output, databaseName, scenario string, progressInterval time.Duration, recorders []*neobench.ResultRecorder) {
-	nextProgressReport := time.Now().Add(progressInterval)
-	originalDelta := deadline.Sub(time.Now()).Seconds()
-	for {
-		select {
-		case <-stopCh:
-			return
-		default:
-		}
-
-		now := time.Now()
-		delta := deadline.Sub(now)
-		if delta < 2*time.Second {
-			time.Sleep(delta)
-			break
-		}
-
-		if now.After(nextProgressReport) {
-			nextProgressReport = nextProgressReport.Add(progressInterval)
-			checkpoint := neobench.NewResult(databaseName, scenario)
-			for _, r := range recorders {
-				checkpoint.Add(r.ProgressReport(time.Now()))
-			}
+// Sets vars[name] to def unless -D gave it, and checks it is a number between 0 and 1
+func defaultFraction(vars map[string]interface{}, name string, def float64) error {
+	if _, ok := vars[name]; !ok {
+		vars[name] = def
+	}
+	value, ok := vars[name].(float64)
+	if intValue, isInt := vars[name].(int64); isInt {
+		value, ok = float64(intValue), true
+	}
+	if !ok || value < 0 || value > 1 {
+		return fmt.Errorf("expected a number between 0 and 1, got %v", vars[name])
+	}
+	return nil
+}
 
-			completeness := 1 - delta.Seconds()/originalDelta
-			out.ReportWorkloadProgress(completeness, checkpoint)
+func parseBuiltinQueries(name string, queries []neobench.BuiltinQuery, weight uint) ([]neobench.Script, error) {
+	scripts := make([]neobench.Script, 0, len(queries))
+	for _, query := range queries {
+		script, err := neobench.Parse(name+"/"+query.Name, query.Script, weight*query.Weight)
+		if err != nil {
+			return nil, err
 		}
-		time.Sleep(time.Millisecond * 100)
+		script.Readonly = query.Readonly
+		script.Metric = query.Metric
+		scripts = append(scripts, script)
 	}
+	return scripts, nil
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"neobench/pkg/neobench"
+	"os"
+	"time"
+)
+
+// Entry point for `neobench merge`; returns the exit code: 0 if the results were merged, 1 if they couldn't be
+// and 2 for invalid usage
+func runMerge(args []string) int {
+	flags := pflag.NewFlagSet("merge", pflag.ContinueOnError)
+	output := flags.StringP("output", "o", "auto", "output `formats`, as for a run, eg. interactive,json=merged.json")
+	percentiles := flags.Float64Slice("percentiles", nil, "latency percentiles to report, eg. 50,90,99,99.9; defaults depend on output format")
+	latencyMode := flags.BoolP("latency", "l", false, "report the results as from --latency runs")
+	minOverlap := flags.Float64("min-overlap", 90, "refuse to merge unless all runs were going for this many `percent` of the time from the first start to the last end")
+	save := flags.String("save-result", "", "save the merged result to this `file`, to merge again later")
+	alignStarts := flags.Bool("align-starts", false, "take the runs to have started at the same time, the start of the first RESULT, for machines with clocks that are off")
+	timeSeriesPath := flags.String("timeseries", "", "write the merged progress intervals of the runs, as --timeseries does, to this `file`")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Combines the results of runs that went on at the same time on several machines into one report, adding up
+their rates and counts and merging their latency histograms.
+
+Usage:
+  neobench merge [OPTION]... RESULT...
+
+RESULT is a file written by --save-result. The runs should have been started together and run for as long,
+since their rates are added as if they had all run for the whole time; the merged report covers the time
+they were all going. Progress intervals are lined up by the clocks of the machines they ran on, unless
+--align-starts is given, so those should be synchronized, eg. with NTP, for the merged time series to be sharp.
+
+Options:
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() < 2 || *minOverlap < 0 || *minOverlap > 100 {
+		flags.Usage()
+		return 2
+	}
+	if err := neobench.ValidatePercentiles(*percentiles); err != nil {
+		logger.Errorf("%s", err)
+		return 2
+	}
+	latencyFormat, err := neobench.NewLatencyFormat("ms", 3)
+	if err != nil {
+		logger.Errorf("%s", err)
+		return 2
+	}
+
+	results := make([]neobench.Result, 0, flags.NArg())
+	for _, path := range flags.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			logger.Errorf("%s", err)
+			return 2
+		}
+		result, err := neobench.DecodeResult(f)
+		f.Close()
+		if err != nil {
+			logger.Errorf("failed to read %s: %s", path, err)
+			return 1
+		}
+		results = append(results, result)
+	}
+	if *alignStarts {
+		for i := range results {
+			results[i].ShiftClock(results[0].Start.Sub(results[i].Start))
+		}
+	}
+	merged, window, err := neobench.MergeResults(results, *minOverlap/100)
+	if err != nil {
+		logger.Errorf("%s", err)
+		return 1
+	}
+	logger.Infof("merged %d results over the %s they were all running, %.0f%% of the %s from the first start to the last end",
+		len(results), window.End.Sub(window.Start).Truncate(time.Second), window.Overlap()*100, window.Last.Sub(window.First).Truncate(time.Second))
+
+	if *timeSeriesPath != "" {
+		if err := writeMergedTimeSeries(*timeSeriesPath, merged, window.First); err != nil {
+			logger.Errorf("%s", err)
+			return 1
+		}
+	}
+	if *save != "" {
+		if err := saveResult(*save, merged); err != nil {
+			logger.Errorf("%s", err)
+			return 1
+		}
+	}
+	out, err := neobench.NewOutputs(*output, neobench.OutputOptions{
+		Percentiles: *percentiles,
+		Latency:     latencyFormat,
+	})
+	if err != nil {
+		logger.Errorf("%s", err)
+		return 2
+	}
+	if *latencyMode {
+		out.ReportLatency(merged)
+	} else {
+		out.ReportThroughput(merged)
+	}
+	if err := out.Close(); err != nil {
+		logger.Errorf("%s", err)
+		return 1
+	}
+	return 0
+}
+
+func writeMergedTimeSeries(path string, merged neobench.Result, runStart time.Time) error {
+	if len(merged.Intervals) == 0 {
+		return fmt.Errorf("the results have no progress intervals to write a time series from")
+	}
+	timeSeries, err := neobench.NewTimeSeriesFile(path, runStart)
+	if err != nil {
+		return err
+	}
+	for _, interval := range merged.Intervals {
+		if err := timeSeries.WriteInterval(interval.Start, interval.End, interval.Result(merged.DatabaseName, merged.Scenario)); err != nil {
+			_ = timeSeries.Close()
+			return fmt.Errorf("failed to write time series: %s", err)
+		}
+	}
+	return timeSeries.Close()
+}
@@ -0,0 +1,331 @@
+package neobench
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Somewhere the files of a run outlive the machine it ran on, eg. a container that goes away with its pod;
+// see NewArtifactStore
+type ArtifactStore interface {
+	// Stores content under name, a slash separated path within the store
+	Put(name string, content []byte) error
+	String() string
+}
+
+// Opens the store at dest: a directory, eg. one a sidecar syncs elsewhere, s3://bucket/prefix or
+// gs://bucket/prefix. S3 credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN,
+// the region from AWS_REGION and S3-compatible servers are reached with AWS_ENDPOINT_URL. GCS takes an access
+// token from GOOGLE_OAUTH_ACCESS_TOKEN, or the metadata server when running on Google Cloud.
+func NewArtifactStore(dest string) (ArtifactStore, error) {
+	switch {
+	case strings.HasPrefix(dest, "s3://"):
+		bucket, prefix := splitBucket(strings.TrimPrefix(dest, "s3://"))
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid artifact destination %s, expected s3://bucket/prefix", dest)
+		}
+		store := &s3Store{
+			bucket:       bucket,
+			prefix:       prefix,
+			region:       os.Getenv("AWS_REGION"),
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			endpoint:     os.Getenv("AWS_ENDPOINT_URL"),
+			client:       &http.Client{Timeout: time.Minute},
+		}
+		if store.region == "" {
+			store.region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+		if store.region == "" {
+			store.region = "us-east-1"
+		}
+		if store.accessKey == "" || store.secretKey == "" {
+			return nil, fmt.Errorf("storing artifacts in %s needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", dest)
+		}
+		return store, nil
+	case strings.HasPrefix(dest, "gs://"):
+		bucket, prefix := splitBucket(strings.TrimPrefix(dest, "gs://"))
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid artifact destination %s, expected gs://bucket/prefix", dest)
+		}
+		return &gcsStore{
+			bucket:   bucket,
+			prefix:   prefix,
+			endpoint: "https://storage.googleapis.com",
+			token:    os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+			client:   &http.Client{Timeout: time.Minute},
+		}, nil
+	case strings.Contains(dest, "://"):
+		return nil, fmt.Errorf("unsupported artifact destination %s, expected a directory, s3:// or gs://", dest)
+	}
+	return dirStore(dest), nil
+}
+
+func splitBucket(s string) (bucket, prefix string) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) == 2 {
+		prefix = strings.Trim(parts[1], "/")
+	}
+	return parts[0], prefix
+}
+
+// Writes the report files of result to dir for publishing: the json report, the full result for neobench merge,
+// and a latency histogram per script
+func WriteArtifacts(dir, mode string, result Result, percentiles []float64, statementLatencies bool) error {
+	report, err := json.MarshalIndent(NewJsonReport(mode, result, orPercentiles(percentiles, jsonPercentiles), statementLatencies), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "result.json"), report, 0644); err != nil {
+		return err
+	}
+	full := bytes.Buffer{}
+	if err := EncodeResult(&full, result); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "result.neobench"), full.Bytes(), 0644); err != nil {
+		return err
+	}
+	return WriteHgrmFiles(filepath.Join(dir, "hgrm"), result)
+}
+
+// Puts every file under dir in store, named by their path below dir and under runName; returns how many it stored
+func PublishArtifacts(store ArtifactStore, dir, runName string) (int, error) {
+	var names []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			names = append(names, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(names)
+	for i, p := range names {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return i, err
+		}
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return i, err
+		}
+		if err := store.Put(path.Join(runName, filepath.ToSlash(rel)), content); err != nil {
+			return i, fmt.Errorf("failed to store %s in %s: %s", rel, store, err)
+		}
+	}
+	return len(names), nil
+}
+
+type dirStore string
+
+func (d dirStore) Put(name string, content []byte) error {
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	// Written aside and renamed into place, so a sync never picks up half a file
+	tmp := p + ".partial"
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (d dirStore) String() string {
+	return string(d)
+}
+
+type s3Store struct {
+	bucket, prefix, region string
+	accessKey, secretKey   string
+	sessionToken           string
+	// Empty for AWS itself, otherwise the base url of an S3-compatible server, addressed with path-style urls
+	endpoint string
+	client   *http.Client
+	now      func() time.Time
+}
+
+func (s *s3Store) Put(name string, content []byte) error {
+	key := path.Join(s.prefix, name)
+	var u string
+	if s.endpoint == "" {
+		u = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, s3Escape(key))
+	} else {
+		u = fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.endpoint, "/"), s.bucket, s3Escape(key))
+	}
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	s.sign(req, sha256Hex(content), now().UTC())
+	return doArtifactRequest(s.client, req)
+}
+
+// Signs req with AWS signature version 4, see
+// https://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html
+func (s *s3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	signature := hex.EncodeToString(hmacSHA256(sigV4Key(s.secretKey, date, s.region, "s3"), stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func (s *s3Store) String() string {
+	return "s3://" + path.Join(s.bucket, s.prefix)
+}
+
+func sigV4Key(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Escapes each segment of an object key as S3 signatures expect, leaving the slashes between them
+func s3Escape(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		escaped := strings.Builder{}
+		for _, b := range []byte(segment) {
+			if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+				escaped.WriteByte(b)
+			} else {
+				escaped.WriteString(fmt.Sprintf("%%%02X", b))
+			}
+		}
+		segments[i] = escaped.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+// Where Google Cloud machines, and GKE pods with workload identity, get access tokens for their service account
+const gcpMetadataTokenUrl = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+type gcsStore struct {
+	bucket, prefix string
+	endpoint       string
+	// Set from GOOGLE_OAUTH_ACCESS_TOKEN, or fetched from the metadata server on first use
+	token  string
+	client *http.Client
+}
+
+func (g *gcsStore) Put(name string, content []byte) error {
+	if g.token == "" {
+		token, err := g.metadataToken()
+		if err != nil {
+			return fmt.Errorf("no GOOGLE_OAUTH_ACCESS_TOKEN, and no token from the metadata server: %s", err)
+		}
+		g.token = token
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		g.endpoint, url.PathEscape(g.bucket), url.QueryEscape(path.Join(g.prefix, name)))
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	return doArtifactRequest(g.client, req)
+}
+
+func (g *gcsStore) metadataToken() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, gcpMetadataTokenUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+func (g *gcsStore) String() string {
+	return "gs://" + path.Join(g.bucket, g.prefix)
+}
+
+func doArtifactRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package neobench
+
+import (
+	"encoding/hex"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublishArtifactsToDirectory(t *testing.T) {
+	staging, err := ioutil.TempDir("", "neobench-staging")
+	assert.NoError(t, err)
+	defer os.RemoveAll(staging)
+	dest, err := ioutil.TempDir("", "neobench-dest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dest)
+
+	result := NewResult("neo4j", "test")
+	result.Scripts["read"] = &ScriptResult{ScriptName: "read", Succeeded: 3, Latencies: hdrhistogram.New(0, 60*60*1000000, 5)}
+	assert.NoError(t, WriteArtifacts(staging, "throughput", result, nil, false))
+
+	store, err := NewArtifactStore(dest)
+	assert.NoError(t, err)
+	stored, err := PublishArtifacts(store, staging, "run1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stored)
+	for _, name := range []string{"result.json", "result.neobench", filepath.Join("hgrm", "read.hgrm")} {
+		assert.FileExists(t, filepath.Join(dest, "run1", name))
+	}
+}
+
+func TestNewArtifactStore(t *testing.T) {
+	_, err := NewArtifactStore("ftp://somewhere/results")
+	assert.Error(t, err)
+	store, err := NewArtifactStore("gs://bucket/runs/nightly/")
+	assert.NoError(t, err)
+	assert.Equal(t, "gs://bucket/runs/nightly", store.String())
+}
+
+func TestSigV4Key(t *testing.T) {
+	// From the AWS signature version 4 documentation
+	key := sigV4Key("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20150830", "us-east-1", "iam")
+	assert.Equal(t, "c4afb1cc5771d871763a393e44b703571b55cc28424d1a5e86da6ed3c154a4b9", hex.EncodeToString(key))
+}
+
+func TestS3StorePut(t *testing.T) {
+	var path, auth, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := ioutil.ReadAll(r.Body)
+		path, auth, body = r.URL.EscapedPath(), r.Header.Get("Authorization"), string(content)
+	}))
+	defer server.Close()
+	store := &s3Store{
+		bucket: "results", prefix: "nightly", region: "eu-west-1",
+		accessKey: "AKID", secretKey: "secret",
+		endpoint: server.URL, client: server.Client(),
+		now: func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+
+	assert.NoError(t, store.Put("run 1/result.json", []byte("{}")))
+
+	assert.Equal(t, "/results/nightly/run%201/result.json", path)
+	assert.Equal(t, "{}", body)
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20200102/eu-west-1/s3/aws4_request, "), auth)
+}
@@ -0,0 +1,229 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// A driver that re-reads its bearer token or kerberos ticket at an interval, for runs that outlast the token.
+// When the token has changed, a new driver is created with it; sessions move over to the new driver before
+// their next transaction, and the previous driver is closed at the refresh after that.
+type RefreshingDriver struct {
+	auth      AuthOptions
+	newDriver func(neo4j.AuthToken) (neo4j.Driver, error)
+	onError   func(error)
+	stop      chan struct{}
+	stopOnce  sync.Once
+
+	mu         sync.Mutex
+	current    neo4j.Driver
+	retired    neo4j.Driver
+	generation int
+	secret     string
+}
+
+// onError is called when a refresh fails; the current driver is kept until a later refresh succeeds
+func NewRefreshingDriver(urlStr string, auth AuthOptions, interval time.Duration, encryptionMode EncryptionMode,
+	tlsOptions TlsOptions, conn ConnectionOptions, logger log.Logger, onError func(error)) (*RefreshingDriver, error) {
+	target, configure, err := newDriverConfig(urlStr, encryptionMode, tlsOptions, conn, logger)
+	if err != nil {
+		return nil, err
+	}
+	return newRefreshingDriver(auth, interval, func(token neo4j.AuthToken) (neo4j.Driver, error) {
+		return newBoltDriver(target, token, configure)
+	}, onError)
+}
+
+func newRefreshingDriver(auth AuthOptions, interval time.Duration, newDriver func(neo4j.AuthToken) (neo4j.Driver, error),
+	onError func(error)) (*RefreshingDriver, error) {
+	secret, err := auth.secret()
+	if err != nil {
+		return nil, err
+	}
+	driver, err := newDriver(auth.token(secret))
+	if err != nil {
+		return nil, err
+	}
+	d := &RefreshingDriver{
+		auth:      auth,
+		newDriver: newDriver,
+		onError:   onError,
+		stop:      make(chan struct{}),
+		current:   driver,
+		secret:    secret,
+	}
+	go d.refreshLoop(interval)
+	return d, nil
+}
+
+func (d *RefreshingDriver) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if err := d.refresh(); err != nil && d.onError != nil {
+				d.onError(err)
+			}
+		}
+	}
+}
+
+func (d *RefreshingDriver) refresh() error {
+	secret, err := d.auth.secret()
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	unchanged := secret == d.secret
+	retired := d.retired
+	d.retired = nil
+	d.mu.Unlock()
+	if retired != nil {
+		_ = retired.Close()
+	}
+	if unchanged {
+		return nil
+	}
+
+	driver, err := d.newDriver(d.auth.token(secret))
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.retired = d.current
+	d.current = driver
+	d.generation++
+	d.secret = secret
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *RefreshingDriver) currentDriver() (neo4j.Driver, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.current, d.generation
+}
+
+// Creates a new driver with the current token, for use as a DriverFactory
+func (d *RefreshingDriver) Dial() (neo4j.Driver, error) {
+	d.mu.Lock()
+	secret := d.secret
+	d.mu.Unlock()
+	return d.newDriver(d.auth.token(secret))
+}
+
+func (d *RefreshingDriver) Target() url.URL {
+	driver, _ := d.currentDriver()
+	return driver.Target()
+}
+
+func (d *RefreshingDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return &refreshingSession{driver: d, config: config}
+}
+
+func (d *RefreshingDriver) VerifyConnectivity() error {
+	driver, _ := d.currentDriver()
+	return driver.VerifyConnectivity()
+}
+
+func (d *RefreshingDriver) IsEncrypted() bool {
+	driver, _ := d.currentDriver()
+	return driver.IsEncrypted()
+}
+
+func (d *RefreshingDriver) Close() error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.retired != nil {
+		_ = d.retired.Close()
+		d.retired = nil
+	}
+	return d.current.Close()
+}
+
+// Session that reopens itself on the newest driver when the token has been refreshed, carrying its bookmark over
+type refreshingSession struct {
+	driver     *RefreshingDriver
+	config     neo4j.SessionConfig
+	session    neo4j.Session
+	generation int
+	// Guards replacing session against Interrupt, which is called from other goroutines
+	mut         sync.Mutex
+	interrupted bool
+}
+
+func (s *refreshingSession) current() neo4j.Session {
+	driver, generation := s.driver.currentDriver()
+	if s.session != nil && s.generation == generation {
+		return s.session
+	}
+	if s.session != nil {
+		if bookmark := s.session.LastBookmark(); bookmark != "" {
+			s.config.Bookmarks = []string{bookmark}
+		}
+		_ = s.session.Close()
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.session = driver.NewSession(s.config)
+	s.generation = generation
+	if s.interrupted {
+		interrupt(s.session)
+	}
+	return s.session
+}
+
+func (s *refreshingSession) Interrupt() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.interrupted = true
+	interrupt(s.session)
+}
+
+func (s *refreshingSession) LastBookmarks() neo4j.Bookmarks {
+	if s.session == nil {
+		return nil
+	}
+	return s.session.LastBookmarks()
+}
+
+func (s *refreshingSession) LastBookmark() string {
+	if s.session == nil {
+		return ""
+	}
+	return s.session.LastBookmark()
+}
+
+func (s *refreshingSession) BeginTransaction(configurers ...func(*neo4j.TransactionConfig)) (neo4j.Transaction, error) {
+	return s.current().BeginTransaction(configurers...)
+}
+
+func (s *refreshingSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return s.current().ReadTransaction(work, configurers...)
+}
+
+func (s *refreshingSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return s.current().WriteTransaction(work, configurers...)
+}
+
+func (s *refreshingSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	return s.current().Run(cypher, params, configurers...)
+}
+
+func (s *refreshingSession) Close() error {
+	if s.session == nil {
+		return nil
+	}
+	err := s.session.Close()
+	s.mut.Lock()
+	s.session = nil
+	s.mut.Unlock()
+	return err
+}
@@ -0,0 +1,79 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRefreshingDriverMovesSessionsToNewToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("token-1\n"), 0600))
+
+	var drivers []*tokenDriver
+	driver, err := newRefreshingDriver(AuthOptions{Scheme: "bearer", BearerToken: tokenFile}, time.Hour,
+		func(token neo4j.AuthToken) (neo4j.Driver, error) {
+			d := &tokenDriver{token: fmt.Sprintf("%v", token)}
+			drivers = append(drivers, d)
+			return d, nil
+		}, nil)
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	session := driver.NewSession(neo4j.SessionConfig{})
+	used, _ := session.WriteTransaction(nil)
+	assert.Contains(t, used, "token-1")
+
+	// Unchanged token, no new driver
+	assert.NoError(t, driver.refresh())
+	assert.Len(t, drivers, 1)
+
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("token-2\n"), 0600))
+	assert.NoError(t, driver.refresh())
+	used, _ = session.WriteTransaction(nil)
+	assert.Contains(t, used, "token-2")
+	assert.False(t, drivers[0].closed)
+
+	// The old driver is closed one refresh later, giving transactions on it time to finish
+	assert.NoError(t, driver.refresh())
+	assert.True(t, drivers[0].closed)
+	assert.False(t, drivers[1].closed)
+}
+
+type tokenDriver struct {
+	neo4j.Driver
+	token  string
+	closed bool
+}
+
+func (d *tokenDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return &tokenSession{token: d.token}
+}
+
+func (d *tokenDriver) Close() error {
+	d.closed = true
+	return nil
+}
+
+type tokenSession struct {
+	neo4j.Session
+	token string
+}
+
+func (s *tokenSession) LastBookmark() string {
+	return ""
+}
+
+func (s *tokenSession) Close() error {
+	return nil
+}
+
+func (s *tokenSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return s.token, nil
+}
@@ -0,0 +1,206 @@
+package neobench
+
+import (
+	"context"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"net/url"
+)
+
+// Sessions whose unit of work can be cut short from another goroutine, for workers still stuck in a transaction
+// once the run is over; closing a session or driver doesn't do that, the connection stays busy until the server
+// answers
+type interruptibleSession interface {
+	// Fails what the session is running, and anything it runs after, without waiting on the server
+	Interrupt()
+}
+
+// Interrupts session if it can be
+func interrupt(session neo4j.Session) {
+	if s, ok := session.(interruptibleSession); ok {
+		s.Interrupt()
+	}
+}
+
+// The bolt driver, with each session running on a context of its own that Interrupt cancels; otherwise the same
+// as the drivers own neo4j.Driver, which runs everything on context.Background()
+func newBoltDriver(target string, auth neo4j.AuthToken, configurers ...func(*neo4j.Config)) (neo4j.Driver, error) {
+	driver, err := neo4j.NewDriverWithContext(target, auth, configurers...)
+	if err != nil {
+		return nil, err
+	}
+	return &boltDriver{driver: driver}, nil
+}
+
+type boltDriver struct {
+	driver neo4j.DriverWithContext
+}
+
+func (d *boltDriver) Target() url.URL {
+	return d.driver.Target()
+}
+
+func (d *boltDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &boltSession{session: d.driver.NewSession(ctx, config), ctx: ctx, cancel: cancel}
+}
+
+func (d *boltDriver) VerifyConnectivity() error {
+	return d.driver.VerifyConnectivity(context.Background())
+}
+
+func (d *boltDriver) IsEncrypted() bool {
+	return d.driver.IsEncrypted()
+}
+
+func (d *boltDriver) Close() error {
+	return d.driver.Close(context.Background())
+}
+
+type boltSession struct {
+	session neo4j.SessionWithContext
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func (s *boltSession) Interrupt() {
+	s.cancel()
+}
+
+func (s *boltSession) LastBookmarks() neo4j.Bookmarks {
+	return s.session.LastBookmarks()
+}
+
+func (s *boltSession) LastBookmark() string {
+	bookmarks := s.session.LastBookmarks()
+	if len(bookmarks) == 0 {
+		return ""
+	}
+	return bookmarks[len(bookmarks)-1]
+}
+
+func (s *boltSession) BeginTransaction(configurers ...func(*neo4j.TransactionConfig)) (neo4j.Transaction, error) {
+	tx, err := s.session.BeginTransaction(s.ctx, configurers...)
+	if err != nil {
+		return nil, err
+	}
+	return &boltTransaction{tx: tx, ctx: s.ctx}, nil
+}
+
+func (s *boltSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return s.session.ExecuteRead(s.ctx, s.managed(work), configurers...)
+}
+
+func (s *boltSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return s.session.ExecuteWrite(s.ctx, s.managed(work), configurers...)
+}
+
+func (s *boltSession) managed(work neo4j.TransactionWork) neo4j.ManagedTransactionWork {
+	return func(tx neo4j.ManagedTransaction) (interface{}, error) {
+		return work(&boltManagedTransaction{tx: tx, ctx: s.ctx})
+	}
+}
+
+func (s *boltSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	result, err := s.session.Run(s.ctx, cypher, params, configurers...)
+	if err != nil {
+		return nil, err
+	}
+	return &boltResult{result: result, ctx: s.ctx}, nil
+}
+
+func (s *boltSession) Close() error {
+	defer s.cancel()
+	return s.session.Close(s.ctx)
+}
+
+type boltTransaction struct {
+	tx  neo4j.ExplicitTransaction
+	ctx context.Context
+}
+
+func (tx *boltTransaction) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	result, err := tx.tx.Run(tx.ctx, cypher, params)
+	if err != nil {
+		return nil, err
+	}
+	return &boltResult{result: result, ctx: tx.ctx}, nil
+}
+
+func (tx *boltTransaction) Commit() error {
+	return tx.tx.Commit(tx.ctx)
+}
+
+func (tx *boltTransaction) Rollback() error {
+	return tx.tx.Rollback(tx.ctx)
+}
+
+func (tx *boltTransaction) Close() error {
+	return tx.tx.Close(tx.ctx)
+}
+
+// Transactions of ReadTransaction and WriteTransaction, which the driver commits or rolls back itself
+type boltManagedTransaction struct {
+	tx  neo4j.ManagedTransaction
+	ctx context.Context
+}
+
+func (tx *boltManagedTransaction) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	result, err := tx.tx.Run(tx.ctx, cypher, params)
+	if err != nil {
+		return nil, err
+	}
+	return &boltResult{result: result, ctx: tx.ctx}, nil
+}
+
+func (tx *boltManagedTransaction) Commit() error {
+	return &neo4j.UsageError{Message: "Commit not allowed on retryable transaction"}
+}
+
+func (tx *boltManagedTransaction) Rollback() error {
+	return &neo4j.UsageError{Message: "Rollback not allowed on retryable transaction"}
+}
+
+func (tx *boltManagedTransaction) Close() error {
+	return &neo4j.UsageError{Message: "Close not allowed on retryable transaction"}
+}
+
+type boltResult struct {
+	result neo4j.ResultWithContext
+	ctx    context.Context
+}
+
+func (r *boltResult) Keys() ([]string, error) {
+	return r.result.Keys()
+}
+
+func (r *boltResult) Next() bool {
+	return r.result.Next(r.ctx)
+}
+
+func (r *boltResult) NextRecord(record **neo4j.Record) bool {
+	return r.result.NextRecord(r.ctx, record)
+}
+
+func (r *boltResult) PeekRecord(record **neo4j.Record) bool {
+	return r.result.PeekRecord(r.ctx, record)
+}
+
+func (r *boltResult) Err() error {
+	return r.result.Err()
+}
+
+func (r *boltResult) Record() *neo4j.Record {
+	return r.result.Record()
+}
+
+func (r *boltResult) Collect() ([]*neo4j.Record, error) {
+	return r.result.Collect(r.ctx)
+}
+
+func (r *boltResult) Single() (*neo4j.Record, error) {
+	return r.result.Single(r.ctx)
+}
+
+func (r *boltResult) Consume() (neo4j.ResultSummary, error) {
+	return r.result.Consume(r.ctx)
+}
@@ -0,0 +1,168 @@
+package neobench
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Builds a Script in Go, for programs generating many workload variants, without writing script text to be
+// parsed again. Methods chain, and the first mistake is reported by Build:
+//
+//	script, err := neobench.NewScriptBuilder("transfer").
+//		SetVariable("aid", neobench.Random(1, 100000)).
+//		AddStatement("MATCH (a:Account {aid: $aid}) SET a.balance = a.balance + 1").
+//		Build()
+type ScriptBuilder struct {
+	script Script
+	err    error
+}
+
+func NewScriptBuilder(name string) *ScriptBuilder {
+	b := &ScriptBuilder{script: Script{Name: name, Weight: 1}}
+	if strings.TrimSpace(name) == "" {
+		b.err = fmt.Errorf("script needs a name")
+	}
+	return b
+}
+
+// Relative weight of the script in a workload, like @weight in -w; defaults to 1
+func (b *ScriptBuilder) SetWeight(weight uint) *ScriptBuilder {
+	b.script.Weight = weight
+	return b
+}
+
+// Max transactions per second for the script across all clients, like :rate= in -w
+func (b *ScriptBuilder) SetRate(rate float64) *ScriptBuilder {
+	if rate < 0 {
+		b.fail(fmt.Errorf("script rate must be 0 or more, got %v", rate))
+	}
+	b.script.Rate = rate
+	return b
+}
+
+// Marks the script as only reading, so it runs in read transactions that can go to read replicas
+func (b *ScriptBuilder) SetReadonly(readonly bool) *ScriptBuilder {
+	b.script.Readonly = readonly
+	return b
+}
+
+// Sets a variable for the statements after it, like \set; it is passed to them as a parameter of the same name
+func (b *ScriptBuilder) SetVariable(name string, value Expression) *ScriptBuilder {
+	if !functionNamePattern.MatchString(name) {
+		b.fail(fmt.Errorf("variable name must be letters, digits and underscores, not starting with a digit, got '%s'", name))
+	}
+	b.script.Commands = append(b.script.Commands, SetCommand{VarName: name, Expression: compile(value)})
+	return b
+}
+
+// Adds a statement to the unit of work, run with the variables set before it as parameters
+func (b *ScriptBuilder) AddStatement(query string) *ScriptBuilder {
+	if strings.TrimSpace(query) == "" {
+		b.fail(fmt.Errorf("statement %d of %s is empty", b.statements()+1, b.script.Name))
+	}
+	b.script.Commands = append(b.script.Commands, QueryCommand{Query: query})
+	return b
+}
+
+// Pauses the unit of work for duration, like \sleep
+func (b *ScriptBuilder) AddSleep(duration time.Duration) *ScriptBuilder {
+	b.script.Commands = append(b.script.Commands, SleepCommand{Duration: Int(duration.Microseconds()), Unit: time.Microsecond})
+	return b
+}
+
+// Checks the rows of the statement just added, like \expect; column is empty to check the row count
+func (b *ScriptBuilder) Expect(column string, value Expression) *ScriptBuilder {
+	if !followsStatement(b.script.Commands) {
+		b.fail(fmt.Errorf("expectations must directly follow the statement they check"))
+	}
+	b.script.Commands = append(b.script.Commands, ExpectCommand{Column: column, Value: compile(value)})
+	return b
+}
+
+func (b *ScriptBuilder) Build() (Script, error) {
+	if b.err == nil && b.statements() == 0 {
+		b.err = fmt.Errorf("script %s has no statements", b.script.Name)
+	}
+	if b.err != nil {
+		return Script{}, b.err
+	}
+	script := b.script
+	script.Commands = append([]Command(nil), b.script.Commands...)
+	return script, nil
+}
+
+func (b *ScriptBuilder) statements() int {
+	n := 0
+	for _, cmd := range b.script.Commands {
+		if _, ok := cmd.(QueryCommand); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *ScriptBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// A workload of scripts, drawing random numbers from seed so runs can be repeated; vars are available to every
+// script, like -D
+func NewWorkload(seed int64, vars map[string]interface{}, scripts ...Script) Workload {
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	return Workload{
+		Variables: vars,
+		Scripts:   NewScripts(scripts...),
+		Rand:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+func Int(value int64) Expression {
+	return Expression{Kind: intExpr, Payload: value}
+}
+
+func Float(value float64) Expression {
+	return Expression{Kind: floatExpr, Payload: value}
+}
+
+// The value of a variable, set by an earlier SetVariable or given to the workload
+func Var(name string) Expression {
+	return Expression{Kind: varExpr, Payload: name}
+}
+
+// A call of a builtin or registered function, eg. Call("least", Var("a"), Int(10))
+func Call(name string, args ...Expression) Expression {
+	return Expression{Kind: callExpr, Payload: CallExpr{name: name, args: args}}
+}
+
+// Uniformly distributed integer from min to max, inclusive
+func Random(min, max int64) Expression {
+	return Call("random", Int(min), Int(max))
+}
+
+// Integer from min to max, following a gaussian distribution around the middle; larger parameters
+// concentrate it more, see random_gaussian in scripts
+func RandomGaussian(min, max int64, parameter float64) Expression {
+	return Call("random_gaussian", Int(min), Int(max), Float(parameter))
+}
+
+// Integer from min to max, following an exponential distribution favouring min
+func RandomExponential(min, max int64, parameter float64) Expression {
+	return Call("random_exponential", Int(min), Int(max), Float(parameter))
+}
+
+// Integer from min to max, following a zipfian distribution, so a few values come up most of the time
+func RandomZipfian(min, max int64, parameter float64) Expression {
+	return Call("random_zipfian", Int(min), Int(max), Float(parameter))
+}
+
+// Uniformly distributed integer from the part of min to max that belongs to the client, so clients don't
+// contend for the same keys; see random_partitioned in scripts
+func RandomPartitioned(min, max int64) Expression {
+	return Call("random_partitioned", Int(min), Int(max))
+}
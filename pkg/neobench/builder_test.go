@@ -0,0 +1,55 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScriptBuilderMatchesParsedScript(t *testing.T) {
+	built, err := NewScriptBuilder("transfer").
+		SetWeight(3).
+		SetVariable("aid", Random(1, 1000)).
+		SetVariable("delta", Call("least", RandomGaussian(1, 100, 5), Var("limit"))).
+		AddStatement("MATCH (a:Account {aid: $aid}) SET a.balance = a.balance + $delta").
+		AddStatement("MATCH (a:Account {aid: $aid}) RETURN a.balance").
+		Build()
+	assert.NoError(t, err)
+	parsed, err := Parse("transfer", `\set aid random(1, 1000)
+\set delta least(random_gaussian(1, 100, 5.0), $limit)
+MATCH (a:Account {aid: $aid}) SET a.balance = a.balance + $delta;
+MATCH (a:Account {aid: $aid}) RETURN a.balance;`, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), built.Weight)
+
+	// The same seed draws the same units of work from both
+	builtWorkload := NewWorkload(1337, map[string]interface{}{"limit": int64(50)}, built)
+	parsedWorkload := NewWorkload(1337, map[string]interface{}{"limit": int64(50)}, parsed)
+	builtWork, parsedWork := builtWorkload.NewClient(), parsedWorkload.NewClient()
+	for i := 0; i < 10; i++ {
+		builtUow, err := builtWork.Next()
+		assert.NoError(t, err)
+		parsedUow, err := parsedWork.Next()
+		assert.NoError(t, err)
+		assert.Len(t, builtUow.Statements, 2)
+		for j := range builtUow.Statements {
+			assert.Equal(t, parsedUow.Statements[j].Params, builtUow.Statements[j].Params)
+		}
+		assert.LessOrEqual(t, builtUow.Statements[0].Params["delta"], int64(50))
+	}
+}
+
+func TestScriptBuilderReportsFirstMistake(t *testing.T) {
+	_, err := NewScriptBuilder("empty").SetVariable("a", Int(1)).Build()
+	assert.EqualError(t, err, "script empty has no statements")
+
+	_, err = NewScriptBuilder("bad").SetVariable("1a", Int(1)).AddStatement("").Build()
+	assert.EqualError(t, err, "variable name must be letters, digits and underscores, not starting with a digit, got '1a'")
+
+	_, err = NewScriptBuilder("expect").Expect("", Int(1)).AddStatement("RETURN 1").Build()
+	assert.Error(t, err)
+
+	script, err := NewScriptBuilder("nap").AddSleep(2*time.Millisecond).AddStatement("RETURN 1").Expect("", Int(1)).Build()
+	assert.NoError(t, err)
+	assert.Len(t, script.Commands, 3)
+}
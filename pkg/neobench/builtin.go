@@ -1,9 +1,69 @@
 package neobench
 
 import (
-	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
+// Creates the dataset of a builtin workload in -i mode. vars are the workload variables, with the builtins
+// defaults filled in.
+type InitFunc func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error
+
+// Init of each builtin workload, by the name given to -w
+var BuiltinInits = map[string]InitFunc{
+	"builtin:tpcb-like": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitTPCBLike(scale, dbName, driver, opts, out)
+	},
+	"builtin:match-only": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitMatchOnly(scale, dbName, driver, opts, out)
+	},
+	// Reads the tpcb-like dataset, including history written by tpcb-like runs
+	"builtin:aggregation": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitTPCBLike(scale, dbName, driver, opts, out)
+	},
+	"builtin:ldbc-snb": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitLdbcSnb(scale, dbName, driver, opts, out)
+	},
+	"builtin:insert-heavy": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitInsertHeavy(scale, dbName, driver, opts, out)
+	},
+	"builtin:supernode": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitSupernode(scale, vars["hotspots"].(int64), dbName, driver, opts, out)
+	},
+	"builtin:gds": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitGds(scale, dbName, driver, opts, out)
+	},
+	"builtin:fulltext": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitFulltext(scale, dbName, driver, opts, out)
+	},
+	"builtin:vector": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitVector(scale, dbName, driver, opts, out)
+	},
+	"builtin:traversal": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitTraversal(scale, dbName, driver, opts, out)
+	},
+	"builtin:merge": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitMerge(dbName, driver, opts, out)
+	},
+	"builtin:pagination": func(scale int64, vars map[string]interface{}, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		return InitPagination(scale, dbName, driver, opts, out)
+	},
+}
+
+// One script of a builtin made up of several, like LdbcSnb; it runs as "<builtin>/<Name>"
+type BuiltinQuery struct {
+	Name     string
+	Weight   uint
+	Readonly bool
+	// See Script.Metric
+	Metric string
+	Script string
+}
+
+// Name builtin:tpcb-like runs and reports results under; misspelled, but kept so results stay comparable with
+// those stored by earlier versions
+const TPCBLikeScriptName = "builtin:tpcp-like"
+
 const TPCBLike = `
 \set aid random(1, 100000 * $scale)
 \set bid random(1, 1 * $scale)
@@ -24,28 +84,107 @@ const MatchOnly = `
 MATCH (account:Account {aid:$aid}) RETURN account.balance;
 `
 
-func InitTPCBLike(scale int64, dbName string, driver neo4j.Driver, out Output) error {
+// Sustained ingest: each transaction creates $batch readings, each linked to a random device, so throughput
+// is bounded by write and index maintenance rather than by lock contention. Set the batch size with -D batch=...
+const InsertHeavy = `
+\set deviceCount 1000 * $scale
+UNWIND range(1, $batch) AS i
+MATCH (device:Device {id: toInteger(rand() * $deviceCount) + 1})
+CREATE (device)-[:RECORDED {time: timestamp()}]->(:Reading {id: randomUUID(), value: rand(), time: timestamp()});
+`
+
+// Readings per transaction for builtin:insert-heavy unless -D batch is given
+const DefaultInsertBatch = 100
+
+// Skewed traffic: a fraction $hot of transactions go to the first $hotspots items, the supernodes every other item
+// links to, and the rest to a random other item. Each transaction reads the item's latest links, which on a
+// supernode means sorting a dense relationship chain, then bumps its counter and adds a link, which all
+// transactions on that supernode contend for.
+const Supernode = `
+\set hotId random(1, $hotspots)
+\set coldId random($hotspots + 1, 100000 * $scale)
+\set linkerId random($hotspots + 1, 100000 * $scale)
+\set roll random(0, 999999)
+
+MATCH (item:Item {id: CASE WHEN $roll < $hot * 1000000 THEN $hotId ELSE $coldId END})<-[link:LINKS_TO]-(linker:Item)
+WITH item, linker ORDER BY link.time DESC LIMIT 10
+RETURN item.id, item.counter, collect(linker.id);
+
+MATCH (item:Item {id: CASE WHEN $roll < $hot * 1000000 THEN $hotId ELSE $coldId END}), (linker:Item {id: $linkerId})
+SET item.counter = item.counter + 1
+CREATE (linker)-[:LINKS_TO {time: timestamp()}]->(item);
+`
+
+// Upserts, like an integration pipeline syncing customers and who referred whom. Each of the two customers is,
+// with probability $collision, one of a small set of shared keys that concurrent transactions collide on, and
+// otherwise almost certainly new. Two transactions that lock the same shared pair in opposite order deadlock,
+// and the driver retries them, as it would in a real pipeline.
+const Merge = `
+\set roll1 random(0, 999999)
+\set roll2 random(0, 999999)
+\set shared1 random(1, 100 * $scale)
+\set shared2 random(1, 100 * $scale)
+\set fresh1 random(100 * $scale + 1, 1000000000000)
+\set fresh2 random(100 * $scale + 1, 1000000000000)
+
+MERGE (a:Customer {key: CASE WHEN $roll1 < $collision * 1000000 THEN $shared1 ELSE $fresh1 END})
+ON CREATE SET a.created = timestamp(), a.updates = 0
+ON MATCH SET a.updates = a.updates + 1
+MERGE (b:Customer {key: CASE WHEN $roll2 < $collision * 1000000 THEN $shared2 ELSE $fresh2 END})
+ON CREATE SET b.created = timestamp(), b.updates = 0
+ON MATCH SET b.updates = b.updates + 1
+MERGE (a)-[r:REFERRED]->(b)
+ON CREATE SET r.count = 1
+ON MATCH SET r.count = r.count + 1;
+`
+
+// Probability of a shared key for builtin:merge unless -D collision is given
+const DefaultMergeCollision = 0.1
+
+// Defaults for builtin:supernode unless -D hot and -D hotspots are given
+const (
+	DefaultSupernodeHot      = 0.1
+	DefaultSupernodeHotspots = 10
+)
+
+// Heavy, read-only analytics over the tpcb-like dataset: each query scans every account or history entry. Meant
+// to be mixed into a transactional workload at a low weight, eg. -w builtin:tpcb-like@100 -w builtin:aggregation@1
+var Aggregation = []BuiltinQuery{
+	{Name: "balance-distribution", Weight: 1, Readonly: true, Script: `
+MATCH (account:Account)
+RETURN account.balance / 1000 AS bucket, count(*) AS accounts, sum(account.balance) AS total
+ORDER BY bucket;
+`},
+	{Name: "teller-activity", Weight: 1, Readonly: true, Script: `
+MATCH (history:History)
+RETURN history.tid AS teller, count(*) AS transactions, sum(history.delta) AS net, avg(abs(history.delta)) AS meanSize
+ORDER BY transactions DESC
+LIMIT 10;
+`},
+	{Name: "global-counts", Weight: 1, Readonly: true, Script: `
+MATCH (account:Account)
+WITH count(account) AS accounts, sum(account.balance) AS accountTotal
+MATCH (history:History)
+RETURN accounts, accountTotal, count(history) AS history, sum(history.delta) AS historyTotal;
+`},
+}
+
+func InitTPCBLike(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
 	numBranches := 1 * scale
 	numTellers := 10 * scale
 	numAccounts := 100000 * scale
-	session, err := driver.NewSession(neo4j.SessionConfig{
+	session := driver.NewSession(neo4j.SessionConfig{
 		AccessMode:   neo4j.AccessModeWrite,
 		DatabaseName: dbName,
 	})
-	if err != nil {
-		return err
-	}
+	var err error
 	defer session.Close()
 
-	out.ReportProgress(ProgressReport{
-		Section:      "init",
-		Step:         "create schema",
-		Completeness: 0,
-	})
-	_, err = session.Run(`CREATE CONSTRAINT ON (b:Branch) ASSERT b.bid IS UNIQUE
-CREATE CONSTRAINT ON (t:Teller) ASSERT t.tid IS UNIQUE
-CREATE CONSTRAINT ON (a:Account) ASSERT a.aid IS UNIQUE
-`, map[string]interface{}{})
+	err = createSchema(session, opts, out,
+		"CREATE CONSTRAINT ON (b:Branch) ASSERT b.bid IS UNIQUE",
+		"CREATE CONSTRAINT ON (t:Teller) ASSERT t.tid IS UNIQUE",
+		"CREATE CONSTRAINT ON (a:Account) ASSERT a.aid IS UNIQUE",
+	)
 	if err != nil {
 		return err
 	}
@@ -55,8 +194,9 @@ CREATE CONSTRAINT ON (a:Account) ASSERT a.aid IS UNIQUE
 		Step:         "create branches & tellers",
 		Completeness: 0,
 	})
+	// Merging on the id alone, so branches and tellers whose balance an earlier run changed are left as they are
 	_, err = session.Run(`UNWIND range(1, $nBranches) AS branchId 
-MERGE (:Branch {bid: branchId, balance: 0})
+MERGE (b:Branch {bid: branchId}) ON CREATE SET b.balance = 0
 `, map[string]interface{}{
 		"nBranches": numBranches,
 	})
@@ -65,7 +205,7 @@ MERGE (:Branch {bid: branchId, balance: 0})
 	}
 
 	_, err = session.Run(`UNWIND range(1, $nTellers) AS tellerId 
-MERGE (t:Teller {tid: tellerId, balance: 0})
+MERGE (t:Teller {tid: tellerId}) ON CREATE SET t.balance = 0
 `, map[string]interface{}{
 		"nTellers": numTellers,
 	})
@@ -73,40 +213,161 @@ MERGE (t:Teller {tid: tellerId, balance: 0})
 		return err
 	}
 
+	return createAccounts(session, driver, dbName, numAccounts, opts, out)
+}
+
+// builtin:match-only only reads accounts, so unlike tpcb-like it gets no branches or tellers
+func InitMatchOnly(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	var err error
+	defer session.Close()
+
+	if err = createSchema(session, opts, out, "CREATE CONSTRAINT ON (a:Account) ASSERT a.aid IS UNIQUE"); err != nil {
+		return err
+	}
+	return createAccounts(session, driver, dbName, 100000*scale, opts, out)
+}
+
+// Creates accounts up to numAccounts, carrying on from where an earlier init stopped
+func createAccounts(session neo4j.Session, driver neo4j.Driver, dbName string, numAccounts int64, opts InitOptions, out Output) error {
+	cp, err := startCheckpoint(session, "accounts", out)
+	if err != nil {
+		return err
+	}
+	existingAccountNum, err := cp.existingIds(session, "Account", "aid")
+	if err != nil {
+		return err
+	}
+	err = loadBatches(driver, dbName, opts, cp, "create accounts", existingAccountNum+1, numAccounts, `UNWIND range($start, $end) AS accountId 
+CREATE (a:Account {aid: accountId, balance: 0})
+SET a += coalesce($content[accountId - $start], {})
+`, map[string]interface{}{
+		"content": opts.Content.accounts(),
+	}, out)
+	if err != nil {
+		return err
+	}
+	return cp.finish(session)
+}
+
+// Returns the highest id of the nodes with label, or 0 if there are none. Builtins number their nodes from 1 in
+// batches, so this is how far an earlier init got, and where to carry on from when it is run again at a larger scale.
+// Batches of a parallel init can commit out of order, so there may be gaps below the highest id if one was
+// interrupted. An initCheckpoint resumes around them, so finding gaps here, without one, is an error.
+func existingIds(session neo4j.Session, label, idProperty string) (int64, error) {
+	result, err := session.Run(fmt.Sprintf("MATCH (n:`%s`) RETURN coalesce(max(n.`%s`), 0) AS n, count(n) AS count", label, idProperty), nil)
+	if err != nil {
+		return 0, err
+	}
+	if !result.Next() {
+		if err = result.Err(); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("no result counting existing %s nodes", label)
+	}
+	highest := result.Record().Values[0].(int64)
+	if count := result.Record().Values[1].(int64); count < highest {
+		return 0, fmt.Errorf("found %d %s nodes with ids up to %d, an earlier init was interrupted part way; remove them with --cleanup and run -i again", count, label, highest)
+	}
+	return highest, nil
+}
+
+// Creates the devices builtin:insert-heavy attaches readings to, and the constraints ingest has to maintain
+func InitInsertHeavy(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	numDevices := 1000 * scale
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	var err error
+	defer session.Close()
+
+	err = createSchema(session, opts, out,
+		"CREATE CONSTRAINT ON (d:Device) ASSERT d.id IS UNIQUE",
+		"CREATE CONSTRAINT ON (r:Reading) ASSERT r.id IS UNIQUE",
+		"CREATE INDEX ON :Reading(time)",
+	)
+	if err != nil {
+		return err
+	}
+
+	cp, err := startCheckpoint(session, "insert-heavy", out)
+	if err != nil {
+		return err
+	}
+	existingDeviceNum, err := cp.existingIds(session, "Device", "id")
+	if err != nil {
+		return err
+	}
+	err = loadBatches(driver, dbName, opts, cp, "create devices", existingDeviceNum+1, numDevices, `UNWIND range($start, $end) AS deviceId
+CREATE (:Device {id: deviceId})
+`, nil, out)
+	if err != nil {
+		return err
+	}
+	return cp.finish(session)
+}
+
+// builtin:merge starts from an empty graph; this only creates the constraint that makes concurrent MERGE safe, so
+// without it, see InitOptions.SkipSchema, concurrent upserts of the same key create duplicates
+func InitMerge(dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	return createSchema(session, opts, out, "CREATE CONSTRAINT ON (c:Customer) ASSERT c.key IS UNIQUE")
+}
+
+// Creates the items builtin:supernode runs against. Items 1 to hotspots are the supernodes, and every other item
+// links to one of them, so each has around 100000 * scale / hotspots relationships.
+func InitSupernode(scale, hotspots int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	numItems := 100000 * scale
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	var err error
+	defer session.Close()
+
+	if err = createSchema(session, opts, out, "CREATE CONSTRAINT ON (i:Item) ASSERT i.id IS UNIQUE"); err != nil {
+		return err
+	}
+
 	out.ReportProgress(ProgressReport{
 		Section:      "init",
-		Step:         "create accounts",
+		Step:         "create supernodes",
 		Completeness: 0,
 	})
-	result, err := session.Run("MATCH (:Account) RETURN COUNT(*) AS n", nil)
+	_, err = session.Run(`UNWIND range(1, $hotspots) AS itemId
+MERGE (i:Item {id: itemId}) ON CREATE SET i.counter = 0
+`, map[string]interface{}{
+		"hotspots": hotspots,
+	})
 	if err != nil {
 		return err
 	}
-	result.Next()
-	existingAccountNum := result.Record().GetByIndex(0).(int64)
 
-	batchSize := int64(5000)
-	numBatches := numAccounts / batchSize
-	for batchNo := int64(0); batchNo <= numBatches; batchNo++ {
-		startAccount := max(existingAccountNum, batchSize*batchNo+1)
-		endAccount := min(numAccounts, startAccount+batchSize)
-		if endAccount <= startAccount {
-			continue
-		}
-		_, err = session.Run(`UNWIND range($startAccount, $endAccount) AS accountId 
-CREATE (a:Account {aid: accountId, balance: 0})
+	cp, err := startCheckpoint(session, "supernode", out)
+	if err != nil {
+		return err
+	}
+	existingItemNum, err := cp.existingIds(session, "Item", "id")
+	if err != nil {
+		return err
+	}
+	err = loadBatches(driver, dbName, opts, cp, "create items", existingItemNum+1, numItems, `UNWIND range($start, $end) AS itemId
+MATCH (hub:Item {id: (itemId - 1) % $hotspots + 1})
+CREATE (:Item {id: itemId, counter: 0})-[:LINKS_TO {time: timestamp()}]->(hub)
 `, map[string]interface{}{
-			"startAccount": startAccount,
-			"endAccount":   endAccount,
-		})
-		if err != nil {
-			return err
-		}
-		out.ReportProgress(ProgressReport{
-			Section:      "init",
-			Step:         "create accounts",
-			Completeness: float64(batchNo) / float64(numBatches),
-		})
+		"hotspots": hotspots,
+	}, out)
+	if err != nil {
+		return err
 	}
-	return nil
+	return cp.finish(session)
 }
@@ -0,0 +1,61 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCreateAccountsTopsUpFromExistingAccounts(t *testing.T) {
+	driver := &batchDriver{}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := createAccounts(&datasetSession{existing: 7000, count: 7000}, driver, "neo4j", 20000, DefaultInitOptions, out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]int64{{7001, 12000}, {12001, 17000}, {17001, 20000}}, driver.sortedBatches())
+}
+
+func TestCreateAccountsDoesNothingAtTheSameScale(t *testing.T) {
+	driver := &batchDriver{}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := createAccounts(&datasetSession{existing: 100000, count: 100000}, driver, "neo4j", 100000, DefaultInitOptions, out)
+
+	assert.NoError(t, err)
+	assert.Empty(t, driver.batches)
+}
+
+func TestCreateAccountsRefusesToTopUpAroundGaps(t *testing.T) {
+	driver := &batchDriver{}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := createAccounts(&datasetSession{existing: 20000, count: 15000}, driver, "neo4j", 100000, DefaultInitOptions, out)
+
+	assert.EqualError(t, err, "found 15000 Account nodes with ids up to 20000, an earlier init was interrupted part way; remove them with --cleanup and run -i again")
+	assert.Empty(t, driver.batches)
+}
+
+// Answers existingIds with the highest id and number of nodes already there, with no init checkpoint
+type datasetSession struct {
+	neo4j.Session
+	existing int64
+	count    int64
+}
+
+func (s *datasetSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	switch {
+	case strings.Contains(cypher, "OPTIONAL MATCH (c:NeobenchInit"):
+		return &rowResult{rows: [][]interface{}{{false}}, next: -1}, nil
+	case strings.Contains(cypher, "RETURN c[$key]"):
+		return &rowResult{next: -1}, nil
+	case strings.Contains(cypher, "DETACH DELETE"):
+		return &rowResult{rows: [][]interface{}{{int64(0)}}, next: -1}, nil
+	case strings.Contains(cypher, "count(row)"):
+		return &rowResult{rows: [][]interface{}{{s.count}}, next: -1}, nil
+	case strings.Contains(cypher, "max("):
+		return &rowResult{rows: [][]interface{}{{s.existing, s.count}}, next: -1}, nil
+	}
+	return consumedResult{}, nil
+}
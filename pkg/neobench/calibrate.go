@@ -0,0 +1,149 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"io"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A driver that answers every statement at once with no rows, without a network or a server, so a run against
+// it measures nothing but what neobench itself spends on each transaction; see --calibrate
+func NewNoopDriver() neo4j.Driver {
+	return noopDriver{}
+}
+
+type noopDriver struct{}
+
+func (noopDriver) Target() url.URL {
+	return url.URL{Scheme: "noop"}
+}
+
+func (noopDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return noopSession{}
+}
+
+func (noopDriver) VerifyConnectivity() error {
+	return nil
+}
+
+func (noopDriver) IsEncrypted() bool {
+	return false
+}
+
+func (noopDriver) Close() error {
+	return nil
+}
+
+type noopSession struct{}
+
+func (noopSession) LastBookmarks() neo4j.Bookmarks {
+	return nil
+}
+
+func (noopSession) LastBookmark() string {
+	return ""
+}
+
+func (noopSession) BeginTransaction(configurers ...func(*neo4j.TransactionConfig)) (neo4j.Transaction, error) {
+	return noopTransaction{}, nil
+}
+
+func (noopSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(noopTransaction{})
+}
+
+func (noopSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(noopTransaction{})
+}
+
+func (noopSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	return noopResult{}, nil
+}
+
+func (noopSession) Close() error {
+	return nil
+}
+
+type noopTransaction struct{}
+
+func (noopTransaction) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	return noopResult{}, nil
+}
+
+func (noopTransaction) Commit() error {
+	return nil
+}
+
+func (noopTransaction) Rollback() error {
+	return nil
+}
+
+func (noopTransaction) Close() error {
+	return nil
+}
+
+type noopResult struct{}
+
+func (noopResult) Keys() ([]string, error)               { return nil, nil }
+func (noopResult) Next() bool                            { return false }
+func (noopResult) NextRecord(record **neo4j.Record) bool { *record = nil; return false }
+func (noopResult) PeekRecord(record **neo4j.Record) bool { *record = nil; return false }
+func (noopResult) Err() error                            { return nil }
+func (noopResult) Record() *neo4j.Record                 { return nil }
+func (noopResult) Collect() ([]*neo4j.Record, error)     { return nil, nil }
+func (noopResult) Single() (*neo4j.Record, error) {
+	return nil, fmt.Errorf("no-op driver returns no records")
+}
+func (noopResult) Consume() (neo4j.ResultSummary, error) { return httpSummary{}, nil }
+
+// Builds a schedule running as fast as possible with each number of clients in turn, for --calibrate
+func CalibrationSteps(clients []int, stepDuration time.Duration) ([]Phase, error) {
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("no client counts given for --calibrate")
+	}
+	phases := make([]Phase, 0, len(clients))
+	seen := make(map[int]bool, len(clients))
+	for i, n := range clients {
+		if n < 1 {
+			return nil, fmt.Errorf("--calibrate client counts must all be at least 1, got %d", n)
+		}
+		if seen[n] {
+			return nil, fmt.Errorf("--calibrate lists %d clients more than once", n)
+		}
+		seen[n] = true
+		start := time.Duration(i) * stepDuration
+		phases = append(phases, Phase{
+			Name:    fmt.Sprintf("%d clients", n),
+			Start:   start,
+			End:     start + stepDuration,
+			Clients: n,
+		})
+	}
+	return phases, nil
+}
+
+// Writes a table with the overhead per transaction at each number of clients of a run against NewNoopDriver;
+// latencies well above these are down to the server rather than to neobench
+func WriteCalibrationSummary(w io.Writer, phases []Phase, result Result, f LatencyFormat) error {
+	s := strings.Builder{}
+	s.WriteString("Calibration summary, time neobench itself spends on each transaction:\n")
+	tw := tabwriter.NewWriter(&s, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "  Clients\tRate\tMean\tP50\tP99\tMax\n")
+	for _, phase := range phases {
+		totals := phaseTotals(phase, result)
+		if totals.succeeded+totals.failed == 0 {
+			continue
+		}
+		histo := totals.latencies
+		_, _ = fmt.Fprintf(tw, "  %d\t%.3f/s\t%s\t%s\t%s\t%s\n", phase.Clients, totals.rate, f.format(histo.Mean()),
+			f.format(float64(histo.ValueAtQuantile(50))), f.format(float64(histo.ValueAtQuantile(99))),
+			f.format(float64(histo.Max())))
+	}
+	_ = tw.Flush()
+	_, err := fmt.Fprint(w, s.String())
+	return err
+}
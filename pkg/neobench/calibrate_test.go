@@ -0,0 +1,45 @@
+package neobench
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalibrationRunsEachClientCountAgainstNoopDriver(t *testing.T) {
+	script, err := Parse("calibrate", `\set aid random(1, 100000)
+MATCH (a:Account {aid: $aid}) RETURN a;`, 1)
+	assert.NoError(t, err)
+	schedule, err := CalibrationSteps([]int{1, 4}, 100*time.Millisecond)
+	assert.NoError(t, err)
+
+	result, err := Run(context.Background(), BenchmarkConfig{
+		Driver:   NewNoopDriver(),
+		Workload: Workload{Scripts: NewScripts(script), Rand: rand.New(rand.NewSource(1337))},
+		Schedule: schedule,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.TotalFailed())
+	for _, name := range []string{"1 clients/calibrate", "4 clients/calibrate"} {
+		assert.Greater(t, result.Scripts[name].Succeeded, int64(0), name)
+	}
+	var summary strings.Builder
+	assert.NoError(t, WriteCalibrationSummary(&summary, schedule, result, DefaultLatencyFormat))
+	lines := strings.Split(strings.TrimSpace(summary.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(strings.TrimSpace(lines[3]), "4 "), lines[3])
+}
+
+func TestCalibrationSteps(t *testing.T) {
+	for _, clients := range [][]int{nil, {0}, {4, 4}} {
+		_, err := CalibrationSteps(clients, time.Second)
+		assert.Error(t, err, "%v", clients)
+	}
+	phases, err := CalibrationSteps([]int{1, 16}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, Phase{Name: "16 clients", Start: time.Minute, End: 2 * time.Minute, Clients: 16}, phases[1])
+}
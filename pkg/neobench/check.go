@@ -0,0 +1,114 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"strings"
+)
+
+// Checks that the dataset of a builtin workload is complete for scale, see BuiltinChecks
+type CheckFunc func(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error
+
+// Check of each builtin workload, by the name given to -w
+var BuiltinChecks = map[string]CheckFunc{
+	"builtin:tpcb-like":    checkDataset("accounts", nil, expectedNodes{"Branch", "bid", 1}, expectedNodes{"Teller", "tid", 10}, expectedNodes{"Account", "aid", 100000}),
+	"builtin:match-only":   checkDataset("accounts", nil, expectedNodes{"Account", "aid", 100000}),
+	"builtin:aggregation":  checkDataset("accounts", nil, expectedNodes{"Branch", "bid", 1}, expectedNodes{"Teller", "tid", 10}, expectedNodes{"Account", "aid", 100000}),
+	"builtin:ldbc-snb":     checkDataset("ldbc-snb", nil, expectedNodes{"Person", "id", 1000}, expectedNodes{"Forum", "id", 100}, expectedNodes{"Message", "id", 30000}),
+	"builtin:insert-heavy": checkDataset("insert-heavy", nil, expectedNodes{"Device", "id", 1000}),
+	"builtin:supernode":    checkDataset("supernode", nil, expectedNodes{"Item", "id", 100000}),
+	"builtin:gds":          checkDataset("gds", nil, expectedNodes{"Page", "id", 10000}),
+	"builtin:fulltext":     checkDataset("fulltext", []string{FulltextIndexName}, expectedNodes{"Document", "id", 10000}),
+	"builtin:vector":       checkDataset("vector", []string{VectorIndexName}, expectedNodes{"Chunk", "id", 10000}),
+	"builtin:traversal":    checkDataset("traversal", nil, expectedNodes{"Place", "id", 10000}),
+	// Customers are created by the workload itself, only the constraint it relies on comes from init
+	"builtin:merge":      checkDataset("merge", nil, expectedNodes{"Customer", "key", 0}),
+	"builtin:pagination": checkDataset("pagination", nil, expectedNodes{"Article", "id", 100000}),
+}
+
+// Nodes of label that init creates, numbered from 1 in idProperty, perScale of them for each step of the scale.
+// Their ids are indexed, unless init was told to skip the schema.
+type expectedNodes struct {
+	label      string
+	idProperty string
+	perScale   int64
+}
+
+// Checks that no init of dataset was interrupted, that there are at least as many nodes of each label as init
+// creates at the scale, with no gaps in their ids, and that their indexes and the named ones exist. All problems
+// found are reported together, so one run tells everything -i has left to do.
+func checkDataset(dataset string, indexNames []string, expected ...expectedNodes) CheckFunc {
+	return func(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+		session := driver.NewSession(neo4j.SessionConfig{
+			AccessMode:   neo4j.AccessModeRead,
+			DatabaseName: dbName,
+		})
+		defer session.Close()
+
+		out.ReportProgress(ProgressReport{Section: "check", Step: dataset, Completeness: 0})
+		var problems []string
+		result, err := session.Run("OPTIONAL MATCH (c:NeobenchInit {dataset: $dataset}) RETURN c IS NOT NULL", map[string]interface{}{
+			"dataset": dataset,
+		})
+		if err != nil {
+			return err
+		}
+		if !result.Next() {
+			return fmt.Errorf("no result looking up init checkpoint of %s: %v", dataset, result.Err())
+		}
+		if interrupted, _ := result.Record().Values[0].(bool); interrupted {
+			problems = append(problems, "an init was interrupted, run -i again to finish it")
+		}
+
+		for _, nodes := range expected {
+			if nodes.perScale == 0 {
+				continue
+			}
+			want := nodes.perScale * scale
+			have, err := existingIds(session, nodes.label, nodes.idProperty)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			if have < want {
+				problems = append(problems, fmt.Sprintf("found %d %s nodes, scale %d needs %d; run -i -s %d", have, nodes.label, scale, want, scale))
+			}
+		}
+
+		result, err = session.Run("SHOW INDEXES YIELD name, labelsOrTypes RETURN name, labelsOrTypes", nil)
+		if err != nil {
+			return fmt.Errorf("failed to list indexes: %s", err)
+		}
+		indexed := make(map[string]bool)
+		for result.Next() {
+			name, _ := result.Record().Values[0].(string)
+			indexed[name] = true
+			for _, nodes := range expected {
+				if schemaOnLabels(result.Record().Values[1], []string{nodes.label}) {
+					indexed[":"+nodes.label] = true
+				}
+			}
+		}
+		if err = result.Err(); err != nil {
+			return err
+		}
+		if !opts.SkipSchema {
+			for _, nodes := range expected {
+				if !indexed[":"+nodes.label] {
+					problems = append(problems, fmt.Sprintf("there is no index on :%s, create it with -i or --init-schema", nodes.label))
+				}
+			}
+		}
+		for _, name := range indexNames {
+			if !indexed[name] {
+				problems = append(problems, fmt.Sprintf("index %s is missing, create it with -i", name))
+			}
+		}
+
+		out.ReportProgress(ProgressReport{Section: "check", Step: dataset, Completeness: 1})
+		if len(problems) > 0 {
+			return fmt.Errorf("dataset %s is incomplete: %s", dataset, strings.Join(problems, "; "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCheckPassesForCompleteDataset(t *testing.T) {
+	session := &checkSession{
+		nodes:   map[string][2]int64{"Document": {30000, 30000}},
+		indexes: [][]interface{}{{"constraint_1", []interface{}{"Document"}}, {FulltextIndexName, []interface{}{"Document"}}},
+	}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := BuiltinChecks["builtin:fulltext"](2, "neo4j", checkDriver{session: session}, DefaultInitOptions, out)
+
+	assert.NoError(t, err)
+}
+
+func TestCheckReportsEveryProblem(t *testing.T) {
+	session := &checkSession{
+		interrupted: true,
+		nodes:       map[string][2]int64{"Branch": {2, 2}, "Teller": {20, 15}, "Account": {150000, 150000}},
+		indexes:     [][]interface{}{{"constraint_1", []interface{}{"Branch"}}},
+	}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := BuiltinChecks["builtin:tpcb-like"](2, "neo4j", checkDriver{session: session}, DefaultInitOptions, out)
+
+	assert.EqualError(t, err, "dataset accounts is incomplete: an init was interrupted, run -i again to finish it; "+
+		"found 15 Teller nodes with ids up to 20, an earlier init was interrupted part way; remove them with --cleanup and run -i again; "+
+		"found 150000 Account nodes, scale 2 needs 200000; run -i -s 2; "+
+		"there is no index on :Teller, create it with -i or --init-schema; "+
+		"there is no index on :Account, create it with -i or --init-schema")
+}
+
+func TestCheckSkipsLabelIndexesWithoutSchema(t *testing.T) {
+	session := &checkSession{nodes: map[string][2]int64{"Chunk": {10000, 10000}}}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := BuiltinChecks["builtin:vector"](1, "neo4j", checkDriver{session: session}, InitOptions{SkipSchema: true}, out)
+
+	// The vector index is needed by the queries, so it is checked either way
+	assert.EqualError(t, err, "dataset vector is incomplete: index neobenchEmbeddings is missing, create it with -i")
+}
+
+type checkDriver struct {
+	neo4j.Driver
+	session *checkSession
+}
+
+func (d checkDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return d.session
+}
+
+// Answers the queries a check runs from the highest id and count of nodes of each label, and listed indexes
+type checkSession struct {
+	neo4j.Session
+	interrupted bool
+	nodes       map[string][2]int64
+	indexes     [][]interface{}
+}
+
+func (s *checkSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	switch {
+	case strings.Contains(cypher, "NeobenchInit"):
+		return &rowResult{rows: [][]interface{}{{s.interrupted}}, next: -1}, nil
+	case strings.Contains(cypher, "SHOW INDEXES"):
+		return &rowResult{rows: s.indexes, next: -1}, nil
+	}
+	for label, nodes := range s.nodes {
+		if strings.Contains(cypher, ":`"+label+"`)") {
+			return &rowResult{rows: [][]interface{}{{nodes[0], nodes[1]}}, next: -1}, nil
+		}
+	}
+	return &rowResult{rows: [][]interface{}{{int64(0), int64(0)}}, next: -1}, nil
+}
+
+func (s *checkSession) Close() error {
+	return nil
+}
@@ -0,0 +1,154 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"sort"
+)
+
+// Progress of the init of a builtin dataset, kept in the database itself so that an init that is interrupted, even
+// one that left gaps by running batches in parallel, picks up where it stopped when -i is run again. A
+// :NeobenchInit node remembers what the init saw when it first started, like how many nodes already existed, so a
+// resumed init works through the same ranges; a :NeobenchInitBatch node, written in the same transaction as the
+// batch itself, records each batch that committed. Both are removed once the init completes.
+type initCheckpoint struct {
+	dataset string
+	// True if an earlier init of the dataset was interrupted, and this one carries on from it
+	resumed bool
+}
+
+// Starts tracking the init of dataset, or resumes tracking an earlier, interrupted one
+func startCheckpoint(session neo4j.Session, dataset string, out Output) (*initCheckpoint, error) {
+	result, err := session.Run("OPTIONAL MATCH (c:NeobenchInit {dataset: $dataset}) RETURN c IS NOT NULL", map[string]interface{}{
+		"dataset": dataset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !result.Next() {
+		return nil, fmt.Errorf("no result looking up init checkpoint of %s: %v", dataset, result.Err())
+	}
+	c := &initCheckpoint{dataset: dataset, resumed: result.Record().Values[0].(bool)}
+	if c.resumed {
+		out.ReportProgress(ProgressReport{Section: "init", Step: "resume interrupted init", Completeness: 0})
+		return c, nil
+	}
+	_, err = session.Run("CREATE (:NeobenchInit {dataset: $dataset, started: timestamp()})", map[string]interface{}{
+		"dataset": dataset,
+	})
+	return c, err
+}
+
+// Returns the value remembered under key, or if there is none yet, computes it with value and remembers it
+func (c *initCheckpoint) remember(session neo4j.Session, key string, value func() (int64, error)) (int64, error) {
+	result, err := session.Run("MATCH (c:NeobenchInit {dataset: $dataset}) RETURN c[$key]", map[string]interface{}{
+		"dataset": c.dataset,
+		"key":     key,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if result.Next() {
+		if remembered, ok := result.Record().Values[0].(int64); ok {
+			return remembered, nil
+		}
+	} else if err = result.Err(); err != nil {
+		return 0, err
+	}
+	v, err := value()
+	if err != nil {
+		return 0, err
+	}
+	_, err = session.Run("MATCH (c:NeobenchInit {dataset: $dataset}) SET c += $values", map[string]interface{}{
+		"dataset": c.dataset,
+		"values":  map[string]interface{}{key: v},
+	})
+	return v, err
+}
+
+// Like existingIds, but as of when the init first started, so a resumed init ignores what its earlier run created
+func (c *initCheckpoint) existingIds(session neo4j.Session, label, idProperty string) (int64, error) {
+	return c.remember(session, "existing"+label, func() (int64, error) {
+		return existingIds(session, label, idProperty)
+	})
+}
+
+// Ranges of ids, inclusive, that batches of step committed before the init was interrupted
+func (c *initCheckpoint) committedBatches(session neo4j.Session, step string) ([][2]int64, error) {
+	if !c.resumed {
+		return nil, nil
+	}
+	result, err := session.Run("MATCH (b:NeobenchInitBatch {dataset: $dataset, step: $step}) RETURN b.start, b.end", map[string]interface{}{
+		"dataset": c.dataset,
+		"step":    step,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var committed [][2]int64
+	for result.Next() {
+		committed = append(committed, [2]int64{result.Record().Values[0].(int64), result.Record().Values[1].(int64)})
+	}
+	return committed, result.Err()
+}
+
+// Records, as part of tx, that the batch of step from start to end committed
+func (c *initCheckpoint) recordBatch(tx neo4j.Transaction, step string, start, end int64) error {
+	result, err := tx.Run("CREATE (:NeobenchInitBatch {dataset: $dataset, step: $step, start: $start, end: $end})", map[string]interface{}{
+		"dataset": c.dataset,
+		"step":    step,
+		"start":   start,
+		"end":     end,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume()
+	return err
+}
+
+// Removes the checkpoint, once the init has completed
+func (c *initCheckpoint) finish(session neo4j.Session) error {
+	return clearCheckpoint(session, c.dataset)
+}
+
+// Deletes the checkpoint nodes of dataset, in batches since an interrupted init at a large scale leaves many
+func clearCheckpoint(session neo4j.Session, dataset string) error {
+	for {
+		result, err := session.Run(`MATCH (n) WHERE (n:NeobenchInit OR n:NeobenchInitBatch) AND n.dataset = $dataset
+WITH n LIMIT 10000 DETACH DELETE n RETURN count(*)`, map[string]interface{}{
+			"dataset": dataset,
+		})
+		if err != nil {
+			return err
+		}
+		if !result.Next() {
+			return fmt.Errorf("deleting init checkpoint of %s returned no count: %v", dataset, result.Err())
+		}
+		if result.Record().Values[0].(int64) == 0 {
+			return nil
+		}
+	}
+}
+
+// Splits start to end, inclusive, into ranges of at most batchSize ids, leaving out the ids in committed
+func remainingBatches(start, end, batchSize int64, committed [][2]int64) [][2]int64 {
+	sort.Slice(committed, func(i, j int) bool {
+		return committed[i][0] < committed[j][0]
+	})
+	var batches [][2]int64
+	next := start
+	addUpTo := func(last int64) {
+		for next <= last {
+			batchEnd := min(last, next+batchSize-1)
+			batches = append(batches, [2]int64{next, batchEnd})
+			next = batchEnd + 1
+		}
+	}
+	for _, done := range committed {
+		addUpTo(min(end, done[0]-1))
+		next = max(next, done[1]+1)
+	}
+	addUpTo(end)
+	return batches
+}
@@ -0,0 +1,25 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRemainingBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		committed [][2]int64
+		expected  [][2]int64
+	}{
+		{"nothing committed", nil, [][2]int64{{1, 4}, {5, 8}, {9, 10}}},
+		{"everything committed", [][2]int64{{1, 4}, {5, 8}, {9, 10}}, nil},
+		{"gaps left by parallel batches", [][2]int64{{9, 10}, {1, 4}}, [][2]int64{{5, 8}}},
+		{"batch size changed since", [][2]int64{{1, 3}, {5, 5}}, [][2]int64{{4, 4}, {6, 9}, {10, 10}}},
+		{"committed past a smaller scale", [][2]int64{{1, 4}, {9, 20}}, [][2]int64{{5, 8}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, remainingBatches(1, 10, 4, tt.committed))
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"strings"
+)
+
+// Removes what the init of a builtin workload created, see BuiltinCleanups
+type CleanupFunc func(dbName string, driver neo4j.Driver, out Output) error
+
+// Cleanup of each builtin workload, by the name given to -w. Builtins that share a dataset, like tpcb-like and
+// match-only, remove all of it.
+var BuiltinCleanups = map[string]CleanupFunc{
+	"builtin:tpcb-like":    cleanupLabels("accounts", "Branch", "Teller", "Account", "History"),
+	"builtin:match-only":   cleanupLabels("accounts", "Branch", "Teller", "Account", "History"),
+	"builtin:aggregation":  cleanupLabels("accounts", "Branch", "Teller", "Account", "History"),
+	"builtin:ldbc-snb":     cleanupLabels("ldbc-snb", "Person", "City", "Forum", "Message"),
+	"builtin:insert-heavy": cleanupLabels("insert-heavy", "Device", "Reading"),
+	"builtin:supernode":    cleanupLabels("supernode", "Item"),
+	"builtin:gds": func(dbName string, driver neo4j.Driver, out Output) error {
+		if err := dropGdsGraph(dbName, driver); err != nil {
+			return err
+		}
+		return cleanupLabels("gds", "Page")(dbName, driver, out)
+	},
+	"builtin:fulltext":   cleanupLabels("fulltext", "Document"),
+	"builtin:vector":     cleanupLabels("vector", "Chunk"),
+	"builtin:traversal":  cleanupLabels("traversal", "Place"),
+	"builtin:merge":      cleanupLabels("merge", "Customer"),
+	"builtin:pagination": cleanupLabels("pagination", "Article"),
+}
+
+// Deletes every node with one of labels, in batches so no one transaction gets too large, then drops the
+// constraints and indexes on them, and the checkpoint of an interrupted init of dataset
+func cleanupLabels(dataset string, labels ...string) CleanupFunc {
+	return func(dbName string, driver neo4j.Driver, out Output) error {
+		session := driver.NewSession(neo4j.SessionConfig{
+			AccessMode:   neo4j.AccessModeWrite,
+			DatabaseName: dbName,
+		})
+		defer session.Close()
+
+		for i, label := range labels {
+			out.ReportProgress(ProgressReport{
+				Section:      "cleanup",
+				Step:         fmt.Sprintf("delete :%s nodes", label),
+				Completeness: float64(i) / float64(len(labels)),
+			})
+			for {
+				result, err := session.Run(fmt.Sprintf("MATCH (n:`%s`) WITH n LIMIT 10000 DETACH DELETE n RETURN count(*)", label), nil)
+				if err != nil {
+					return err
+				}
+				if !result.Next() {
+					return fmt.Errorf("deleting :%s nodes returned no count", label)
+				}
+				if result.Record().Values[0].(int64) == 0 {
+					break
+				}
+			}
+		}
+
+		out.ReportProgress(ProgressReport{
+			Section:      "cleanup",
+			Step:         "drop constraints and indexes",
+			Completeness: 1,
+		})
+		// Constraints go first, since the indexes backing them can't be dropped on their own
+		constraints, err := schemaNames(session, "CALL db.constraints() YIELD name, description RETURN name, description", labels)
+		if err != nil {
+			return err
+		}
+		for _, name := range constraints {
+			if _, err = session.Run(fmt.Sprintf("DROP CONSTRAINT `%s`", name), nil); err != nil {
+				return err
+			}
+		}
+		indexes, err := schemaNames(session, "CALL db.indexes() YIELD name, labelsOrTypes RETURN name, labelsOrTypes", labels)
+		if err != nil {
+			return err
+		}
+		for _, name := range indexes {
+			if _, err = session.Run(fmt.Sprintf("DROP INDEX `%s`", name), nil); err != nil {
+				return err
+			}
+		}
+		return clearCheckpoint(session, dataset)
+	}
+}
+
+// Names of the schema items listed by query that are on one of labels. query returns the name and either a
+// description, like "CONSTRAINT ON ( a:Account ) ASSERT (a.aid) IS UNIQUE", or a list of labels.
+func schemaNames(session neo4j.Session, query string, labels []string) ([]string, error) {
+	result, err := session.Run(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for result.Next() {
+		name, _ := result.Record().Values[0].(string)
+		if schemaOnLabels(result.Record().Values[1], labels) {
+			names = append(names, name)
+		}
+	}
+	return names, result.Err()
+}
+
+func schemaOnLabels(on interface{}, labels []string) bool {
+	for _, label := range labels {
+		switch on := on.(type) {
+		case string:
+			if strings.Contains(on, ":"+label+" ") || strings.Contains(on, ":"+label+")") {
+				return true
+			}
+		case []interface{}:
+			for _, other := range on {
+				if other == label {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func dropGdsGraph(dbName string, driver neo4j.Driver) error {
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	result, err := session.Run("CALL gds.graph.exists($name) YIELD exists RETURN exists", map[string]interface{}{
+		"name": GdsGraphName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check the graph catalog, is the Graph Data Science library installed? %s", err)
+	}
+	result.Next()
+	if exists, _ := result.Record().Values[0].(bool); !exists {
+		return nil
+	}
+	_, err = session.Run("CALL gds.graph.drop($name)", map[string]interface{}{"name": GdsGraphName})
+	return err
+}
@@ -0,0 +1,98 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCleanupDeletesInBatchesThenDropsSchema(t *testing.T) {
+	session := &schemaSession{
+		deleteCounts: []int64{10000, 42, 0, 0},
+		constraints: [][]interface{}{
+			{"constraint_1", "CONSTRAINT ON ( item:Item ) ASSERT (item.id) IS UNIQUE"},
+			{"constraint_2", "CONSTRAINT ON ( other:Other ) ASSERT (other.id) IS UNIQUE"},
+		},
+		indexes: [][]interface{}{
+			{"index_1", []interface{}{"Item"}},
+			{"index_2", []interface{}{"Other"}},
+		},
+	}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := BuiltinCleanups["builtin:supernode"]("neo4j", schemaDriver{session: session}, out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, session.deletes)
+	assert.Equal(t, []string{"DROP CONSTRAINT `constraint_1`", "DROP INDEX `index_1`"}, session.dropped)
+}
+
+func TestSchemaOnLabels(t *testing.T) {
+	assert.True(t, schemaOnLabels("CONSTRAINT ON ( a:Account ) ASSERT (a.aid) IS UNIQUE", []string{"Account"}))
+	assert.False(t, schemaOnLabels("CONSTRAINT ON ( a:AccountHistory ) ASSERT (a.aid) IS UNIQUE", []string{"Account"}))
+	assert.True(t, schemaOnLabels([]interface{}{"Document"}, []string{"Page", "Document"}))
+	assert.False(t, schemaOnLabels([]interface{}{"Document"}, []string{"Page"}))
+}
+
+type schemaDriver struct {
+	neo4j.Driver
+	session *schemaSession
+}
+
+func (d schemaDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return d.session
+}
+
+// Answers the queries cleanup runs from canned rows, and records what it drops
+type schemaSession struct {
+	neo4j.Session
+	deleteCounts []int64
+	deletes      int
+	constraints  [][]interface{}
+	indexes      [][]interface{}
+	dropped      []string
+}
+
+func (s *schemaSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	switch {
+	case strings.Contains(cypher, "DETACH DELETE"):
+		count := s.deleteCounts[s.deletes]
+		s.deletes++
+		return &rowResult{rows: [][]interface{}{{count}}, next: -1}, nil
+	case strings.Contains(cypher, "db.constraints"):
+		return &rowResult{rows: s.constraints, next: -1}, nil
+	case strings.Contains(cypher, "db.indexes"):
+		return &rowResult{rows: s.indexes, next: -1}, nil
+	}
+	s.dropped = append(s.dropped, cypher)
+	return consumedResult{}, nil
+}
+
+func (s *schemaSession) Close() error {
+	return nil
+}
+
+type rowResult struct {
+	neo4j.Result
+	rows [][]interface{}
+	next int
+}
+
+func (r *rowResult) Next() bool {
+	r.next++
+	return r.next < len(r.rows)
+}
+
+func (r *rowResult) Record() *neo4j.Record {
+	return &neo4j.Record{Values: r.rows[r.next]}
+}
+
+func (r *rowResult) Err() error {
+	return nil
+}
+
+func (r *rowResult) Consume() (neo4j.ResultSummary, error) {
+	r.next = len(r.rows)
+	return nil, nil
+}
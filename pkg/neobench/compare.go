@@ -0,0 +1,170 @@
+package neobench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Two-sided 95% confidence; differences with a larger test statistic are flagged as significant
+const significanceZ = 1.96
+
+// Loads a saved result for comparison. Source is either a file written by -o json, or a --results-sqlite
+// file optionally followed by #<run id>; without a run id, the latest run in the file is used.
+func LoadReport(source string) (JsonReport, error) {
+	path, runId := source, int64(0)
+	if i := strings.LastIndex(source, "#"); i >= 0 {
+		id, err := strconv.ParseInt(source[i+1:], 10, 64)
+		if err == nil {
+			path, runId = source[:i], id
+		}
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return JsonReport{}, fmt.Errorf("failed to read result file: %s", err)
+	}
+	if bytes.HasPrefix(content, []byte("SQLite format 3\x00")) {
+		return LoadSqliteReport(path, runId)
+	}
+	if runId != 0 {
+		return JsonReport{}, fmt.Errorf("%s is not a sqlite file, run ids can only be used with --results-sqlite files", path)
+	}
+	var report JsonReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		return JsonReport{}, fmt.Errorf("%s is neither a sqlite file nor a valid -o json report: %s", path, err)
+	}
+	return report, nil
+}
+
+// Outcome of comparing one metric between the base and the new result
+type MetricComparison struct {
+	Name     string
+	Base     float64
+	New      float64
+	Delta    float64
+	Verdict  string
+	Worsened bool
+}
+
+const (
+	VerdictImproved  = "improved"
+	VerdictRegressed = "regressed"
+	VerdictNoChange  = ""
+)
+
+// Compares each script present in both reports: throughput and mean latency changes are flagged if they
+// are statistically significant, percentiles are flagged if they move by more than threshold percent,
+// since we don't have the full distributions to test them with.
+func CompareReports(base, other JsonReport, threshold float64) map[string][]MetricComparison {
+	out := make(map[string][]MetricComparison)
+	baseDuration := base.End.Sub(base.Start).Seconds()
+	otherDuration := other.End.Sub(other.Start).Seconds()
+	for _, a := range base.Scripts {
+		for _, b := range other.Scripts {
+			if a.Name != b.Name {
+				continue
+			}
+			var metrics []MetricComparison
+
+			rate := newMetricComparison("rate (tx/s)", a.Rate, b.Rate, false)
+			if baseDuration > 0 && otherDuration > 0 {
+				// Transaction counts are treated as poisson, so the variance of a rate r = n/t is n/t^2
+				variance := float64(a.Succeeded+a.Failed)/(baseDuration*baseDuration) +
+					float64(b.Succeeded+b.Failed)/(otherDuration*otherDuration)
+				rate.flag(variance > 0 && math.Abs(b.Rate-a.Rate)/math.Sqrt(variance) > significanceZ)
+			}
+			metrics = append(metrics, rate)
+
+			mean := newMetricComparison("mean (ms)", a.Latency.Mean, b.Latency.Mean, true)
+			if a.Latency.Count > 1 && b.Latency.Count > 1 {
+				// Welch's t-test; with the sample sizes we get from benchmarks, t is close enough to normal
+				variance := a.Latency.StdDev*a.Latency.StdDev/float64(a.Latency.Count) +
+					b.Latency.StdDev*b.Latency.StdDev/float64(b.Latency.Count)
+				mean.flag(variance > 0 && math.Abs(b.Latency.Mean-a.Latency.Mean)/math.Sqrt(variance) > significanceZ)
+			}
+			metrics = append(metrics, mean)
+
+			for _, pa := range a.Latency.Percentiles {
+				for _, pb := range b.Latency.Percentiles {
+					if pa.Percentile != pb.Percentile {
+						continue
+					}
+					p := newMetricComparison(fmt.Sprintf("p%s (ms)", strconv.FormatFloat(pa.Percentile, 'f', -1, 64)), pa.Value, pb.Value, true)
+					p.flag(math.Abs(p.Delta) > threshold)
+					metrics = append(metrics, p)
+				}
+			}
+			out[a.Name] = metrics
+		}
+	}
+	return out
+}
+
+func newMetricComparison(name string, base, new float64, higherIsWorse bool) MetricComparison {
+	delta := 0.0
+	if base != 0 {
+		delta = 100 * (new - base) / base
+	}
+	return MetricComparison{
+		Name:     name,
+		Base:     base,
+		New:      new,
+		Delta:    delta,
+		Worsened: (new > base) == higherIsWorse && new != base,
+	}
+}
+
+func (m *MetricComparison) flag(significant bool) {
+	if !significant {
+		m.Verdict = VerdictNoChange
+	} else if m.Worsened {
+		m.Verdict = VerdictRegressed
+	} else {
+		m.Verdict = VerdictImproved
+	}
+}
+
+// Writes a table per script comparing the two reports; returns true if any metric regressed
+func WriteComparison(w io.Writer, baseName string, base JsonReport, otherName string, other JsonReport, threshold float64) (regressed bool, err error) {
+	s := strings.Builder{}
+	s.WriteString(fmt.Sprintf("Comparing %s (base) with %s (new)\n", baseName, otherName))
+	if base.Scenario != other.Scenario {
+		s.WriteString(fmt.Sprintf("  Warning, the scenarios differ:\n    base: %s\n    new:  %s\n",
+			strings.TrimSpace(base.Scenario), strings.TrimSpace(other.Scenario)))
+	}
+	s.WriteString("\n")
+
+	comparisons := CompareReports(base, other, threshold)
+	for _, script := range base.Scripts {
+		metrics, found := comparisons[script.Name]
+		if !found {
+			s.WriteString(fmt.Sprintf("Script %s is only in %s\n\n", script.Name, baseName))
+			continue
+		}
+		s.WriteString(fmt.Sprintf("Script %s:\n", script.Name))
+		tw := tabwriter.NewWriter(&s, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "  metric\tbase\tnew\tdelta\t\n")
+		for _, m := range metrics {
+			_, _ = fmt.Fprintf(tw, "  %s\t%.3f\t%.3f\t%+.2f%%\t%s\n", m.Name, m.Base, m.New, m.Delta, m.Verdict)
+			if m.Verdict == VerdictRegressed {
+				regressed = true
+			}
+		}
+		_ = tw.Flush()
+		s.WriteString("\n")
+	}
+	for _, script := range other.Scripts {
+		if _, found := comparisons[script.Name]; !found {
+			s.WriteString(fmt.Sprintf("Script %s is only in %s\n\n", script.Name, otherName))
+		}
+	}
+	s.WriteString(fmt.Sprintf("Rate and mean changes are flagged if significant at 95%% confidence, percentiles if they move more than %.1f%%\n", threshold))
+	_, err = fmt.Fprint(w, s.String())
+	return regressed, err
+}
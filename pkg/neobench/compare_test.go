@@ -0,0 +1,47 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareReports(t *testing.T) {
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	report := func(rate, mean, p99 float64) JsonReport {
+		count := int64(rate * 60)
+		return JsonReport{
+			Start: start,
+			End:   start.Add(time.Minute),
+			Scripts: []JsonScriptReport{{
+				Name:      "read",
+				Rate:      rate,
+				Succeeded: count,
+				Latency: JsonLatencyReport{
+					Count:       count,
+					Mean:        mean,
+					StdDev:      1,
+					Percentiles: []JsonPercentileReport{{Percentile: 99, Value: p99}},
+				},
+			}},
+		}
+	}
+
+	comparison := CompareReports(report(1000, 2, 10), report(900, 2.5, 10.2), 5)["read"]
+
+	assert.Equal(t, "rate (tx/s)", comparison[0].Name)
+	assert.InDelta(t, -10, comparison[0].Delta, 0.001)
+	assert.Equal(t, VerdictRegressed, comparison[0].Verdict)
+	assert.Equal(t, "mean (ms)", comparison[1].Name)
+	assert.Equal(t, VerdictRegressed, comparison[1].Verdict)
+	// A 2% move in a percentile is below the threshold
+	assert.Equal(t, "p99 (ms)", comparison[2].Name)
+	assert.Equal(t, VerdictNoChange, comparison[2].Verdict)
+
+	out := strings.Builder{}
+	regressed, err := WriteComparison(&out, "a.json", report(1000, 2, 10), "b.json", report(1100, 1.5, 8), 5)
+	assert.NoError(t, err)
+	assert.False(t, regressed)
+	assert.Contains(t, out.String(), "improved")
+}
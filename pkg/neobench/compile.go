@@ -0,0 +1,165 @@
+package neobench
+
+import "fmt"
+
+// An expression compiled to a closure, see compile
+type evalFunc func(ctx *ScriptContext) (interface{}, error)
+
+// The same for numeric expressions, so operators on them pass numbers along without boxing each in an interface
+type numberFunc func(ctx *ScriptContext) (Number, error)
+
+// Builtins that give the same result for the same arguments, so calls with constant arguments can be evaluated
+// once, when compiling
+var pureFunctions = map[string]bool{
+	"abs": true, "int": true, "double": true, "greatest": true, "least": true, "pi": true, "sqrt": true,
+	"+": true, "-": true, "*": true, "/": true,
+}
+
+// Compiles e to closures that evaluate it the same way, so each transaction doesn't walk the syntax tree and
+// dispatch on function names again: operators and random() get closures of their own that keep intermediate
+// results unboxed, registered functions are looked up once, and calls of pure builtins with constant arguments
+// are replaced by their result
+func compile(e Expression) Expression {
+	switch e.Kind {
+	case intExpr, floatExpr:
+		value := e.Payload
+		number, _ := toNumber(value)
+		e.eval = func(*ScriptContext) (interface{}, error) {
+			return value, nil
+		}
+		e.number = func(*ScriptContext) (Number, error) {
+			return number, nil
+		}
+	case varExpr:
+		name := e.Payload.(string)
+		e.eval = func(ctx *ScriptContext) (interface{}, error) {
+			value, found := ctx.Vars[name]
+			if !found {
+				return nil, fmt.Errorf("this variable is not defined: %s", name)
+			}
+			return value, nil
+		}
+	case callExpr:
+		call := e.Payload.(CallExpr)
+		args := make([]Expression, len(call.args))
+		constant := true
+		for i, arg := range call.args {
+			args[i] = compile(arg)
+			constant = constant && (args[i].Kind == intExpr || args[i].Kind == floatExpr)
+		}
+		call = CallExpr{name: call.name, args: args}
+		e.Payload = call
+		if constant && pureFunctions[call.name] {
+			// Errors are left for when the script runs, where they are reported as they always were
+			if value, err := call.Eval(nil); err == nil {
+				switch v := value.(type) {
+				case int64:
+					return compile(Int(v))
+				case float64:
+					return compile(Float(v))
+				}
+			}
+		}
+		if e.number = compileNumericCall(call); e.number != nil {
+			e.eval = e.number.boxed()
+		} else {
+			e.eval = compileCall(call)
+		}
+	}
+	return e
+}
+
+func compileNumericCall(call CallExpr) numberFunc {
+	if len(call.args) != 2 {
+		return nil
+	}
+	switch call.name {
+	case "+":
+		return arithmetic(call, func(a, b int64) int64 { return a + b }, func(a, b float64) float64 { return a + b })
+	case "-":
+		return arithmetic(call, func(a, b int64) int64 { return a - b }, func(a, b float64) float64 { return a - b })
+	case "*":
+		return arithmetic(call, func(a, b int64) int64 { return a * b }, func(a, b float64) float64 { return a * b })
+	case "/":
+		return arithmetic(call, nil, func(a, b float64) float64 { return a / b })
+	case "random":
+		lbArg, ubArg := call.args[0], call.args[1]
+		return func(ctx *ScriptContext) (Number, error) {
+			lb, err := evalNumber(ctx, lbArg)
+			if err != nil {
+				return Number{}, fmt.Errorf("in %s: %s", call.String(), err)
+			}
+			ub, err := evalNumber(ctx, ubArg)
+			if err != nil {
+				return Number{}, fmt.Errorf("in %s: %s", call.String(), err)
+			}
+			if lb.isDouble || ub.isDouble {
+				return Number{}, fmt.Errorf("interval for random() must be integers, not doubles, in %s", call.String())
+			}
+			if lb.iVal == ub.iVal {
+				return lb, nil
+			}
+			return intNumber(lb.iVal + ctx.Rand.Int63n(ub.iVal-lb.iVal)), nil
+		}
+	}
+	return nil
+}
+
+// Evaluates to the int64 or float64 f gives
+func (f numberFunc) boxed() evalFunc {
+	return func(ctx *ScriptContext) (interface{}, error) {
+		n, err := f(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if n.isDouble {
+			return n.val, nil
+		}
+		return n.iVal, nil
+	}
+}
+
+func compileCall(call CallExpr) evalFunc {
+	switch {
+	case !builtinFunctions[call.name]:
+		// Functions can't be unregistered, so once found, this is the one every call would find
+		if custom, found := lookupFunction(call.name); found {
+			return func(ctx *ScriptContext) (interface{}, error) {
+				args := make([]interface{}, len(call.args))
+				for i, arg := range call.args {
+					value, err := arg.Eval(ctx)
+					if err != nil {
+						return nil, err
+					}
+					args[i] = value
+				}
+				value, err := custom(ctx, args)
+				if err != nil {
+					return nil, fmt.Errorf("in %s: %s", call.String(), err)
+				}
+				return value, nil
+			}
+		}
+	}
+	// The other builtins are called less often than operators, and still get their arguments compiled
+	return call.Eval
+}
+
+// Closure for a binary operator; intOp is used when both sides are integers, unless it's nil
+func arithmetic(call CallExpr, intOp func(a, b int64) int64, floatOp func(a, b float64) float64) numberFunc {
+	lhs, rhs := call.args[0], call.args[1]
+	return func(ctx *ScriptContext) (Number, error) {
+		a, err := evalNumber(ctx, lhs)
+		if err != nil {
+			return Number{}, fmt.Errorf("in %s: %s", call.String(), err)
+		}
+		b, err := evalNumber(ctx, rhs)
+		if err != nil {
+			return Number{}, fmt.Errorf("in %s: %s", call.String(), err)
+		}
+		if intOp == nil || a.isDouble || b.isDouble {
+			return Number{isDouble: true, val: floatOp(a.val, b.val)}, nil
+		}
+		return intNumber(intOp(a.iVal, b.iVal)), nil
+	}
+}
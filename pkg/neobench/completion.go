@@ -0,0 +1,116 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"io"
+	"strings"
+)
+
+// Values we know up front for flags that take an enumerated argument; used to
+// give useful completions rather than just flag names.
+var completionValues = map[string][]string{
+	"workload":     {"builtin:tpcb-like", "builtin:match-only"},
+	"output":       {"auto", "interactive", "dashboard", "csv", "json", "html"},
+	"encryption":   {"auto", "true", "false"},
+	"latency-unit": {"ms", "us"},
+	"arrival":      {"uniform", "poisson"},
+}
+
+// Writes a shell completion script for the given shell to w, generated from the registered flags
+func WriteCompletion(w io.Writer, flags *pflag.FlagSet, shell string) error {
+	switch shell {
+	case "bash":
+		return writeBashCompletion(w, flags)
+	case "zsh":
+		return writeZshCompletion(w, flags)
+	case "fish":
+		return writeFishCompletion(w, flags)
+	default:
+		return fmt.Errorf("unknown shell for completion: '%s', supported shells are 'bash', 'zsh' and 'fish'", shell)
+	}
+}
+
+func writeBashCompletion(w io.Writer, flags *pflag.FlagSet) error {
+	var opts []string
+	var cases strings.Builder
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		names := []string{"--" + f.Name}
+		if f.Shorthand != "" {
+			names = append(names, "-"+f.Shorthand)
+		}
+		opts = append(opts, names...)
+		if values, ok := completionValues[f.Name]; ok {
+			cases.WriteString(fmt.Sprintf("    %s)\n      COMPREPLY=($(compgen -W \"%s\" -- \"${cur}\"))\n      return 0\n      ;;\n",
+				strings.Join(names, "|"), strings.Join(values, " ")))
+		}
+	})
+
+	_, err := fmt.Fprintf(w, `# bash completion for neobench
+_neobench() {
+  local cur prev
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  prev="${COMP_WORDS[COMP_CWORD-1]}"
+  case "${prev}" in
+%s  esac
+  COMPREPLY=($(compgen -W "%s" -- "${cur}"))
+}
+complete -o default -F _neobench neobench
+`, cases.String(), strings.Join(opts, " "))
+	return err
+}
+
+func writeZshCompletion(w io.Writer, flags *pflag.FlagSet) error {
+	var args strings.Builder
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		usage := strings.NewReplacer("[", "\\[", "]", "\\]", "'", "'\\''", ":", "\\:").Replace(f.Usage)
+		action := ""
+		if values, ok := completionValues[f.Name]; ok {
+			action = fmt.Sprintf(":%s:(%s)", f.Name, strings.Join(values, " "))
+		} else if f.Value.Type() != "bool" {
+			action = fmt.Sprintf(":%s:", f.Name)
+		}
+		if f.Shorthand != "" {
+			args.WriteString(fmt.Sprintf("    '(-%s --%s)'{-%s,--%s}'[%s]%s' \\\n", f.Shorthand, f.Name, f.Shorthand, f.Name, usage, action))
+		} else {
+			args.WriteString(fmt.Sprintf("    '--%s[%s]%s' \\\n", f.Name, usage, action))
+		}
+	})
+
+	_, err := fmt.Fprintf(w, `#compdef neobench
+_neobench() {
+  _arguments \
+%s    '1:database name:'
+}
+compdef _neobench neobench
+`, args.String())
+	return err
+}
+
+func writeFishCompletion(w io.Writer, flags *pflag.FlagSet) error {
+	var s strings.Builder
+	s.WriteString("# fish completion for neobench\n")
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		s.WriteString(fmt.Sprintf("complete -c neobench -l %s", f.Name))
+		if f.Shorthand != "" {
+			s.WriteString(fmt.Sprintf(" -s %s", f.Shorthand))
+		}
+		if values, ok := completionValues[f.Name]; ok {
+			s.WriteString(fmt.Sprintf(" -x -a '%s'", strings.Join(values, " ")))
+		} else if f.Value.Type() != "bool" {
+			s.WriteString(" -r")
+		}
+		s.WriteString(fmt.Sprintf(" -d '%s'\n", strings.ReplaceAll(f.Usage, "'", "\\'")))
+	})
+	_, err := fmt.Fprint(w, s.String())
+	return err
+}
@@ -0,0 +1,37 @@
+package neobench
+
+import (
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCompletionCoversVisibleFlagsAndKnownValues(t *testing.T) {
+	flags := pflag.NewFlagSet("neobench", pflag.ContinueOnError)
+	flags.StringP("output", "o", "auto", "output format")
+	flags.Bool("init", false, "run the init script")
+	flags.String("completion", "", "generate completion")
+	assert.NoError(t, flags.MarkHidden("completion"))
+
+	bash := strings.Builder{}
+	assert.NoError(t, WriteCompletion(&bash, flags, "bash"))
+	assert.Contains(t, bash.String(), "    --output|-o)\n      COMPREPLY=($(compgen -W \"auto interactive dashboard csv json html\" -- \"${cur}\"))")
+	assert.Contains(t, bash.String(), `COMPREPLY=($(compgen -W "--init --output -o" -- "${cur}"))`)
+
+	zsh := strings.Builder{}
+	assert.NoError(t, WriteCompletion(&zsh, flags, "zsh"))
+	assert.Contains(t, zsh.String(), "    '--init[run the init script]' \\\n")
+	assert.Contains(t, zsh.String(), "    '(-o --output)'{-o,--output}'[output format]:output:(auto interactive dashboard csv json html)' \\\n")
+
+	fish := strings.Builder{}
+	assert.NoError(t, WriteCompletion(&fish, flags, "fish"))
+	assert.Contains(t, fish.String(), "complete -c neobench -l init -d 'run the init script'\n")
+	assert.Contains(t, fish.String(), "complete -c neobench -l output -s o -x -a 'auto interactive dashboard csv json html' -d 'output format'\n")
+
+	// Hidden flags are left out
+	assert.NotContains(t, bash.String(), "--completion")
+	assert.NotContains(t, zsh.String(), "--completion")
+	assert.NotContains(t, fish.String(), "-l completion")
+	assert.EqualError(t, WriteCompletion(&strings.Builder{}, flags, "powershell"), "unknown shell for completion: 'powershell', supported shells are 'bash', 'zsh' and 'fish'")
+}
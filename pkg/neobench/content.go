@@ -0,0 +1,215 @@
+package neobench
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Generates realistic property values for the nodes init creates: names, text and numbers drawn from skewed
+// distributions, so property sizes and cardinalities, and with them store size and page cache behaviour, resemble
+// production data rather than "Post 17". Values are derived from the seed and the id of each node alone, so the same
+// seed and scale produce the same dataset however batches are split across --init-workers.
+type ContentGenerator struct {
+	Seed int64
+}
+
+// Property values for each node of a batch, by id; loadBatches passes them as a list parameter with one entry for
+// each id of the batch, starting at $start. A nil batchContent is passed as null, so queries overlay it with
+// SET n += coalesce($content[id - $start], {}).
+type batchContent func(id int64) map[string]interface{}
+
+// The list parameter for the ids start to end, or nil without content
+func (c batchContent) batch(start, end int64) interface{} {
+	if c == nil {
+		return nil
+	}
+	rows := make([]interface{}, 0, end-start+1)
+	for id := start; id <= end; id++ {
+		rows = append(rows, c(id))
+	}
+	return rows
+}
+
+// Dates of generated content fall in the years after this
+var contentEpoch = time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+
+const contentYearMillis = 365 * 24 * 60 * 60 * 1000
+
+var firstNames = []string{
+	"James", "Mary", "Wei", "Maria", "Mohammed", "Anna", "John", "Fatima", "David", "Olga", "Carlos", "Yuki",
+	"Michael", "Elena", "Ahmed", "Sofia", "Robert", "Priya", "Luis", "Emma", "Chen", "Aisha", "Daniel", "Ingrid",
+	"Raj", "Laura", "Juan", "Hana", "Peter", "Amara", "Ivan", "Chloe", "Kenji", "Lucia", "Omar", "Freya",
+	"Thomas", "Mei", "Diego", "Noor",
+}
+
+var lastNames = []string{
+	"Smith", "Wang", "Garcia", "Müller", "Kim", "Nguyen", "Johnson", "Silva", "Ivanov", "Patel", "Brown", "Rossi",
+	"Li", "Hernandez", "Kowalski", "Sato", "Jones", "Andersson", "Martin", "Khan", "Lopez", "Novak", "Williams",
+	"Dubois", "Singh", "Tanaka", "Miller", "Jensen", "Okafor", "Costa", "Davis", "Yilmaz", "Wilson", "Nakamura",
+	"Fischer", "Hansen", "Moore", "Kaur", "Taylor", "Schmidt",
+}
+
+var cities = []string{
+	"London", "Berlin", "Stockholm", "New York", "Tokyo", "São Paulo", "Mumbai", "Shanghai", "Paris", "Madrid",
+	"Malmö", "Toronto", "Sydney", "Lagos", "Seoul", "Mexico City", "Istanbul", "Chicago", "Jakarta", "Cairo",
+}
+
+var browsers = []string{"Chrome", "Safari", "Firefox", "Edge", "Opera", "Samsung Internet"}
+
+var emailDomains = []string{"gmail.com", "outlook.com", "yahoo.com", "icloud.com", "proton.me", "example.org"}
+
+// Drawn from with a skew towards the start of the list, like word frequencies in natural text
+var words = strings.Fields(`the of and to in a is that for it as was with be by on not he this are or his from at
+which but have an they you were her she there been one all we their has would when if so no will more can out
+up about who them some could him into its then two time my than first only new over also after other any may use
+these see way how our work well even because most through back where just good people much year day made being
+those before same while last should down great own between never might life world still part long without home
+graph node database query index transaction memory cluster page cache latency throughput server write read store
+data model value system network relationship property label schema result plan engine load test benchmark`)
+
+// Deterministic random numbers for one node, a splitmix64 stream seeded from the seed, the kind of node and its id.
+// It is a rand.Source64, so the same stream drives the random functions of scripts in a --init-generator spec.
+type contentSource struct {
+	state uint64
+}
+
+func (g *ContentGenerator) random(kind string, id int64) *rand.Rand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(kind))
+	s := &contentSource{state: uint64(g.Seed) ^ h.Sum64() ^ uint64(id)*0x9E3779B97F4A7C15}
+	// Mix the seed in before the first value, so neighbouring ids don't start out alike
+	s.Uint64()
+	return rand.New(s)
+}
+
+func (s *contentSource) Uint64() uint64 {
+	s.state += 0x9E3779B97F4A7C15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+func (s *contentSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *contentSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// Log-uniform in [0, n), so low indexes are far more likely; close to a zipfian distribution over the list
+func skewed(r *rand.Rand, n int) int {
+	return int(math.Exp(r.Float64()*math.Log(float64(n+1)))) - 1
+}
+
+// Log-normally distributed around median, the shape of sizes, amounts and counts in most real data
+func logNormal(r *rand.Rand, median, sigma float64) float64 {
+	// Box-Muller; 1 - Float64() so the log is never of 0
+	normal := math.Sqrt(-2*math.Log(1-r.Float64())) * math.Cos(2*math.Pi*r.Float64())
+	return median * math.Exp(sigma*normal)
+}
+
+// Epoch millis within the years after contentEpoch
+func contentDate(r *rand.Rand, years int) int64 {
+	return contentEpoch + int64(r.Float64()*float64(years*contentYearMillis))
+}
+
+// Between minWords and maxWords words of text, around medianWords long
+func contentText(r *rand.Rand, medianWords float64, minWords, maxWords int) string {
+	n := int(logNormal(r, medianWords, 0.6))
+	if n < minWords {
+		n = minWords
+	} else if n > maxWords {
+		n = maxWords
+	}
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		word := words[skewed(r, len(words))]
+		if i == 0 {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		} else {
+			b.WriteByte(' ')
+		}
+		b.WriteString(word)
+		if i == n-1 || r.Intn(12) == 0 {
+			b.WriteByte('.')
+		}
+	}
+	return b.String()
+}
+
+// Name, contact details and signup date of a customer, for :Account nodes
+func (g *ContentGenerator) accounts() batchContent {
+	if g == nil {
+		return nil
+	}
+	return func(id int64) map[string]interface{} {
+		r := g.random("account", id)
+		first, last := firstNames[skewed(r, len(firstNames))], lastNames[skewed(r, len(lastNames))]
+		return map[string]interface{}{
+			"name":        first + " " + last,
+			"email":       fmt.Sprintf("%s.%s%d@%s", strings.ToLower(first), strings.ToLower(last), id, emailDomains[skewed(r, len(emailDomains))]),
+			"city":        cities[skewed(r, len(cities))],
+			"creditLimit": int64(logNormal(r, 5000, 0.8)),
+			"created":     contentDate(r, 10),
+		}
+	}
+}
+
+// Profile of a :Person in builtin:ldbc-snb
+func (g *ContentGenerator) persons() batchContent {
+	if g == nil {
+		return nil
+	}
+	return func(id int64) map[string]interface{} {
+		r := g.random("person", id)
+		gender := "male"
+		if r.Intn(2) == 0 {
+			gender = "female"
+		}
+		return map[string]interface{}{
+			"firstName":    firstNames[skewed(r, len(firstNames))],
+			"lastName":     lastNames[skewed(r, len(lastNames))],
+			"gender":       gender,
+			"birthday":     int64(19500000 + r.Intn(55)*10000 + (r.Intn(12)+1)*100 + r.Intn(28) + 1),
+			"creationDate": contentDate(r, 10),
+			"locationIP":   fmt.Sprintf("%d.%d.%d.%d", r.Intn(223)+1, r.Intn(256), r.Intn(256), r.Intn(254)+1),
+			"browserUsed":  browsers[skewed(r, len(browsers))],
+		}
+	}
+}
+
+// Text of a :Message in builtin:ldbc-snb, posts being longer than comments
+func (g *ContentGenerator) messages(medianWords float64) batchContent {
+	if g == nil {
+		return nil
+	}
+	return func(id int64) map[string]interface{} {
+		r := g.random("message", id)
+		content := contentText(r, medianWords, 1, 1000)
+		return map[string]interface{}{
+			"content":      content,
+			"length":       int64(len(content)),
+			"creationDate": contentDate(r, 10),
+		}
+	}
+}
+
+// Title and body of an :Article in builtin:pagination
+func (g *ContentGenerator) articles() batchContent {
+	if g == nil {
+		return nil
+	}
+	return func(id int64) map[string]interface{} {
+		r := g.random("article", id)
+		return map[string]interface{}{
+			"title": strings.TrimSuffix(contentText(r, 7, 3, 16), "."),
+			"body":  contentText(r, 400, 50, 5000),
+		}
+	}
+}
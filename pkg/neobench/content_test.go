@@ -0,0 +1,58 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestContentIsDeterministic(t *testing.T) {
+	g := &ContentGenerator{Seed: 7}
+
+	assert.Equal(t, g.accounts()(42), (&ContentGenerator{Seed: 7}).accounts()(42))
+	assert.NotEqual(t, g.accounts()(42), g.accounts()(43))
+	assert.NotEqual(t, g.accounts()(42), (&ContentGenerator{Seed: 8}).accounts()(42))
+
+	// However the ids are split into batches
+	whole := g.messages(40).batch(1, 10).([]interface{})
+	split := append(g.messages(40).batch(1, 4).([]interface{}), g.messages(40).batch(5, 10).([]interface{})...)
+	assert.Equal(t, whole, split)
+}
+
+func TestContentWithoutGeneratorIsNull(t *testing.T) {
+	var g *ContentGenerator
+
+	assert.Nil(t, g.accounts().batch(1, 10))
+	assert.Nil(t, g.persons().batch(1, 10))
+}
+
+func TestContentDistributions(t *testing.T) {
+	g := &ContentGenerator{Seed: 1}
+	persons, messages := g.persons(), g.messages(40)
+	totalLength := int64(0)
+	for id := int64(1); id <= 1000; id++ {
+		birthday := persons(id)["birthday"].(int64)
+		month, day := birthday/100%100, birthday%100
+		assert.True(t, month >= 1 && month <= 12, "birthday %d", birthday)
+		assert.True(t, day >= 1 && day <= 28, "birthday %d", birthday)
+
+		message := messages(id)
+		assert.Equal(t, int64(len(message["content"].(string))), message["length"])
+		assert.True(t, strings.HasSuffix(message["content"].(string), "."))
+		totalLength += message["length"].(int64)
+	}
+	// Around 40 words of around 4 letters and a space each
+	mean := totalLength / 1000
+	assert.True(t, mean > 100 && mean < 400, "mean message length %d", mean)
+}
+
+func TestSkewedStaysInRange(t *testing.T) {
+	r := (&ContentGenerator{}).random("test", 1)
+	counts := make([]int, 5)
+	for i := 0; i < 10000; i++ {
+		counts[skewed(r, 5)]++
+	}
+	for i := 1; i < len(counts); i++ {
+		assert.True(t, counts[i-1] > counts[i], "counts %v", counts)
+	}
+}
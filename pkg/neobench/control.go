@@ -0,0 +1,209 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The rate of a run in latency mode, shared by its workers so it can be changed while they run
+type RateControl struct {
+	clients int
+	// Total rate as float64 bits, and the resulting time between transactions for each client in nanoseconds
+	rate     uint64
+	interval int64
+}
+
+func NewRateControl(clients int, rate float64) *RateControl {
+	r := &RateControl{clients: clients}
+	r.store(rate)
+	return r
+}
+
+// Total transactions per second across all clients
+func (r *RateControl) Rate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&r.rate))
+}
+
+// Time between transactions for each client
+func (r *RateControl) Interval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&r.interval))
+}
+
+func (r *RateControl) Set(rate float64) error {
+	if !(rate > 0) || math.IsInf(rate, 0) {
+		return fmt.Errorf("rate must be a positive number of transactions per second, got %v", rate)
+	}
+	r.store(rate)
+	return nil
+}
+
+func (r *RateControl) store(rate float64) {
+	atomic.StoreUint64(&r.rate, math.Float64bits(rate))
+	atomic.StoreInt64(&r.interval, int64(TotalRatePerSecondToDurationPerClient(r.clients, rate)))
+}
+
+// Lets another goroutine or process look at and steer a running benchmark, see NewControlHandler. Set it as
+// BenchmarkConfig.Control; with a schedule or a rate search, it follows the phase currently running.
+type RunControl struct {
+	// Paused and resumed through the control; the run should use the same one, as BenchmarkConfig.Pause
+	Pause *PauseControl
+	stop  func()
+
+	mut        sync.Mutex
+	database   string
+	scenario   string
+	phaseStart time.Time
+	recorders  []*ResultRecorder
+	rate       *RateControl
+}
+
+// stop is called to end the run early, normally by cancelling the context given to Run
+func NewRunControl(pause *PauseControl, stop func()) *RunControl {
+	return &RunControl{Pause: pause, stop: stop}
+}
+
+func (c *RunControl) startPhase(cfg BenchmarkConfig, recorders []*ResultRecorder, rate *RateControl) {
+	if c == nil {
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.database, c.scenario = cfg.DatabaseName, cfg.Scenario
+	c.phaseStart = time.Now()
+	c.recorders, c.rate = recorders, rate
+}
+
+// Results of the current phase so far
+func (c *RunControl) Stats() Result {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	result := NewResult(c.database, c.scenario)
+	result.Start, result.End = c.phaseStart, time.Now()
+	for _, rec := range c.recorders {
+		result.Add(rec.Snapshot())
+	}
+	return result
+}
+
+// Current target rate, 0 when measuring throughput or before the run starts
+func (c *RunControl) Rate() float64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.rate == nil {
+		return 0
+	}
+	return c.rate.Rate()
+}
+
+// Changes the target rate of the current phase; only runs in latency mode have one
+func (c *RunControl) SetRate(rate float64) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.rate == nil {
+		return fmt.Errorf("the run has no target rate to change, it is measuring throughput or not started yet")
+	}
+	return c.rate.Set(rate)
+}
+
+// Ends the run early, like an interrupt; the results up to now are still reported
+func (c *RunControl) Stop() {
+	if c.stop != nil {
+		c.stop()
+	}
+}
+
+type controlStatus struct {
+	Paused bool       `json:"paused"`
+	Rate   float64    `json:"rate"`
+	Stats  JsonReport `json:"stats"`
+}
+
+// Serves the control endpoints:
+//
+//	GET  /stats          results of the current phase so far, as json
+//	POST /rate?tps=<n>   change the target rate, in latency mode
+//	POST /pause          stop starting new transactions
+//	POST /resume         start them again
+//	POST /stop           end the run, still reporting the results
+//
+// Percentiles are the latency percentiles in the stats, nil for the json output defaults.
+func NewControlHandler(c *RunControl, percentiles []float64) http.Handler {
+	percentiles = orPercentiles(percentiles, jsonPercentiles)
+	mux := http.NewServeMux()
+	status := func(w http.ResponseWriter) {
+		rate := c.Rate()
+		mode := "throughput"
+		if rate > 0 {
+			mode = "latency"
+		}
+		writeControlJson(w, http.StatusOK, controlStatus{
+			Paused: c.Pause != nil && c.Pause.Paused(),
+			Rate:   rate,
+			Stats:  NewJsonReport(mode, c.Stats(), percentiles, false),
+		})
+	}
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("use GET for /stats"))
+			return
+		}
+		status(w)
+	})
+	mux.HandleFunc("/rate", post(func(w http.ResponseWriter, r *http.Request) {
+		rate, err := strconv.ParseFloat(r.URL.Query().Get("tps"), 64)
+		if err != nil {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("tps should be a number, eg. /rate?tps=100"))
+			return
+		}
+		if err := c.SetRate(rate); err != nil {
+			writeControlError(w, http.StatusConflict, err)
+			return
+		}
+		status(w)
+	}))
+	mux.HandleFunc("/pause", post(func(w http.ResponseWriter, r *http.Request) {
+		if c.Pause == nil {
+			writeControlError(w, http.StatusConflict, fmt.Errorf("the run can't be paused"))
+			return
+		}
+		c.Pause.Pause()
+		status(w)
+	}))
+	mux.HandleFunc("/resume", post(func(w http.ResponseWriter, r *http.Request) {
+		if c.Pause != nil {
+			c.Pause.Resume()
+		}
+		status(w)
+	}))
+	mux.HandleFunc("/stop", post(func(w http.ResponseWriter, r *http.Request) {
+		c.Stop()
+		status(w)
+	}))
+	return mux
+}
+
+func post(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST for %s", r.URL.Path))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeControlJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeControlError(w http.ResponseWriter, code int, err error) {
+	writeControlJson(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,114 @@
+package neobench
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlChangesRateAndStopsRun(t *testing.T) {
+	script, err := Parse("controltest", `RETURN 1;`, 1)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	control := NewRunControl(NewPauseControl(nil), cancel)
+	server := httptest.NewServer(NewControlHandler(control, nil))
+	defer server.Close()
+
+	done := make(chan Result)
+	go func() {
+		result, err := Run(ctx, BenchmarkConfig{
+			Driver:       instantDriver{},
+			DatabaseName: "neo4j",
+			Workload:     Workload{Scripts: NewScripts(script), Rand: rand.New(rand.NewSource(1337))},
+			Clients:      2,
+			Duration:     time.Minute,
+			Rate:         100,
+			Pause:        control.Pause,
+			Control:      control,
+		})
+		assert.NoError(t, err)
+		done <- result
+	}()
+	assert.Eventually(t, func() bool {
+		stats := control.Stats()
+		return stats.TotalSucceeded() > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status := controlRequest(t, server, http.MethodPost, "/rate?tps=500", http.StatusOK)
+	assert.Equal(t, 500.0, status.Rate)
+	assert.Equal(t, "latency", status.Stats.Mode)
+	controlRequest(t, server, http.MethodPost, "/rate?tps=-1", http.StatusConflict)
+	controlRequest(t, server, http.MethodGet, "/pause", http.StatusMethodNotAllowed)
+
+	status = controlRequest(t, server, http.MethodPost, "/pause", http.StatusOK)
+	assert.True(t, status.Paused)
+	status = controlRequest(t, server, http.MethodGet, "/stats", http.StatusOK)
+	assert.Greater(t, status.Stats.Succeeded, int64(0))
+
+	controlRequest(t, server, http.MethodPost, "/stop", http.StatusOK)
+	select {
+	case result := <-done:
+		assert.Greater(t, result.TotalSucceeded(), int64(0))
+	case <-time.After(10 * time.Second):
+		t.Fatal("run did not stop")
+	}
+}
+
+func TestControlRateNeedsLatencyMode(t *testing.T) {
+	control := NewRunControl(nil, nil)
+	assert.Error(t, control.SetRate(100))
+	control.startPhase(BenchmarkConfig{}, nil, NewRateControl(4, 100))
+	assert.NoError(t, control.SetRate(200))
+	assert.Equal(t, 200.0, control.Rate())
+	assert.Equal(t, 20*time.Millisecond, control.rate.Interval())
+}
+
+func controlRequest(t *testing.T, server *httptest.Server, method, path string, code int) controlStatus {
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	assert.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, code, res.StatusCode)
+	var status controlStatus
+	if code == http.StatusOK {
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+	}
+	return status
+}
+
+func TestControlInputPausesAndResumes(t *testing.T) {
+	var changes []bool
+	pause := NewPauseControl(func(paused bool) {
+		changes = append(changes, paused)
+	})
+
+	// Repeated commands are no-ops, an empty line toggles and unknown ones are ignored
+	WatchControlInput(strings.NewReader("pause\nP\nresume\n\nbogus\n"), pause)
+
+	assert.Equal(t, []bool{true, false, true}, changes)
+	assert.True(t, pause.Paused())
+
+	stopCh := make(chan struct{})
+	close(stopCh)
+	assert.False(t, pause.Wait(stopCh), "wait gives up once stopped while paused")
+
+	resumed := make(chan bool)
+	go func() {
+		resumed <- pause.Wait(make(chan struct{}))
+	}()
+	pause.Resume()
+	select {
+	case ok := <-resumed:
+		assert.True(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return on resume")
+	}
+}
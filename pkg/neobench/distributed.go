@@ -0,0 +1,460 @@
+package neobench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variable with the token agents require of coordinators, see NewAgentHandler
+const agentTokenEnv = "NEOBENCH_AGENT_TOKEN"
+
+// The parts of a Result that can be merged across agents or saved runs, with histograms sent as their non-zero counts
+type wireResult struct {
+	DatabaseName       string                  `json:"database_name"`
+	Scenario           string                  `json:"scenario"`
+	Seed               int64                   `json:"seed"`
+	Start              time.Time               `json:"start"`
+	End                time.Time               `json:"end"`
+	Tags               map[string]string       `json:"tags,omitempty"`
+	Server             *ServerInfo             `json:"server,omitempty"`
+	Events             []wireEvent             `json:"events,omitempty"`
+	Intervals          []IntervalSample        `json:"intervals,omitempty"`
+	Scripts            []wireScript            `json:"scripts"`
+	FailedByErrorGroup map[string]wireFailures `json:"failed_by_error_group"`
+	ConnectLatencies   *wireHistogram          `json:"connect_latencies"`
+	QueueTimes         *wireHistogram          `json:"queue_times"`
+	Backlog            *wireHistogram          `json:"backlog"`
+	AcquireTimes       *wireHistogram          `json:"acquire_times"`
+	Dropped            int64                   `json:"dropped"`
+	SessionsOpened     int64                   `json:"sessions_opened"`
+}
+
+type wireScript struct {
+	Name             string          `json:"name"`
+	Rate             float64         `json:"rate"`
+	Succeeded        int64           `json:"succeeded"`
+	Failed           int64           `json:"failed"`
+	Latencies        *wireHistogram  `json:"latencies"`
+	ServiceLatencies *wireHistogram  `json:"service_latencies"`
+	Statements       []wireStatement `json:"statements"`
+	Deadlocks        int64           `json:"deadlocks"`
+	LockWaitAborts   int64           `json:"lock_wait_aborts"`
+	DataErrors       int64           `json:"data_errors"`
+	FirstDataError   string          `json:"first_data_error"`
+	Metric           string          `json:"metric"`
+	MetricValues     *wireHistogram  `json:"metric_values"`
+}
+
+type wireStatement struct {
+	Query     string         `json:"query"`
+	Failed    int64          `json:"failed"`
+	Latencies *wireHistogram `json:"latencies"`
+}
+
+type wireFailures struct {
+	Count        int64  `json:"count"`
+	FirstFailure string `json:"first_failure"`
+}
+
+type wireEvent struct {
+	Time  time.Time `json:"time"`
+	Name  string    `json:"name"`
+	Error string    `json:"error,omitempty"`
+}
+
+type wireHistogram struct {
+	Lowest  int64 `json:"lowest"`
+	Highest int64 `json:"highest"`
+	Figures int64 `json:"figures"`
+	Size    int   `json:"size"`
+	// Index and count of each non-zero bucket
+	Counts [][2]int64 `json:"counts"`
+}
+
+func toWireHistogram(h *hdrhistogram.Histogram) *wireHistogram {
+	if h == nil {
+		return nil
+	}
+	snapshot := h.Export()
+	w := &wireHistogram{
+		Lowest:  snapshot.LowestTrackableValue,
+		Highest: snapshot.HighestTrackableValue,
+		Figures: snapshot.SignificantFigures,
+		Size:    len(snapshot.Counts),
+	}
+	for i, count := range snapshot.Counts {
+		if count != 0 {
+			w.Counts = append(w.Counts, [2]int64{int64(i), count})
+		}
+	}
+	return w
+}
+
+func (w *wireHistogram) histogram() (*hdrhistogram.Histogram, error) {
+	if w == nil {
+		return nil, nil
+	}
+	counts := make([]int64, w.Size)
+	for _, c := range w.Counts {
+		if c[0] < 0 || c[0] >= int64(w.Size) {
+			return nil, fmt.Errorf("histogram bucket %d out of range", c[0])
+		}
+		counts[c[0]] = c[1]
+	}
+	return hdrhistogram.Import(&hdrhistogram.Snapshot{
+		LowestTrackableValue:  w.Lowest,
+		HighestTrackableValue: w.Highest,
+		SignificantFigures:    w.Figures,
+		Counts:                counts,
+	}), nil
+}
+
+// Writes the mergeable parts of result, for an agent to send to its coordinator or to merge later; see DecodeResult
+func EncodeResult(w io.Writer, result Result) error {
+	wire := wireResult{
+		DatabaseName:       result.DatabaseName,
+		Scenario:           result.Scenario,
+		Seed:               result.Seed,
+		Start:              result.Start,
+		End:                result.End,
+		Tags:               result.Tags,
+		Server:             result.Server,
+		Intervals:          result.Intervals,
+		FailedByErrorGroup: make(map[string]wireFailures),
+		ConnectLatencies:   toWireHistogram(result.ConnectLatencies),
+		QueueTimes:         toWireHistogram(result.QueueTimes),
+		Backlog:            toWireHistogram(result.Backlog),
+		AcquireTimes:       toWireHistogram(result.AcquireTimes),
+		Dropped:            result.Dropped,
+		SessionsOpened:     result.SessionsOpened,
+	}
+	for _, script := range result.SortedScripts() {
+		ws := wireScript{
+			Name:             script.ScriptName,
+			Rate:             script.Rate,
+			Succeeded:        script.Succeeded,
+			Failed:           script.Failed,
+			Latencies:        toWireHistogram(script.Latencies),
+			ServiceLatencies: toWireHistogram(script.ServiceLatencies),
+			Deadlocks:        script.Deadlocks,
+			LockWaitAborts:   script.LockWaitAborts,
+			DataErrors:       script.DataErrors,
+			FirstDataError:   script.FirstDataError,
+			Metric:           script.Metric,
+			MetricValues:     toWireHistogram(script.MetricValues),
+		}
+		for _, statement := range script.Statements {
+			if statement == nil {
+				ws.Statements = append(ws.Statements, wireStatement{})
+				continue
+			}
+			ws.Statements = append(ws.Statements, wireStatement{
+				Query: statement.Query, Failed: statement.Failed, Latencies: toWireHistogram(statement.Latencies),
+			})
+		}
+		wire.Scripts = append(wire.Scripts, ws)
+	}
+	for _, event := range result.Events {
+		wire.Events = append(wire.Events, wireEvent{Time: event.Time, Name: event.Name, Error: event.Err})
+	}
+	for name, group := range result.FailedByErrorGroup {
+		failures := wireFailures{Count: group.Count}
+		if group.FirstFailure != nil {
+			failures.FirstFailure = group.FirstFailure.Error()
+		}
+		wire.FailedByErrorGroup[name] = failures
+	}
+	return json.NewEncoder(w).Encode(wire)
+}
+
+// Reads a result written by EncodeResult. Profiles and pool stats aren't sent, and first failures come back as
+// plain errors with the same message.
+func DecodeResult(r io.Reader) (Result, error) {
+	var wire wireResult
+	if err := json.NewDecoder(r).Decode(&wire); err != nil {
+		return Result{}, fmt.Errorf("invalid result: %s", err)
+	}
+	result := NewResult(wire.DatabaseName, wire.Scenario)
+	result.Seed = wire.Seed
+	result.Start = wire.Start
+	result.End = wire.End
+	result.Tags = wire.Tags
+	result.Server = wire.Server
+	result.Intervals = wire.Intervals
+	for _, we := range wire.Events {
+		result.Events = append(result.Events, RunEvent{Time: we.Time, Name: we.Name, Err: we.Error})
+	}
+	var err error
+	histogram := func(w *wireHistogram, into **hdrhistogram.Histogram) {
+		if err != nil || w == nil {
+			return
+		}
+		*into, err = w.histogram()
+	}
+	histogram(wire.ConnectLatencies, &result.ConnectLatencies)
+	histogram(wire.QueueTimes, &result.QueueTimes)
+	histogram(wire.Backlog, &result.Backlog)
+	histogram(wire.AcquireTimes, &result.AcquireTimes)
+	result.Dropped = wire.Dropped
+	result.SessionsOpened = wire.SessionsOpened
+	for _, ws := range wire.Scripts {
+		script := &ScriptResult{
+			ScriptName:     ws.Name,
+			Rate:           ws.Rate,
+			Succeeded:      ws.Succeeded,
+			Failed:         ws.Failed,
+			Deadlocks:      ws.Deadlocks,
+			LockWaitAborts: ws.LockWaitAborts,
+			DataErrors:     ws.DataErrors,
+			FirstDataError: ws.FirstDataError,
+			Metric:         ws.Metric,
+			Latencies:      hdrhistogram.New(0, 60*60*1000000, 5),
+		}
+		histogram(ws.Latencies, &script.Latencies)
+		histogram(ws.ServiceLatencies, &script.ServiceLatencies)
+		histogram(ws.MetricValues, &script.MetricValues)
+		for _, statement := range ws.Statements {
+			if statement.Latencies == nil {
+				script.Statements = append(script.Statements, nil)
+				continue
+			}
+			sr := &StatementResult{Query: statement.Query, Failed: statement.Failed}
+			histogram(statement.Latencies, &sr.Latencies)
+			script.Statements = append(script.Statements, sr)
+		}
+		result.Scripts[ws.Name] = script
+	}
+	for name, failures := range wire.FailedByErrorGroup {
+		result.FailedByErrorGroup[name] = FailureGroup{Count: failures.Count, FirstFailure: errors.New(failures.FirstFailure)}
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid result: %s", err)
+	}
+	return result, nil
+}
+
+// Adds the results of a run alongside this one, like those of another agent; rates add up, as the runs went
+// on at the same time
+func (r *Result) Merge(other Result) {
+	r.Add(WorkerResult{
+		Scripts:            other.Scripts,
+		FailedByErrorGroup: other.FailedByErrorGroup,
+		ConnectLatencies:   other.ConnectLatencies,
+		QueueTimes:         other.QueueTimes,
+		Backlog:            other.Backlog,
+		Dropped:            other.Dropped,
+		SessionsOpened:     other.SessionsOpened,
+		AcquireTimes:       other.AcquireTimes,
+	})
+}
+
+// The part of a benchmark one agent runs
+type AgentShare struct {
+	Clients int
+	// Number of the first of the clients among all of them, see Workload.ClientOffset
+	FirstClient int
+	// Total rate of the agent, 0 when measuring throughput
+	Rate float64
+	Seed int64
+}
+
+// Splits clients, and the rate if there is one, across agents in proportion, and gives each a seed of its own
+// so they don't all run the same sequence of units of work
+func SplitLoad(agents, clients int, rate float64, seed int64) ([]AgentShare, error) {
+	if agents < 1 {
+		return nil, fmt.Errorf("need at least one agent")
+	}
+	if clients < agents {
+		return nil, fmt.Errorf("can't split %d clients across %d agents, each agent needs at least one", clients, agents)
+	}
+	shares := make([]AgentShare, agents)
+	firstClient := 0
+	for i := range shares {
+		shares[i].Clients = clients / agents
+		if i < clients%agents {
+			shares[i].Clients++
+		}
+		shares[i].FirstClient = firstClient
+		firstClient += shares[i].Clients
+		shares[i].Rate = rate * float64(shares[i].Clients) / float64(clients)
+		shares[i].Seed = seed + int64(i)
+	}
+	return shares, nil
+}
+
+type agentRun struct {
+	Args []string `json:"args"`
+}
+
+// Serves POST /run for a coordinator: runs executable, normally neobench itself, with the args in the request
+// and --agent-result, and answers with the result it wrote, see EncodeResult. Runs go one at a time. If token is
+// set, requests must carry it as a bearer token; as the args can run commands through --hook and the like, agents
+// should only listen where coordinators can reach them. GET /clock tells the time, see ProbeClockOffset.
+func NewAgentHandler(executable string, token string, logger *Logger) http.Handler {
+	var mut sync.Mutex
+	running := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST for /run", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "missing or wrong agent token", http.StatusUnauthorized)
+			return
+		}
+		var run agentRun
+		if err := json.NewDecoder(r.Body).Decode(&run); err != nil {
+			http.Error(w, fmt.Sprintf("invalid run request: %s", err), http.StatusBadRequest)
+			return
+		}
+		mut.Lock()
+		busy := running
+		running = true
+		mut.Unlock()
+		if busy {
+			http.Error(w, "agent is already running a benchmark", http.StatusConflict)
+			return
+		}
+		defer func() {
+			mut.Lock()
+			running = false
+			mut.Unlock()
+		}()
+
+		resultFile, err := ioutil.TempFile("", "neobench-agent-*.json")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = resultFile.Close()
+		defer os.Remove(resultFile.Name())
+		cmd := exec.CommandContext(r.Context(), executable, append(run.Args, "--agent-result", resultFile.Name())...)
+		cmd.Stderr = os.Stderr
+		logger.Infof("running neobench %s", strings.Join(run.Args, " "))
+		// Failed transactions or thresholds make neobench exit non-zero too; the result says what went wrong
+		runErr := cmd.Run()
+		content, err := ioutil.ReadFile(resultFile.Name())
+		if err != nil || len(content) == 0 {
+			http.Error(w, fmt.Sprintf("benchmark failed without a result: %v", runErr), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(content)
+	})
+	mux.HandleFunc("/clock", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(agentClock{Time: time.Now()})
+	})
+	return mux
+}
+
+type agentClock struct {
+	Time time.Time `json:"time"`
+}
+
+// Number of times ProbeClockOffset asks an agent for the time; the quickest answer is used
+const clockProbes = 5
+
+// How far the clock of agent is ahead of ours, negative if it's behind, estimated like NTP does from the
+// quickest of a few round trips; accurate to within half that round trip, which is returned too
+func ProbeClockOffset(ctx context.Context, agent string) (offset, roundTrip time.Duration, err error) {
+	url := agent
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	client := http.Client{Timeout: 5 * time.Second}
+	for i := 0; i < clockProbes; i++ {
+		req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(url, "/")+"/clock", nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		sent := time.Now()
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return 0, 0, err
+		}
+		var clock agentClock
+		err = json.NewDecoder(resp.Body).Decode(&clock)
+		received := time.Now()
+		_ = resp.Body.Close()
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid clock response: %s", err)
+		}
+		rtt := received.Sub(sent)
+		if i == 0 || rtt < roundTrip {
+			roundTrip = rtt
+			offset = clock.Time.Sub(sent.Add(rtt / 2))
+		}
+	}
+	return offset, roundTrip, nil
+}
+
+// Runs a benchmark on each agent, at host:port, with the args argsFor gives it, and returns their results
+// in the same order; fails if any agent does. Cancelling ctx abandons the runs.
+func RunOnAgents(ctx context.Context, agents []string, token string, argsFor func(i int) []string) ([]Result, error) {
+	results := make([]Result, len(agents))
+	errs := make([]error, len(agents))
+	var wg sync.WaitGroup
+	for i, agent := range agents {
+		wg.Add(1)
+		go func(i int, agent string) {
+			defer wg.Done()
+			results[i], errs[i] = runOnAgent(ctx, agent, token, argsFor(i))
+		}(i, agent)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %s", agents[i], err)
+		}
+	}
+	return results, nil
+}
+
+func runOnAgent(ctx context.Context, agent, token string, args []string) (Result, error) {
+	url := agent
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	body, err := json.Marshal(agentRun{Args: args})
+	if err != nil {
+		return Result{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(url, "/")+"/run", strings.NewReader(string(body)))
+	if err != nil {
+		return Result{}, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	// No timeout, the run takes as long as the benchmark does
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Result{}, fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return DecodeResult(resp.Body)
+}
+
+// The token agents and coordinators use, from NEOBENCH_AGENT_TOKEN
+func AgentToken() string {
+	return os.Getenv(agentTokenEnv)
+}
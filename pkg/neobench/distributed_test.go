@@ -0,0 +1,113 @@
+package neobench
+
+import (
+	"bytes"
+	"context"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestEncodedResultsMergeLikeWorkerResults(t *testing.T) {
+	agentResult := func(latencyMs int64, failures int64) Result {
+		result := NewResult("neo4j", "")
+		script := &ScriptResult{ScriptName: "read", Rate: 100, Succeeded: 1000, Failed: failures, Latencies: hdrhistogram.New(0, 60*60*1000000, 5)}
+		assert.NoError(t, script.Latencies.RecordValue(latencyMs*1000))
+		script.statement(0, "MATCH (n) RETURN n").Latencies.RecordValue(latencyMs * 1000)
+		result.Scripts["read"] = script
+		if failures > 0 {
+			result.FailedByErrorGroup["Deadlock"] = FailureGroup{Count: failures, FirstFailure: assert.AnError}
+		}
+		return result
+	}
+
+	merged := NewResult("neo4j", "")
+	for _, agent := range []Result{agentResult(10, 0), agentResult(30, 2)} {
+		encoded := bytes.Buffer{}
+		assert.NoError(t, EncodeResult(&encoded, agent))
+		decoded, err := DecodeResult(&encoded)
+		assert.NoError(t, err)
+		merged.Merge(decoded)
+	}
+
+	read := merged.Scripts["read"]
+	assert.Equal(t, 200.0, read.Rate)
+	assert.Equal(t, int64(2000), read.Succeeded)
+	assert.Equal(t, int64(2), read.Failed)
+	assert.Equal(t, int64(2), read.Latencies.TotalCount())
+	assert.InDelta(t, 30000, read.Latencies.Max(), 10)
+	assert.Equal(t, "MATCH (n) RETURN n", read.Statements[0].Query)
+	assert.Equal(t, int64(2), read.Statements[0].Latencies.TotalCount())
+	assert.Equal(t, int64(2), merged.FailedByErrorGroup["Deadlock"].Count)
+	assert.EqualError(t, merged.FailedByErrorGroup["Deadlock"].FirstFailure, assert.AnError.Error())
+}
+
+func TestSplitLoad(t *testing.T) {
+	shares, err := SplitLoad(3, 10, 1000, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, []AgentShare{
+		{Clients: 4, FirstClient: 0, Rate: 400, Seed: 42},
+		{Clients: 3, FirstClient: 4, Rate: 300, Seed: 43},
+		{Clients: 3, FirstClient: 7, Rate: 300, Seed: 44},
+	}, shares)
+
+	_, err = SplitLoad(3, 2, 0, 1)
+	assert.Error(t, err)
+}
+
+func TestAgentRunsBenchmarkAndReturnsResult(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the neobench executable")
+	}
+	dir := t.TempDir()
+	result := NewResult("neo4j", "")
+	result.Scripts["read"] = &ScriptResult{ScriptName: "read", Rate: 50, Succeeded: 10, Latencies: hdrhistogram.New(0, 60*60*1000000, 5)}
+	encoded := bytes.Buffer{}
+	assert.NoError(t, EncodeResult(&encoded, result))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "result.json"), encoded.Bytes(), 0644))
+	// Stands in for neobench: records its args and writes the result to the file after --agent-result
+	executable := filepath.Join(dir, "neobench")
+	assert.NoError(t, ioutil.WriteFile(executable, []byte(`#!/bin/sh
+echo "$@" > "`+dir+`/args"
+while [ "$1" != "--agent-result" ]; do shift; done
+cp "`+dir+`/result.json" "$2"
+exit 1
+`), 0755))
+	logger, err := NewLogger(LogNormal, "text", ioutil.Discard)
+	assert.NoError(t, err)
+	agent := httptest.NewServer(NewAgentHandler(executable, "secret", logger))
+	defer agent.Close()
+
+	// Agents are given as host:port, like --agents takes them
+	results, err := RunOnAgents(context.Background(), []string{agent.Listener.Addr().String()}, "secret", func(i int) []string {
+		return []string{"-c", "2", "-d", "10"}
+	})
+
+	// The exit code of the run doesn't matter, as long as it wrote a result
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, int64(10), results[0].Scripts["read"].Succeeded)
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(args), "-c 2 -d 10 --agent-result ")
+
+	_, err = RunOnAgents(context.Background(), []string{agent.URL}, "wrong", func(i int) []string { return nil })
+	assert.EqualError(t, err, "agent "+agent.URL+": status 401 Unauthorized: missing or wrong agent token")
+}
+
+func TestProbeClockOffset(t *testing.T) {
+	logger, err := NewLogger(LogNormal, "text", ioutil.Discard)
+	assert.NoError(t, err)
+	agent := httptest.NewServer(NewAgentHandler("neobench", "", logger))
+	defer agent.Close()
+
+	// Same machine, so the clocks agree to within the round trip
+	offset, roundTrip, err := ProbeClockOffset(context.Background(), agent.Listener.Addr().String())
+	assert.NoError(t, err)
+	assert.True(t, roundTrip > 0)
+	assert.True(t, offset <= roundTrip && -offset <= roundTrip, "offset %s, round trip %s", offset, roundTrip)
+}
@@ -1,11 +1,21 @@
 package neobench
 
 import (
+	"bufio"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"fmt"
-	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/config"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/log"
 	"io"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"strings"
+	"time"
 )
 
 type EncryptionMode int
@@ -16,38 +26,444 @@ const (
 	EncryptionOn   EncryptionMode = 2
 )
 
-func NewDriver(urlStr, user, password string, encryptionMode EncryptionMode) (neo4j.Driver, error) {
-	var encrypted bool
-	switch encryptionMode {
-	case EncryptionOff:
-		encrypted = false
-	case EncryptionOn:
-		encrypted = true
-	case EncryptionAuto:
-		enabled, err := isTlsEnabled(urlStr)
+// How to verify the servers certificate when encryption is on. The zero value verifies it against the system CAs.
+type TlsOptions struct {
+	// PEM file with the CA certificates to trust instead of the system ones
+	CaFile string
+	// Accept any certificate, without verifying it at all
+	Insecure bool
+	// Trust-on-first-use: the certificate a server presents the first time is pinned in this file,
+	// and later runs refuse to connect if the server presents a different one
+	KnownHostsFile string
+	// Verify the certificate, including its hostname, against the system CAs, or CaFile if given; without this,
+	// CaFile or KnownHostsFile, bolt connections are encrypted but the certificate is not verified
+	Verify bool
+	// PEM files with the client certificate and its key, for servers that require mutual TLS
+	CertFile string
+	KeyFile  string
+}
+
+// Driver connection settings; zero values keep the drivers defaults
+type ConnectionOptions struct {
+	// Connections per server, negative for no limit
+	MaxPoolSize int
+	// Connections older than this are closed rather than reused, negative to keep them forever
+	MaxLifetime time.Duration
+	// How long a transaction waits for a connection when the pool is exhausted, negative to wait forever
+	AcquisitionTimeout time.Duration
+	// Timeout for opening a socket, negative for none
+	ConnectTimeout time.Duration
+	// Turns off TCP keepalive, which the driver enables by default
+	NoKeepalive bool
+	// How long the driver keeps retrying a transaction that failed with a transient error, negative to not retry
+	MaxRetryTime time.Duration
+	// Addresses of the cluster members to fetch a routing table from with neo4j:// urls, tried in turn
+	// in place of the url's own address
+	Routers []string
+}
+
+// The drivers default pool size, which caps concurrency when many clients share one driver
+const DefaultMaxPoolSize = 100
+
+// How to authenticate; Scheme is basic, kerberos, bearer, none or the name of a custom scheme the server has a plugin for
+type AuthOptions struct {
+	Scheme   string
+	User     string
+	Password string
+	Realm    string
+	// Base64 encoded kerberos ticket, or the path to a file containing it
+	Ticket string
+	// SSO bearer token, or the path to a file containing it
+	BearerToken string
+	// Extra parameters sent with custom schemes
+	Parameters map[string]string
+}
+
+func (a AuthOptions) Token() (neo4j.AuthToken, error) {
+	secret, err := a.secret()
+	if err != nil {
+		return neo4j.AuthToken{}, err
+	}
+	return a.token(secret), nil
+}
+
+// The kerberos ticket or bearer token, read from file if it names one; tokens that expire are usually kept
+// up to date in a file by some other process, so this is re-read on every refresh
+func (a AuthOptions) secret() (string, error) {
+	var value, flag string
+	switch a.Scheme {
+	case "kerberos":
+		value, flag = a.Ticket, "--kerberos-ticket"
+	case "bearer":
+		value, flag = a.BearerToken, "--bearer-token"
+	default:
+		return "", nil
+	}
+	if info, err := os.Stat(value); err == nil && !info.IsDir() {
+		content, err := ioutil.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s auth token: %s", a.Scheme, err)
+		}
+		value = strings.TrimSpace(string(content))
+	}
+	if value == "" {
+		return "", fmt.Errorf("%s auth needs a token, see %s", a.Scheme, flag)
+	}
+	return value, nil
+}
+
+func (a AuthOptions) token(secret string) neo4j.AuthToken {
+	switch a.Scheme {
+	case "", "basic":
+		return neo4j.BasicAuth(a.User, a.Password, a.Realm)
+	case "none":
+		return neo4j.NoAuth()
+	case "kerberos":
+		return neo4j.KerberosAuth(secret)
+	case "bearer":
+		return neo4j.BearerAuth(secret)
+	default:
+		var params map[string]interface{}
+		if len(a.Parameters) > 0 {
+			params = make(map[string]interface{}, len(a.Parameters))
+			for k, v := range a.Parameters {
+				params[k] = v
+			}
+		}
+		return neo4j.CustomAuth(a.Scheme, a.User, a.Password, a.Realm, params)
+	}
+}
+
+func NewDriver(urlStr string, auth neo4j.AuthToken, encryptionMode EncryptionMode, tlsOptions TlsOptions, conn ConnectionOptions, logger log.Logger) (neo4j.Driver, error) {
+	dial, err := NewDriverFactory(urlStr, auth, encryptionMode, tlsOptions, conn, logger)
+	if err != nil {
+		return nil, err
+	}
+	return dial()
+}
+
+// Creates new drivers on demand; used when each transaction should get its own connection
+type DriverFactory func() (neo4j.Driver, error)
+
+// Runs every session as user, so transactions have that user's privileges rather than those of the one we
+// authenticated as
+func NewImpersonatingDriver(driver neo4j.Driver, user string) neo4j.Driver {
+	return &impersonatingDriver{Driver: driver, user: user}
+}
+
+// Like NewImpersonatingDriver, for each driver dial creates
+func ImpersonatingDriverFactory(dial DriverFactory, user string) DriverFactory {
+	return func() (neo4j.Driver, error) {
+		driver, err := dial()
 		if err != nil {
 			return nil, err
 		}
-		encrypted = enabled
+		return NewImpersonatingDriver(driver, user), nil
 	}
+}
 
-	config := func(conf *neo4j.Config) { conf.Encrypted = encrypted }
-	return neo4j.NewDriver(urlStr, neo4j.BasicAuth(user, password, ""), config)
+type impersonatingDriver struct {
+	neo4j.Driver
+	user string
 }
 
-func isTlsEnabled(urlStr string) (bool, error) {
+func (d *impersonatingDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	config.ImpersonatedUser = d.user
+	return d.Driver.NewSession(config)
+}
+
+// Resolves the encryption mode once, so auto-detection does not add to the cost of creating each new driver
+// logger receives driver events; nil means the driver logs nothing
+func NewDriverFactory(urlStr string, auth neo4j.AuthToken, encryptionMode EncryptionMode, tlsOptions TlsOptions, conn ConnectionOptions, logger log.Logger) (DriverFactory, error) {
+	target, configure, err := newDriverConfig(urlStr, encryptionMode, tlsOptions, conn, logger)
+	if err != nil {
+		return nil, err
+	}
+	return func() (neo4j.Driver, error) {
+		return newBoltDriver(target, auth, configure)
+	}, nil
+}
+
+// The url to give the driver and its configuration. The driver takes whether to encrypt, and whether to verify
+// the certificate, from the url scheme, eg. neo4j+s or bolt+ssc, so urls with a plain scheme get the suffix
+// for the encryption mode and TLS options; urls that already have one are used as they are.
+func newDriverConfig(urlStr string, encryptionMode EncryptionMode, tlsOptions TlsOptions, conn ConnectionOptions, logger log.Logger) (string, func(*config.Config), error) {
 	parsedUrl, err := url.Parse(urlStr)
 	if err != nil {
-		return false, fmt.Errorf("invalid url: %s, %s", urlStr, err)
+		return "", nil, fmt.Errorf("invalid url: %s, %s", urlStr, err)
+	}
+	var tlsConfig *tls.Config
+	if !strings.Contains(parsedUrl.Scheme, "+") {
+		var encrypted bool
+		switch encryptionMode {
+		case EncryptionOff:
+			encrypted = false
+		case EncryptionOn:
+			encrypted = true
+		case EncryptionAuto:
+			enabled, err := isTlsEnabled(urlStr)
+			if err != nil {
+				return "", nil, err
+			}
+			encrypted = enabled
+		}
+		if encrypted {
+			var suffix string
+			suffix, tlsConfig, err = tlsOptions.tlsConfig(urlStr)
+			if err != nil {
+				return "", nil, err
+			}
+			parsedUrl.Scheme += suffix
+			urlStr = parsedUrl.String()
+		}
+	} else if encryptionMode == EncryptionOff {
+		return "", nil, fmt.Errorf("%s is an encrypted url, it can't be used with encryption turned off", urlStr)
 	}
 
-	host := parsedUrl.Hostname()
+	var routers []neo4j.ServerAddress
+	for _, router := range conn.Routers {
+		parsed, err := url.Parse(router)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid url: %s, %s", router, err)
+		}
+		routers = append(routers, parsed)
+	}
+
+	configure := func(conf *config.Config) {
+		conf.TlsConfig = tlsConfig
+		if logger != nil {
+			conf.Log = logger
+		}
+		if conn.MaxPoolSize != 0 {
+			conf.MaxConnectionPoolSize = conn.MaxPoolSize
+		}
+		if conn.MaxLifetime != 0 {
+			conf.MaxConnectionLifetime = conn.MaxLifetime
+		}
+		if conn.AcquisitionTimeout != 0 {
+			conf.ConnectionAcquisitionTimeout = conn.AcquisitionTimeout
+		}
+		if conn.ConnectTimeout != 0 {
+			conf.SocketConnectTimeout = conn.ConnectTimeout
+		}
+		if conn.NoKeepalive {
+			conf.SocketKeepalive = false
+		}
+		if len(routers) > 0 {
+			conf.AddressResolver = func(neo4j.ServerAddress) []neo4j.ServerAddress {
+				return routers
+			}
+		}
+		if conn.MaxRetryTime < 0 {
+			conf.MaxTransactionRetryTime = 0
+		} else if conn.MaxRetryTime > 0 {
+			conf.MaxTransactionRetryTime = conn.MaxRetryTime
+		}
+	}
+	return urlStr, configure, nil
+}
+
+// Adds routing context to a neo4j:// url as query parameters, which is how the driver takes it
+func WithRoutingContext(urlStr string, routingContext map[string]string) (string, error) {
+	if len(routingContext) == 0 {
+		return urlStr, nil
+	}
+	parsedUrl, err := url.Parse(urlStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %s, %s", urlStr, err)
+	}
+	if !strings.HasPrefix(parsedUrl.Scheme, "neo4j") {
+		return "", fmt.Errorf("routing context only applies to neo4j:// urls, got %s", urlStr)
+	}
+	query := parsedUrl.Query()
+	for k, v := range routingContext {
+		query.Set(k, v)
+	}
+	parsedUrl.RawQuery = query.Encode()
+	return parsedUrl.String(), nil
+}
+
+// The url scheme suffix and TLS config that verify the servers certificate as the options ask: +s has the
+// driver verify it, including its hostname, against the config's roots or the system ones, +ssc leaves it to
+// the config, if anything
+func (o TlsOptions) tlsConfig(urlStr string) (string, *tls.Config, error) {
+	set := 0
+	for _, enabled := range []bool{o.Verify || o.CaFile != "", o.Insecure, o.KnownHostsFile != ""} {
+		if enabled {
+			set++
+		}
+	}
+	if set > 1 {
+		return "", nil, fmt.Errorf("--tls-verify or --tls-ca, --tls-insecure and --tls-known-hosts are mutually exclusive")
+	}
+	config, err := o.clientConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	switch {
+	case o.CaFile != "":
+		certs, err := loadCertificates(o.CaFile)
+		if err != nil {
+			return "", nil, err
+		}
+		config.RootCAs = certPool(certs...)
+		return "+s", config, nil
+	case o.Verify:
+		return "+s", config, nil
+	case o.KnownHostsFile != "":
+		address, err := serverAddress(urlStr)
+		if err != nil {
+			return "", nil, err
+		}
+		cert, err := knownHostCertificate(o.KnownHostsFile, address)
+		if err != nil {
+			return "", nil, err
+		}
+		// The pinned certificate is the trust anchor, so its hostname does not need to match
+		config.VerifyPeerCertificate = verifyChain(certPool(cert))
+		return "+ssc", config, nil
+	default:
+		// Encrypted, but any certificate is accepted, as it always has been unless verification is asked for
+		return "+ssc", config, nil
+	}
+}
+
+// The TLS config to start from: empty, or with the client certificate to present for mutual TLS
+func (o TlsOptions) clientConfig() (*tls.Config, error) {
+	if o.CertFile == "" && o.KeyFile == "" {
+		return &tls.Config{}, nil
+	}
+	if o.CertFile == "" || o.KeyFile == "" {
+		return nil, fmt.Errorf("--tls-cert and --tls-key need to be given together")
+	}
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %s", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+func certPool(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+// Verifies the certificate chain a server presents against roots, without checking its hostname
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server presented no certificate")
+		}
+		intermediates := x509.NewCertPool()
+		var leaf *x509.Certificate
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("invalid server certificate: %s", err)
+			}
+			if i == 0 {
+				leaf = cert
+			} else {
+				intermediates.AddCert(cert)
+			}
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
+
+func loadCertificates(path string) ([]*x509.Certificate, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %s", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in %s: %s", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return certs, nil
+}
+
+// Looks up the certificate pinned for address in the known hosts file, which has one "host:port base64-der"
+// line per server. If the server is not in the file yet, its current certificate is fetched and appended.
+func knownHostCertificate(path, address string) (*x509.Certificate, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known hosts file: %s", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != address {
+			continue
+		}
+		der, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry for %s in %s: %s", address, path, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry for %s in %s: %s", address, path, err)
+		}
+		return cert, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read known hosts file: %s", err)
+	}
+
+	socket, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch certificate from %s: %s", address, err)
+	}
+	defer socket.Close()
+	cert := socket.ConnectionState().PeerCertificates[0]
+	if _, err := fmt.Fprintf(file, "%s %s\n", address, base64.StdEncoding.EncodeToString(cert.Raw)); err != nil {
+		return nil, fmt.Errorf("failed to write known hosts file: %s", err)
+	}
+	return cert, nil
+}
+
+// host:port of the server in urlStr, with the default bolt port if none is given
+func serverAddress(urlStr string) (string, error) {
+	parsedUrl, err := url.Parse(urlStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %s, %s", urlStr, err)
+	}
 	port := parsedUrl.Port()
 	if port == "" {
 		port = "7687"
 	}
+	return fmt.Sprintf("%s:%s", parsedUrl.Hostname(), port), nil
+}
+
+func isTlsEnabled(urlStr string) (bool, error) {
+	address, err := serverAddress(urlStr)
+	if err != nil {
+		return false, err
+	}
 
-	socket, err := tls.Dial("tcp", fmt.Sprintf("%s:%s", host, port), &tls.Config{InsecureSkipVerify: true})
+	socket, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true})
 	if err != nil {
 		if err == io.EOF {
 			return false, nil
@@ -0,0 +1,196 @@
+package neobench
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/config"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithRoutingContext(t *testing.T) {
+	url, err := WithRoutingContext("neo4j://core1:7687", map[string]string{"region": "eu"})
+	assert.NoError(t, err)
+	assert.Equal(t, "neo4j://core1:7687?region=eu", url)
+
+	url, err = WithRoutingContext("bolt://core1:7687", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "bolt://core1:7687", url)
+
+	_, err = WithRoutingContext("bolt://core1:7687", map[string]string{"region": "eu"})
+	assert.Error(t, err)
+}
+
+func TestLoadCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	certs, err := loadCertificates(path)
+	assert.NoError(t, err)
+	assert.Len(t, certs, 1)
+	assert.Equal(t, server.Certificate().Raw, certs[0].Raw)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("not a certificate"), 0600))
+	_, err = loadCertificates(path)
+	assert.Error(t, err)
+}
+
+func TestKnownHostCertificatePinsFirstCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "known_hosts")
+	address := strings.TrimPrefix(server.URL, "https://")
+
+	cert, err := knownHostCertificate(path, address)
+	assert.NoError(t, err)
+	assert.Equal(t, server.Certificate().Raw, cert.Raw)
+
+	// Once pinned, the certificate comes from the file, even with the server gone
+	server.Close()
+	pinned, err := knownHostCertificate(path, address)
+	assert.NoError(t, err)
+	assert.Equal(t, cert.Raw, pinned.Raw)
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "\n"))
+}
+
+func TestTlsOptionsAreMutuallyExclusive(t *testing.T) {
+	_, _, err := TlsOptions{CaFile: "ca.pem", Insecure: true}.tlsConfig("neo4j://localhost")
+	assert.Error(t, err)
+}
+
+func TestCertificateIsOnlyVerifiedWhenAskedTo(t *testing.T) {
+	target, _, err := newDriverConfig("neo4j://localhost", EncryptionOn, TlsOptions{}, ConnectionOptions{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "neo4j+ssc://localhost", target)
+
+	target, _, err = newDriverConfig("neo4j://localhost", EncryptionOn, TlsOptions{Verify: true}, ConnectionOptions{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "neo4j+s://localhost", target)
+
+	_, _, err = TlsOptions{Verify: true, Insecure: true}.tlsConfig("neo4j://localhost")
+	assert.Error(t, err)
+}
+
+func TestClientCertificateIsPresented(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	certFile, keyFile := writeClientCertificate(t, dir)
+
+	_, config, err := TlsOptions{CertFile: certFile, KeyFile: keyFile}.tlsConfig("bolt://localhost")
+	assert.NoError(t, err)
+	assert.Len(t, config.Certificates, 1)
+
+	config, err = TlsOptions{CertFile: certFile, KeyFile: keyFile}.httpConfig()
+	assert.NoError(t, err)
+	assert.Len(t, config.Certificates, 1)
+
+	_, _, err = TlsOptions{CertFile: certFile}.tlsConfig("bolt://localhost")
+	assert.Error(t, err)
+}
+
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "neobench"},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+	return certFile, keyFile
+}
+
+func TestEncryptionIsSetThroughTheUrlScheme(t *testing.T) {
+	target, _, err := newDriverConfig("neo4j://localhost", EncryptionOn, TlsOptions{Insecure: true}, ConnectionOptions{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "neo4j+ssc://localhost", target)
+
+	target, _, err = newDriverConfig("bolt://localhost", EncryptionOff, TlsOptions{}, ConnectionOptions{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "bolt://localhost", target)
+
+	target, _, err = newDriverConfig("neo4j+s://localhost", EncryptionAuto, TlsOptions{}, ConnectionOptions{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "neo4j+s://localhost", target)
+
+	_, _, err = newDriverConfig("neo4j+s://localhost", EncryptionOff, TlsOptions{}, ConnectionOptions{}, nil)
+	assert.Error(t, err)
+}
+
+func TestAuthOptionsToken(t *testing.T) {
+	token, err := AuthOptions{Scheme: "kerberos", Ticket: "dGlja2V0"}.Token()
+	assert.NoError(t, err)
+	assert.Contains(t, fmt.Sprintf("%v", token), "credentials:dGlja2V0")
+
+	_, err = AuthOptions{Scheme: "kerberos"}.Token()
+	assert.Error(t, err)
+
+	token, err = AuthOptions{Scheme: "sso", User: "bob", Parameters: map[string]string{"tenant": "acme"}}.Token()
+	assert.NoError(t, err)
+	assert.Contains(t, fmt.Sprintf("%v", token), "scheme:sso")
+	assert.Contains(t, fmt.Sprintf("%v", token), "tenant:acme")
+}
+
+func TestImpersonatingDriverSetsUserOnEverySession(t *testing.T) {
+	inner := &sessionConfigDriver{}
+	dial := ImpersonatingDriverFactory(func() (neo4j.Driver, error) { return inner, nil }, "alice")
+	driver, err := dial()
+	assert.NoError(t, err)
+
+	driver.NewSession(neo4j.SessionConfig{DatabaseName: "movies", AccessMode: neo4j.AccessModeRead})
+	assert.Equal(t, []neo4j.SessionConfig{
+		{DatabaseName: "movies", AccessMode: neo4j.AccessModeRead, ImpersonatedUser: "alice"},
+	}, inner.configs)
+}
+
+type sessionConfigDriver struct {
+	neo4j.Driver
+	configs []neo4j.SessionConfig
+}
+
+func (d *sessionConfigDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	d.configs = append(d.configs, config)
+	return nil
+}
+
+func TestConnectionOptionsOverrideOnlyWhatIsSet(t *testing.T) {
+	_, configure, err := newDriverConfig("bolt://localhost", EncryptionOff, TlsOptions{}, ConnectionOptions{MaxPoolSize: 500}, nil)
+	assert.NoError(t, err)
+	conf := config.Config{MaxConnectionPoolSize: 100, ConnectionAcquisitionTimeout: time.Minute}
+	configure(&conf)
+	assert.Equal(t, 500, conf.MaxConnectionPoolSize)
+	assert.Equal(t, time.Minute, conf.ConnectionAcquisitionTimeout)
+}
+
+func TestConnectionOptionsCanDisableRetries(t *testing.T) {
+	_, configure, err := newDriverConfig("bolt://localhost", EncryptionOff, TlsOptions{}, ConnectionOptions{MaxRetryTime: -1, NoKeepalive: true}, nil)
+	assert.NoError(t, err)
+	conf := config.Config{MaxTransactionRetryTime: 30 * time.Second, SocketKeepalive: true}
+	configure(&conf)
+	assert.Equal(t, time.Duration(0), conf.MaxTransactionRetryTime)
+	assert.False(t, conf.SocketKeepalive)
+}
@@ -0,0 +1,176 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pkg/errors"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Broad classes of failure, used to summarize the error groups so it's easy to tell eg. lock contention
+// apart from a broken workload
+const (
+	ErrorClassDeadlock       = "deadlock"
+	ErrorClassLockContention = "lock contention"
+	ErrorClassTransient      = "transient"
+	ErrorClassConstraint     = "constraint violation"
+	ErrorClassSecurity       = "security"
+	ErrorClassClient         = "client error"
+	ErrorClassDatabase       = "database error"
+	ErrorClassConnectivity   = "connectivity"
+	ErrorClassTimeout        = "timeout"
+	ErrorClassUnknown        = "unknown"
+)
+
+// Where a failure came from: the server answering with an error, meaning the database rejected the query, or
+// the client giving up on it, eg. after losing the connection or timing out waiting for a reply. Failures on
+// the client side mean the benchmark couldn't measure the database, and exit with ExitCodeClientFailures.
+const (
+	ErrorOriginServer = "server"
+	ErrorOriginClient = "client"
+)
+
+// Exit code when transactions failed on the client side, see ErrorOriginClient; failures reported by the
+// server exit with 1, like other failed runs
+const ExitCodeClientFailures = 3
+
+// Groups errors by their Neo4j status code, eg. Neo.TransientError.Transaction.DeadlockDetected, or
+// by the kind of driver error if the server didn't give us one
+func groupError(err error) string {
+	msg := err.Error()
+	if strings.HasPrefix(msg, "Server error: [") {
+		return strings.Split(strings.Split(msg, "[")[1], "]")[0]
+	}
+	cause := errors.Cause(err)
+	// The driver gives up retrying with the errors of every attempt; the last one is what failed the transaction
+	if limit, ok := cause.(*neo4j.TransactionExecutionLimit); ok && len(limit.Errors) > 0 {
+		return groupError(limit.Errors[len(limit.Errors)-1])
+	}
+	if serverErr, ok := cause.(*neo4j.Neo4jError); ok {
+		return serverErr.Code
+	}
+	if connErr, ok := cause.(*neo4j.ConnectivityError); ok {
+		if isTimeout(connErr.Inner) {
+			return "Timeout"
+		}
+		return "ServiceUnavailable"
+	}
+	if isTimeout(cause) {
+		return "Timeout"
+	}
+	if strings.Contains(msg, "Connection error") {
+		return "ServiceUnavailable"
+	}
+	if _, ok := cause.(*neo4j.InvalidAuthenticationError); ok {
+		return "SecurityError"
+	}
+	return "unknown"
+}
+
+// Timed out waiting for a pooled connection or a reply from the server, as opposed to the server timing out
+// the transaction, which it reports with a status code
+func isTimeout(err error) bool {
+	if strings.HasPrefix(err.Error(), "Timeout while waiting for connection") {
+		return true
+	}
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// Maps an error group, as returned by groupError, to one of the ErrorClass constants
+func ClassifyErrorGroup(group string) string {
+	parts := strings.Split(group, ".")
+	if len(parts) != 4 || parts[0] != "Neo" {
+		switch group {
+		case "ServiceUnavailable":
+			return ErrorClassConnectivity
+		case "SecurityError":
+			return ErrorClassSecurity
+		case "Timeout":
+			return ErrorClassTimeout
+		}
+		return ErrorClassUnknown
+	}
+	classification, category, title := parts[1], parts[2], parts[3]
+	switch {
+	case strings.HasPrefix(title, "TransactionTimedOut"):
+		return ErrorClassTimeout
+	case title == "DeadlockDetected":
+		return ErrorClassDeadlock
+	case strings.HasPrefix(title, "Lock"):
+		return ErrorClassLockContention
+	case category == "Security":
+		return ErrorClassSecurity
+	case title == "ConstraintValidationFailed" || strings.HasPrefix(title, "ConstraintViolation"):
+		return ErrorClassConstraint
+	case classification == "TransientError":
+		return ErrorClassTransient
+	case classification == "ClientError":
+		return ErrorClassClient
+	case classification == "DatabaseError":
+		return ErrorClassDatabase
+	}
+	return ErrorClassUnknown
+}
+
+// Maps an error group, as returned by groupError, to ErrorOriginServer if it has a Neo4j status code, and to
+// ErrorOriginClient otherwise
+func ErrorGroupOrigin(group string) string {
+	if strings.HasPrefix(group, "Neo.") {
+		return ErrorOriginServer
+	}
+	return ErrorOriginClient
+}
+
+// Totals failures reported by the server and failures on the client side
+func (r *Result) FailuresByOrigin() (server, client int64) {
+	for name, group := range r.FailedByErrorGroup {
+		if ErrorGroupOrigin(name) == ErrorOriginServer {
+			server += group.Count
+		} else {
+			client += group.Count
+		}
+	}
+	return server, client
+}
+
+type ErrorClassCount struct {
+	Class string
+	Count int64
+}
+
+// Totals failures by error class, most common first
+func (r *Result) FailuresByErrorClass() []ErrorClassCount {
+	counts := make(map[string]int64)
+	for name, group := range r.FailedByErrorGroup {
+		counts[ClassifyErrorGroup(name)] += group.Count
+	}
+	out := make([]ErrorClassCount, 0, len(counts))
+	for class, count := range counts {
+		out = append(out, ErrorClassCount{Class: class, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Class < out[j].Class
+	})
+	return out
+}
+
+// Error group names, most common first
+func (r *Result) SortedErrorGroups() []string {
+	names := make([]string, 0, len(r.FailedByErrorGroup))
+	for name := range r.FailedByErrorGroup {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := r.FailedByErrorGroup[names[i]], r.FailedByErrorGroup[names[j]]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
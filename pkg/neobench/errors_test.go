@@ -0,0 +1,92 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestClassifyErrorGroup(t *testing.T) {
+	cases := map[string]string{
+		"Neo.TransientError.Transaction.DeadlockDetected":       ErrorClassDeadlock,
+		"Neo.TransientError.Transaction.LockClientStopped":      ErrorClassLockContention,
+		"Neo.TransientError.Transaction.LockAcquisitionTimeout": ErrorClassLockContention,
+		"Neo.TransientError.General.OutOfMemoryError":           ErrorClassTransient,
+		"Neo.ClientError.Schema.ConstraintValidationFailed":     ErrorClassConstraint,
+		"Neo.ClientError.Security.Unauthorized":                 ErrorClassSecurity,
+		"Neo.ClientError.Statement.SyntaxError":                 ErrorClassClient,
+		"Neo.DatabaseError.General.UnknownError":                ErrorClassDatabase,
+		"Neo.ClientError.Transaction.TransactionTimedOut":       ErrorClassTimeout,
+		"ServiceUnavailable":                                    ErrorClassConnectivity,
+		"Timeout":                                               ErrorClassTimeout,
+		"unknown":                                               ErrorClassUnknown,
+	}
+	for group, expected := range cases {
+		assert.Equal(t, expected, ClassifyErrorGroup(group), group)
+	}
+}
+
+func TestGroupServerError(t *testing.T) {
+	err := fmt.Errorf("Server error: [Neo.TransientError.Transaction.DeadlockDetected] ForsetiClient can't acquire lock")
+	assert.Equal(t, "Neo.TransientError.Transaction.DeadlockDetected", groupError(err))
+}
+
+func TestGroupDriverErrors(t *testing.T) {
+	serverErr := &neo4j.Neo4jError{Code: "Neo.ClientError.Schema.ConstraintValidationFailed", Msg: "already exists"}
+	assert.Equal(t, "Neo.ClientError.Schema.ConstraintValidationFailed", groupError(serverErr))
+	assert.Equal(t, "Neo.ClientError.Schema.ConstraintValidationFailed", groupError(&neo4j.TransactionExecutionLimit{
+		Cause:  "timeout (exceeded max retry time: 30s)",
+		Errors: []error{fmt.Errorf("Connection error: reset"), serverErr},
+	}))
+	assert.Equal(t, "ServiceUnavailable", groupError(&neo4j.ConnectivityError{Inner: fmt.Errorf("connection refused")}))
+	assert.Equal(t, "Timeout", groupError(&neo4j.ConnectivityError{Inner: &net.OpError{Op: "read", Err: timeoutError{}}}))
+}
+
+func TestGroupClientTimeouts(t *testing.T) {
+	assert.Equal(t, "Timeout", groupError(fmt.Errorf("Timeout while waiting for connection to any of [[localhost:7687]]: context deadline exceeded")))
+	assert.Equal(t, "Timeout", groupError(&net.OpError{Op: "read", Err: timeoutError{}}))
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestFailuresByOrigin(t *testing.T) {
+	result := NewResult("neo4j", "test")
+	result.Scripts["s"] = &ScriptResult{ScriptName: "s", Failed: 10, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+	result.FailedByErrorGroup["Neo.ClientError.Statement.SyntaxError"] = FailureGroup{Count: 2, FirstFailure: fmt.Errorf("bad")}
+	result.FailedByErrorGroup["Neo.TransientError.Transaction.DeadlockDetected"] = FailureGroup{Count: 3, FirstFailure: fmt.Errorf("deadlock")}
+	result.FailedByErrorGroup["ServiceUnavailable"] = FailureGroup{Count: 4, FirstFailure: fmt.Errorf("gone")}
+	result.FailedByErrorGroup["Timeout"] = FailureGroup{Count: 1, FirstFailure: fmt.Errorf("slow")}
+
+	server, client := result.FailuresByOrigin()
+	assert.Equal(t, int64(5), server)
+	assert.Equal(t, int64(5), client)
+
+	s := strings.Builder{}
+	writeErrorReport(result, &s)
+	assert.Contains(t, s.String(), "    server, the database rejected the query  5  (50.000 %)\n")
+
+	report := NewJsonReport("throughput", result, nil, false)
+	assert.Equal(t, ErrorOriginServer, report.Errors[0].Origin)
+	assert.Equal(t, ErrorOriginClient, report.Errors[3].Origin)
+}
+
+func TestFailuresByErrorClass(t *testing.T) {
+	result := NewResult("neo4j", "test")
+	result.FailedByErrorGroup["Neo.TransientError.Transaction.DeadlockDetected"] = FailureGroup{Count: 3}
+	result.FailedByErrorGroup["Neo.TransientError.Transaction.LockClientStopped"] = FailureGroup{Count: 5}
+	result.FailedByErrorGroup["Neo.TransientError.Transaction.LockAcquisitionTimeout"] = FailureGroup{Count: 1}
+
+	assert.Equal(t, []ErrorClassCount{
+		{Class: ErrorClassLockContention, Count: 6},
+		{Class: ErrorClassDeadlock, Count: 3},
+	}, result.FailuresByErrorClass())
+	assert.Equal(t, "Neo.TransientError.Transaction.LockClientStopped", result.SortedErrorGroups()[0])
+}
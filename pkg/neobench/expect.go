@@ -0,0 +1,86 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Declares what the statement before it should return, eg. \expect rows = 1 or \expect balance = $delta; see
+// Statement.Expect. Value is evaluated for each unit of work, unless Text is set, for a quoted string.
+type ExpectCommand struct {
+	// Column each row must have Value in, or empty to expect Value rows
+	Column string
+	Value  Expression
+	Text   *string
+}
+
+func (c ExpectCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	var value interface{}
+	if c.Text != nil {
+		value = *c.Text
+	} else {
+		var err error
+		if value, err = c.Value.Eval(ctx); err != nil {
+			return err
+		}
+	}
+	if len(uow.Statements) == 0 {
+		return fmt.Errorf("\\expect must follow the statement it checks")
+	}
+	last := &uow.Statements[len(uow.Statements)-1]
+	last.Expect = append(last.Expect, Expectation{Column: c.Column, Value: value})
+	return nil
+}
+
+// A value a statement must return, see ExpectCommand
+type Expectation struct {
+	Column string
+	Value  interface{}
+}
+
+// Checks one row a statement returned against its expectations, row counting from 1; returns a description
+// of the first mismatch, or an empty string if the row is as expected
+func checkRow(expect []Expectation, record *neo4j.Record, row int64) string {
+	for _, e := range expect {
+		if e.Column == "" {
+			continue
+		}
+		actual, found := record.Get(e.Column)
+		if !found {
+			return fmt.Sprintf("expected a column %s, but it was not returned", e.Column)
+		}
+		if !expectedValue(e.Value, actual) {
+			return fmt.Sprintf("expected %s = %v, got %v in row %d", e.Column, e.Value, actual, row)
+		}
+	}
+	return ""
+}
+
+// Checks how many rows a statement returned against its expectations, like checkRow
+func checkRowCount(expect []Expectation, rows int64) string {
+	for _, e := range expect {
+		if e.Column == "" && !expectedValue(e.Value, rows) {
+			return fmt.Sprintf("expected %v rows, got %d", e.Value, rows)
+		}
+	}
+	return ""
+}
+
+// Integers and floats compare by value, so \expect total = 10 matches a sum returned as 10.0
+func expectedValue(expected, actual interface{}) bool {
+	if e, ok := toFloat(expected); ok {
+		a, ok := toFloat(actual)
+		return ok && a == e
+	}
+	return expected == actual
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,179 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Splits a comma-separated list of addresses, eg. "bolt://core1:7687,core2:7687,core3:7687". Addresses without
+// a scheme take the scheme of the first one, and all of them must use the same scheme.
+func ParseAddresses(spec string) ([]string, error) {
+	var addresses []string
+	scheme := ""
+	for _, raw := range strings.Split(spec, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "://") {
+			if scheme == "" {
+				return nil, fmt.Errorf("invalid address '%s', the first address needs a scheme, eg. neo4j://%s", raw, raw)
+			}
+			raw = scheme + "://" + raw
+		}
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address '%s': %s", raw, err)
+		}
+		if scheme == "" {
+			scheme = parsed.Scheme
+		} else if parsed.Scheme != scheme {
+			return nil, fmt.Errorf("addresses must all use the same scheme, got %s and %s", scheme, parsed.Scheme)
+		}
+		addresses = append(addresses, raw)
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no address given")
+	}
+	return addresses, nil
+}
+
+// Spreads sessions across drivers for several servers, and moves a session on to the next server when its
+// current one is unreachable, so a run survives rolling restarts. The transaction that hit the connection
+// error still fails and is reported; the ones after it go to the next server.
+type FailoverDriver struct {
+	drivers []neo4j.Driver
+	mu      sync.Mutex
+	next    int
+}
+
+func NewFailoverDriver(drivers []neo4j.Driver) *FailoverDriver {
+	return &FailoverDriver{drivers: drivers}
+}
+
+func (d *FailoverDriver) Target() url.URL {
+	return d.drivers[0].Target()
+}
+
+// Sessions start on the servers in turn, so workers are spread evenly across them
+func (d *FailoverDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	d.mu.Lock()
+	start := d.next
+	d.next = (d.next + 1) % len(d.drivers)
+	d.mu.Unlock()
+
+	// Sessions only connect once they run something, so that is where failing over happens
+	return &failoverSession{drivers: d.drivers, config: config, current: start, session: d.drivers[start].NewSession(config)}
+}
+
+// Succeeds if any of the servers is reachable
+func (d *FailoverDriver) VerifyConnectivity() error {
+	var err error
+	for _, driver := range d.drivers {
+		if err = driver.VerifyConnectivity(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (d *FailoverDriver) IsEncrypted() bool {
+	return d.drivers[0].IsEncrypted()
+}
+
+func (d *FailoverDriver) Close() error {
+	var firstErr error
+	for _, driver := range d.drivers {
+		if err := driver.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type failoverSession struct {
+	drivers []neo4j.Driver
+	config  neo4j.SessionConfig
+	current int
+	session neo4j.Session
+	// Guards replacing session against Interrupt, which is called from other goroutines
+	mut         sync.Mutex
+	interrupted bool
+}
+
+// Moves on to the next server if err says the current one is unreachable, or stopped answering
+func (s *failoverSession) failover(err error) {
+	if err == nil || len(s.drivers) == 1 {
+		return
+	}
+	if class := ClassifyErrorGroup(groupError(err)); class != ErrorClassConnectivity && class != ErrorClassTimeout {
+		return
+	}
+	next := (s.current + 1) % len(s.drivers)
+	_ = s.session.Close()
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.session, s.current = s.drivers[next].NewSession(s.config), next
+	if s.interrupted {
+		interrupt(s.session)
+	}
+}
+
+func (s *failoverSession) Interrupt() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.interrupted = true
+	interrupt(s.session)
+}
+
+func (s *failoverSession) LastBookmarks() neo4j.Bookmarks {
+	return s.session.LastBookmarks()
+}
+
+func (s *failoverSession) LastBookmark() string {
+	return s.session.LastBookmark()
+}
+
+func (s *failoverSession) BeginTransaction(configurers ...func(*neo4j.TransactionConfig)) (neo4j.Transaction, error) {
+	tx, err := s.session.BeginTransaction(configurers...)
+	s.failover(err)
+	return tx, err
+}
+
+func (s *failoverSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	result, err := s.session.ReadTransaction(work, configurers...)
+	s.failover(err)
+	return result, err
+}
+
+func (s *failoverSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	result, err := s.session.WriteTransaction(work, configurers...)
+	s.failover(err)
+	return result, err
+}
+
+func (s *failoverSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	result, err := s.session.Run(cypher, params, configurers...)
+	s.failover(err)
+	return result, err
+}
+
+func (s *failoverSession) Close() error {
+	return s.session.Close()
+}
+
+// Creates drivers from each factory in turn, for connect-per-transaction runs against several servers
+func RoundRobinDriverFactory(factories []DriverFactory) DriverFactory {
+	var mu sync.Mutex
+	next := 0
+	return func() (neo4j.Driver, error) {
+		mu.Lock()
+		factory := factories[next]
+		next = (next + 1) % len(factories)
+		mu.Unlock()
+		return factory()
+	}
+}
@@ -0,0 +1,64 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAddresses(t *testing.T) {
+	addresses, err := ParseAddresses("bolt://core1:7687, core2:7687,bolt://core3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bolt://core1:7687", "bolt://core2:7687", "bolt://core3"}, addresses)
+
+	_, err = ParseAddresses("bolt://core1:7687,neo4j://core2:7687")
+	assert.Error(t, err)
+	_, err = ParseAddresses("core1:7687")
+	assert.Error(t, err)
+}
+
+func TestFailoverDriverSpreadsSessionsAndFailsOver(t *testing.T) {
+	up := &namedDriver{name: "core1"}
+	down := &namedDriver{name: "core2", down: true}
+	driver := NewFailoverDriver([]neo4j.Driver{down, up})
+
+	session := driver.NewSession(neo4j.SessionConfig{})
+	_, err := session.WriteTransaction(nil)
+	assert.Error(t, err)
+	server, err := session.WriteTransaction(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "core1", server)
+
+	// The next session starts on the next server
+	session = driver.NewSession(neo4j.SessionConfig{})
+	server, err = session.WriteTransaction(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "core1", server)
+}
+
+type namedDriver struct {
+	neo4j.Driver
+	name string
+	down bool
+}
+
+func (d *namedDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return &namedSession{driver: d}
+}
+
+type namedSession struct {
+	neo4j.Session
+	driver *namedDriver
+}
+
+func (s *namedSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	if s.driver.down {
+		return nil, fmt.Errorf("Connection error: dial tcp %s:7687: connect: connection refused", s.driver.name)
+	}
+	return s.driver.name, nil
+}
+
+func (s *namedSession) Close() error {
+	return nil
+}
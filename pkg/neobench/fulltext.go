@@ -0,0 +1,77 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Name of the full-text index InitFulltext creates over :Document(body)
+const FulltextIndexName = "neobenchDocuments"
+
+// Search-heavy traffic: looks up the top 10 documents for a term, picking terms with a zipfian skew so a few
+// common terms take most searches, like real search traffic. Document bodies are drawn from term1 to term10000.
+const Fulltext = `
+\set term random_zipfian(1, 10000, 1.1)
+CALL db.index.fulltext.queryNodes("` + FulltextIndexName + `", "term" + $term)
+YIELD node, score
+RETURN node.id, score
+LIMIT 10;
+`
+
+// Creates 10000 * scale documents of 50 terms each, with common terms far more frequent than rare ones, and
+// the full-text index over them. The index is created after the documents and awaited, so the run does not
+// measure index population.
+func InitFulltext(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	numDocuments := 10000 * scale
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	if err := createSchema(session, opts, out, "CREATE CONSTRAINT ON (d:Document) ASSERT d.id IS UNIQUE"); err != nil {
+		return err
+	}
+
+	cp, err := startCheckpoint(session, "fulltext", out)
+	if err != nil {
+		return err
+	}
+	existingDocumentNum, err := cp.existingIds(session, "Document", "id")
+	if err != nil {
+		return err
+	}
+
+	// exp(rand() * log(10000)) is log-uniform over 1 to 10000, roughly the zipfian shape of natural language
+	err = loadBatches(driver, dbName, opts, cp, "create documents", existingDocumentNum+1, numDocuments, `UNWIND range($start, $end) AS documentId
+CREATE (:Document {id: documentId, body: reduce(body = "", i IN range(1, 50) |
+  body + " term" + toInteger(exp(rand() * log(10000))))})
+`, nil, out)
+	if err != nil {
+		return err
+	}
+
+	out.ReportProgress(ProgressReport{
+		Section:      "init",
+		Step:         "create full-text index",
+		Completeness: 0,
+	})
+	result, err := session.Run("CALL db.indexes() YIELD name WHERE name = $name RETURN count(*)", map[string]interface{}{
+		"name": FulltextIndexName,
+	})
+	if err != nil {
+		return err
+	}
+	result.Next()
+	if result.Record().Values[0].(int64) == 0 {
+		_, err = session.Run("CALL db.index.fulltext.createNodeIndex($name, ['Document'], ['body'])", map[string]interface{}{
+			"name": FulltextIndexName,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if _, err = session.Run("CALL db.awaitIndexes(3600)", nil); err != nil {
+		return err
+	}
+	return cp.finish(session)
+}
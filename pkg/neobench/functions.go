@@ -0,0 +1,184 @@
+package neobench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A function scripts can call on top of the builtin ones, see RegisterFunction. Args are the evaluated
+// arguments, each an int64, float64 or string; the result should be one of those too, or a list or map of them.
+// Functions that need randomness should draw from ctx.Rand, so runs with the same seed get the same values.
+type Function func(ctx *ScriptContext, args []interface{}) (interface{}, error)
+
+var builtinFunctions = map[string]bool{
+	"abs": true, "int": true, "debug": true, "double": true, "greatest": true, "least": true, "pi": true, "sqrt": true,
+	"random": true, "random_exponential": true, "random_gaussian": true, "random_zipfian": true,
+	"random_partitioned": true, "partition_lb": true, "partition_ub": true,
+	"first_name": true, "last_name": true, "name": true, "city": true, "text": true, "date": true,
+}
+
+var functionNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var functionsMut sync.RWMutex
+var functions = make(map[string]Function)
+
+// Makes f callable from scripts as name(...); fails if a builtin or another registered function has the name.
+// Functions must be registered before the scripts calling them run, and can't be unregistered.
+func RegisterFunction(name string, f Function) error {
+	if err := checkFunctionName(name); err != nil {
+		return err
+	}
+	if builtinFunctions[name] {
+		return fmt.Errorf("can't register %s(), there is a builtin function with that name", name)
+	}
+	functionsMut.Lock()
+	defer functionsMut.Unlock()
+	if _, found := functions[name]; found {
+		return fmt.Errorf("function %s() is already registered", name)
+	}
+	functions[name] = f
+	return nil
+}
+
+func checkFunctionName(name string) error {
+	if !functionNamePattern.MatchString(name) {
+		return fmt.Errorf("function name must be letters, digits and underscores, not starting with a digit, got '%s'", name)
+	}
+	return nil
+}
+
+func lookupFunction(name string) (Function, bool) {
+	functionsMut.RLock()
+	defer functionsMut.RUnlock()
+	f, found := functions[name]
+	return f, found
+}
+
+// A function answered by another process, for functions that aren't written in Go, or that need something
+// only another program has, like a service to draw ids from. The process gets one json object per line on
+// stdin for each call, {"function": "<name>", "args": [...]}, and must answer each with a line of its own,
+// {"value": ...} or {"error": "..."}. Calls go one at a time, so a slow process slows down every client
+// that calls it.
+type ExternalFunction struct {
+	Name    string
+	Command string
+
+	mut    sync.Mutex
+	closer func() error
+	in     io.WriteCloser
+	out    *bufio.Reader
+}
+
+type externalCall struct {
+	Function string        `json:"function"`
+	Args     []interface{} `json:"args"`
+}
+
+type externalAnswer struct {
+	Value interface{} `json:"value"`
+	Error string      `json:"error"`
+}
+
+// Parses "<name>=<command>", as given to --function
+func ParseExternalFunction(spec string) (*ExternalFunction, error) {
+	eq := strings.Index(spec, "=")
+	if eq < 0 || strings.TrimSpace(spec[eq+1:]) == "" {
+		return nil, fmt.Errorf("function %q should be <name>=<command>, eg. \"customer_id=./customer-ids.py\"", spec)
+	}
+	name := strings.TrimSpace(spec[:eq])
+	if err := checkFunctionName(name); err != nil {
+		return nil, err
+	}
+	return &ExternalFunction{Name: name, Command: strings.TrimSpace(spec[eq+1:])}, nil
+}
+
+// Starts the process and registers the function, see RegisterFunction
+func (f *ExternalFunction) Start() error {
+	cmd := shellCommand(f.Command)
+	// Let the process explain itself if it fails
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start function %s: %s", f.Name, err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start function %s: %s", f.Name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start function %s: %s", f.Name, err)
+	}
+	f.in, f.out, f.closer = in, bufio.NewReader(out), cmd.Wait
+	if err := RegisterFunction(f.Name, f.Call); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return nil
+}
+
+func (f *ExternalFunction) Call(ctx *ScriptContext, args []interface{}) (interface{}, error) {
+	request, err := json.Marshal(externalCall{Function: f.Name, Args: args})
+	if err != nil {
+		return nil, err
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if _, err := f.in.Write(append(request, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to call %s: %s", f.Command, err)
+	}
+	line, err := f.out.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read answer from %s: %s", f.Command, err)
+	}
+	var answer externalAnswer
+	decoder := json.NewDecoder(strings.NewReader(string(line)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&answer); err != nil {
+		return nil, fmt.Errorf("%s answered with invalid json: %s", f.Command, err)
+	}
+	if answer.Error != "" {
+		return nil, fmt.Errorf("%s", answer.Error)
+	}
+	return fromJsonValue(answer.Value), nil
+}
+
+// Ends the process by closing its stdin, and waits for it to exit
+func (f *ExternalFunction) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	_ = f.in.Close()
+	if err := f.closer(); err != nil {
+		return fmt.Errorf("function %s: %s", f.Name, err)
+	}
+	return nil
+}
+
+// Numbers in json answers become int64 if they are whole, like literals in scripts
+func fromJsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case []interface{}:
+		for i := range value {
+			value[i] = fromJsonValue(value[i])
+		}
+	case map[string]interface{}:
+		for k := range value {
+			value[k] = fromJsonValue(value[k])
+		}
+	}
+	return v
+}
@@ -0,0 +1,55 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestScriptsCallRegisteredFunctions(t *testing.T) {
+	assert.NoError(t, RegisterFunction("customer_id", func(ctx *ScriptContext, args []interface{}) (interface{}, error) {
+		region, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a region, got %v", args[0])
+		}
+		return fmt.Sprintf("%s-%d", region, ctx.Rand.Int63n(10)+args[1].(int64)), nil
+	}))
+	assert.Error(t, RegisterFunction("customer_id", nil))
+	assert.Error(t, RegisterFunction("random", nil))
+	assert.Error(t, RegisterFunction("2fast", nil))
+
+	script, err := Parse("functiontest", `\set id customer_id(city(), 100 + 1)
+MATCH (c:Customer {id: $id}) RETURN c;`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	assert.Regexp(t, `.+-(10[1-9]|110)$`, uow.Statements[0].Params["id"])
+
+	script, err = Parse("functiontest", `\set id customer_id(1, 2)
+RETURN $id;`, 1)
+	assert.NoError(t, err)
+	_, err = script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.EqualError(t, err, "in customer_id(1, 2): expected a region, got 1")
+}
+
+func TestExternalFunctionAnswersOverStdio(t *testing.T) {
+	_, err := ParseExternalFunction("no_command")
+	assert.Error(t, err)
+
+	f, err := ParseExternalFunction(`order_ids = while read call; do echo '{"value": [1, 2.5, "three"]}'; done`)
+	assert.NoError(t, err)
+	assert.Equal(t, "order_ids", f.Name)
+	assert.NoError(t, f.Start())
+	value, err := f.Call(nil, []interface{}{int64(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(1), 2.5, "three"}, value)
+	assert.NoError(t, f.Close())
+
+	failing, err := ParseExternalFunction(`no_ids_left=read call; echo '{"error": "out of ids"}'`)
+	assert.NoError(t, err)
+	assert.NoError(t, failing.Start())
+	_, err = failing.Call(nil, nil)
+	assert.EqualError(t, err, "out of ids")
+	assert.NoError(t, failing.Close())
+}
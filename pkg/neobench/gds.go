@@ -0,0 +1,88 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Name of the in-memory graph InitGds projects and the Gds procedures run against
+const GdsGraphName = "neobench"
+
+// A rotation of Graph Data Science algorithms over the projected graph, in stats mode so the cost measured is the
+// algorithm rather than streaming its results back. The projection lives in the memory of the server that ran
+// init, so these are sent as writes: in a cluster, that is the leader, where init ran.
+var Gds = []BuiltinQuery{
+	{Name: "pagerank", Weight: 1, Script: `
+CALL gds.pageRank.stats("` + GdsGraphName + `", {maxIterations: 20})
+YIELD ranIterations, didConverge
+RETURN ranIterations, didConverge;
+`},
+	{Name: "wcc", Weight: 1, Script: `
+CALL gds.wcc.stats("` + GdsGraphName + `")
+YIELD componentCount
+RETURN componentCount;
+`},
+	{Name: "node-similarity", Weight: 1, Script: `
+CALL gds.nodeSimilarity.stats("` + GdsGraphName + `", {topK: 10})
+YIELD nodesCompared, similarityPairs
+RETURN nodesCompared, similarityPairs;
+`},
+}
+
+// Creates 10000 * scale pages that each link to 10 random others, and projects them into the graph catalog. The
+// projection is in memory only, so init has to run again after the server restarts; it replaces an existing one.
+func InitGds(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	numPages := 10000 * scale
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	if err := createSchema(session, opts, out, "CREATE CONSTRAINT ON (p:Page) ASSERT p.id IS UNIQUE"); err != nil {
+		return err
+	}
+
+	cp, err := startCheckpoint(session, "gds", out)
+	if err != nil {
+		return err
+	}
+	existingPageNum, err := cp.existingIds(session, "Page", "id")
+	if err != nil {
+		return err
+	}
+
+	// Pages are all created before linking, so links can point at any of them; a rerun only links new pages
+	err = loadBatches(driver, dbName, opts, cp, "create pages", existingPageNum+1, numPages, `UNWIND range($start, $end) AS pageId
+CREATE (:Page {id: pageId})
+`, nil, out)
+	if err != nil {
+		return err
+	}
+	err = loadBatches(driver, dbName, opts, cp, "create links", existingPageNum+1, numPages, `UNWIND range($start, $end) AS pageId
+MATCH (page:Page {id: pageId})
+UNWIND range(1, 10) AS i
+MATCH (target:Page {id: toInteger(rand() * $numPages) + 1})
+CREATE (page)-[:LINKS]->(target)
+`, map[string]interface{}{
+		"numPages": numPages,
+	}, out)
+	if err != nil {
+		return err
+	}
+
+	out.ReportProgress(ProgressReport{
+		Section:      "init",
+		Step:         "project graph",
+		Completeness: 0,
+	})
+	if err = dropGdsGraph(dbName, driver); err != nil {
+		return err
+	}
+	_, err = session.Run("CALL gds.graph.create($name, 'Page', 'LINKS')", map[string]interface{}{
+		"name": GdsGraphName,
+	})
+	if err != nil {
+		return err
+	}
+	return cp.finish(session)
+}
@@ -0,0 +1,330 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"math/rand"
+	"os"
+	"strings"
+	"text/scanner"
+)
+
+// A synthetic graph, as described by an --init-generator file: nodes of each label, with counts that grow with the
+// scale, and relationships between them with a degree distribution. Counts, degrees and property values are
+// expressions, like in \set, so they can use $scale and random functions:
+//
+//	node Person 1000 * $scale
+//	  name = name()
+//	  age = random(18, 80)
+//	relationship KNOWS Person -> Person degree random_zipfian(1, 50, 1.5)
+//	  since = date(10)
+type GeneratorSpec struct {
+	Name          string
+	Nodes         []NodeSpec
+	Relationships []RelationshipSpec
+}
+
+// Nodes numbered from 1 to Count, in an id property
+type NodeSpec struct {
+	Label      string
+	Count      Expression
+	Properties []PropertySpec
+}
+
+// Degree relationships from each From node, each to the To node with the id Target picks, or to a uniformly random
+// one if Target is not set. Degree and Target can use $id of the From node, and $from_count and $to_count.
+type RelationshipSpec struct {
+	Type       string
+	From       string
+	To         string
+	Degree     Expression
+	Target     Expression
+	Properties []PropertySpec
+}
+
+// A property, set to Value evaluated for each node or relationship; node properties can use $id, and relationship
+// properties $from and $to
+type PropertySpec struct {
+	Name  string
+	Value Expression
+}
+
+func ParseGenerator(filename, spec string) (GeneratorSpec, error) {
+	var s scanner.Scanner
+	s.Init(strings.NewReader(spec))
+	s.Filename = filename
+	s.Whitespace ^= 1 << '\n' // don't skip newlines
+
+	c := &parseContext{
+		s: s,
+	}
+
+	g := GeneratorSpec{Name: filename}
+	for !c.done {
+		tok := c.Peek()
+		if tok == scanner.EOF {
+			break
+		} else if tok == '\n' {
+			c.Next()
+			continue
+		}
+		switch keyword := ident(c); keyword {
+		case "node":
+			node := NodeSpec{Label: ident(c)}
+			node.Count = expr(c)
+			node.Properties = generatorProperties(c)
+			g.Nodes = append(g.Nodes, node)
+		case "relationship":
+			rel := RelationshipSpec{Type: ident(c), From: ident(c)}
+			expect(c, '-')
+			expect(c, '>')
+			rel.To = ident(c)
+			if kw := ident(c); kw != "degree" && !c.done {
+				c.fail(fmt.Errorf("expected 'degree', got '%s'", kw))
+			}
+			rel.Degree = expr(c)
+			if c.Peek() == scanner.Ident {
+				if kw := ident(c); kw != "target" {
+					c.fail(fmt.Errorf("expected 'target' or end of line, got '%s'", kw))
+				}
+				rel.Target = expr(c)
+			}
+			rel.Properties = generatorProperties(c)
+			g.Relationships = append(g.Relationships, rel)
+		default:
+			if !c.done {
+				c.fail(fmt.Errorf("expected 'node' or 'relationship', got '%s'", keyword))
+			}
+		}
+	}
+	if c.err != nil {
+		return GeneratorSpec{}, c.err
+	}
+
+	labels := make(map[string]bool)
+	for _, node := range g.Nodes {
+		if labels[node.Label] {
+			return GeneratorSpec{}, fmt.Errorf("%s: node %s is declared more than once", filename, node.Label)
+		}
+		labels[node.Label] = true
+	}
+	for _, rel := range g.Relationships {
+		for _, label := range []string{rel.From, rel.To} {
+			if !labels[label] {
+				return GeneratorSpec{}, fmt.Errorf("%s: relationship %s is between %s and %s, but there is no node %s", filename, rel.Type, rel.From, rel.To, label)
+			}
+		}
+	}
+	return g, nil
+}
+
+// The end of the line a node or relationship is declared on, and the property lines that follow it
+func generatorProperties(c *parseContext) []PropertySpec {
+	generatorEndOfLine(c)
+	var props []PropertySpec
+	for !c.done {
+		tok := c.Peek()
+		if tok == '\n' {
+			c.Next()
+			continue
+		}
+		if tok != scanner.Ident || c.peekText == "node" || c.peekText == "relationship" {
+			return props
+		}
+		prop := PropertySpec{Name: ident(c)}
+		expect(c, '=')
+		prop.Value = expr(c)
+		props = append(props, prop)
+		generatorEndOfLine(c)
+	}
+	return props
+}
+
+func generatorEndOfLine(c *parseContext) {
+	switch tok := c.Peek(); tok {
+	case '\n':
+		c.Next()
+	case scanner.EOF:
+	default:
+		c.fail(fmt.Errorf("expected end of line, got '%s'", c.peekText))
+	}
+}
+
+// Creates the graph, tracked by a checkpoint like builtins, so it can be grown to a larger scale and resumed if
+// interrupted. Values are derived from seed and the id of each node, so the same seed and scale produce the same
+// graph however batches are split across workers. Unless opts skips the schema, ids get uniqueness constraints,
+// which relationships are created through.
+func (g GeneratorSpec) Init(vars map[string]interface{}, seed int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	gen := &ContentGenerator{Seed: seed}
+	counts := make(map[string]int64)
+	for _, node := range g.Nodes {
+		count, err := evalGeneratorInt(node.Count, gen.random("count:"+node.Label, 0), vars, nil)
+		if err != nil {
+			return fmt.Errorf("%s: count of %s: %s", g.Name, node.Label, err)
+		}
+		counts[node.Label] = count
+	}
+	// Evaluate everything once up front, so a mistake fails the init before anything is created; the batches
+	// themselves can't report errors, and leave out properties that fail
+	for _, node := range g.Nodes {
+		if _, err := g.nodeContent(gen, node, vars)(1); err != nil {
+			return err
+		}
+	}
+	for _, rel := range g.Relationships {
+		if _, err := g.relationshipContent(gen, rel, vars, counts)(1); err != nil {
+			return err
+		}
+	}
+
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	var constraints []string
+	for _, node := range g.Nodes {
+		constraints = append(constraints, fmt.Sprintf("CREATE CONSTRAINT ON (n:%s) ASSERT n.id IS UNIQUE", node.Label))
+	}
+	if err := createSchema(session, opts, out, constraints...); err != nil {
+		return err
+	}
+	cp, err := startCheckpoint(session, "generator:"+g.Name, out)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]int64)
+	for _, node := range g.Nodes {
+		existing[node.Label], err = cp.existingIds(session, node.Label, "id")
+		if err != nil {
+			return err
+		}
+	}
+	for _, node := range g.Nodes {
+		content := g.nodeContent(gen, node, vars)
+		err = loadBatches(driver, dbName, opts, cp, "create "+node.Label, existing[node.Label]+1, counts[node.Label],
+			fmt.Sprintf("UNWIND range($start, $end) AS id CREATE (n:%s {id: id}) SET n += $content[id - $start]", node.Label),
+			map[string]interface{}{"content": batchContent(func(id int64) map[string]interface{} {
+				props, _ := content(id)
+				return props
+			})}, out)
+		if err != nil {
+			return err
+		}
+	}
+	for _, rel := range g.Relationships {
+		// Only nodes this init created get relationships, so growing the graph leaves the existing part as it was
+		content := g.relationshipContent(gen, rel, vars, counts)
+		err = loadBatches(driver, dbName, opts, cp, fmt.Sprintf("create (:%s)-[:%s]->(:%s)", rel.From, rel.Type, rel.To), existing[rel.From]+1, counts[rel.From],
+			fmt.Sprintf(`UNWIND range($start, $end) AS id
+MATCH (a:%s {id: id})
+UNWIND $content[id - $start].rels AS rel
+MATCH (b:%s {id: rel.to})
+CREATE (a)-[r:%s]->(b) SET r = rel.props`, rel.From, rel.To, rel.Type),
+			map[string]interface{}{"content": batchContent(func(id int64) map[string]interface{} {
+				rels, _ := content(id)
+				return rels
+			})}, out)
+		if err != nil {
+			return err
+		}
+	}
+	return cp.finish(session)
+}
+
+// Properties of the node with the given id
+func (g GeneratorSpec) nodeContent(gen *ContentGenerator, node NodeSpec, vars map[string]interface{}) func(id int64) (map[string]interface{}, error) {
+	return func(id int64) (map[string]interface{}, error) {
+		props, err := evalGeneratorProperties(node.Properties, gen.random("node:"+node.Label, id), vars, map[string]interface{}{
+			"id": id,
+		})
+		if err != nil {
+			return props, fmt.Errorf("%s: node %s: %s", g.Name, node.Label, err)
+		}
+		return props, nil
+	}
+}
+
+// Relationships from the node with the given id, as {rels: [{to, props}]}
+func (g GeneratorSpec) relationshipContent(gen *ContentGenerator, rel RelationshipSpec, vars map[string]interface{}, counts map[string]int64) func(id int64) (map[string]interface{}, error) {
+	return func(id int64) (map[string]interface{}, error) {
+		r := gen.random("relationship:"+rel.From+":"+rel.Type+":"+rel.To, id)
+		relVars := map[string]interface{}{
+			"id":         id,
+			"from_count": counts[rel.From],
+			"to_count":   counts[rel.To],
+		}
+		rels := make([]interface{}, 0)
+		degree, err := evalGeneratorInt(rel.Degree, r, vars, relVars)
+		if err != nil {
+			return map[string]interface{}{"rels": rels}, fmt.Errorf("%s: degree of %s: %s", g.Name, rel.Type, err)
+		}
+		for i := int64(0); i < degree; i++ {
+			var to int64
+			if rel.Target.Kind == nullExpr {
+				if counts[rel.To] < 1 {
+					break
+				}
+				to = 1 + r.Int63n(counts[rel.To])
+			} else if to, err = evalGeneratorInt(rel.Target, r, vars, relVars); err != nil {
+				return map[string]interface{}{"rels": rels}, fmt.Errorf("%s: target of %s: %s", g.Name, rel.Type, err)
+			}
+			props, err := evalGeneratorProperties(rel.Properties, r, vars, map[string]interface{}{
+				"from": id,
+				"to":   to,
+			})
+			if err != nil {
+				return map[string]interface{}{"rels": rels}, fmt.Errorf("%s: relationship %s: %s", g.Name, rel.Type, err)
+			}
+			rels = append(rels, map[string]interface{}{"to": to, "props": props})
+		}
+		return map[string]interface{}{"rels": rels}, nil
+	}
+}
+
+// Evaluates e with vars and extra, the variables of one node or relationship, to an integer; doubles are truncated
+func evalGeneratorInt(e Expression, r *rand.Rand, vars, extra map[string]interface{}) (int64, error) {
+	value, err := e.Eval(generatorContext(r, vars, extra))
+	if err != nil {
+		return 0, err
+	}
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("expected a number from %s, got %v", e.String(), value)
+	}
+}
+
+// Evaluates each property, leaving out those that fail after returning the first error
+func evalGeneratorProperties(props []PropertySpec, r *rand.Rand, vars, extra map[string]interface{}) (map[string]interface{}, error) {
+	ctx := generatorContext(r, vars, extra)
+	values := make(map[string]interface{}, len(props))
+	var firstErr error
+	for _, prop := range props {
+		value, err := prop.Value.Eval(ctx)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("property %s: %s", prop.Name, err)
+			}
+			continue
+		}
+		values[prop.Name] = value
+	}
+	return values, firstErr
+}
+
+func generatorContext(r *rand.Rand, vars, extra map[string]interface{}) *ScriptContext {
+	all := make(map[string]interface{}, len(vars)+len(extra))
+	for k, v := range vars {
+		all[k] = v
+	}
+	for k, v := range extra {
+		all[k] = v
+	}
+	return &ScriptContext{Stderr: os.Stderr, Vars: all, Rand: r}
+}
@@ -0,0 +1,81 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseGenerator(t *testing.T) {
+	g, err := ParseGenerator("social.gen", `// A small social network
+node Person 1000 * $scale
+  name = name()
+  age = random(18, 80)
+
+node City 20
+relationship LIVES_IN Person -> City degree 1
+relationship KNOWS Person -> Person degree random_zipfian(1, 50, 1.5) target random(1, $to_count)
+  since = date(10)
+`)
+
+	assert.NoError(t, err)
+	assert.Len(t, g.Nodes, 2)
+	assert.Equal(t, "Person", g.Nodes[0].Label)
+	assert.Equal(t, "*(1000, :scale)", g.Nodes[0].Count.String())
+	assert.Equal(t, []string{"name", "age"}, []string{g.Nodes[0].Properties[0].Name, g.Nodes[0].Properties[1].Name})
+	assert.Empty(t, g.Nodes[1].Properties)
+	assert.Len(t, g.Relationships, 2)
+	assert.Equal(t, RelationshipSpec{Type: "LIVES_IN", From: "Person", To: "City", Degree: Expression{Kind: intExpr, Payload: int64(1)}}, g.Relationships[0])
+	assert.Equal(t, "random(1, :to_count)", g.Relationships[1].Target.String())
+	assert.Equal(t, "since", g.Relationships[1].Properties[0].Name)
+}
+
+func TestParseGeneratorErrors(t *testing.T) {
+	_, err := ParseGenerator("bad.gen", "nodes Person 10\n")
+	assert.EqualError(t, err, "expected 'node' or 'relationship', got 'nodes' (at bad.gen:1:6)")
+
+	_, err = ParseGenerator("bad.gen", "node Person 10\nrelationship KNOWS Person -> Robot degree 1\n")
+	assert.EqualError(t, err, "bad.gen: relationship KNOWS is between Person and Robot, but there is no node Robot")
+
+	_, err = ParseGenerator("bad.gen", "node Person 10 20\n")
+	assert.EqualError(t, err, "expected end of line, got '20' (at bad.gen:1:18)")
+}
+
+func TestGeneratorContentIsDeterministic(t *testing.T) {
+	g, err := ParseGenerator("social.gen", `node Person 10
+  name = name()
+  bio = text(20)
+  double = $id * 2
+relationship KNOWS Person -> Person degree 3
+  weight = random(1, 100)
+`)
+	assert.NoError(t, err)
+	vars := map[string]interface{}{"scale": int64(1)}
+	counts := map[string]int64{"Person": 10}
+
+	first, err := g.nodeContent(&ContentGenerator{Seed: 1}, g.Nodes[0], vars)(7)
+	assert.NoError(t, err)
+	again, _ := g.nodeContent(&ContentGenerator{Seed: 1}, g.Nodes[0], vars)(7)
+	other, _ := g.nodeContent(&ContentGenerator{Seed: 2}, g.Nodes[0], vars)(7)
+	assert.Equal(t, first, again)
+	assert.NotEqual(t, first, other)
+	assert.Equal(t, int64(14), first["double"])
+	assert.IsType(t, "", first["name"])
+
+	rels, err := g.relationshipContent(&ContentGenerator{Seed: 1}, g.Relationships[0], vars, counts)(7)
+	assert.NoError(t, err)
+	assert.Len(t, rels["rels"], 3)
+	for _, rel := range rels["rels"].([]interface{}) {
+		to := rel.(map[string]interface{})["to"].(int64)
+		assert.True(t, to >= 1 && to <= 10, "target %d outside of 1 to 10", to)
+		assert.Contains(t, rel.(map[string]interface{})["props"], "weight")
+	}
+}
+
+func TestGeneratorContentReportsUnknownVariables(t *testing.T) {
+	g, err := ParseGenerator("social.gen", "node Person 10\n  age = $nope\n")
+	assert.NoError(t, err)
+
+	_, err = g.nodeContent(&ContentGenerator{Seed: 1}, g.Nodes[0], nil)(1)
+
+	assert.EqualError(t, err, "social.gen: node Person: property age: this variable is not defined: nope")
+}
@@ -0,0 +1,95 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Number of reporting points per halving of the distance to 100%, same default as HdrHistogram uses
+const hgrmTicksPerHalfDistance = 5
+
+// Writes one .hgrm percentile distribution file per script in result to dir, for use with
+// HdrHistogram plotters. Values are in milliseconds.
+func WriteHgrmFiles(dir string, result Result) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create histogram output directory %s: %s", dir, err)
+	}
+	for _, script := range result.Scripts {
+		path := filepath.Join(dir, hgrmFileName(script.ScriptName))
+		if err := writeHgrmFile(path, script.Latencies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHgrmFile(path string, histo *hdrhistogram.Histogram) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create histogram file %s: %s", path, err)
+	}
+	defer f.Close()
+	if err := WritePercentileDistribution(f, histo, 1000.0); err != nil {
+		return fmt.Errorf("failed to write histogram file %s: %s", path, err)
+	}
+	return nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func hgrmFileName(scriptName string) string {
+	name := unsafeFileNameChars.ReplaceAllString(filepath.Base(scriptName), "_")
+	return strings.TrimSuffix(name, ".script") + ".hgrm"
+}
+
+// Writes histo in the HdrHistogram percentile distribution format; recorded values are divided by
+// valueUnitRatio, eg. 1000 to report microsecond recordings in milliseconds.
+func WritePercentileDistribution(w io.Writer, histo *hdrhistogram.Histogram, valueUnitRatio float64) error {
+	s := strings.Builder{}
+	s.WriteString(fmt.Sprintf("%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)"))
+
+	total := histo.TotalCount()
+	if total > 0 {
+		for _, percentile := range hgrmPercentiles() {
+			value := float64(valueAtPercentile(histo, percentile)) / valueUnitRatio
+			count := int64(math.Ceil(float64(total) * percentile / 100))
+			if percentile == 0 {
+				count = 1
+			}
+			if percentile == 100 {
+				s.WriteString(fmt.Sprintf("%12.3f %2.12f %10d\n", value, 1.0, count))
+			} else {
+				s.WriteString(fmt.Sprintf("%12.3f %2.12f %10d %14.2f\n", value, percentile/100, count, 1/(1-percentile/100)))
+			}
+		}
+	}
+
+	s.WriteString(fmt.Sprintf("#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", histo.Mean()/valueUnitRatio, histo.StdDev()/valueUnitRatio))
+	s.WriteString(fmt.Sprintf("#[Max     = %12.3f, Total count    = %12d]\n", float64(histo.Max())/valueUnitRatio, total))
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// The percentile reporting levels used by HdrHistogram: evenly spaced ticks that get denser as they
+// approach 100%, halving the distance to 100% every hgrmTicksPerHalfDistance steps.
+func hgrmPercentiles() []float64 {
+	out := make([]float64, 0)
+	level := 0.0
+	for level < 100 {
+		out = append(out, level)
+		halvings := math.Floor(math.Log2(100/(100-level))) + 1
+		level += 100 / (hgrmTicksPerHalfDistance * math.Pow(2, halvings))
+		// Stop once we're closer to 100% than float precision in the output lets us tell apart
+		if 100-level < 1e-10 {
+			break
+		}
+	}
+	return append(out, 100)
+}
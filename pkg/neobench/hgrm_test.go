@@ -0,0 +1,30 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestWritePercentileDistribution(t *testing.T) {
+	histo := hdrhistogram.New(0, 60*60*1000000, 3)
+	for i := int64(1); i <= 1000; i++ {
+		assert.NoError(t, histo.RecordValue(i*1000))
+	}
+
+	s := strings.Builder{}
+	assert.NoError(t, WritePercentileDistribution(&s, histo, 1000.0))
+
+	lines := strings.Split(s.String(), "\n")
+	assert.Equal(t, "       Value     Percentile TotalCount 1/(1-Percentile)", lines[0])
+	assert.Equal(t, "       1.000 0.000000000000          1           1.00", lines[2])
+	assert.Equal(t, "     500.223 0.500000000000        500           2.00", lines[7])
+	assert.Contains(t, s.String(), "    1000.447 1.000000000000       1000\n")
+	assert.Contains(t, s.String(), "#[Max     =     1000.447, Total count    =         1000]\n")
+}
+
+func TestHgrmFileName(t *testing.T) {
+	assert.Equal(t, "builtin_tpcb-like.hgrm", hgrmFileName("builtin:tpcb-like"))
+	assert.Equal(t, "read.hgrm", hgrmFileName("/tmp/workloads/read.script"))
+}
@@ -0,0 +1,275 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A command or HTTP request run at set times during a benchmark, eg. to kill the leader of a cluster or
+// partition the network, so the impact shows up in the results. See ParseHook for the syntax.
+type Hook struct {
+	Spec string
+	// Offset from the start of the run at which the hook first fires
+	At time.Duration
+	// If non-zero, the hook fires again every Every after At, until the run ends
+	Every time.Duration
+	// Either a shell command, or an HTTP request with Method to Url
+	Command string
+	Method  string
+	Url     string
+}
+
+// Parses a hook from "<when>: <action>", where when is a duration from the start of the run, eg. 2m, or
+// "every 5m", optionally followed by "from 1m" to start somewhere other than after the first interval. The
+// action is "exec <shell command>" or "http [method] <url>", with POST as the default method.
+func ParseHook(spec string) (Hook, error) {
+	hook := Hook{Spec: strings.TrimSpace(spec)}
+	colon := strings.Index(spec, ":")
+	if colon < 0 {
+		return hook, fmt.Errorf("hook %q should be <when>: <action>, eg. \"2m: exec ./kill-leader.sh\"", spec)
+	}
+	when, action := strings.Fields(spec[:colon]), strings.TrimSpace(spec[colon+1:])
+
+	var err error
+	switch {
+	case len(when) == 1:
+		hook.At, err = time.ParseDuration(when[0])
+	case len(when) == 2 && when[0] == "every":
+		hook.Every, err = time.ParseDuration(when[1])
+		hook.At = hook.Every
+	case len(when) == 4 && when[0] == "every" && when[2] == "from":
+		if hook.Every, err = time.ParseDuration(when[1]); err == nil {
+			hook.At, err = time.ParseDuration(when[3])
+		}
+	default:
+		return hook, fmt.Errorf("hook %q should start with a duration, or every <duration> [from <duration>]", spec)
+	}
+	if err != nil {
+		return hook, fmt.Errorf("invalid time in hook %q: %s", spec, err)
+	}
+	if hook.At < 0 || hook.Every < 0 || (len(when) > 1 && hook.Every == 0) {
+		return hook, fmt.Errorf("hook %q must fire at a positive time from the start of the run", spec)
+	}
+
+	kind, rest := action, ""
+	if space := strings.IndexAny(action, " \t"); space >= 0 {
+		kind, rest = action[:space], strings.TrimSpace(action[space:])
+	}
+	switch kind {
+	case "exec":
+		hook.Command = rest
+	case "http":
+		fields := strings.Fields(rest)
+		switch len(fields) {
+		case 1:
+			hook.Method, hook.Url = http.MethodPost, fields[0]
+		case 2:
+			hook.Method, hook.Url = strings.ToUpper(fields[0]), fields[1]
+		}
+	default:
+		return hook, fmt.Errorf("hook %q should run exec <command> or http [method] <url>, got '%s'", spec, kind)
+	}
+	if hook.Command == "" && hook.Url == "" {
+		return hook, fmt.Errorf("hook %q is missing the command or url to run", spec)
+	}
+	return hook, nil
+}
+
+// Something that happened during a run that explains changes in its metrics, like a hook killing a server
+type RunEvent struct {
+	Time time.Time
+	// What happened, eg. the spec of the hook that fired
+	Name string
+	// Empty if it went as planned, otherwise what went wrong
+	Err string
+}
+
+// Runs hooks at their times from start, until stopped; each firing is passed to sinks and kept for the report
+type HookRunner struct {
+	hooks  []Hook
+	start  time.Time
+	client *http.Client
+	sinks  []EventSink
+	errors func(err error)
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+	mut    sync.Mutex
+	events []RunEvent
+}
+
+// Starts running hooks relative to start; errors from sinks go to onError, failed hooks are events of their own
+func StartHooks(hooks []Hook, start time.Time, sinks []EventSink, onError func(err error)) *HookRunner {
+	r := &HookRunner{
+		hooks:  hooks,
+		start:  start,
+		client: &http.Client{Timeout: 30 * time.Second},
+		sinks:  sinks,
+		errors: onError,
+		stopCh: make(chan struct{}),
+	}
+	for _, hook := range hooks {
+		r.wg.Add(1)
+		go r.schedule(hook)
+	}
+	return r
+}
+
+func (r *HookRunner) schedule(hook Hook) {
+	defer r.wg.Done()
+	next := r.start.Add(hook.At)
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-r.stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		r.fire(hook)
+		if hook.Every == 0 {
+			return
+		}
+		next = next.Add(hook.Every)
+	}
+}
+
+func (r *HookRunner) fire(hook Hook) {
+	event := RunEvent{Time: time.Now(), Name: hook.Spec}
+	if err := r.run(hook); err != nil {
+		event.Err = err.Error()
+	}
+	r.mut.Lock()
+	r.events = append(r.events, event)
+	r.mut.Unlock()
+	for _, sink := range r.sinks {
+		if err := sink.WriteEvent(event); err != nil {
+			r.errors(err)
+		}
+	}
+}
+
+func (r *HookRunner) run(hook Hook) error {
+	if hook.Command != "" {
+		output, err := shellCommand(hook.Command).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	req, err := http.NewRequest(hook.Method, hook.Url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// A Cypher script or shell command run once before the workers start or after they stop, eg. to reset
+// counters, clear caches or snapshot statistics. See ParseStageHook.
+type StageHook struct {
+	Spec string
+	// Either a shell command, or a script whose statements are run in order
+	Command string
+	Script  *Script
+}
+
+// Parses "exec <shell command>", or the path of a script file; scripts use the workload format, so \set and
+// friends work, but each statement runs in a transaction of its own, like \init sections do
+func ParseStageHook(spec string) (StageHook, error) {
+	hook := StageHook{Spec: strings.TrimSpace(spec)}
+	if strings.HasPrefix(hook.Spec, "exec ") {
+		hook.Command = strings.TrimSpace(hook.Spec[len("exec "):])
+		return hook, nil
+	}
+	if hook.Spec == "" || hook.Spec == "exec" {
+		return hook, fmt.Errorf("hook should be exec <command> or the path of a cypher file, got %q", spec)
+	}
+	content, err := ioutil.ReadFile(hook.Spec)
+	if err != nil {
+		return hook, fmt.Errorf("failed to read hook script at %s: %s", hook.Spec, err)
+	}
+	script, err := Parse(hook.Spec, string(content), 1)
+	if err != nil {
+		return hook, err
+	}
+	hook.Script = &script
+	return hook, nil
+}
+
+// Runs the hook to completion. Command output and any rows the statements return go to ctx.Stderr, so
+// statistics a hook snapshots end up in the log of the run.
+func (h StageHook) Run(ctx ScriptContext, dbName string, driver neo4j.Driver) error {
+	if ctx.Stderr == nil {
+		ctx.Stderr = os.Stderr
+	}
+	if h.Command != "" {
+		cmd := shellCommand(h.Command)
+		cmd.Stdout, cmd.Stderr = ctx.Stderr, ctx.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("hook %s failed: %s", h.Spec, err)
+		}
+		return nil
+	}
+	uow, err := h.Script.Eval(ctx)
+	if err != nil {
+		return fmt.Errorf("hook %s failed: %s", h.Spec, err)
+	}
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+	for i, statement := range uow.Statements {
+		result, err := session.Run(statement.Query, statement.Params)
+		if err != nil {
+			return fmt.Errorf("hook %s: statement %d failed: %s", h.Spec, i+1, err)
+		}
+		for result.Next() {
+			record := result.Record()
+			fields := make([]string, 0, len(record.Keys))
+			for j, key := range record.Keys {
+				fields = append(fields, fmt.Sprintf("%s=%v", key, record.Values[j]))
+			}
+			_, _ = fmt.Fprintf(ctx.Stderr, "%s: %s\n", h.Spec, strings.Join(fields, " "))
+		}
+		if _, err = result.Consume(); err != nil {
+			return fmt.Errorf("hook %s: statement %d failed: %s", h.Spec, i+1, err)
+		}
+	}
+	return nil
+}
+
+// Runs command with the shell of the platform, so it can use pipes, globs and the like
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// Stops hooks that haven't fired yet, waits for any running now, and returns the events of those that fired
+func (r *HookRunner) Stop() []RunEvent {
+	close(r.stopCh)
+	r.wg.Wait()
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.events
+}
@@ -0,0 +1,152 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseHook(t *testing.T) {
+	hook, err := ParseHook("2m: exec ./kill-leader.sh --force")
+	assert.NoError(t, err)
+	assert.Equal(t, Hook{Spec: "2m: exec ./kill-leader.sh --force", At: 2 * time.Minute, Command: "./kill-leader.sh --force"}, hook)
+
+	hook, err = ParseHook("every 5m: http http://chaos:8080/partition")
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, hook.At)
+	assert.Equal(t, 5*time.Minute, hook.Every)
+	assert.Equal(t, "POST", hook.Method)
+	assert.Equal(t, "http://chaos:8080/partition", hook.Url)
+
+	hook, err = ParseHook("every 30s from 1m: http delete http://chaos:8080/partition")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, hook.At)
+	assert.Equal(t, 30*time.Second, hook.Every)
+	assert.Equal(t, "DELETE", hook.Method)
+
+	_, err = ParseHook("2m exec ./kill-leader.sh")
+	assert.EqualError(t, err, `hook "2m exec ./kill-leader.sh" should be <when>: <action>, eg. "2m: exec ./kill-leader.sh"`)
+	_, err = ParseHook("2m: ssh leader reboot")
+	assert.EqualError(t, err, `hook "2m: ssh leader reboot" should run exec <command> or http [method] <url>, got 'ssh'`)
+	_, err = ParseHook("every 0s: exec true")
+	assert.Error(t, err)
+}
+
+func TestHooksFireAndAreWrittenToTimeSeries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	hook, err := ParseHook("every 10ms from 0s: http " + server.URL)
+	assert.NoError(t, err)
+	start := time.Now()
+	out := &closableBuilder{}
+	series := NewTimeSeriesWriter(out, start)
+
+	runner := StartHooks([]Hook{hook}, start, []EventSink{series}, func(err error) { t.Error(err) })
+	time.Sleep(50 * time.Millisecond)
+	events := runner.Stop()
+
+	assert.True(t, len(events) >= 2, "expected at least 2 events, got %d", len(events))
+	assert.Equal(t, "", events[0].Err)
+	assert.True(t, strings.HasPrefix(events[1].Err, "status 503 Service Unavailable"), events[1].Err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, strings.Join(timeSeriesColumns, ","), lines[0])
+	assert.Contains(t, lines[1], `,"event: every 10ms from 0s: http `+server.URL+`",,,,,,,`)
+}
+
+type closableBuilder struct {
+	strings.Builder
+}
+
+func (b *closableBuilder) Close() error {
+	return nil
+}
+
+func TestStageHookRunsScriptStatementsInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reset.cypher")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("\\set limit 10\nCALL db.clearQueryCaches();\nMATCH (c:Counter) RETURN count(c) AS counters LIMIT $limit;\n"), 0644))
+	hook, err := ParseStageHook(path)
+	assert.NoError(t, err)
+	session := &stageSession{}
+	stderr := &strings.Builder{}
+
+	err = hook.Run(ScriptContext{Stderr: stderr, Vars: map[string]interface{}{}}, "neo4j", &stageDriver{session: session})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CALL db.clearQueryCaches()", "MATCH (c:Counter) RETURN count(c) AS counters LIMIT $limit"}, session.queries)
+	assert.Equal(t, int64(10), session.params[1]["limit"])
+	assert.Equal(t, path+": counters=3\n"+path+": counters=3\n", stderr.String())
+}
+
+func TestStageHookRunsCommands(t *testing.T) {
+	hook, err := ParseStageHook("exec echo snapshot taken")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo snapshot taken", hook.Command)
+	stderr := &strings.Builder{}
+	assert.NoError(t, hook.Run(ScriptContext{Stderr: stderr}, "neo4j", nil))
+	assert.Equal(t, "snapshot taken\n", stderr.String())
+
+	hook, _ = ParseStageHook("exec exit 3")
+	assert.Error(t, hook.Run(ScriptContext{Stderr: stderr}, "neo4j", nil))
+	_, err = ParseStageHook("no-such-file.cypher")
+	assert.Error(t, err)
+}
+
+type stageDriver struct {
+	neo4j.Driver
+	session *stageSession
+}
+
+func (d *stageDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return d.session
+}
+
+// Records the statements run on it; each returns two rows with a counters column
+type stageSession struct {
+	neo4j.Session
+	queries []string
+	params  []map[string]interface{}
+}
+
+func (s *stageSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	s.queries = append(s.queries, strings.TrimSpace(cypher))
+	s.params = append(s.params, params)
+	if !strings.Contains(cypher, "RETURN") {
+		return &stageResult{next: -1}, nil
+	}
+	return &stageResult{rows: 2, next: -1}, nil
+}
+
+func (s *stageSession) Close() error {
+	return nil
+}
+
+type stageResult struct {
+	neo4j.Result
+	rows int
+	next int
+}
+
+func (r *stageResult) Next() bool {
+	r.next++
+	return r.next < r.rows
+}
+
+func (r *stageResult) Record() *neo4j.Record {
+	return &neo4j.Record{Keys: []string{"counters"}, Values: []interface{}{int64(3)}}
+}
+
+func (r *stageResult) Consume() (neo4j.ResultSummary, error) {
+	return nil, nil
+}
@@ -0,0 +1,488 @@
+package neobench
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Database used over http when none is given; unlike bolt, the http endpoint has no notion of a default database
+const DefaultHttpDatabase = "neo4j"
+
+// Runs transactions against the HTTP transactional endpoint, /db/{name}/tx, behind the same neo4j.Driver interface
+// the bolt driver has, so workers can run the same scripts over either protocol. Transactions are begun
+// together with their first statement, and transient errors are retried like the bolt driver does.
+//
+// Only what neobench itself uses is implemented: summaries carry no statement type, counters or plans, and
+// transaction timeouts are ignored.
+type httpDriver struct {
+	target       url.URL
+	client       *http.Client
+	authHeader   string
+	maxRetryTime time.Duration
+}
+
+// urlStr is the http:// or https:// address of the server, eg. http://localhost:7474. Basic, bearer and no auth
+// are supported. With https, the certificate is verified unless --tls-insecure is given, as browsers would, and
+// --tls-known-hosts does not apply.
+func NewHttpDriver(urlStr string, auth AuthOptions, tlsOptions TlsOptions, conn ConnectionOptions) (neo4j.Driver, error) {
+	target, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %s, %s", urlStr, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("--protocol http needs an http:// or https:// address, got %s", urlStr)
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/")
+
+	d := &httpDriver{target: *target, maxRetryTime: 30 * time.Second}
+	if conn.MaxRetryTime < 0 {
+		d.maxRetryTime = 0
+	} else if conn.MaxRetryTime > 0 {
+		d.maxRetryTime = conn.MaxRetryTime
+	}
+
+	switch auth.Scheme {
+	case "", "basic":
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(auth.User, auth.Password)
+		d.authHeader = req.Header.Get("Authorization")
+	case "bearer":
+		token, err := auth.secret()
+		if err != nil {
+			return nil, err
+		}
+		d.authHeader = "Bearer " + token
+	case "none":
+	default:
+		return nil, fmt.Errorf("%s auth is not supported with --protocol http", auth.Scheme)
+	}
+
+	tlsConfig, err := tlsOptions.httpConfig()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	if conn.ConnectTimeout != 0 {
+		dialer.Timeout = conn.ConnectTimeout
+	}
+	if conn.NoKeepalive {
+		dialer.KeepAlive = -1
+	}
+	poolSize := DefaultMaxPoolSize
+	if conn.MaxPoolSize != 0 {
+		poolSize = conn.MaxPoolSize
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConnsPerHost: poolSize,
+	}
+	if poolSize > 0 {
+		transport.MaxConnsPerHost = poolSize
+	}
+	if conn.MaxLifetime > 0 {
+		transport.IdleConnTimeout = conn.MaxLifetime
+	}
+	d.client = &http.Client{Transport: transport}
+	return d, nil
+}
+
+func (o TlsOptions) httpConfig() (*tls.Config, error) {
+	if o.KnownHostsFile != "" {
+		return nil, fmt.Errorf("--tls-known-hosts is not supported with --protocol http")
+	}
+	if (o.CaFile != "" || o.Verify) && o.Insecure {
+		return nil, fmt.Errorf("--tls-verify or --tls-ca and --tls-insecure are mutually exclusive")
+	}
+	config, err := o.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.InsecureSkipVerify = o.Insecure
+	if o.CaFile != "" {
+		certs, err := loadCertificates(o.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		for _, cert := range certs {
+			config.RootCAs.AddCert(cert)
+		}
+	}
+	return config, nil
+}
+
+func (d *httpDriver) Target() url.URL {
+	return d.target
+}
+
+func (d *httpDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	database := config.DatabaseName
+	if database == "" {
+		database = DefaultHttpDatabase
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &httpSession{driver: d, txUrl: fmt.Sprintf("%s/db/%s/tx", d.target.String(), url.PathEscape(database)), ctx: ctx, cancel: cancel}
+}
+
+func (d *httpDriver) VerifyConnectivity() error {
+	req, err := d.newRequest(context.Background(), http.MethodGet, d.target.String()+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Connection error: %s", err)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (d *httpDriver) newRequest(ctx context.Context, method, endpoint string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if d.authHeader != "" {
+		req.Header.Set("Authorization", d.authHeader)
+	}
+	return req, nil
+}
+
+func (d *httpDriver) IsEncrypted() bool {
+	return d.target.Scheme == "https"
+}
+
+func (d *httpDriver) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
+
+type httpStatement struct {
+	Statement  string                 `json:"statement"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type httpResponse struct {
+	Results []struct {
+		Columns []string `json:"columns"`
+		Data    []struct {
+			Row []interface{} `json:"row"`
+		} `json:"data"`
+	} `json:"results"`
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Formatted like bolt server errors, so they are grouped and classified the same way
+type httpServerError struct {
+	code    string
+	message string
+}
+
+func (e *httpServerError) Error() string {
+	return fmt.Sprintf("Server error: [%s] %s", e.code, e.message)
+}
+
+// Posts statements to endpoint, returning the first result and the Location header, which is the transaction url
+// when a transaction is begun
+func (d *httpDriver) post(ctx context.Context, endpoint string, statements []httpStatement) (*httpResult, string, error) {
+	payload, err := json.Marshal(map[string]interface{}{"statements": statements})
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := d.newRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("Connection error: %s", err)
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("Connection error: %s", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, "", &httpServerError{code: "Neo.ClientError.Security.Unauthorized", message: strings.TrimSpace(string(raw))}
+	}
+	if resp.StatusCode >= 400 {
+		return nil, "", &httpServerError{code: fmt.Sprintf("HTTP %d", resp.StatusCode), message: strings.TrimSpace(string(raw))}
+	}
+
+	var parsed httpResponse
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, "", fmt.Errorf("invalid response from %s: %s", endpoint, err)
+	}
+	if len(parsed.Errors) > 0 {
+		return nil, "", &httpServerError{code: parsed.Errors[0].Code, message: parsed.Errors[0].Message}
+	}
+	result := &httpResult{index: -1}
+	if len(parsed.Results) > 0 {
+		result.keys = parsed.Results[0].Columns
+		for _, data := range parsed.Results[0].Data {
+			result.rows = append(result.rows, convertJsonNumbers(data.Row).([]interface{}))
+		}
+	}
+	return result, resp.Header.Get("Location"), nil
+}
+
+// JSON has only one number type; integers become int64, as they would over bolt
+func convertJsonNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case []interface{}:
+		for i := range value {
+			value[i] = convertJsonNumbers(value[i])
+		}
+		return value
+	case map[string]interface{}:
+		for k := range value {
+			value[k] = convertJsonNumbers(value[k])
+		}
+		return value
+	}
+	return v
+}
+
+// Requests run on ctx, which Interrupt cancels
+type httpSession struct {
+	driver *httpDriver
+	txUrl  string
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (s *httpSession) Interrupt() {
+	s.cancel()
+}
+
+func (s *httpSession) LastBookmarks() neo4j.Bookmarks {
+	return nil
+}
+
+func (s *httpSession) LastBookmark() string {
+	return ""
+}
+
+func (s *httpSession) BeginTransaction(configurers ...func(*neo4j.TransactionConfig)) (neo4j.Transaction, error) {
+	return &httpTransaction{session: s}, nil
+}
+
+func (s *httpSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return s.runTransaction(work)
+}
+
+func (s *httpSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return s.runTransaction(work)
+}
+
+// Retries transient errors with the same backoff as the bolt driver: 1s, doubling, with 20% jitter
+func (s *httpSession) runTransaction(work neo4j.TransactionWork) (interface{}, error) {
+	start := time.Now()
+	delay := time.Second
+	for {
+		tx := &httpTransaction{session: s}
+		result, err := work(tx)
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			_ = tx.Rollback()
+		}
+		if err == nil {
+			return result, nil
+		}
+		serverErr, ok := err.(*httpServerError)
+		if !ok || !strings.Contains(serverErr.code, ".TransientError.") || time.Since(start) >= s.driver.maxRetryTime {
+			return nil, err
+		}
+		jitter := (rand.Float64()*0.4 - 0.2) * float64(delay)
+		time.Sleep(delay + time.Duration(jitter))
+		delay *= 2
+	}
+}
+
+func (s *httpSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	result, _, err := s.driver.post(s.ctx, s.txUrl+"/commit", []httpStatement{{Statement: cypher, Parameters: params}})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *httpSession) Close() error {
+	s.cancel()
+	return nil
+}
+
+// Begun lazily with the first statement, saving a round trip
+type httpTransaction struct {
+	session *httpSession
+	url     string
+	done    bool
+}
+
+func (tx *httpTransaction) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	if tx.done {
+		return nil, fmt.Errorf("transaction is already closed")
+	}
+	endpoint := tx.url
+	if endpoint == "" {
+		endpoint = tx.session.txUrl
+	}
+	result, location, err := tx.session.driver.post(tx.session.ctx, endpoint, []httpStatement{{Statement: cypher, Parameters: params}})
+	if tx.url == "" && location != "" {
+		tx.url = location
+	}
+	if err != nil {
+		// The server rolls the transaction back on any error
+		if _, ok := err.(*httpServerError); ok {
+			tx.done = true
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (tx *httpTransaction) Commit() error {
+	if tx.done {
+		return fmt.Errorf("transaction is already closed")
+	}
+	tx.done = true
+	if tx.url == "" {
+		return nil
+	}
+	_, _, err := tx.session.driver.post(tx.session.ctx, tx.url+"/commit", []httpStatement{})
+	return err
+}
+
+func (tx *httpTransaction) Rollback() error {
+	if tx.done || tx.url == "" {
+		tx.done = true
+		return nil
+	}
+	tx.done = true
+	req, err := tx.session.driver.newRequest(tx.session.ctx, http.MethodDelete, tx.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := tx.session.driver.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Connection error: %s", err)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (tx *httpTransaction) Close() error {
+	return tx.Rollback()
+}
+
+// All rows arrive with the response, so results are fully buffered
+type httpResult struct {
+	keys  []string
+	rows  [][]interface{}
+	index int
+}
+
+func (r *httpResult) Keys() ([]string, error) {
+	return r.keys, nil
+}
+
+func (r *httpResult) Next() bool {
+	if r.index+1 >= len(r.rows) {
+		r.index = len(r.rows)
+		return false
+	}
+	r.index++
+	return true
+}
+
+func (r *httpResult) NextRecord(record **neo4j.Record) bool {
+	next := r.Next()
+	*record = r.Record()
+	return next
+}
+
+func (r *httpResult) PeekRecord(record **neo4j.Record) bool {
+	if r.index+1 >= len(r.rows) {
+		*record = nil
+		return false
+	}
+	*record = &neo4j.Record{Keys: r.keys, Values: r.rows[r.index+1]}
+	return true
+}
+
+func (r *httpResult) Err() error {
+	return nil
+}
+
+func (r *httpResult) Record() *neo4j.Record {
+	if r.index < 0 || r.index >= len(r.rows) {
+		return nil
+	}
+	return &neo4j.Record{Keys: r.keys, Values: r.rows[r.index]}
+}
+
+func (r *httpResult) Collect() ([]*neo4j.Record, error) {
+	var records []*neo4j.Record
+	for r.Next() {
+		records = append(records, r.Record())
+	}
+	return records, nil
+}
+
+func (r *httpResult) Single() (*neo4j.Record, error) {
+	records, _ := r.Collect()
+	if len(records) != 1 {
+		return nil, fmt.Errorf("expected a single record, got %d", len(records))
+	}
+	return records[0], nil
+}
+
+func (r *httpResult) Consume() (neo4j.ResultSummary, error) {
+	r.index = len(r.rows)
+	return httpSummary{}, nil
+}
+
+// The http endpoint reports none of this; StatementType is always unknown, so preflight treats scripts as writes
+type httpSummary struct{}
+
+func (httpSummary) Server() neo4j.ServerInfo                  { return nil }
+func (httpSummary) Query() neo4j.Query                        { return nil }
+func (httpSummary) StatementType() neo4j.StatementType        { return neo4j.StatementTypeUnknown }
+func (httpSummary) Counters() neo4j.Counters                  { return nil }
+func (httpSummary) Plan() neo4j.Plan                          { return nil }
+func (httpSummary) Profile() neo4j.ProfiledPlan               { return nil }
+func (httpSummary) Notifications() []neo4j.Notification       { return nil }
+func (httpSummary) GqlStatusObjects() []neo4j.GqlStatusObject { return nil }
+func (httpSummary) ResultAvailableAfter() time.Duration       { return 0 }
+func (httpSummary) ResultConsumedAfter() time.Duration        { return 0 }
+func (httpSummary) Database() neo4j.DatabaseInfo              { return nil }
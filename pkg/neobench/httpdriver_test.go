@@ -0,0 +1,107 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpDriverRunsTransactionsAgainstTxEndpoint(t *testing.T) {
+	var requests []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "neo4j", user)
+		assert.Equal(t, "secret", password)
+		var body struct {
+			Statements []httpStatement `json:"statements"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch r.URL.Path {
+		case "/db/bench/tx":
+			assert.Equal(t, "RETURN $n AS n", body.Statements[0].Statement)
+			w.Header().Set("Location", server.URL+"/db/bench/tx/7")
+			_, _ = w.Write([]byte(`{"results":[{"columns":["n"],"data":[{"row":[42]}]}],"errors":[]}`))
+		case "/db/bench/tx/7/commit":
+			assert.Empty(t, body.Statements)
+			_, _ = w.Write([]byte(`{"results":[],"errors":[]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	driver, err := NewHttpDriver(server.URL, AuthOptions{User: "neo4j", Password: "secret"}, TlsOptions{}, ConnectionOptions{})
+	assert.NoError(t, err)
+	session := driver.NewSession(neo4j.SessionConfig{DatabaseName: "bench"})
+
+	n, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("RETURN $n AS n", map[string]interface{}{"n": 42})
+		if err != nil {
+			return nil, err
+		}
+		res.Next()
+		return res.Record().Values[0], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+	assert.Equal(t, []string{"POST /db/bench/tx", "POST /db/bench/tx/7/commit"}, requests)
+}
+
+func TestHttpDriverReportsServerErrorsLikeBolt(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		_, _ = w.Write([]byte(`{"results":[],"errors":[{"code":"Neo.ClientError.Schema.ConstraintValidationFailed","message":"already exists"}]}`))
+	}))
+	defer server.Close()
+
+	driver, err := NewHttpDriver(server.URL, AuthOptions{}, TlsOptions{}, ConnectionOptions{})
+	assert.NoError(t, err)
+	session := driver.NewSession(neo4j.SessionConfig{})
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		return tx.Run("CREATE (:Thing {id: 1})", nil)
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "Neo.ClientError.Schema.ConstraintValidationFailed", groupError(err))
+	// Client errors are not retried
+	assert.Equal(t, 1, attempts)
+}
+
+func TestHttpSessionInterruptFailsTheRequestInFlight(t *testing.T) {
+	// Never answers; only returns once the client has gone
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	driver, err := NewHttpDriver(server.URL, AuthOptions{}, TlsOptions{}, ConnectionOptions{})
+	assert.NoError(t, err)
+	session := driver.NewSession(neo4j.SessionConfig{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			return tx.Run("CREATE (:Thing)", nil)
+		})
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	interrupt(session)
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("transaction still running after the session was interrupted")
+	}
+	assert.NoError(t, session.Close())
+}
@@ -0,0 +1,121 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Pushes interval metrics to an InfluxDB write endpoint, or anything else that accepts line protocol
+// over HTTP, eg. http://localhost:8086/write?db=neobench
+type InfluxSink struct {
+	url    string
+	client *http.Client
+	// Tags added to every point, on top of db and script
+	tags map[string]string
+}
+
+func NewInfluxSink(url string, tags map[string]string) *InfluxSink {
+	return &InfluxSink{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		tags:   tags,
+	}
+}
+
+func (s *InfluxSink) WriteInterval(start, end time.Time, interval Result) error {
+	body := strings.Builder{}
+	if err := WriteInfluxLines(&body, end, interval, s.tags); err != nil {
+		return err
+	}
+	return s.post(body.String())
+}
+
+// Writes the event as a point in the "neobench_event" measurement, so dashboards can annotate graphs with it
+func (s *InfluxSink) WriteEvent(event RunEvent) error {
+	body := strings.Builder{}
+	body.WriteString("neobench_event")
+	keys := make([]string, 0, len(s.tags))
+	for k := range s.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if s.tags[k] != "" {
+			body.WriteString(fmt.Sprintf(",%s=%s", escapeInfluxTag(k), escapeInfluxTag(s.tags[k])))
+		}
+	}
+	body.WriteString(fmt.Sprintf(" name=\"%s\",error=\"%s\",failed=%t %d\n",
+		influxFieldEscaper.Replace(event.Name), influxFieldEscaper.Replace(event.Err), event.Err != "", event.Time.UnixNano()))
+	return s.post(body.String())
+}
+
+func (s *InfluxSink) post(body string) error {
+	resp, err := s.client.Post(s.url, "text/plain; charset=utf-8", strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to write to influx at %s: %s", s.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("influx at %s rejected write with status %s: %s", s.url, resp.Status, msg)
+	}
+	return nil
+}
+
+func (s *InfluxSink) Close() error {
+	return nil
+}
+
+// Writes one line protocol point per script in result, in the "neobench" measurement
+func WriteInfluxLines(w io.Writer, timestamp time.Time, result Result, tags map[string]string) error {
+	tagKeys := make([]string, 0, len(tags))
+	for k := range tags {
+		tagKeys = append(tagKeys, k)
+	}
+	// Influx prefers tags sorted by key
+	sort.Strings(tagKeys)
+
+	s := strings.Builder{}
+	for _, script := range result.SortedScripts() {
+		allTags := map[string]string{"db": result.DatabaseName, "script": script.ScriptName}
+		for k, v := range tags {
+			allTags[k] = v
+		}
+		keys := append([]string{"db", "script"}, tagKeys...)
+		sort.Strings(keys)
+
+		s.WriteString("neobench")
+		seen := make(map[string]bool)
+		for _, k := range keys {
+			// Empty tag values are not allowed in line protocol
+			if seen[k] || allTags[k] == "" {
+				continue
+			}
+			seen[k] = true
+			s.WriteString(fmt.Sprintf(",%s=%s", escapeInfluxTag(k), escapeInfluxTag(allTags[k])))
+		}
+		histo := script.Latencies
+		s.WriteString(fmt.Sprintf(" rate=%f,succeeded=%di,failed=%di,p50=%f,p95=%f,p99=%f,max=%f %d\n",
+			script.Rate, script.Succeeded, script.Failed,
+			float64(histo.ValueAtQuantile(50))/1000.0,
+			float64(histo.ValueAtQuantile(95))/1000.0,
+			float64(histo.ValueAtQuantile(99))/1000.0,
+			float64(histo.Max())/1000.0,
+			timestamp.UnixNano()))
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+var influxTagEscaper = strings.NewReplacer(",", "\\,", "=", "\\=", " ", "\\ ")
+
+var influxFieldEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func escapeInfluxTag(v string) string {
+	return influxTagEscaper.Replace(v)
+}
@@ -0,0 +1,29 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteInfluxLines(t *testing.T) {
+	result := NewResult("neo4j", "-c 1")
+	histo := hdrhistogram.New(0, 60*60*1000000, 3)
+	assert.NoError(t, histo.RecordValue(2000))
+	result.Scripts["my script"] = &ScriptResult{
+		ScriptName: "my script",
+		Rate:       1.5,
+		Succeeded:  3,
+		Failed:     1,
+		Latencies:  histo,
+	}
+
+	s := strings.Builder{}
+	err := WriteInfluxLines(&s, time.Unix(10, 0), result, map[string]string{"scenario": "-c 1,-s 2", "run": "a"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "neobench,db=neo4j,run=a,scenario=-c\\ 1\\,-s\\ 2,script=my\\ script "+
+		"rate=1.500000,succeeded=3i,failed=1i,p50=2.000000,p95=2.000000,p99=2.000000,max=2.000000 10000000000\n", s.String())
+}
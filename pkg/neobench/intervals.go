@@ -0,0 +1,234 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// What each script did over one progress interval, kept in saved results so time series can be merged
+// across machines
+type IntervalSample struct {
+	Start   time.Time        `json:"start"`
+	End     time.Time        `json:"end"`
+	Scripts []IntervalScript `json:"scripts"`
+}
+
+type IntervalScript struct {
+	Name      string         `json:"name"`
+	Succeeded int64          `json:"succeeded"`
+	Failed    int64          `json:"failed"`
+	Latencies *wireHistogram `json:"latencies"`
+}
+
+// Result for the interval, with a script result per script, for IntervalSinks
+func (s IntervalSample) Result(databaseName, scenario string) Result {
+	result := NewResult(databaseName, scenario)
+	seconds := s.End.Sub(s.Start).Seconds()
+	for _, script := range s.Scripts {
+		latencies, err := script.Latencies.histogram()
+		if err != nil || latencies == nil {
+			latencies = hdrhistogram.New(0, 60*60*1000000, 3)
+		}
+		sr := &ScriptResult{
+			ScriptName: script.Name,
+			Succeeded:  script.Succeeded,
+			Failed:     script.Failed,
+			Latencies:  latencies,
+		}
+		if seconds > 0 {
+			sr.Rate = float64(script.Succeeded+script.Failed) / seconds
+		}
+		result.Scripts[script.Name] = sr
+	}
+	return result
+}
+
+// Transactions per second over each progress interval of a run, so a run that swings between fast and slow
+// can be told apart from a steady one with the same average
+type ThroughputStats struct {
+	Intervals       int64
+	Sum, SumSquares float64
+	Min, Max        float64
+}
+
+func (t *ThroughputStats) record(rate float64) {
+	if t.Intervals == 0 || rate < t.Min {
+		t.Min = rate
+	}
+	if rate > t.Max {
+		t.Max = rate
+	}
+	t.Intervals++
+	t.Sum += rate
+	t.SumSquares += rate * rate
+}
+
+// Combines the intervals of both, as if they were one run after the other
+func (t ThroughputStats) Add(other ThroughputStats) ThroughputStats {
+	if other.Intervals == 0 {
+		return t
+	}
+	if t.Intervals == 0 {
+		return other
+	}
+	return ThroughputStats{
+		Intervals:  t.Intervals + other.Intervals,
+		Sum:        t.Sum + other.Sum,
+		SumSquares: t.SumSquares + other.SumSquares,
+		Min:        math.Min(t.Min, other.Min),
+		Max:        math.Max(t.Max, other.Max),
+	}
+}
+
+func (t ThroughputStats) Mean() float64 {
+	if t.Intervals == 0 {
+		return 0
+	}
+	return t.Sum / float64(t.Intervals)
+}
+
+func (t ThroughputStats) StdDev() float64 {
+	if t.Intervals == 0 {
+		return 0
+	}
+	mean := t.Mean()
+	// Rounding can take this just below zero for perfectly steady runs
+	return math.Sqrt(math.Max(0, t.SumSquares/float64(t.Intervals)-mean*mean))
+}
+
+// Coefficient of variation, the standard deviation relative to the mean; 0 for a perfectly steady run
+func (t ThroughputStats) CV() float64 {
+	if mean := t.Mean(); mean > 0 {
+		return t.StdDev() / mean
+	}
+	return 0
+}
+
+// IntervalSink that keeps compact samples of each interval, for --save-result
+type IntervalRecorder struct {
+	mut     sync.Mutex
+	samples []IntervalSample
+}
+
+func (r *IntervalRecorder) WriteInterval(start, end time.Time, interval Result) error {
+	sample := IntervalSample{Start: start, End: end}
+	for _, script := range interval.SortedScripts() {
+		sample.Scripts = append(sample.Scripts, IntervalScript{
+			Name:      script.ScriptName,
+			Succeeded: script.Succeeded,
+			Failed:    script.Failed,
+			Latencies: toWireHistogram(script.Latencies),
+		})
+	}
+	r.mut.Lock()
+	r.samples = append(r.samples, sample)
+	r.mut.Unlock()
+	return nil
+}
+
+func (r *IntervalRecorder) Samples() []IntervalSample {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return append([]IntervalSample(nil), r.samples...)
+}
+
+func (r *IntervalRecorder) Close() error {
+	return nil
+}
+
+// Moves the times of the result by offset, eg. to correct for the clock of the machine it ran on being off
+func (r *Result) ShiftClock(offset time.Duration) {
+	if !r.Start.IsZero() {
+		r.Start = r.Start.Add(offset)
+		r.End = r.End.Add(offset)
+	}
+	for i := range r.Events {
+		r.Events[i].Time = r.Events[i].Time.Add(offset)
+	}
+	for i := range r.Recovery {
+		r.Recovery[i].Start = r.Recovery[i].Start.Add(offset)
+		if !r.Recovery[i].Recovered.IsZero() {
+			r.Recovery[i].Recovered = r.Recovery[i].Recovered.Add(offset)
+		}
+	}
+	for i := range r.Intervals {
+		r.Intervals[i].Start = r.Intervals[i].Start.Add(offset)
+		r.Intervals[i].End = r.Intervals[i].End.Add(offset)
+	}
+}
+
+// Combines the interval samples of runs on several machines into one series. Each machine ends its intervals
+// at its own times, so samples are put in steps of the typical interval length, counted from the first
+// sample, by their midpoint; the times should be on the same clock, see ShiftClock.
+func MergeIntervals(results []Result) []IntervalSample {
+	var origin time.Time
+	lengths := make([]time.Duration, 0)
+	for _, result := range results {
+		for _, sample := range result.Intervals {
+			if origin.IsZero() || sample.Start.Before(origin) {
+				origin = sample.Start
+			}
+			lengths = append(lengths, sample.End.Sub(sample.Start))
+		}
+	}
+	if len(lengths) == 0 {
+		return nil
+	}
+	sort.Slice(lengths, func(i, j int) bool { return lengths[i] < lengths[j] })
+	step := lengths[len(lengths)/2].Round(100 * time.Millisecond)
+	if step <= 0 {
+		step = time.Second
+	}
+
+	steps := make(map[int64]map[string]*IntervalScript)
+	latencies := make(map[int64]map[string]*hdrhistogram.Histogram)
+	for _, result := range results {
+		for _, sample := range result.Intervals {
+			mid := sample.Start.Add(sample.End.Sub(sample.Start) / 2)
+			i := int64(mid.Sub(origin) / step)
+			if steps[i] == nil {
+				steps[i] = make(map[string]*IntervalScript)
+				latencies[i] = make(map[string]*hdrhistogram.Histogram)
+			}
+			for _, script := range sample.Scripts {
+				merged := steps[i][script.Name]
+				if merged == nil {
+					merged = &IntervalScript{Name: script.Name}
+					steps[i][script.Name] = merged
+				}
+				merged.Succeeded += script.Succeeded
+				merged.Failed += script.Failed
+				histo, err := script.Latencies.histogram()
+				if err != nil || histo == nil {
+					continue
+				}
+				if latencies[i][script.Name] == nil {
+					latencies[i][script.Name] = histo
+				} else {
+					latencies[i][script.Name].Merge(histo)
+				}
+			}
+		}
+	}
+
+	indexes := make([]int64, 0, len(steps))
+	for i := range steps {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool { return indexes[a] < indexes[b] })
+	merged := make([]IntervalSample, 0, len(indexes))
+	for _, i := range indexes {
+		start := origin.Add(time.Duration(i) * step)
+		sample := IntervalSample{Start: start, End: start.Add(step)}
+		for name, script := range steps[i] {
+			script.Latencies = toWireHistogram(latencies[i][name])
+			sample.Scripts = append(sample.Scripts, *script)
+		}
+		sort.Slice(sample.Scripts, func(a, b int) bool { return sample.Scripts[a].Name < sample.Scripts[b].Name })
+		merged = append(merged, sample)
+	}
+	return merged
+}
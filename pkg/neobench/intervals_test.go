@@ -0,0 +1,64 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMergeIntervalsFromSkewedClocks(t *testing.T) {
+	t0 := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	run := func(clockOffset time.Duration, perInterval int64) Result {
+		recorder := IntervalRecorder{}
+		for i := 0; i < 3; i++ {
+			interval := NewResult("neo4j", "")
+			interval.Scripts["read"] = &ScriptResult{ScriptName: "read", Succeeded: perInterval, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+			assert.NoError(t, interval.Scripts["read"].Latencies.RecordValue(1000))
+			// Each machine ends its intervals a little differently
+			start := t0.Add(clockOffset + time.Duration(i)*10*time.Second + 200*time.Millisecond)
+			assert.NoError(t, recorder.WriteInterval(start, start.Add(10*time.Second), interval))
+		}
+		result := NewResult("neo4j", "")
+		result.Start, result.End = t0.Add(clockOffset), t0.Add(clockOffset+30*time.Second)
+		result.Intervals = recorder.Samples()
+		return result
+	}
+
+	// The second machine's clock is 4s ahead, which would smear its intervals into the neighbouring steps
+	ahead := run(4*time.Second, 200)
+	ahead.ShiftClock(-4 * time.Second)
+	merged := MergeIntervals([]Result{run(0, 100), ahead})
+
+	assert.Len(t, merged, 3)
+	for _, interval := range merged {
+		assert.Equal(t, 10*time.Second, interval.End.Sub(interval.Start))
+		result := interval.Result("neo4j", "")
+		assert.Equal(t, int64(300), result.Scripts["read"].Succeeded)
+		assert.Equal(t, 30.0, result.Scripts["read"].Rate)
+		assert.Equal(t, int64(2), result.Scripts["read"].Latencies.TotalCount())
+	}
+	assert.Equal(t, t0.Add(200*time.Millisecond), merged[0].Start)
+}
+
+func TestThroughputStatsTellSteadyRunsFromSwingingOnes(t *testing.T) {
+	steady, swinging := ThroughputStats{}, ThroughputStats{}
+	for _, rate := range []float64{100, 100, 100, 100} {
+		steady.record(rate)
+	}
+	for _, rate := range []float64{20, 180, 20, 180} {
+		swinging.record(rate)
+	}
+
+	assert.Equal(t, 100.0, steady.Mean())
+	assert.Equal(t, 0.0, steady.CV())
+	assert.Equal(t, 100.0, swinging.Mean())
+	assert.InDelta(t, 80, swinging.StdDev(), 0.001)
+	assert.InDelta(t, 0.8, swinging.CV(), 0.001)
+
+	both := steady.Add(swinging)
+	assert.Equal(t, int64(8), both.Intervals)
+	assert.Equal(t, 20.0, both.Min)
+	assert.Equal(t, 180.0, both.Max)
+	assert.Equal(t, swinging, ThroughputStats{}.Add(swinging))
+}
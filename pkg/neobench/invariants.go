@@ -0,0 +1,82 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"strings"
+)
+
+// Sums over the tpcb-like dataset that its transactions keep in step: each adds the same delta to the balance of
+// an account and a branch, and records it in a :History node. Tellers are left out; the script updates :Tellers
+// rather than the :Teller nodes init creates, and changing it would change what earlier results measured.
+type TPCBTotals struct {
+	AccountBalance int64
+	BranchBalance  int64
+	HistoryDelta   int64
+	HistoryCount   int64
+}
+
+func ReadTPCBTotals(dbName string, driver neo4j.Driver) (TPCBTotals, error) {
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeRead,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	result, err := session.Run(`MATCH (a:Account) WITH sum(a.balance) AS accounts
+MATCH (b:Branch) WITH accounts, sum(b.balance) AS branches
+OPTIONAL MATCH (h:History)
+RETURN accounts, branches, coalesce(sum(h.delta), 0), count(h)`, nil)
+	if err != nil {
+		return TPCBTotals{}, err
+	}
+	if !result.Next() {
+		return TPCBTotals{}, fmt.Errorf("no result summing tpcb-like balances: %v", result.Err())
+	}
+	record := result.Record()
+	return TPCBTotals{
+		AccountBalance: record.Values[0].(int64),
+		BranchBalance:  record.Values[1].(int64),
+		HistoryDelta:   record.Values[2].(int64),
+		HistoryCount:   record.Values[3].(int64),
+	}, nil
+}
+
+// How many tpcb-like transactions of a run committed and failed, across all phases or steps of the run, which
+// report it as eg. "phase 1/builtin:tpcp-like"
+func TPCBTransactions(result Result) (committed, failed int64) {
+	for name, script := range result.Scripts {
+		if name == TPCBLikeScriptName || strings.HasSuffix(name, "/"+TPCBLikeScriptName) {
+			committed += script.Succeeded
+			failed += script.Failed
+		}
+	}
+	return committed, failed
+}
+
+// Checks how the totals changed over a run against the TPC-B consistency conditions, given how many tpcb-like
+// transactions committed and failed. Comparing changes rather than the totals themselves keeps whatever earlier
+// runs left behind out of it. A failed transaction may still have committed, eg. if the connection dropped
+// before the commit was acknowledged, so the history may grow by up to failed more than committed. Returns a
+// description of each violation, or nothing if the run kept the dataset consistent.
+func TPCBViolations(before, after TPCBTotals, committed, failed int64) []string {
+	var violations []string
+	accounts := after.AccountBalance - before.AccountBalance
+	changes := []struct {
+		name  string
+		delta int64
+	}{
+		{"branch balances", after.BranchBalance - before.BranchBalance},
+		{"history deltas", after.HistoryDelta - before.HistoryDelta},
+	}
+	for _, change := range changes {
+		if change.delta != accounts {
+			violations = append(violations, fmt.Sprintf("account balances changed by %d, but %s by %d", accounts, change.name, change.delta))
+		}
+	}
+	history := after.HistoryCount - before.HistoryCount
+	if history < committed || history > committed+failed {
+		violations = append(violations, fmt.Sprintf("history grew by %d entries, but %d transactions committed and %d failed", history, committed, failed))
+	}
+	return violations
+}
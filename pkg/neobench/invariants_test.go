@@ -0,0 +1,44 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTPCBViolations(t *testing.T) {
+	before := TPCBTotals{AccountBalance: 100, BranchBalance: 100, HistoryDelta: 100, HistoryCount: 10}
+
+	consistent := TPCBTotals{AccountBalance: 70, BranchBalance: 70, HistoryDelta: 70, HistoryCount: 15}
+	assert.Empty(t, TPCBViolations(before, consistent, 5, 0))
+	// Two of the failed transactions committed before failing
+	assert.Empty(t, TPCBViolations(before, consistent, 3, 4))
+
+	lostUpdate := TPCBTotals{AccountBalance: 70, BranchBalance: 100, HistoryDelta: 70, HistoryCount: 14}
+	assert.Equal(t, []string{
+		"account balances changed by -30, but branch balances by 0",
+		"history grew by 4 entries, but 5 transactions committed and 0 failed",
+	}, TPCBViolations(before, lostUpdate, 5, 0))
+}
+
+func TestTPCBTransactionsSumsPhases(t *testing.T) {
+	total := NewResult("neo4j", "")
+	for i, phase := range []Phase{{Name: "phase 1"}, {Name: "phase 2"}} {
+		result := NewResult("neo4j", "")
+		result.Scripts[TPCBLikeScriptName] = &ScriptResult{
+			ScriptName: TPCBLikeScriptName,
+			Succeeded:  int64(10 * (i + 1)),
+			Failed:     1,
+			Latencies:  hdrhistogram.New(0, 60*60*1000000, 3),
+		}
+		result.Scripts["reads"] = &ScriptResult{ScriptName: "reads", Succeeded: 100, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+		total.AddPhase(phase, result)
+	}
+
+	committed, failed := TPCBTransactions(total)
+
+	assert.Equal(t, int64(30), committed)
+	assert.Equal(t, int64(2), failed)
+	before := TPCBTotals{HistoryCount: 10}
+	assert.Empty(t, TPCBViolations(before, TPCBTotals{HistoryCount: 40}, committed, failed))
+}
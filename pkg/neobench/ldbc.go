@@ -0,0 +1,198 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Modelled on the short reads (IS1-7) and a subset of the updates (IU2, IU6-8) of the LDBC Social Network
+// Benchmark interactive workload, run against the synthetic social network InitLdbcSnb creates. This is not
+// an audited LDBC run: there is no official data generator or substitution parameters, and the complex reads
+// are left out, but it exercises the same traversal shapes.
+//
+// A scale of 1 has 1000 persons, each with 10 posts and 20 comments; ids are assigned in that order, so
+// messages 1 to 10000 * $scale are posts and the rest are comments.
+var LdbcSnb = []BuiltinQuery{
+	{Name: "is1-person-profile", Weight: 10, Readonly: true, Script: `
+\set personId random(1, 1000 * $scale)
+MATCH (n:Person {id: $personId})-[:IS_LOCATED_IN]->(city:City)
+RETURN n.firstName, n.lastName, n.birthday, n.locationIP, n.browserUsed, city.id, n.gender, n.creationDate;
+`},
+	{Name: "is2-person-recent-messages", Weight: 10, Readonly: true, Script: `
+\set personId random(1, 1000 * $scale)
+MATCH (:Person {id: $personId})<-[:HAS_CREATOR]-(message:Message)
+WITH message ORDER BY message.creationDate DESC LIMIT 10
+MATCH (message)-[:REPLY_OF*0..]->(post:Post)-[:HAS_CREATOR]->(author:Person)
+RETURN message.id, message.content, message.creationDate, post.id, author.id, author.firstName, author.lastName;
+`},
+	{Name: "is3-person-friends", Weight: 10, Readonly: true, Script: `
+\set personId random(1, 1000 * $scale)
+MATCH (:Person {id: $personId})-[r:KNOWS]-(friend:Person)
+RETURN friend.id, friend.firstName, friend.lastName, r.creationDate
+ORDER BY r.creationDate DESC, friend.id ASC;
+`},
+	{Name: "is4-message-content", Weight: 10, Readonly: true, Script: `
+\set messageId random(1, 30000 * $scale)
+MATCH (message:Message {id: $messageId})
+RETURN message.creationDate, message.content;
+`},
+	{Name: "is5-message-creator", Weight: 10, Readonly: true, Script: `
+\set messageId random(1, 30000 * $scale)
+MATCH (:Message {id: $messageId})-[:HAS_CREATOR]->(person:Person)
+RETURN person.id, person.firstName, person.lastName;
+`},
+	{Name: "is6-message-forum", Weight: 10, Readonly: true, Script: `
+\set messageId random(1, 30000 * $scale)
+MATCH (:Message {id: $messageId})-[:REPLY_OF*0..]->(:Post)<-[:CONTAINER_OF]-(forum:Forum)-[:HAS_MODERATOR]->(moderator:Person)
+RETURN forum.id, forum.title, moderator.id, moderator.firstName, moderator.lastName;
+`},
+	{Name: "is7-message-replies", Weight: 10, Readonly: true, Script: `
+\set messageId random(1, 30000 * $scale)
+MATCH (message:Message {id: $messageId})<-[:REPLY_OF]-(reply:Comment)-[:HAS_CREATOR]->(replier:Person)
+OPTIONAL MATCH (message)-[:HAS_CREATOR]->(:Person)-[knows:KNOWS]-(replier)
+RETURN reply.id, reply.content, reply.creationDate, replier.id, replier.firstName, replier.lastName, knows IS NOT NULL
+ORDER BY reply.creationDate DESC, replier.id ASC;
+`},
+	{Name: "iu2-like-post", Weight: 10, Script: `
+\set personId random(1, 1000 * $scale)
+\set postId random(1, 10000 * $scale)
+MATCH (person:Person {id: $personId}), (post:Post {id: $postId})
+CREATE (person)-[:LIKES {creationDate: timestamp()}]->(post);
+`},
+	{Name: "iu6-add-post", Weight: 5, Script: `
+\set personId random(1, 1000 * $scale)
+\set forumId random(1, 100 * $scale)
+MATCH (person:Person {id: $personId}), (forum:Forum {id: $forumId})
+CREATE (forum)-[:CONTAINER_OF]->(:Message:Post {id: randomUUID(), content: "New post", creationDate: timestamp()})-[:HAS_CREATOR]->(person);
+`},
+	{Name: "iu7-add-comment", Weight: 10, Script: `
+\set personId random(1, 1000 * $scale)
+\set messageId random(1, 30000 * $scale)
+MATCH (person:Person {id: $personId}), (parent:Message {id: $messageId})
+CREATE (parent)<-[:REPLY_OF]-(:Message:Comment {id: randomUUID(), content: "New comment", creationDate: timestamp()})-[:HAS_CREATOR]->(person);
+`},
+	{Name: "iu8-add-friendship", Weight: 5, Script: `
+\set personId random(1, 1000 * $scale)
+\set friendId random(1, 1000 * $scale)
+MATCH (person:Person {id: $personId}), (friend:Person {id: $friendId})
+WHERE person <> friend
+MERGE (person)-[r:KNOWS]->(friend)
+ON CREATE SET r.creationDate = timestamp();
+`},
+}
+
+// Creates the social network the LdbcSnb queries run against, scaled by scale; see LdbcSnb for its size.
+// Each person knows 10 others at random, posts go round-robin into forums and comments reply to a random
+// earlier message, so threads form.
+func InitLdbcSnb(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	numPersons := 1000 * scale
+	numForums := 100 * scale
+	numPosts := 10 * numPersons
+	numComments := 20 * numPersons
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	cp, err := startCheckpoint(session, "ldbc-snb", out)
+	if err != nil {
+		return err
+	}
+	// Unlike the other builtins this can't be topped up to a larger scale: comment ids start after the last post of
+	// the scale, so the layout of the whole dataset changes with it. For the same reason, an interrupted init can only
+	// be resumed at its own scale.
+	if cp.resumed {
+		startedScale, err := cp.remember(session, "scale", func() (int64, error) { return scale, nil })
+		if err != nil {
+			return err
+		}
+		if startedScale != scale {
+			return fmt.Errorf("an interrupted init of builtin:ldbc-snb at scale %d is in the database; run -i with -s %d to finish it, or remove it with --cleanup", startedScale, startedScale)
+		}
+	} else {
+		result, err := session.Run("MATCH (p:Person) RETURN COUNT(p) AS n", nil)
+		if err != nil {
+			return err
+		}
+		result.Next()
+		if existing := result.Record().Values[0].(int64); existing == numPersons {
+			out.ReportProgress(ProgressReport{Section: "init", Step: "already initialized", Completeness: 1})
+			return cp.finish(session)
+		} else if existing > 0 {
+			return fmt.Errorf("database has %d Person nodes, expected none or the %d of scale %d; builtin:ldbc-snb needs an empty database to initialize, see --cleanup", existing, numPersons, scale)
+		}
+		if _, err = cp.remember(session, "scale", func() (int64, error) { return scale, nil }); err != nil {
+			return err
+		}
+	}
+
+	err = createSchema(session, opts, out,
+		"CREATE CONSTRAINT ON (p:Person) ASSERT p.id IS UNIQUE",
+		"CREATE CONSTRAINT ON (m:Message) ASSERT m.id IS UNIQUE",
+		"CREATE CONSTRAINT ON (f:Forum) ASSERT f.id IS UNIQUE",
+		"CREATE CONSTRAINT ON (c:City) ASSERT c.id IS UNIQUE",
+		"CREATE INDEX ON :Post(id)",
+	)
+	if err != nil {
+		return err
+	}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create cities", Completeness: 0})
+	if _, err = session.Run(`UNWIND range(1, 100) AS id MERGE (c:City {id: id}) ON CREATE SET c.name = 'City ' + id`, nil); err != nil {
+		return err
+	}
+
+	steps := []struct {
+		step       string
+		start      int64
+		end        int64
+		sequential bool
+		content    batchContent
+		cypher     string
+	}{
+		{"create persons", 1, numPersons, false, opts.Content.persons(), `UNWIND range($start, $end) AS id
+MATCH (city:City {id: id % 100 + 1})
+CREATE (p:Person {id: id, firstName: 'First' + id, lastName: 'Last' + id, gender: CASE id % 2 WHEN 0 THEN 'female' ELSE 'male' END,
+  birthday: 19500101 + id % 50 * 10000, creationDate: timestamp() - toInteger(rand() * 100000000000),
+  locationIP: '10.0.' + (id / 256 % 256) + '.' + (id % 256), browserUsed: 'Firefox'})-[:IS_LOCATED_IN]->(city)
+SET p += coalesce($content[id - $start], {})`},
+		{"create forums", 1, numForums, false, nil, `UNWIND range($start, $end) AS id
+MATCH (moderator:Person {id: id * 10})
+CREATE (:Forum {id: id, title: 'Forum ' + id})-[:HAS_MODERATOR]->(moderator)`},
+		{"create friendships", 1, numPersons, false, nil, `UNWIND range($start, $end) AS id
+MATCH (person:Person {id: id})
+UNWIND range(1, 10) AS i
+WITH person, toInteger(rand() * $numPersons) + 1 AS friendId
+MATCH (friend:Person {id: friendId})
+WHERE friend <> person
+MERGE (person)-[r:KNOWS]->(friend)
+ON CREATE SET r.creationDate = timestamp() - toInteger(rand() * 100000000000)`},
+		{"create posts", 1, numPosts, false, opts.Content.messages(40), `UNWIND range($start, $end) AS id
+MATCH (creator:Person {id: (id - 1) / 10 + 1}), (forum:Forum {id: (id - 1) % $numForums + 1})
+CREATE (forum)-[:CONTAINER_OF]->(post:Message:Post {id: id, content: 'Post ' + id,
+  creationDate: timestamp() - toInteger(rand() * 100000000000)})-[:HAS_CREATOR]->(creator)
+SET post += coalesce($content[id - $start], {})`},
+		// Parents come from earlier batches, so batches have to run one after the other for them to exist
+		{"create comments", numPosts + 1, numPosts + numComments, true, opts.Content.messages(12), `UNWIND range($start, $end) AS id
+MATCH (creator:Person {id: toInteger(rand() * $numPersons) + 1}), (parent:Message {id: toInteger(rand() * ($start - 1)) + 1})
+CREATE (parent)<-[:REPLY_OF]-(comment:Message:Comment {id: id, content: 'Comment ' + id,
+  creationDate: timestamp() - toInteger(rand() * 100000000000)})-[:HAS_CREATOR]->(creator)
+SET comment += coalesce($content[id - $start], {})`},
+	}
+	for _, step := range steps {
+		stepOpts := opts
+		if step.sequential {
+			stepOpts = opts.Sequential()
+		}
+		err = loadBatches(driver, dbName, stepOpts, cp, step.step, step.start, step.end, step.cypher, map[string]interface{}{
+			"numPersons": numPersons,
+			"numForums":  numForums,
+			"content":    step.content,
+		}, out)
+		if err != nil {
+			return err
+		}
+	}
+	return cp.finish(session)
+}
@@ -0,0 +1,29 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestParseLdbcSnb(t *testing.T) {
+	vars := map[string]interface{}{"scale": int64(2)}
+	for _, query := range LdbcSnb {
+		script, err := Parse("builtin:ldbc-snb/"+query.Name, query.Script, query.Weight)
+		assert.NoError(t, err, query.Name)
+		if err != nil {
+			continue
+		}
+		uow, err := script.Eval(ScriptContext{
+			Vars: vars,
+			Rand: rand.New(rand.NewSource(1337)),
+		})
+		assert.NoError(t, err, query.Name)
+		assert.Len(t, uow.Statements, 1, query.Name)
+		for name, value := range uow.Statements[0].Params {
+			if name == "personId" || name == "friendId" {
+				assert.True(t, value.(int64) >= 1 && value.(int64) <= 2000, query.Name)
+			}
+		}
+	}
+}
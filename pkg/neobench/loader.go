@@ -0,0 +1,223 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"sync"
+	"time"
+)
+
+// How -i creates the datasets of builtin workloads
+type InitOptions struct {
+	// Number of concurrent sessions creating batches
+	Workers int
+	// Number of ids each batch creates, each batch is committed on its own
+	BatchSize int64
+	// Leave out the constraints and indexes builtins create, to benchmark without them or with a schema of
+	// your own. Full-text and vector indexes are still created, since their queries can't run without them.
+	SkipSchema bool
+	// If set, nodes get realistic property values from this, rather than only what the queries need
+	Content *ContentGenerator
+}
+
+var DefaultInitOptions = InitOptions{Workers: 1, BatchSize: 5000}
+
+// Same options, but running one batch at a time; for steps where a batch reads what earlier batches created
+func (o InitOptions) Sequential() InitOptions {
+	o.Workers = 1
+	return o
+}
+
+// Creates the constraints and indexes of a builtin, unless opts says to leave them out
+func createSchema(session neo4j.Session, opts InitOptions, out Output, statements ...string) error {
+	if opts.SkipSchema {
+		out.ReportProgress(ProgressReport{Section: "init", Step: "skip schema", Completeness: 1})
+		return nil
+	}
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0})
+	for _, statement := range statements {
+		if _, err := session.Run(statement, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Runs the constraints and indexes in a --init-schema file, in place of those of builtins. The file is parsed like
+// a workload script, so statements end with ; and can use variables, and each runs in its own transaction.
+func RunSchemaFile(path, content string, ctx ScriptContext, dbName string, driver neo4j.Driver, out Output) error {
+	script, err := Parse(path, content, 1)
+	if err != nil {
+		return err
+	}
+	schema := Script{Name: path, Init: script.Commands}
+	return schema.RunInit(ctx, dbName, driver, DefaultInitOptions, out)
+}
+
+// Runs the query of a \load_csv statement for each row of its CSV file, in batches of rows picked with SKIP and
+// LIMIT. The file is read with a header, and each row is given to the query as row. Like the datasets of
+// builtins, the load is tracked in a checkpoint, so an interrupted load resumes from the batches it committed.
+func loadCsv(session neo4j.Session, driver neo4j.Driver, dbName string, opts InitOptions, scriptName string, statement Statement, out Output) error {
+	result, err := session.Run("LOAD CSV WITH HEADERS FROM $csvUrl AS row RETURN count(row)", map[string]interface{}{
+		"csvUrl": statement.LoadCsv,
+	})
+	if err != nil {
+		return err
+	}
+	if !result.Next() {
+		return fmt.Errorf("no row count for %s: %v", statement.LoadCsv, result.Err())
+	}
+	rows := result.Record().Values[0].(int64)
+
+	cp, err := startCheckpoint(session, "csv:"+scriptName+":"+statement.LoadCsv, out)
+	if err != nil {
+		return err
+	}
+	params := map[string]interface{}{"csvUrl": statement.LoadCsv}
+	for k, v := range statement.Params {
+		params[k] = v
+	}
+	err = loadBatches(driver, dbName, opts, cp, "load "+statement.LoadCsv, 1, rows, `LOAD CSV WITH HEADERS FROM $csvUrl AS row
+WITH row SKIP $start - 1 LIMIT $end - $start + 1
+`+statement.Query, params, out)
+	if err != nil {
+		return err
+	}
+	return cp.finish(session)
+}
+
+// Runs cypher for the ids start to end, inclusive, split into batches given as $start and $end alongside params.
+// Batches are spread across opts.Workers sessions, each in its own write transaction, so the driver retries the
+// deadlocks concurrent batches can run into. Each batch is recorded in cp, if given, and batches an interrupted
+// init already committed are skipped. Progress, and the ids created per second, are reported as step.
+func loadBatches(driver neo4j.Driver, dbName string, opts InitOptions, cp *initCheckpoint, step string, start, end int64, cypher string, params map[string]interface{}, out Output) error {
+	if end < start {
+		return nil
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		batchSize = DefaultInitOptions.BatchSize
+	}
+	var committed [][2]int64
+	if cp != nil && cp.resumed {
+		session := driver.NewSession(neo4j.SessionConfig{
+			AccessMode:   neo4j.AccessModeRead,
+			DatabaseName: dbName,
+		})
+		var err error
+		committed, err = cp.committedBatches(session, step)
+		session.Close()
+		if err != nil {
+			return err
+		}
+	}
+	todo := remainingBatches(start, end, batchSize, committed)
+	if len(todo) == 0 {
+		return nil
+	}
+	total := int64(0)
+	for _, batch := range todo {
+		total += batch[1] - batch[0] + 1
+	}
+
+	batches := make(chan [2]int64)
+	// Closed by the first batch to fail, so no more are started
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	go func() {
+		defer close(batches)
+		for _, batch := range todo {
+			select {
+			case batches <- batch:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// Number of ids each finished batch created, or the error it failed with
+	type batchOutcome struct {
+		created int64
+		err     error
+	}
+	outcomes := make(chan batchOutcome)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session := driver.NewSession(neo4j.SessionConfig{
+				AccessMode:   neo4j.AccessModeWrite,
+				DatabaseName: dbName,
+			})
+			defer session.Close()
+			var err error
+			for batch := range batches {
+				select {
+				case <-stop:
+					continue
+				default:
+				}
+				batchStart, batchEnd := batch[0], batch[1]
+				batchParams := map[string]interface{}{
+					"start": batchStart,
+					"end":   batchEnd,
+				}
+				for k, v := range params {
+					if content, ok := v.(batchContent); ok {
+						batchParams[k] = content.batch(batchStart, batchEnd)
+						continue
+					}
+					batchParams[k] = v
+				}
+				_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+					result, err := tx.Run(cypher, batchParams)
+					if err != nil {
+						return nil, err
+					}
+					if _, err = result.Consume(); err != nil {
+						return nil, err
+					}
+					if cp != nil {
+						return nil, cp.recordBatch(tx, step, batchStart, batchEnd)
+					}
+					return nil, nil
+				})
+				if err != nil {
+					stopOnce.Do(func() { close(stop) })
+				}
+				outcomes <- batchOutcome{created: batchEnd - batchStart + 1, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: step, Completeness: 0})
+	began := time.Now()
+	created := int64(0)
+	var firstErr error
+	for outcome := range outcomes {
+		if outcome.err != nil {
+			// Batches already running on other workers still finish
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to %s: %s", step, outcome.err)
+			}
+			continue
+		}
+		created += outcome.created
+		out.ReportProgress(ProgressReport{
+			Section:      "init",
+			Step:         step,
+			Completeness: float64(created) / float64(total),
+			Rate:         float64(created) / time.Since(began).Seconds(),
+		})
+	}
+	return firstErr
+}
@@ -0,0 +1,188 @@
+package neobench
+
+import (
+	"errors"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadBatchesCoversRangeAcrossWorkers(t *testing.T) {
+	driver := &batchDriver{}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := loadBatches(driver, "neo4j", InitOptions{Workers: 4, BatchSize: 3}, nil, "create things", 5, 15,
+		"UNWIND range($start, $end) AS id CREATE (:Thing {id: id, size: $size})", map[string]interface{}{"size": 10}, out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]int64{{5, 7}, {8, 10}, {11, 13}, {14, 15}}, driver.sortedBatches())
+	assert.Equal(t, 4, driver.sessions)
+	for _, params := range driver.params {
+		assert.Equal(t, 10, params["size"])
+	}
+}
+
+func TestLoadBatchesExpandsContentPerBatch(t *testing.T) {
+	driver := &batchDriver{}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+	content := batchContent(func(id int64) map[string]interface{} {
+		return map[string]interface{}{"double": id * 2}
+	})
+
+	err := loadBatches(driver, "neo4j", InitOptions{Workers: 1, BatchSize: 2}, nil, "create things", 1, 3, "",
+		map[string]interface{}{"content": content, "none": batchContent(nil)}, out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"double": int64(2)}, map[string]interface{}{"double": int64(4)}}, driver.params[0]["content"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"double": int64(6)}}, driver.params[1]["content"])
+	assert.Nil(t, driver.params[0]["none"])
+}
+
+func TestLoadBatchesStopsAtFirstError(t *testing.T) {
+	driver := &batchDriver{failAt: 4}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err := loadBatches(driver, "neo4j", InitOptions{Workers: 1, BatchSize: 1}, nil, "create things", 1, 100, "", nil, out)
+
+	assert.EqualError(t, err, "failed to create things: constraint violated")
+	assert.Equal(t, [][2]int64{{1, 1}, {2, 2}, {3, 3}}, driver.sortedBatches())
+}
+
+func TestLoadBatchesResumesFromCheckpoint(t *testing.T) {
+	driver := &batchDriver{committed: [][]interface{}{{int64(4), int64(6)}, {int64(1), int64(3)}, {int64(10), int64(12)}}}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+	cp := &initCheckpoint{dataset: "things", resumed: true}
+
+	err := loadBatches(driver, "neo4j", InitOptions{Workers: 2, BatchSize: 3}, cp, "create things", 1, 14, "", nil, out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]int64{{7, 9}, {13, 14}}, driver.sortedBatches())
+	assert.ElementsMatch(t, [][2]int64{{7, 9}, {13, 14}}, driver.recorded)
+}
+
+func TestLoadBatchesReportsRate(t *testing.T) {
+	stderr := &strings.Builder{}
+	out := &CsvOutput{ErrStream: stderr, OutStream: &strings.Builder{}}
+
+	err := loadBatches(&batchDriver{}, "neo4j", InitOptions{Workers: 1, BatchSize: 10}, nil, "create things", 1, 10, "", nil, out)
+
+	assert.NoError(t, err)
+	assert.Contains(t, stderr.String(), "[init][create things] 0.00%\n")
+	// The second report of a step is rate limited, so the final one may not be printed
+	assert.NotContains(t, stderr.String(), "NaN")
+}
+
+func TestCreateSchema(t *testing.T) {
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	session := &recordingSession{}
+	assert.NoError(t, createSchema(session, DefaultInitOptions, out, "CREATE INDEX ON :A(id)", "CREATE INDEX ON :B(id)"))
+	assert.Len(t, session.ran, 2)
+
+	session = &recordingSession{}
+	assert.NoError(t, createSchema(session, InitOptions{SkipSchema: true}, out, "CREATE INDEX ON :A(id)"))
+	assert.Empty(t, session.ran)
+}
+
+func TestRunSchemaFile(t *testing.T) {
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+	session := &recordingSession{}
+
+	err := RunSchemaFile("schema.cypher", `
+CREATE CONSTRAINT ON (a:Account) ASSERT a.aid IS UNIQUE;
+CREATE INDEX ON :Account(balance);
+`, ScriptContext{Vars: map[string]interface{}{}}, "neo4j", recordingDriver{session: session}, out)
+
+	assert.NoError(t, err)
+	assert.Len(t, session.ran, 2)
+	assert.Equal(t, "CREATE INDEX ON :Account(balance)", session.ran[1].Query)
+}
+
+func TestLoadCsvRunsQueryInBatchesOfRows(t *testing.T) {
+	driver := &batchDriver{}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+	statement := Statement{
+		Query:   "CREATE (:Person {id: toInteger(row.id), team: $team})",
+		Params:  map[string]interface{}{"team": "blue"},
+		LoadCsv: "file:///people.csv",
+	}
+
+	err := loadCsv(&datasetSession{count: 12000}, driver, "neo4j", DefaultInitOptions, "people.script", statement, out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]int64{{1, 5000}, {5001, 10000}, {10001, 12000}}, driver.sortedBatches())
+	assert.Equal(t, "file:///people.csv", driver.params[0]["csvUrl"])
+	assert.Equal(t, "blue", driver.params[0]["team"])
+}
+
+// Runs batch transactions from any number of sessions, recording the $start and $end of each that commits
+type batchDriver struct {
+	neo4j.Driver
+	// Fail the transaction for the batch starting here
+	failAt int64
+	// Rows of start and end of the batches an interrupted init committed
+	committed [][]interface{}
+	mu        sync.Mutex
+	sessions  int
+	batches   [][2]int64
+	params    []map[string]interface{}
+	// Batches recorded in the init checkpoint
+	recorded [][2]int64
+}
+
+func (d *batchDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessions++
+	return &batchSession{driver: d}
+}
+
+func (d *batchDriver) sortedBatches() [][2]int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sort.Slice(d.batches, func(i, j int) bool {
+		return d.batches[i][0] < d.batches[j][0]
+	})
+	return d.batches
+}
+
+type batchSession struct {
+	neo4j.Session
+	driver *batchDriver
+}
+
+func (s *batchSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(&batchTx{driver: s.driver})
+}
+
+func (s *batchSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	return &rowResult{rows: s.driver.committed, next: -1}, nil
+}
+
+func (s *batchSession) Close() error {
+	return nil
+}
+
+type batchTx struct {
+	neo4j.Transaction
+	driver *batchDriver
+}
+
+func (tx *batchTx) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	start, end := params["start"].(int64), params["end"].(int64)
+	if start == tx.driver.failAt {
+		return nil, errors.New("constraint violated")
+	}
+	tx.driver.mu.Lock()
+	defer tx.driver.mu.Unlock()
+	if strings.Contains(cypher, "NeobenchInitBatch") {
+		tx.driver.recorded = append(tx.driver.recorded, [2]int64{start, end})
+		return consumedResult{}, nil
+	}
+	tx.driver.batches = append(tx.driver.batches, [2]int64{start, end})
+	tx.driver.params = append(tx.driver.params, params)
+	return consumedResult{}, nil
+}
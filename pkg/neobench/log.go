@@ -0,0 +1,139 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/log"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	// Only errors; the final report is the only other thing written
+	LogQuiet LogLevel = 0
+	// Errors, warnings and general information about what we're doing
+	LogNormal LogLevel = 1
+	// Everything, including per-worker lifecycle and driver events like retries
+	LogVerbose LogLevel = 2
+)
+
+// Leveled logger for diagnostics; results and progress go through Output, this is for everything else.
+// Safe for concurrent use.
+type Logger struct {
+	mut    sync.Mutex
+	Level  LogLevel
+	JSON   bool
+	Stream io.Writer
+	now    func() time.Time
+}
+
+func NewLogger(level LogLevel, format string, stream io.Writer) (*Logger, error) {
+	var jsonFormat bool
+	switch format {
+	case "text":
+		jsonFormat = false
+	case "json":
+		jsonFormat = true
+	default:
+		return nil, fmt.Errorf("unknown log format: %s, supported formats are 'text' and 'json'", format)
+	}
+	return &Logger{
+		Level:  level,
+		JSON:   jsonFormat,
+		Stream: stream,
+		now:    time.Now,
+	}, nil
+}
+
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.write("error", format, a...)
+}
+
+// Logs the error and exits with exit code 1
+func (l *Logger) Fatalf(format string, a ...interface{}) {
+	l.write("error", format, a...)
+	os.Exit(1)
+}
+
+func (l *Logger) Warningf(format string, a ...interface{}) {
+	if l.Level >= LogNormal {
+		l.write("warning", format, a...)
+	}
+}
+
+func (l *Logger) Infof(format string, a ...interface{}) {
+	if l.Level >= LogNormal {
+		l.write("info", format, a...)
+	}
+}
+
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	if l.Level >= LogVerbose {
+		l.write("debug", format, a...)
+	}
+}
+
+func (l *Logger) write(level, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	var line []byte
+	if l.JSON {
+		encoded, err := json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{l.now().UTC().Format(time.RFC3339Nano), level, msg})
+		if err != nil {
+			panic(err)
+		}
+		line = append(encoded, '\n')
+	} else {
+		line = []byte(fmt.Sprintf("%s: %s\n", strings.ToUpper(level), msg))
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if _, err := l.Stream.Write(line); err != nil {
+		panic(err)
+	}
+}
+
+// Adapts this logger so the driver can log through it; the driver reports things like retries and
+// failed transactions, which are expected during a benchmark, so we only pass them on in verbose mode
+func (l *Logger) DriverLogging() log.Logger {
+	return &driverLogging{l}
+}
+
+type driverLogging struct {
+	l *Logger
+}
+
+func (d *driverLogging) enabled() bool { return d.l.Level >= LogVerbose }
+
+func (d *driverLogging) Error(name string, id string, err error) {
+	if d.enabled() {
+		d.l.write("error", "[driver] %s %s: %s", name, id, err)
+	}
+}
+
+func (d *driverLogging) Warnf(name string, id string, message string, args ...interface{}) {
+	if d.enabled() {
+		d.l.write("warning", "[driver] "+name+" "+id+": "+message, args...)
+	}
+}
+
+func (d *driverLogging) Infof(name string, id string, message string, args ...interface{}) {
+	if d.enabled() {
+		d.l.write("info", "[driver] "+name+" "+id+": "+message, args...)
+	}
+}
+
+func (d *driverLogging) Debugf(name string, id string, message string, args ...interface{}) {
+	if d.enabled() {
+		d.l.write("debug", "[driver] "+name+" "+id+": "+message, args...)
+	}
+}
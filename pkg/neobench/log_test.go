@@ -0,0 +1,42 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerOnlyWritesLevelsItWasAskedFor(t *testing.T) {
+	logAll := func(level LogLevel) string {
+		out := strings.Builder{}
+		logger, err := NewLogger(level, "text", &out)
+		assert.NoError(t, err)
+		logger.Errorf("e%d", 1)
+		logger.Warningf("w")
+		logger.Infof("i")
+		logger.Debugf("d")
+		logger.DriverLogging().Warnf("pool", "1", "retrying in %s", "1s")
+		return out.String()
+	}
+
+	assert.Equal(t, "ERROR: e1\n", logAll(LogQuiet))
+	assert.Equal(t, "ERROR: e1\nWARNING: w\nINFO: i\n", logAll(LogNormal))
+	assert.Equal(t, "ERROR: e1\nWARNING: w\nINFO: i\nDEBUG: d\nWARNING: [driver] pool 1: retrying in 1s\n", logAll(LogVerbose))
+}
+
+func TestLoggerWritesJsonLines(t *testing.T) {
+	out := strings.Builder{}
+	logger, err := NewLogger(LogNormal, "json", &out)
+	assert.NoError(t, err)
+	logger.now = func() time.Time { return time.Date(2021, 3, 4, 10, 0, 0, 0, time.FixedZone("CET", 3600)) }
+
+	logger.Warningf("lost %s", "connection")
+	logger.DriverLogging().Error("router", "2", fmt.Errorf("no leader"))
+
+	assert.Equal(t, `{"time":"2021-03-04T09:00:00Z","level":"warning","msg":"lost connection"}`+"\n", out.String())
+
+	_, err = NewLogger(LogNormal, "xml", &out)
+	assert.EqualError(t, err, "unknown log format: xml, supported formats are 'text' and 'json'")
+}
@@ -0,0 +1,99 @@
+package neobench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// When the runs given to MergeResults went on
+type MergeWindow struct {
+	// From the last run to start to the first to end, while all of them were running
+	Start time.Time
+	End   time.Time
+	// From the first run to start to the last to end
+	First time.Time
+	Last  time.Time
+}
+
+// Fraction of the time from first start to last end during which all runs were going
+func (w MergeWindow) Overlap() float64 {
+	if !w.End.After(w.Start) || !w.Last.After(w.First) {
+		return 0
+	}
+	return w.End.Sub(w.Start).Seconds() / w.Last.Sub(w.First).Seconds()
+}
+
+// Combines the results of runs that went on at the same time against the same database, like those of load
+// generators started by hand on several machines, into one. Rates add up, so they describe the time all runs
+// were going; the counts and histograms are of whole runs, so the runs should overlap for most of their time,
+// at least minOverlap of it, as a fraction, or an error is returned. The merged result spans the overlap, and
+// keeps the tags all runs agree on.
+func MergeResults(results []Result, minOverlap float64) (Result, MergeWindow, error) {
+	if len(results) == 0 {
+		return Result{}, MergeWindow{}, fmt.Errorf("no results to merge")
+	}
+	window := MergeWindow{}
+	scenarios := make([]string, 0, len(results))
+	seenScenarios := make(map[string]bool)
+	for i, result := range results {
+		if result.Start.IsZero() || !result.End.After(result.Start) {
+			return Result{}, MergeWindow{}, fmt.Errorf("result %d has no start and end time to merge by", i+1)
+		}
+		if result.DatabaseName != results[0].DatabaseName {
+			return Result{}, MergeWindow{}, fmt.Errorf("can't merge results from different databases, %s and %s", results[0].DatabaseName, result.DatabaseName)
+		}
+		if i == 0 || result.Start.After(window.Start) {
+			window.Start = result.Start
+		}
+		if i == 0 || result.End.Before(window.End) {
+			window.End = result.End
+		}
+		if i == 0 || result.Start.Before(window.First) {
+			window.First = result.Start
+		}
+		if i == 0 || result.End.After(window.Last) {
+			window.Last = result.End
+		}
+		if !seenScenarios[result.Scenario] {
+			seenScenarios[result.Scenario] = true
+			scenarios = append(scenarios, result.Scenario)
+		}
+	}
+	if overlap := window.Overlap(); overlap < minOverlap {
+		return Result{}, window, fmt.Errorf("the runs were all going for only %.0f%% of the %s from the first start to the last end, need %.0f%%",
+			overlap*100, window.Last.Sub(window.First).Truncate(time.Second), minOverlap*100)
+	}
+
+	merged := NewResult(results[0].DatabaseName, strings.Join(scenarios, "; "))
+	merged.Start = window.Start
+	merged.End = window.End
+	merged.Seed = results[0].Seed
+	merged.Tags = results[0].Tags
+	merged.Server = results[0].Server
+	for _, result := range results {
+		merged.Merge(result)
+		merged.Events = append(merged.Events, result.Events...)
+		if result.Seed != merged.Seed {
+			merged.Seed = 0
+		}
+		merged.Tags = commonTags(merged.Tags, result.Tags)
+	}
+	sort.SliceStable(merged.Events, func(i, j int) bool { return merged.Events[i].Time.Before(merged.Events[j].Time) })
+	merged.Intervals = MergeIntervals(results)
+	return merged, window, nil
+}
+
+func commonTags(a, b map[string]string) map[string]string {
+	var common map[string]string
+	for k, v := range a {
+		if other, ok := b[k]; ok && other == v {
+			if common == nil {
+				common = make(map[string]string)
+			}
+			common[k] = v
+		}
+	}
+	return common
+}
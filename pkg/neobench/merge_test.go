@@ -0,0 +1,57 @@
+package neobench
+
+import (
+	"bytes"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMergeSavedResults(t *testing.T) {
+	t0 := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	savedRun := func(start time.Duration, latencyMs int64, host string) Result {
+		result := NewResult("neo4j", "-l -r 100")
+		result.Seed = int64(latencyMs)
+		result.Start = t0.Add(start)
+		result.End = result.Start.Add(100 * time.Second)
+		result.Tags = map[string]string{"build": "1.2", "host": host}
+		result.Events = []RunEvent{{Time: result.Start.Add(time.Second), Name: "hook " + host}}
+		script := &ScriptResult{ScriptName: "read", Rate: 100, Succeeded: 10000, Latencies: hdrhistogram.New(0, 60*60*1000000, 5)}
+		assert.NoError(t, script.Latencies.RecordValue(latencyMs*1000))
+		result.Scripts["read"] = script
+
+		saved := bytes.Buffer{}
+		assert.NoError(t, EncodeResult(&saved, result))
+		loaded, err := DecodeResult(&saved)
+		assert.NoError(t, err)
+		return loaded
+	}
+
+	merged, window, err := MergeResults([]Result{savedRun(0, 10, "a"), savedRun(5*time.Second, 30, "b")}, 0.9)
+	assert.NoError(t, err)
+	assert.Equal(t, t0.Add(5*time.Second), merged.Start)
+	assert.Equal(t, t0.Add(100*time.Second), merged.End)
+	assert.InDelta(t, 95.0/105, window.Overlap(), 0.0001)
+	assert.Equal(t, "neo4j", merged.DatabaseName)
+	assert.Equal(t, "-l -r 100", merged.Scenario)
+	assert.Equal(t, int64(0), merged.Seed)
+	assert.Equal(t, map[string]string{"build": "1.2"}, merged.Tags)
+	assert.Equal(t, []string{"hook a", "hook b"}, []string{merged.Events[0].Name, merged.Events[1].Name})
+
+	read := merged.Scripts["read"]
+	assert.Equal(t, 200.0, read.Rate)
+	assert.Equal(t, int64(20000), read.Succeeded)
+	assert.Equal(t, int64(2), read.Latencies.TotalCount())
+
+	_, _, err = MergeResults([]Result{savedRun(0, 10, "a"), savedRun(50*time.Second, 30, "b")}, 0.9)
+	assert.EqualError(t, err, "the runs were all going for only 33% of the 2m30s from the first start to the last end, need 90%")
+
+	other := savedRun(0, 10, "c")
+	other.DatabaseName = "system"
+	_, _, err = MergeResults([]Result{savedRun(0, 10, "a"), other}, 0.9)
+	assert.Error(t, err)
+
+	_, _, err = MergeResults([]Result{savedRun(0, 10, "a"), NewResult("neo4j", "")}, 0.9)
+	assert.EqualError(t, err, "result 2 has no start and end time to merge by")
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/codahale/hdrhistogram"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 )
 
@@ -13,17 +16,62 @@ type ProgressReport struct {
 	Section      string
 	Step         string
 	Completeness float64
+	// Items processed per second so far, eg. nodes created during init; 0 if the step doesn't measure it
+	Rate float64
+}
+
+// The line outputs write to stderr for a progress report
+func formatProgress(report ProgressReport) string {
+	if report.Rate > 0 {
+		return fmt.Sprintf("[%s][%s] %.02f%% (%.0f/s)\n", report.Section, report.Step, report.Completeness*100, report.Rate)
+	}
+	return fmt.Sprintf("[%s][%s] %.02f%%\n", report.Section, report.Step, report.Completeness*100)
 }
 
 type Result struct {
 	// Targeted database
 	DatabaseName string
 	Scenario     string
+	// Seed used to generate the workload, wall clock time the run began and ended, and user-defined tags;
+	// only set on the final result, not on progress checkpoints
+	Seed  int64
+	Start time.Time
+	End   time.Time
+	Tags  map[string]string
+	// Version and topology of the server the run was against, if they could be read; only set on the final result
+	Server *ServerInfo
+	// Hooks that fired during the run, in the order they fired; only set on the final result
+	Events []RunEvent
+	// Stretches of the run disrupted by leader switches or failing servers, with --measure-recovery
+	Recovery []RecoveryWindow
+	// How the run fared against --threshold conditions; only set on the final result
+	Thresholds []ThresholdResult
+	// Each progress interval of the run, only kept when saving results to merge, see IntervalRecorder
+	Intervals []IntervalSample
 
 	FailedByErrorGroup map[string]FailureGroup
 
 	// Results by script
 	Scripts map[string]*ScriptResult
+
+	// Time taken to establish connections, only recorded when running with a connection per transaction
+	ConnectLatencies *hdrhistogram.Histogram
+
+	// Time units of work waited past their scheduled start and the backlog behind them, see WorkerResult
+	QueueTimes *hdrhistogram.Histogram
+	Backlog    *hdrhistogram.Histogram
+	Dropped    int64
+	// Sessions opened to run units of work in, see WorkerResult
+	SessionsOpened int64
+
+	// Time to get a connection and begin each transaction, see WorkerResult, and pool events seen by the driver
+	AcquireTimes *hdrhistogram.Histogram
+	Pool         PoolStats
+
+	// Throughput over each progress interval; not kept when merging results of runs that went on at the same time
+	Throughput ThroughputStats
+	// Each client, or executor with virtual users, by worker id; only kept for runs of a single phase on one machine
+	Workers []WorkerSummary
 }
 
 func NewResult(databaseName, scenario string) Result {
@@ -32,6 +80,10 @@ func NewResult(databaseName, scenario string) Result {
 		Scenario:           scenario,
 		FailedByErrorGroup: make(map[string]FailureGroup),
 		Scripts:            make(map[string]*ScriptResult),
+		ConnectLatencies:   hdrhistogram.New(0, 60*60*1000000, 3),
+		QueueTimes:         hdrhistogram.New(0, 60*60*1000000, 3),
+		Backlog:            hdrhistogram.New(0, 60*60*1000000, 3),
+		AcquireTimes:       hdrhistogram.New(0, 60*60*1000000, 3),
 	}
 }
 
@@ -49,6 +101,13 @@ func (r *Result) TotalFailed() (n int64) {
 	return
 }
 
+func (r *Result) TotalDataErrors() (n int64) {
+	for _, s := range r.Scripts {
+		n += s.DataErrors
+	}
+	return
+}
+
 func (r *Result) TotalRate() (n float64) {
 	for _, s := range r.Scripts {
 		n += s.Rate
@@ -56,6 +115,16 @@ func (r *Result) TotalRate() (n float64) {
 	return
 }
 
+// Scripts ordered by name, so reports list them in a stable order
+func (r *Result) SortedScripts() []*ScriptResult {
+	scripts := make([]*ScriptResult, 0, len(r.Scripts))
+	for _, script := range r.Scripts {
+		scripts = append(scripts, script)
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].ScriptName < scripts[j].ScriptName })
+	return scripts
+}
+
 func (r *Result) Add(res WorkerResult) {
 	for _, workerScriptResult := range res.Scripts {
 		combinedScriptResult := r.Scripts[workerScriptResult.ScriptName]
@@ -66,12 +135,47 @@ func (r *Result) Add(res WorkerResult) {
 				Rate:       workerScriptResult.Rate,
 				Succeeded:  workerScriptResult.Succeeded,
 				Failed:     workerScriptResult.Failed,
+				Statements: copyStatementResults(workerScriptResult.Statements),
+
+				Deadlocks:      workerScriptResult.Deadlocks,
+				LockWaitAborts: workerScriptResult.LockWaitAborts,
+				DataErrors:     workerScriptResult.DataErrors,
+				FirstDataError: workerScriptResult.FirstDataError,
+			}
+			if workerScriptResult.ServiceLatencies != nil {
+				r.Scripts[workerScriptResult.ScriptName].ServiceLatencies = hdrhistogram.Import(workerScriptResult.ServiceLatencies.Export())
+			}
+			if workerScriptResult.MetricValues != nil {
+				r.Scripts[workerScriptResult.ScriptName].Metric = workerScriptResult.Metric
+				r.Scripts[workerScriptResult.ScriptName].MetricValues = hdrhistogram.Import(workerScriptResult.MetricValues.Export())
 			}
 		} else {
 			combinedScriptResult.Rate += workerScriptResult.Rate
 			combinedScriptResult.Succeeded += workerScriptResult.Succeeded
 			combinedScriptResult.Failed += workerScriptResult.Failed
+			combinedScriptResult.Deadlocks += workerScriptResult.Deadlocks
+			combinedScriptResult.LockWaitAborts += workerScriptResult.LockWaitAborts
+			combinedScriptResult.DataErrors += workerScriptResult.DataErrors
+			if combinedScriptResult.FirstDataError == "" {
+				combinedScriptResult.FirstDataError = workerScriptResult.FirstDataError
+			}
 			combinedScriptResult.Latencies.Merge(workerScriptResult.Latencies)
+			combinedScriptResult.mergeStatements(workerScriptResult.Statements)
+			if workerScriptResult.ServiceLatencies != nil {
+				if combinedScriptResult.ServiceLatencies == nil {
+					combinedScriptResult.ServiceLatencies = hdrhistogram.Import(workerScriptResult.ServiceLatencies.Export())
+				} else {
+					combinedScriptResult.ServiceLatencies.Merge(workerScriptResult.ServiceLatencies)
+				}
+			}
+			if workerScriptResult.MetricValues != nil {
+				if combinedScriptResult.MetricValues == nil {
+					combinedScriptResult.Metric = workerScriptResult.Metric
+					combinedScriptResult.MetricValues = hdrhistogram.Import(workerScriptResult.MetricValues.Export())
+				} else {
+					combinedScriptResult.MetricValues.Merge(workerScriptResult.MetricValues)
+				}
+			}
 		}
 	}
 	for name, group := range res.FailedByErrorGroup {
@@ -85,6 +189,18 @@ func (r *Result) Add(res WorkerResult) {
 			r.FailedByErrorGroup[name] = group
 		}
 	}
+	r.ConnectLatencies.Merge(res.ConnectLatencies)
+	if res.QueueTimes != nil {
+		r.QueueTimes.Merge(res.QueueTimes)
+	}
+	if res.Backlog != nil {
+		r.Backlog.Merge(res.Backlog)
+	}
+	r.Dropped += res.Dropped
+	r.SessionsOpened += res.SessionsOpened
+	if res.AcquireTimes != nil {
+		r.AcquireTimes.Merge(res.AcquireTimes)
+	}
 }
 
 // Result for one script; normally a workload is just one script, but we allow workloads to be made up of
@@ -97,7 +213,92 @@ type ScriptResult struct {
 	Rate      float64
 	Failed    int64
 	Succeeded int64
+	// Measured from when each unit of work was scheduled to start, which in latency mode includes time spent
+	// waiting for earlier units of work if the database is falling behind
 	Latencies *hdrhistogram.Histogram
+	// Measured from when each unit of work actually started; may be nil
+	ServiceLatencies *hdrhistogram.Histogram
+	// Breakdown by statement, in the order they appear in the script
+	Statements []*StatementResult
+	// Attempts aborted by deadlocks or lock wait timeouts, including ones the driver retried successfully;
+	// these are expected in contended write workloads, so they're counted apart from failures
+	Deadlocks      int64
+	LockWaitAborts int64
+	// Units of work that succeeded, but returned something a statements \expect didn't, and the first mismatch
+	// found; these mean the database or the workload is wrong rather than slow, so they're counted apart from failures
+	DataErrors     int64
+	FirstDataError string
+	// Distribution of the values of the scripts metric column, see Script.Metric; nil if it has none
+	Metric       string
+	MetricValues *hdrhistogram.Histogram
+}
+
+// Converts a count of events in this result to a per-second rate, using the rate of units of work
+func (s *ScriptResult) perSecond(n int64) float64 {
+	total := s.Succeeded + s.Failed
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * s.Rate / float64(total)
+}
+
+// Result for one statement within a script
+type StatementResult struct {
+	Query string
+	// Failed counts units of work that failed on this statement
+	Failed    int64
+	Latencies *hdrhistogram.Histogram
+	// Only set if some runs of the statement were profiled, see Worker.ProfileSampleRate
+	Profile *StatementProfile
+}
+
+// Gets or creates the result for the statement at index i
+func (s *ScriptResult) statement(i int, query string) *StatementResult {
+	for len(s.Statements) <= i {
+		s.Statements = append(s.Statements, nil)
+	}
+	if s.Statements[i] == nil {
+		s.Statements[i] = &StatementResult{
+			Query:     query,
+			Latencies: hdrhistogram.New(0, 60*60*1000000, 3),
+		}
+	}
+	return s.Statements[i]
+}
+
+func (s *ScriptResult) mergeStatements(other []*StatementResult) {
+	for i, statement := range other {
+		if statement == nil {
+			continue
+		}
+		combined := s.statement(i, statement.Query)
+		combined.Failed += statement.Failed
+		combined.Latencies.Merge(statement.Latencies)
+		if statement.Profile != nil {
+			if combined.Profile == nil {
+				combined.Profile = newStatementProfile()
+			}
+			combined.Profile.merge(statement.Profile)
+		}
+	}
+}
+
+func copyStatementResults(statements []*StatementResult) []*StatementResult {
+	out := make([]*StatementResult, len(statements))
+	for i, statement := range statements {
+		if statement == nil {
+			continue
+		}
+		out[i] = &StatementResult{
+			Query:     statement.Query,
+			Failed:    statement.Failed,
+			Latencies: hdrhistogram.Import(statement.Latencies.Export()),
+		}
+		if statement.Profile != nil {
+			out[i].Profile = statement.Profile.copy()
+		}
+	}
+	return out
 }
 
 type Output interface {
@@ -109,39 +310,159 @@ type Output interface {
 	Errorf(format string, a ...interface{})
 }
 
-func NewOutput(name string) (Output, error) {
+// Options that apply across output formats
+type OutputOptions struct {
+	// How latencies are written in human readable outputs; json output is always in milliseconds
+	Latency LatencyFormat
+	// Latency percentiles to report; if nil, each format uses its own default set
+	Percentiles []float64
+	// Include a latency breakdown for each statement within each script
+	StatementLatencies bool
+	// Only report the final results and errors, see NewQuietOutput
+	Quiet bool
+}
+
+// Creates an output in the named format, writing its report to stdout; see NewOutputs to combine several
+func NewOutput(name string, opts OutputOptions) (Output, error) {
+	return NewOutputTo(name, os.Stdout, opts)
+}
+
+// Like NewOutput, but the report goes to out; progress and errors still go to stderr
+func NewOutputTo(name string, out io.Writer, opts OutputOptions) (Output, error) {
 	if name == "auto" {
 		fi, _ := os.Stdout.Stat()
-		if fi.Mode()&os.ModeCharDevice == 0 {
-			return &CsvOutput{
-				ErrStream: os.Stderr,
-				OutStream: os.Stdout,
-			}, nil
+		if fi.Mode()&os.ModeCharDevice == 0 || out != io.Writer(os.Stdout) {
+			name = "csv"
 		} else {
-			return &InteractiveOutput{
-				ErrStream: os.Stderr,
-				OutStream: os.Stdout,
-			}, nil
+			name = "interactive"
 		}
 	}
-	if name == "interactive" {
-		return &InteractiveOutput{
-			ErrStream: os.Stderr,
-			OutStream: os.Stdout,
-		}, nil
+	outputsMut.RLock()
+	factory, found := outputFormats[name]
+	outputsMut.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown output format: %s, supported formats are 'auto', %s", name, strings.Join(OutputFormats(), ", "))
 	}
-	if name == "csv" {
-		return &CsvOutput{
-			ErrStream: os.Stderr,
-			OutStream: os.Stdout,
-		}, nil
+	output, err := factory(out, opts)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("unknown output format: %s, supported formats are 'auto', 'interactive' and 'csv'", name)
+	if opts.Quiet {
+		output = NewQuietOutput(output)
+	}
+	return output, nil
+}
+
+// Validates a user-provided list of percentiles to report
+func ValidatePercentiles(percentiles []float64) error {
+	for _, p := range percentiles {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("percentiles must be between 0 and 100, got %v", p)
+		}
+	}
+	return nil
+}
+
+// The latency at the given percentile, 0 being the fastest and 100 the slowest recorded value
+func valueAtPercentile(histo *hdrhistogram.Histogram, percentile float64) int64 {
+	if percentile == 0 {
+		// ValueAtQuantile(0) gives 0 rather than the smallest recorded value
+		return histo.Min()
+	}
+	return histo.ValueAtQuantile(percentile)
+}
+
+// How latencies are written in reports; the zero value means DefaultLatencyFormat
+type LatencyFormat struct {
+	// "ms" or "us"
+	Unit string
+	// Number of decimals
+	Precision int
+}
+
+var DefaultLatencyFormat = LatencyFormat{Unit: "ms", Precision: 3}
+
+func NewLatencyFormat(unit string, precision int) (LatencyFormat, error) {
+	if unit != "ms" && unit != "us" {
+		return LatencyFormat{}, fmt.Errorf("unknown latency unit: %s, supported units are 'ms' and 'us'", unit)
+	}
+	if precision < 0 || precision > 9 {
+		return LatencyFormat{}, fmt.Errorf("latency precision must be between 0 and 9, got %d", precision)
+	}
+	return LatencyFormat{Unit: unit, Precision: precision}, nil
+}
+
+func (f LatencyFormat) orDefault() LatencyFormat {
+	if f.Unit == "" {
+		return DefaultLatencyFormat
+	}
+	return f
+}
+
+// Converts a latency in microseconds, as recorded in our histograms, to the configured unit
+func (f LatencyFormat) value(micros float64) float64 {
+	if f.orDefault().Unit == "ms" {
+		return micros / 1000.0
+	}
+	return micros
+}
+
+// The latency in the configured unit and precision, without the unit
+func (f LatencyFormat) number(micros float64) string {
+	return fmt.Sprintf("%.*f", f.orDefault().Precision, f.value(micros))
+}
+
+// The latency in the configured unit and precision, eg. 1.234ms
+func (f LatencyFormat) format(micros float64) string {
+	return f.number(micros) + f.orDefault().Unit
+}
+
+// Like format, but for latencies already converted to milliseconds, as in JsonReport; used by templates
+func (f LatencyFormat) FormatMillis(ms float64) string {
+	return f.format(ms * 1000)
+}
+
+// Wraps another output, only passing on final reports and errors
+func NewQuietOutput(inner Output) Output {
+	return &quietOutput{inner: inner}
+}
+
+type quietOutput struct {
+	inner Output
+}
+
+func (o *quietOutput) BenchmarkStart(databaseName, url string) {
+	// The CSV latency report relies on the header written at start
+	if csv, ok := o.inner.(*CsvOutput); ok {
+		csv.writeHeader()
+	}
+}
+
+func (o *quietOutput) ReportProgress(report ProgressReport) {}
+
+func (o *quietOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {}
+
+func (o *quietOutput) ReportThroughput(result Result) {
+	o.inner.ReportThroughput(result)
+}
+
+func (o *quietOutput) ReportLatency(result Result) {
+	o.inner.ReportLatency(result)
+}
+
+func (o *quietOutput) Errorf(format string, a ...interface{}) {
+	o.inner.Errorf(format, a...)
 }
 
+// Percentiles reported by default in the interactive output
+var interactivePercentiles = []float64{0, 25, 50, 75, 95, 99, 99.999}
+
 type InteractiveOutput struct {
-	ErrStream io.Writer
-	OutStream io.Writer
+	ErrStream          io.Writer
+	OutStream          io.Writer
+	Percentiles        []float64
+	StatementLatencies bool
+	Latency            LatencyFormat
 	// Used to rate-limit progress reporting
 	LastProgressReport ProgressReport
 	LastProgressTime   time.Time
@@ -158,7 +479,12 @@ func (o *InteractiveOutput) BenchmarkStart(databaseName, address string) {
 }
 
 func (o *InteractiveOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
-	_, err := fmt.Fprintf(o.ErrStream, "[%.02f%%] %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	poolWaits := ""
+	if checkpoint.Pool.Exhausted > 0 {
+		poolWaits = fmt.Sprintf(" / %d waits for a connection, p99 %s to start", checkpoint.Pool.Exhausted,
+			o.Latency.format(float64(checkpoint.AcquireTimes.ValueAtQuantile(99))))
+	}
+	_, err := fmt.Fprintf(o.ErrStream, "[%.02f%%] %.02f tps / %d failures%s\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed(), poolWaits)
 	if err != nil {
 		panic(err)
 	}
@@ -171,7 +497,7 @@ func (o *InteractiveOutput) ReportProgress(report ProgressReport) {
 	}
 	o.LastProgressReport = report
 	o.LastProgressTime = now
-	_, err := fmt.Fprintf(o.ErrStream, "[%s][%s] %.02f%%\n", report.Section, report.Step, report.Completeness*100)
+	_, err := fmt.Fprint(o.ErrStream, formatProgress(report))
 	if err != nil {
 		panic(err)
 	}
@@ -182,15 +508,39 @@ func (o *InteractiveOutput) ReportThroughput(result Result) {
 
 	s.WriteString("== Results ==\n")
 	s.WriteString(fmt.Sprintf("Scenario: %s\n", result.Scenario))
+	if result.Server != nil {
+		s.WriteString(fmt.Sprintf("Server: %s\n", result.Server))
+	}
 	s.WriteString(fmt.Sprintf("Successful Transactions: %d (%.3f per second)\n", result.TotalSucceeded(), result.TotalRate()))
+	writeThroughputStability(result, &s)
 	s.WriteString("\n")
-	for _, script := range result.Scripts {
-		s.WriteString(fmt.Sprintf("  [%s]: %.03f successful transactions per second\n", script.ScriptName, script.Rate))
+	if len(result.Scripts) > 1 {
+		writeScriptBreakdown(result, &s, o.Latency)
+	} else {
+		for _, script := range result.Scripts {
+			s.WriteString(fmt.Sprintf("  [%s]: %.03f successful transactions per second\n", script.ScriptName, script.Rate))
+		}
 	}
 	s.WriteString("\n")
+	if o.StatementLatencies {
+		for _, script := range result.SortedScripts() {
+			s.WriteString(fmt.Sprintf("-- Statements: %s --\n\n", script.ScriptName))
+			writeStatementBreakdown(script, &s, "  ", o.Latency)
+			s.WriteString("\n")
+		}
+	}
+	writeMetricReport(result, &s)
+	writeMetricReport(result, &s)
+	writeProfileReport(result, &s)
+	writePoolReport(result, &s, o.Latency)
+	writeSkewReport(result, &s, o.Latency)
+	writeConnectReport(result, &s, o.Latency)
+	writeEventReport(result, &s)
+	writeRecoveryReport(result, &s, o.Latency)
 	writeErrorReport(result, &s)
+	writeThresholdReport(result, &s)
 
-	_, err := fmt.Fprintf(o.OutStream, s.String())
+	_, err := fmt.Fprint(o.OutStream, s.String())
 	if err != nil {
 		panic(err)
 	}
@@ -202,17 +552,38 @@ func (o *InteractiveOutput) ReportLatency(result Result) {
 	s.WriteString("== Results ==\n")
 
 	s.WriteString(fmt.Sprintf("Scenario: %s\n", result.Scenario))
+	if result.Server != nil {
+		s.WriteString(fmt.Sprintf("Server: %s\n", result.Server))
+	}
 	s.WriteString(fmt.Sprintf("Successful Transactions: %d (%.3f per second)\n", result.TotalSucceeded(), result.TotalRate()))
+	writeThroughputStability(result, &s)
+
+	if len(result.Scripts) > 1 {
+		s.WriteString("\n")
+		writeScriptBreakdown(result, &s, o.Latency)
+	}
 
 	if result.TotalSucceeded() > 0 {
-		for _, workload := range result.Scripts {
+		for _, workload := range result.SortedScripts() {
 			s.WriteString("\n")
 			s.WriteString(fmt.Sprintf("-- Script: %s --\n\n", workload.ScriptName))
-			summarizeLatency(workload, &s, "  ")
+			summarizeLatency(workload, &s, "  ", o.Percentiles, o.Latency)
+			if o.StatementLatencies {
+				s.WriteString("\n")
+				writeStatementBreakdown(workload, &s, "  ", o.Latency)
+			}
 		}
 	}
 	s.WriteString("\n")
+	writeProfileReport(result, &s)
+	writeSaturationReport(result, &s, o.Latency)
+	writePoolReport(result, &s, o.Latency)
+	writeSkewReport(result, &s, o.Latency)
+	writeConnectReport(result, &s, o.Latency)
+	writeEventReport(result, &s)
+	writeRecoveryReport(result, &s, o.Latency)
 	writeErrorReport(result, &s)
+	writeThresholdReport(result, &s)
 
 	_, err := fmt.Fprint(o.OutStream, s.String())
 	if err != nil {
@@ -220,20 +591,109 @@ func (o *InteractiveOutput) ReportLatency(result Result) {
 	}
 }
 
-func summarizeLatency(script *ScriptResult, s *strings.Builder, indent string) {
+// Throughput varying more than this between progress intervals is called out in the report
+const unsteadyThroughputCV = 0.2
+
+// Describes how much throughput varied between progress intervals, if there were enough of them to tell
+func writeThroughputStability(result Result, s *strings.Builder) {
+	t := result.Throughput
+	if t.Intervals < 2 {
+		return
+	}
+	s.WriteString(fmt.Sprintf("Throughput per interval: Mean: %.3f, Stddev: %.3f, Min: %.3f, Max: %.3f, CV: %.1f %% over %d intervals\n",
+		t.Mean(), t.StdDev(), t.Min, t.Max, 100*t.CV(), t.Intervals))
+	if t.CV() > unsteadyThroughputCV {
+		s.WriteString("  Throughput swung widely between intervals, the average hides that; see the progress output or --timeseries\n")
+	}
+}
+
+// Describes how far behind schedule the database fell in latency mode
+func writeSaturationReport(result Result, s *strings.Builder, f LatencyFormat) {
+	queued := result.QueueTimes
+	if queued == nil || queued.TotalCount() == 0 {
+		return
+	}
+	scheduled := queued.TotalCount() + result.Dropped
+	s.WriteString("Saturation:\n")
+	s.WriteString(fmt.Sprintf("  Time in queue: Max: %s, Mean: %s, P99.000: %s\n",
+		f.format(float64(queued.Max())), f.format(queued.Mean()), f.format(float64(queued.ValueAtQuantile(99)))))
+	s.WriteString(fmt.Sprintf("  Backlog per client: Max: %d, Mean: %.3f\n", result.Backlog.Max(), result.Backlog.Mean()))
+	s.WriteString(fmt.Sprintf("  Dropped: %d (%.3f %% of scheduled)\n", result.Dropped, 100*float64(result.Dropped)/float64(scheduled)))
+	s.WriteString("\n")
+}
+
+// Table comparing the scripts in a mixed workload side by side
+func writeScriptBreakdown(result Result, s *strings.Builder, f LatencyFormat) {
+	total := result.TotalSucceeded() + result.TotalFailed()
+	w := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "  Script\tShare\tRate\tP50\tP95\tP99\tFailed\n")
+	for _, script := range result.SortedScripts() {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(script.Succeeded+script.Failed) / float64(total)
+		}
+		_, _ = fmt.Fprintf(w, "  %s\t%.1f%%\t%.3f/s\t%s\t%s\t%s\t%d\n", script.ScriptName, share, script.Rate,
+			f.format(float64(script.Latencies.ValueAtQuantile(50))),
+			f.format(float64(script.Latencies.ValueAtQuantile(95))),
+			f.format(float64(script.Latencies.ValueAtQuantile(99))),
+			script.Failed)
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+}
+
+// Table of latency and failures by statement within a script
+func writeStatementBreakdown(script *ScriptResult, s *strings.Builder, indent string, f LatencyFormat) {
+	w := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "%s#\tP50\tP95\tP99\tMax\tFailed\tStatement\n", indent)
+	for i, statement := range script.Statements {
+		if statement == nil {
+			continue
+		}
+		histo := statement.Latencies
+		_, _ = fmt.Fprintf(w, "%s%d\t%s\t%s\t%s\t%s\t%d\t%s\n", indent, i+1,
+			f.format(float64(histo.ValueAtQuantile(50))),
+			f.format(float64(histo.ValueAtQuantile(95))),
+			f.format(float64(histo.ValueAtQuantile(99))),
+			f.format(float64(histo.Max())),
+			statement.Failed,
+			abbreviateQuery(statement.Query, 60))
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+}
+
+// Collapses whitespace and truncates a query so it fits on one line of a report
+func abbreviateQuery(query string, maxLen int) string {
+	oneLine := strings.Join(strings.Fields(query), " ")
+	if len(oneLine) > maxLen {
+		return oneLine[:maxLen-3] + "..."
+	}
+	return oneLine
+}
+
+func summarizeLatency(script *ScriptResult, s *strings.Builder, indent string, percentiles []float64, f LatencyFormat) {
 	histo := script.Latencies
 	lines := []string{
 		fmt.Sprintf("Successful Transactions: %d (%.3f per second)\n\n", script.Succeeded, script.Rate),
-		fmt.Sprintf("Max: %.3fms, Min: %.3fms, Mean: %.3fms, Stddev: %.3f\n\n",
-			float64(histo.Max())/1000.0, float64(histo.Min())/1000.0, histo.Mean()/1000.0, histo.StdDev()/1000.0),
+		fmt.Sprintf("Max: %s, Min: %s, Mean: %s, Stddev: %s\n\n",
+			f.format(float64(histo.Max())), f.format(float64(histo.Min())), f.format(histo.Mean()), f.format(histo.StdDev())),
 		fmt.Sprintf("Latency distribution:\n"),
-		fmt.Sprintf("  P00.000: %.03fms\n", float64(histo.Min())/1000.0),
-		fmt.Sprintf("  P25.000: %.03fms\n", float64(histo.ValueAtQuantile(25))/1000.0),
-		fmt.Sprintf("  P50.000: %.03fms\n", float64(histo.ValueAtQuantile(50))/1000.0),
-		fmt.Sprintf("  P75.000: %.03fms\n", float64(histo.ValueAtQuantile(75))/1000.0),
-		fmt.Sprintf("  P95.000: %.03fms\n", float64(histo.ValueAtQuantile(95))/1000.0),
-		fmt.Sprintf("  P99.000: %.03fms\n", float64(histo.ValueAtQuantile(99))/1000.0),
-		fmt.Sprintf("  P99.999: %.03fms\n", float64(histo.ValueAtQuantile(99.999))/1000.0),
+	}
+	for _, p := range percentiles {
+		lines = append(lines, fmt.Sprintf("  P%06.3f: %s\n", p, f.format(float64(valueAtPercentile(histo, p)))))
+	}
+	if service := script.ServiceLatencies; service != nil && service.TotalCount() > 0 {
+		lines = append(lines,
+			"\n",
+			fmt.Sprintf("Service time distribution (from actual start, excluding time queued behind earlier transactions):\n"),
+			fmt.Sprintf("  Max: %s, Min: %s, Mean: %s, Stddev: %s\n",
+				f.format(float64(service.Max())), f.format(float64(service.Min())), f.format(service.Mean()), f.format(service.StdDev())))
+		for _, p := range percentiles {
+			lines = append(lines, fmt.Sprintf("  P%06.3f: %s\n", p, f.format(float64(valueAtPercentile(service, p)))))
+		}
 	}
 	for _, line := range lines {
 		s.WriteString(indent)
@@ -241,6 +701,124 @@ func summarizeLatency(script *ScriptResult, s *strings.Builder, indent string) {
 	}
 }
 
+// Distribution of each scripts metric column, for scripts that have one
+func writeMetricReport(result Result, s *strings.Builder) {
+	for _, script := range result.SortedScripts() {
+		histo := script.MetricValues
+		if histo == nil || histo.TotalCount() == 0 {
+			continue
+		}
+		s.WriteString(fmt.Sprintf("%s, %s:\n", script.ScriptName, script.Metric))
+		s.WriteString(fmt.Sprintf("  Count: %d, Min: %d, Mean: %.2f, Max: %d\n", histo.TotalCount(), histo.Min(), histo.Mean(), histo.Max()))
+		s.WriteString(fmt.Sprintf("  P50: %d, P90: %d, P99: %d\n", histo.ValueAtQuantile(50), histo.ValueAtQuantile(90), histo.ValueAtQuantile(99)))
+		s.WriteString("\n")
+	}
+}
+
+// Describes time spent getting connections, to tell a slow server apart from clients starved for connections
+func writePoolReport(result Result, s *strings.Builder, f LatencyFormat) {
+	acquire := result.AcquireTimes
+	if acquire == nil || acquire.TotalCount() == 0 {
+		return
+	}
+	s.WriteString("Connection pool:\n")
+	s.WriteString(fmt.Sprintf("  Transaction start (acquire connection and begin): Mean: %s, P99.000: %s, Max: %s\n",
+		f.format(acquire.Mean()), f.format(float64(acquire.ValueAtQuantile(99))), f.format(float64(acquire.Max()))))
+	s.WriteString(fmt.Sprintf("  Connections opened: %d, dropped as dead or too old: %d\n", result.Pool.ConnectionsCreated, result.Pool.ConnectionsClosed))
+	s.WriteString(fmt.Sprintf("  Waits for a free connection: %d, timed out: %d\n", result.Pool.Exhausted, result.Pool.AcquireTimeouts))
+	if result.SessionsOpened > 0 {
+		s.WriteString(fmt.Sprintf("  Sessions opened: %d (%.1f transactions per session)\n", result.SessionsOpened,
+			float64(result.TotalSucceeded()+result.TotalFailed())/float64(result.SessionsOpened)))
+	}
+	if result.Pool.Exhausted > 0 {
+		s.WriteString("  Clients queued for connections, latencies include that wait; consider --max-pool-size\n")
+	}
+	s.WriteString("\n")
+}
+
+// Lists each threshold with the value it was checked against, ending with whether the run passed overall
+func writeThresholdReport(result Result, s *strings.Builder) {
+	if len(result.Thresholds) == 0 {
+		return
+	}
+	s.WriteString("Thresholds:\n")
+	tw := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+	failed := 0
+	for _, t := range result.Thresholds {
+		verdict := "pass"
+		if !t.Passed {
+			verdict = "FAIL"
+			failed++
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\n", verdict, t.Threshold.Spec, t.FormatValue())
+	}
+	_ = tw.Flush()
+	if failed > 0 {
+		s.WriteString(fmt.Sprintf("  FAILED: %d of %d thresholds not met\n", failed, len(result.Thresholds)))
+	} else {
+		s.WriteString(fmt.Sprintf("  PASSED: all %d thresholds met\n", len(result.Thresholds)))
+	}
+	s.WriteString("\n")
+}
+
+// Lists the hooks that fired, by time from the start of the run, so changes in the metrics can be put down to them
+func writeEventReport(result Result, s *strings.Builder) {
+	if len(result.Events) == 0 {
+		return
+	}
+	s.WriteString("Events:\n")
+	for _, event := range result.Events {
+		outcome := "ok"
+		if event.Err != "" {
+			outcome = "failed: " + event.Err
+		}
+		s.WriteString(fmt.Sprintf("  +%s %s (%s)\n", event.Time.Sub(result.Start).Truncate(time.Millisecond), event.Name, outcome))
+	}
+	s.WriteString("\n")
+}
+
+// Lists each disruption with how long the workload took to recover from it, and how many transactions it cost
+func writeRecoveryReport(result Result, s *strings.Builder, f LatencyFormat) {
+	if len(result.Recovery) == 0 {
+		return
+	}
+	s.WriteString("Recovery from leader switches and failed servers:\n")
+	tw := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "  Start\tTime to recover\tFailed\tRouting changes\tCause\n")
+	var longest time.Duration
+	var failed int64
+	for _, window := range result.Recovery {
+		recovered := "not recovered"
+		if !window.Recovered.IsZero() {
+			recovered = f.format(float64(window.TimeToRecover().Microseconds()))
+		}
+		if window.TimeToRecover() > longest {
+			longest = window.TimeToRecover()
+		}
+		failed += window.Failed
+		_, _ = fmt.Fprintf(tw, "  +%s\t%s\t%d\t%d\t%s\n", window.Start.Sub(result.Start).Truncate(time.Millisecond),
+			recovered, window.Failed, window.RoutingChanges, window.Cause)
+	}
+	_ = tw.Flush()
+	s.WriteString(fmt.Sprintf("  Longest time to recover: %s, transactions failed while disrupted: %d\n", f.format(float64(longest.Microseconds())), failed))
+	s.WriteString("\n")
+}
+
+// Describes connection setup latency, if we ran with a new connection per transaction
+func writeConnectReport(result Result, s *strings.Builder, f LatencyFormat) {
+	histo := result.ConnectLatencies
+	if histo.TotalCount() == 0 {
+		return
+	}
+	s.WriteString("Connection setup latency:\n")
+	s.WriteString(fmt.Sprintf("  Connections: %d\n", histo.TotalCount()))
+	s.WriteString(fmt.Sprintf("  Max: %s, Min: %s, Mean: %s, Stddev: %s\n",
+		f.format(float64(histo.Max())), f.format(float64(histo.Min())), f.format(histo.Mean()), f.format(histo.StdDev())))
+	s.WriteString(fmt.Sprintf("  P50.000: %s\n", f.format(float64(histo.ValueAtQuantile(50)))))
+	s.WriteString(fmt.Sprintf("  P99.000: %s\n", f.format(float64(histo.ValueAtQuantile(99)))))
+	s.WriteString("\n")
+}
+
 func writeErrorReport(result Result, s *strings.Builder) {
 	s.WriteString(fmt.Sprintf("Error stats:\n"))
 	if result.TotalFailed() == 0 {
@@ -248,12 +826,65 @@ func writeErrorReport(result Result, s *strings.Builder) {
 	} else {
 		s.WriteString(fmt.Sprintf("  Failed transactions: %d (%.3f %%)\n", result.TotalFailed(), 100*float64(result.TotalFailed())/float64(result.TotalFailed()+result.TotalSucceeded())))
 		s.WriteString(fmt.Sprintf("\n"))
+		server, client := result.FailuresByOrigin()
+		s.WriteString(fmt.Sprintf("  By origin:\n"))
+		tw := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "    server, the database rejected the query\t%d\t(%.3f %%)\n", server, 100*float64(server)/float64(result.TotalFailed()))
+		_, _ = fmt.Fprintf(tw, "    client, no answer from the database\t%d\t(%.3f %%)\n", client, 100*float64(client)/float64(result.TotalFailed()))
+		_ = tw.Flush()
+		s.WriteString(fmt.Sprintf("\n"))
+		s.WriteString(fmt.Sprintf("  By classification:\n"))
+		tw = tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+		for _, class := range result.FailuresByErrorClass() {
+			_, _ = fmt.Fprintf(tw, "    %s\t%d\t(%.3f %%)\n", class.Class, class.Count, 100*float64(class.Count)/float64(result.TotalFailed()))
+		}
+		_ = tw.Flush()
+		s.WriteString(fmt.Sprintf("\n"))
 		s.WriteString(fmt.Sprintf("  Causes:\n"))
-		for name, info := range result.FailedByErrorGroup {
-			s.WriteString(fmt.Sprintf("    %s: %d failures\n", name, info.Count))
+		for _, name := range result.SortedErrorGroups() {
+			info := result.FailedByErrorGroup[name]
+			s.WriteString(fmt.Sprintf("    %s (%s): %d failures\n", name, ClassifyErrorGroup(name), info.Count))
 			s.WriteString(fmt.Sprintf("      (ex: %s)\n", info.FirstFailure))
 		}
 	}
+	writeLockConflictReport(result, s)
+	writeDataErrorReport(result, s)
+}
+
+// Units of work that returned something their scripts \expect didn't, per script
+func writeDataErrorReport(result Result, s *strings.Builder) {
+	if result.TotalDataErrors() == 0 {
+		return
+	}
+	s.WriteString("\n")
+	s.WriteString("Data errors (transactions that succeeded, but returned results their script didn't expect):\n")
+	for _, script := range result.SortedScripts() {
+		if script.DataErrors == 0 {
+			continue
+		}
+		s.WriteString(fmt.Sprintf("  %s: %d (%.3f %% of successful transactions)\n", script.ScriptName, script.DataErrors, 100*float64(script.DataErrors)/float64(script.Succeeded)))
+		s.WriteString(fmt.Sprintf("    (ex: %s)\n", script.FirstDataError))
+	}
+}
+
+// Deadlocks and lock wait aborts per script, including ones that succeeded on retry
+func writeLockConflictReport(result Result, s *strings.Builder) {
+	conflicts := false
+	for _, script := range result.Scripts {
+		conflicts = conflicts || script.Deadlocks > 0 || script.LockWaitAborts > 0
+	}
+	if !conflicts {
+		return
+	}
+	s.WriteString("\n")
+	s.WriteString("Lock conflicts (counted per attempt, including ones retried successfully):\n")
+	tw := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "  Script\tDeadlocks\tPer second\tLock wait aborts\tPer second\n")
+	for _, script := range result.SortedScripts() {
+		_, _ = fmt.Fprintf(tw, "  %s\t%d\t%.3f\t%d\t%.3f\n", script.ScriptName,
+			script.Deadlocks, script.perSecond(script.Deadlocks), script.LockWaitAborts, script.perSecond(script.LockWaitAborts))
+	}
+	_ = tw.Flush()
 }
 
 func (o *InteractiveOutput) Errorf(format string, a ...interface{}) {
@@ -266,8 +897,10 @@ func (o *InteractiveOutput) Errorf(format string, a ...interface{}) {
 // Writes simple progress to stderr, and then a result for easy import into eg. a spreadsheet or other app
 // in CSV format to stdout
 type CsvOutput struct {
-	ErrStream io.Writer
-	OutStream io.Writer
+	ErrStream   io.Writer
+	OutStream   io.Writer
+	Percentiles []float64
+	Latency     LatencyFormat
 	// Used to rate-limit progress reporting
 	LastProgressReport ProgressReport
 	LastProgressTime   time.Time
@@ -281,12 +914,16 @@ func (o *CsvOutput) BenchmarkStart(databaseName, address string) {
 	if err != nil {
 		panic(err)
 	}
+	o.writeHeader()
+}
 
-	columnNames := make([]string, 0, len(csvColumns))
-	for _, col := range csvColumns {
+func (o *CsvOutput) writeHeader() {
+	columns := o.columns()
+	columnNames := make([]string, 0, len(columns))
+	for _, col := range columns {
 		columnNames = append(columnNames, col.name)
 	}
-	_, err = fmt.Fprintf(o.OutStream, "%s\n", strings.Join(columnNames, ","))
+	_, err := fmt.Fprintf(o.OutStream, "%s\n", strings.Join(columnNames, ","))
 	if err != nil {
 		panic(err)
 	}
@@ -299,7 +936,7 @@ func (o *CsvOutput) ReportProgress(report ProgressReport) {
 	}
 	o.LastProgressReport = report
 	o.LastProgressTime = now
-	_, err := fmt.Fprintf(o.ErrStream, "[%s][%s] %.02f%%\n", report.Section, report.Step, report.Completeness*100)
+	_, err := fmt.Fprint(o.ErrStream, formatProgress(report))
 	if err != nil {
 		panic(err)
 	}
@@ -348,21 +985,32 @@ func (o *CsvOutput) ReportThroughput(result Result) {
 			panic(err)
 		}
 	}
+	o.writeConnectReport(result)
 }
 
 func (o *CsvOutput) ReportLatency(result Result) {
 	o.writeLatencyRow(result)
+	o.writeConnectReport(result)
+}
+
+func (o *CsvOutput) writeConnectReport(result Result) {
+	s := strings.Builder{}
+	writeConnectReport(result, &s, o.Latency)
+	if _, err := fmt.Fprint(o.ErrStream, s.String()); err != nil {
+		panic(err)
+	}
 }
 
 func (o *CsvOutput) writeLatencyRow(result Result) {
 	s := strings.Builder{}
 
+	columns := o.columns()
 	for _, script := range result.Scripts {
-		for i, col := range csvColumns {
+		for i, col := range columns {
 			if i != 0 {
 				s.WriteString(",")
 			}
-			s.WriteString(col.value(result, script))
+			s.WriteString(col.value(result, script, o.Latency))
 		}
 		s.WriteString("\n")
 	}
@@ -391,34 +1039,37 @@ func fmtFloat(v interface{}) string {
 	return fmt.Sprintf("%v?", v)
 }
 
-var csvColumns = []struct {
+// Percentiles reported by default in the CSV output
+var csvPercentiles = []float64{0, 25, 50, 75, 99, 99.999, 100}
+
+type csvColumn struct {
 	name  string
-	value func(r Result, s *ScriptResult) string
-}{
-	{"db", func(r Result, s *ScriptResult) string { return fmt.Sprintf("\"%s\"", r.DatabaseName) }},
-	{"script", func(r Result, s *ScriptResult) string { return fmt.Sprintf("\"%s\"", s.ScriptName) }},
-	{"rate", func(r Result, s *ScriptResult) string { return fmtFloat(s.Rate) }},
-	{"succeeded", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.TotalCount()) }},
-	{"failed", func(r Result, s *ScriptResult) string { return fmtFloat(s.Failed) }},
-	{"mean", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.Mean() / 1000.0) }},
-	{"stdev", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.StdDev()) }},
-	{"p0", func(r Result, s *ScriptResult) string { return fmtFloat(float64(s.Latencies.Min()) / 1000.0) }},
-	{"p25", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(25)) / 1000.0)
-	}},
-	{"p50", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(50)) / 1000.0)
-	}},
-	{"p75", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(75)) / 1000.0)
-	}},
-	{"p99", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(99)) / 1000.0)
-	}},
-	{"p99999", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(99.999)) / 1000.0)
-	}},
-	{"p100", func(r Result, s *ScriptResult) string { return fmtFloat(float64(s.Latencies.Max()) / 1000.0) }},
+	value func(r Result, s *ScriptResult, f LatencyFormat) string
+}
+
+var csvBaseColumns = []csvColumn{
+	{"db", func(r Result, s *ScriptResult, f LatencyFormat) string { return fmt.Sprintf("\"%s\"", r.DatabaseName) }},
+	{"script", func(r Result, s *ScriptResult, f LatencyFormat) string { return fmt.Sprintf("\"%s\"", s.ScriptName) }},
+	{"rate", func(r Result, s *ScriptResult, f LatencyFormat) string { return fmtFloat(s.Rate) }},
+	{"succeeded", func(r Result, s *ScriptResult, f LatencyFormat) string { return fmtFloat(s.Latencies.TotalCount()) }},
+	{"failed", func(r Result, s *ScriptResult, f LatencyFormat) string { return fmtFloat(s.Failed) }},
+	{"mean", func(r Result, s *ScriptResult, f LatencyFormat) string { return f.number(s.Latencies.Mean()) }},
+	{"stdev", func(r Result, s *ScriptResult, f LatencyFormat) string { return f.number(s.Latencies.StdDev()) }},
+}
+
+// The base columns followed by one column per percentile, named like p99999 for the 99.999th percentile
+func (o *CsvOutput) columns() []csvColumn {
+	columns := append([]csvColumn{}, csvBaseColumns...)
+	for _, p := range o.Percentiles {
+		p := p
+		columns = append(columns, csvColumn{
+			name: "p" + strings.ReplaceAll(strconv.FormatFloat(p, 'f', -1, 64), ".", ""),
+			value: func(r Result, s *ScriptResult, f LatencyFormat) string {
+				return f.number(float64(valueAtPercentile(s.Latencies, p)))
+			},
+		})
+	}
+	return columns
 }
 
 func (o *CsvOutput) Errorf(format string, a ...interface{}) {
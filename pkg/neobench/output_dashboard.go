@@ -0,0 +1,134 @@
+package neobench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	ansiEnterAltScreen = "\x1b[?1049h\x1b[?25l"
+	ansiExitAltScreen  = "\x1b[?25h\x1b[?1049l"
+	ansiClearScreen    = "\x1b[H\x1b[2J"
+)
+
+// Number of recent error lines kept in the dashboard ticker
+const dashboardTickerSize = 8
+
+// Full-screen live view for terminals, redrawn at each progress interval. Once the run completes, it
+// leaves the full-screen view and writes the same final report as the interactive output.
+type DashboardOutput struct {
+	InteractiveOutput
+
+	databaseName string
+	address      string
+	started      time.Time
+	active       bool
+	// Most recent errors first
+	ticker []string
+}
+
+func (o *DashboardOutput) BenchmarkStart(databaseName, address string) {
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	o.databaseName = databaseName
+	o.address = address
+	o.started = time.Now()
+	o.active = true
+	o.write(ansiEnterAltScreen + ansiClearScreen)
+	o.write(fmt.Sprintf("Starting workload on database %s against %s\n", databaseName, address))
+}
+
+func (o *DashboardOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	names := make([]string, 0, len(checkpoint.FailedByErrorGroup))
+	for name := range checkpoint.FailedByErrorGroup {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := time.Now().Format("15:04:05")
+	for _, name := range names {
+		o.addTicker(fmt.Sprintf("%s  %s: %d failures", now, name, checkpoint.FailedByErrorGroup[name].Count))
+	}
+
+	s := strings.Builder{}
+	s.WriteString(ansiClearScreen)
+	s.WriteString(fmt.Sprintf("neobench - database %s against %s - %s elapsed\n\n", o.databaseName, o.address,
+		time.Since(o.started).Truncate(time.Second)))
+	s.WriteString(progressBar(completeness, 50))
+	s.WriteString("\n\n")
+	s.WriteString(fmt.Sprintf("Total: %.2f tps, %d failures in the last interval\n\n", checkpoint.TotalRate(), checkpoint.TotalFailed()))
+
+	w := tabwriter.NewWriter(&s, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "  Script\tTPS\tP95\tP99\tFailed\n")
+	for _, script := range checkpoint.SortedScripts() {
+		_, _ = fmt.Fprintf(w, "  %s\t%.2f\t%s\t%s\t%d\n", script.ScriptName, script.Rate,
+			o.Latency.format(float64(script.Latencies.ValueAtQuantile(95))),
+			o.Latency.format(float64(script.Latencies.ValueAtQuantile(99))),
+			script.Failed)
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+
+	s.WriteString("\nRecent errors:\n")
+	if len(o.ticker) == 0 {
+		s.WriteString("  none\n")
+	}
+	for _, line := range o.ticker {
+		s.WriteString("  " + line + "\n")
+	}
+	o.write(s.String())
+}
+
+func (o *DashboardOutput) ReportThroughput(result Result) {
+	o.leave()
+	o.InteractiveOutput.ReportThroughput(result)
+}
+
+func (o *DashboardOutput) ReportLatency(result Result) {
+	o.leave()
+	o.InteractiveOutput.ReportLatency(result)
+}
+
+func (o *DashboardOutput) Errorf(format string, a ...interface{}) {
+	if o.active {
+		o.addTicker(fmt.Sprintf("%s  ERROR: %s", time.Now().Format("15:04:05"), fmt.Sprintf(format, a...)))
+		return
+	}
+	o.InteractiveOutput.Errorf(format, a...)
+}
+
+func (o *DashboardOutput) addTicker(line string) {
+	o.ticker = append([]string{line}, o.ticker...)
+	if len(o.ticker) > dashboardTickerSize {
+		o.ticker = o.ticker[:dashboardTickerSize]
+	}
+}
+
+// Restores the normal screen
+func (o *DashboardOutput) leave() {
+	if !o.active {
+		return
+	}
+	o.active = false
+	o.write(ansiExitAltScreen)
+}
+
+func (o *DashboardOutput) write(s string) {
+	if _, err := fmt.Fprint(o.ErrStream, s); err != nil {
+		panic(err)
+	}
+}
+
+func progressBar(completeness float64, width int) string {
+	if completeness < 0 {
+		completeness = 0
+	} else if completeness > 1 {
+		completeness = 1
+	}
+	filled := int(completeness * float64(width))
+	return fmt.Sprintf("[%s%s] %5.1f%%", strings.Repeat("#", filled), strings.Repeat(".", width-filled), completeness*100)
+}
@@ -0,0 +1,265 @@
+package neobench
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Writes simple progress to stderr, and then a single self-contained HTML page with charts to stdout
+type HtmlOutput struct {
+	ErrStream   io.Writer
+	OutStream   io.Writer
+	Percentiles []float64
+	Latency     LatencyFormat
+	// Used to rate-limit progress reporting
+	LastProgressReport ProgressReport
+	LastProgressTime   time.Time
+
+	// Throughput samples from each progress checkpoint, for the throughput-over-time chart
+	started time.Time
+	samples []htmlSample
+}
+
+type htmlSample struct {
+	elapsed time.Duration
+	rates   map[string]float64
+}
+
+func (o *HtmlOutput) BenchmarkStart(databaseName, address string) {
+	o.started = time.Now()
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	_, err := fmt.Fprintf(o.ErrStream, "Starting workload on database %s against %s\n", databaseName, address)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *HtmlOutput) ReportProgress(report ProgressReport) {
+	now := time.Now()
+	if report.Section == o.LastProgressReport.Section && report.Step == o.LastProgressReport.Step && now.Sub(o.LastProgressTime).Seconds() < 10 {
+		return
+	}
+	o.LastProgressReport = report
+	o.LastProgressTime = now
+	_, err := fmt.Fprint(o.ErrStream, formatProgress(report))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *HtmlOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	rates := make(map[string]float64)
+	for name, script := range checkpoint.Scripts {
+		rates[name] = script.Rate
+	}
+	o.samples = append(o.samples, htmlSample{elapsed: time.Since(o.started), rates: rates})
+
+	_, err := fmt.Fprintf(o.ErrStream, "[%.02f%%] %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *HtmlOutput) ReportThroughput(result Result) {
+	o.writeReport("Throughput", result)
+}
+
+func (o *HtmlOutput) ReportLatency(result Result) {
+	o.writeReport("Latency", result)
+}
+
+func (o *HtmlOutput) Errorf(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "ERROR: %s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *HtmlOutput) writeReport(mode string, result Result) {
+	report := NewJsonReport(mode, result, o.Percentiles, false)
+
+	percentileSeries := make([]chartSeries, 0, len(result.Scripts))
+	for _, script := range result.SortedScripts() {
+		series := chartSeries{Name: script.ScriptName}
+		if script.Latencies.TotalCount() > 0 {
+			for _, p := range hgrmPercentiles() {
+				if p == 100 {
+					continue
+				}
+				series.Points = append(series.Points, chartPoint{
+					X: percentileToChartX(p),
+					Y: o.Latency.value(float64(valueAtPercentile(script.Latencies, p))),
+				})
+			}
+		}
+		percentileSeries = append(percentileSeries, series)
+	}
+
+	throughputSeries := make([]chartSeries, 0, len(result.Scripts))
+	for _, script := range result.SortedScripts() {
+		series := chartSeries{Name: script.ScriptName}
+		for _, sample := range o.samples {
+			series.Points = append(series.Points, chartPoint{X: sample.elapsed.Seconds(), Y: sample.rates[script.ScriptName]})
+		}
+		throughputSeries = append(throughputSeries, series)
+	}
+
+	err := htmlReportTemplate.Execute(o.OutStream, map[string]interface{}{
+		"Report":          report,
+		"Latency":         o.Latency,
+		"PercentileChart": renderLineChart(percentileSeries, "percentile", fmt.Sprintf("latency (%s)", o.Latency.orDefault().Unit), percentileAxisTicks()),
+		"ThroughputChart": renderLineChart(throughputSeries, "elapsed seconds", "transactions / second", nil),
+		"HasThroughput":   len(o.samples) > 1,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Spreads percentiles out so the tail gets most of the room; 90 is 1, 99 is 2, 99.9 is 3 and so on
+func percentileToChartX(p float64) float64 {
+	return math.Log10(100 / (100 - p))
+}
+
+func percentileAxisTicks() []chartTick {
+	ticks := make([]chartTick, 0)
+	for _, p := range []float64{0, 90, 99, 99.9, 99.99, 99.999} {
+		ticks = append(ticks, chartTick{Value: percentileToChartX(p), Label: fmt.Sprintf("%g%%", p)})
+	}
+	return ticks
+}
+
+type chartPoint struct {
+	X, Y float64
+}
+
+type chartSeries struct {
+	Name   string
+	Points []chartPoint
+}
+
+type chartTick struct {
+	Value float64
+	Label string
+}
+
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// Renders series as an inline SVG line chart; if xTicks is nil, ticks are spread evenly over the x range
+func renderLineChart(series []chartSeries, xLabel, yLabel string, xTicks []chartTick) template.HTML {
+	const width, height, left, right, top, bottom = 720.0, 360.0, 70.0, 160.0, 20.0, 50.0
+	plotW, plotH := width-left-right, height-top-bottom
+
+	minX, maxX, maxY := math.Inf(1), math.Inf(-1), 0.0
+	for _, s := range series {
+		for _, p := range s.Points {
+			minX, maxX, maxY = math.Min(minX, p.X), math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+	}
+	for _, t := range xTicks {
+		minX, maxX = math.Min(minX, t.Value), math.Max(maxX, t.Value)
+	}
+	if math.IsInf(minX, 0) || maxX == minX {
+		return template.HTML("<p><em>Not enough data to chart.</em></p>")
+	}
+	if maxY == 0 {
+		maxY = 1
+	}
+	maxY *= 1.1
+	if xTicks == nil {
+		for i := 0; i <= 5; i++ {
+			v := minX + (maxX-minX)*float64(i)/5
+			xTicks = append(xTicks, chartTick{Value: v, Label: fmt.Sprintf("%.0f", v)})
+		}
+	}
+	px := func(x float64) float64 { return left + (x-minX)/(maxX-minX)*plotW }
+	py := func(y float64) float64 { return top + plotH - y/maxY*plotH }
+
+	s := strings.Builder{}
+	s.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %.0f %.0f" width="%.0f" height="%.0f" font-family="sans-serif" font-size="11">`, width, height, width, height))
+	s.WriteString(fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="none" stroke="#ccc"/>`, left, top, plotW, plotH))
+	for _, t := range xTicks {
+		x := px(t.Value)
+		s.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#eee"/>`, x, top, x, top+plotH))
+		s.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, x, top+plotH+15, template.HTMLEscapeString(t.Label)))
+	}
+	for i := 0; i <= 4; i++ {
+		v := maxY * float64(i) / 4
+		y := py(v)
+		s.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#eee"/>`, left, y, left+plotW, y))
+		s.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" text-anchor="end">%.2f</text>`, left-5, y+4, v))
+	}
+	s.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, left+plotW/2, height-10, template.HTMLEscapeString(xLabel)))
+	s.WriteString(fmt.Sprintf(`<text transform="translate(15,%.1f) rotate(-90)" text-anchor="middle">%s</text>`, top+plotH/2, template.HTMLEscapeString(yLabel)))
+
+	for i, ser := range series {
+		color := chartColors[i%len(chartColors)]
+		points := make([]chartPoint, len(ser.Points))
+		copy(points, ser.Points)
+		sort.Slice(points, func(a, b int) bool { return points[a].X < points[b].X })
+		coords := make([]string, 0, len(points))
+		for _, p := range points {
+			coords = append(coords, fmt.Sprintf("%.1f,%.1f", px(p.X), py(p.Y)))
+		}
+		s.WriteString(fmt.Sprintf(`<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(coords, " ")))
+		ly := top + 15*float64(i+1)
+		s.WriteString(fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="10" height="10" fill="%s"/>`, left+plotW+10, ly-9, color))
+		s.WriteString(fmt.Sprintf(`<text x="%.1f" y="%.1f">%s</text>`, left+plotW+25, ly, template.HTMLEscapeString(abbreviateQuery(ser.Name, 22))))
+	}
+	s.WriteString("</svg>")
+	return template.HTML(s.String())
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>neobench {{.Report.Mode}} report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ddd; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+code { background: #f4f4f4; padding: 2px 4px; }
+</style>
+</head>
+<body>
+<h1>neobench {{.Report.Mode}} report</h1>
+<table>
+<tr><td>Scenario</td><td><code>{{.Report.Scenario}}</code></td></tr>
+<tr><td>Database</td><td>{{if .Report.Database}}{{.Report.Database}}{{else}}&lt;default&gt;{{end}}</td></tr>
+<tr><td>Seed</td><td>{{.Report.Seed}}</td></tr>
+<tr><td>Start</td><td>{{.Report.Start}}</td></tr>
+<tr><td>End</td><td>{{.Report.End}}</td></tr>
+{{range $k, $v := .Report.Tags}}<tr><td>Tag {{$k}}</td><td>{{$v}}</td></tr>
+{{end}}</table>
+
+<h2>Summary</h2>
+<p>{{.Report.Succeeded}} successful and {{.Report.Failed}} failed transactions, {{printf "%.3f" .Report.Rate}} per second.</p>
+{{if .Report.Scripts}}<table>
+<tr><th>Script</th><th>Share</th><th>Rate</th><th>Succeeded</th><th>Failed</th><th>Mean</th>{{range (index .Report.Scripts 0).Latency.Percentiles}}<th>P{{.Percentile}}</th>{{end}}</tr>
+{{range .Report.Scripts}}<tr><td>{{.Name}}</td><td>{{printf "%.1f" .Share}}%</td><td>{{printf "%.3f" .Rate}}/s</td><td>{{.Succeeded}}</td><td>{{.Failed}}</td><td>{{$.Latency.FormatMillis .Latency.Mean}}</td>{{range .Latency.Percentiles}}<td>{{$.Latency.FormatMillis .Value}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+
+<h2>Latency by percentile</h2>
+{{.PercentileChart}}
+
+{{if .HasThroughput}}<h2>Throughput over time</h2>
+{{.ThroughputChart}}
+{{end}}
+
+<h2>Errors</h2>
+{{if .Report.Errors}}<table>
+<tr><th>Cause</th><th>Count</th><th>Example</th></tr>
+{{range .Report.Errors}}<tr><td>{{.Group}}</td><td>{{.Count}}</td><td style="text-align:left">{{.Example}}</td></tr>
+{{end}}</table>{{else}}<p>No errors!</p>{{end}}
+</body>
+</html>
+`))
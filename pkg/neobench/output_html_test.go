@@ -0,0 +1,35 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHtmlReport(t *testing.T) {
+	result := NewResult("neo4j", " -c 1")
+	histo := hdrhistogram.New(0, 60*60*1000000, 3)
+	for i := int64(1); i <= 100; i++ {
+		assert.NoError(t, histo.RecordValue(i*1000))
+	}
+	result.Scripts["<read>"] = &ScriptResult{ScriptName: "<read>", Rate: 10, Succeeded: 100, Latencies: histo}
+	result.FailedByErrorGroup["Neo.TransientError"] = FailureGroup{Count: 2}
+
+	out := strings.Builder{}
+	o := &HtmlOutput{ErrStream: &strings.Builder{}, OutStream: &out, Percentiles: []float64{50, 99}}
+	o.BenchmarkStart("neo4j", "neo4j://localhost")
+	o.samples = []htmlSample{
+		{elapsed: time.Second, rates: map[string]float64{"<read>": 8}},
+		{elapsed: 2 * time.Second, rates: map[string]float64{"<read>": 12}},
+	}
+	o.ReportLatency(result)
+
+	html := out.String()
+	assert.Contains(t, html, "&lt;read&gt;")
+	assert.Contains(t, html, "<th>P99</th>")
+	assert.Contains(t, html, "Throughput over time")
+	assert.Contains(t, html, "Neo.TransientError")
+	assert.Equal(t, 2, strings.Count(html, "<polyline"))
+}
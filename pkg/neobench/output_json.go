@@ -0,0 +1,452 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"sort"
+	"time"
+)
+
+// Percentiles reported by default in the JSON output
+var jsonPercentiles = []float64{0, 25, 50, 75, 95, 99, 99.9, 99.99, 99.999, 100}
+
+// Writes simple progress to stderr, and then the final result as one JSON document to stdout
+type JsonOutput struct {
+	ErrStream          io.Writer
+	OutStream          io.Writer
+	Percentiles        []float64
+	StatementLatencies bool
+	// Used to rate-limit progress reporting
+	LastProgressReport ProgressReport
+	LastProgressTime   time.Time
+}
+
+func (o *JsonOutput) BenchmarkStart(databaseName, address string) {
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	_, err := fmt.Fprintf(o.ErrStream, "Starting workload on database %s against %s\n", databaseName, address)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *JsonOutput) ReportProgress(report ProgressReport) {
+	now := time.Now()
+	if report.Section == o.LastProgressReport.Section && report.Step == o.LastProgressReport.Step && now.Sub(o.LastProgressTime).Seconds() < 10 {
+		return
+	}
+	o.LastProgressReport = report
+	o.LastProgressTime = now
+	_, err := fmt.Fprint(o.ErrStream, formatProgress(report))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *JsonOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	_, err := fmt.Fprintf(o.ErrStream, "[%.02f%%] %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *JsonOutput) ReportThroughput(result Result) {
+	o.writeReport("throughput", result)
+}
+
+func (o *JsonOutput) ReportLatency(result Result) {
+	o.writeReport("latency", result)
+}
+
+func (o *JsonOutput) writeReport(mode string, result Result) {
+	encoder := json.NewEncoder(o.OutStream)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(NewJsonReport(mode, result, o.Percentiles, o.StatementLatencies)); err != nil {
+		panic(err)
+	}
+}
+
+func (o *JsonOutput) Errorf(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "ERROR: %s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// The document written by -o json; latencies are in milliseconds
+type JsonReport struct {
+	Mode      string             `json:"mode"`
+	Database  string             `json:"database"`
+	Scenario  string             `json:"scenario"`
+	Seed      int64              `json:"seed"`
+	Start     time.Time          `json:"start"`
+	End       time.Time          `json:"end"`
+	Tags      map[string]string  `json:"tags"`
+	Succeeded int64              `json:"succeeded"`
+	Failed    int64              `json:"failed"`
+	Rate      float64            `json:"rate"`
+	Scripts   []JsonScriptReport `json:"scripts"`
+	Errors    []JsonErrorReport  `json:"errors"`
+	// Only if the server version could be read at the start of the run
+	Server *ServerInfo `json:"server,omitempty"`
+	// Only if the run had at least two progress intervals
+	Throughput *JsonThroughputReport `json:"throughput,omitempty"`
+	// Each client by id, or executor with virtual users; only for runs of a single phase on one machine
+	Clients []JsonClientReport `json:"clients,omitempty"`
+	// Hooks that fired during the run
+	Events []JsonEventReport `json:"events,omitempty"`
+	// Only with --measure-recovery, and if the workload was disrupted
+	Recovery []JsonRecoveryReport `json:"recovery,omitempty"`
+	// Only with --threshold
+	Thresholds []JsonThresholdReport `json:"thresholds,omitempty"`
+	// Only present when running with a connection per transaction
+	Connect *JsonLatencyReport `json:"connect,omitempty"`
+	// Only in latency mode
+	Saturation *JsonSaturationReport `json:"saturation,omitempty"`
+	// Only when transactions reached the server
+	Pool *JsonPoolReport `json:"pool,omitempty"`
+}
+
+// Time spent getting connections, and pool events seen by the driver
+type JsonPoolReport struct {
+	// From asking for a transaction until it could run statements
+	AcquireTime        JsonLatencyReport `json:"acquire_time"`
+	ConnectionsCreated int64             `json:"connections_created"`
+	ConnectionsClosed  int64             `json:"connections_closed"`
+	Exhausted          int64             `json:"exhausted"`
+	AcquireTimeouts    int64             `json:"acquire_timeouts"`
+	// See --session-reuse
+	SessionsOpened int64 `json:"sessions_opened"`
+}
+
+// Transactions per second over each progress interval
+type JsonThroughputReport struct {
+	Intervals int64   `json:"intervals"`
+	Mean      float64 `json:"mean"`
+	Stddev    float64 `json:"stddev"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	// Coefficient of variation, stddev over mean
+	CV float64 `json:"cv"`
+}
+
+// Transactions and latency of one client across its scripts
+type JsonClientReport struct {
+	Id        int64   `json:"id"`
+	Succeeded int64   `json:"succeeded"`
+	Failed    int64   `json:"failed"`
+	Mean      float64 `json:"mean"`
+	P50       float64 `json:"p50"`
+	P99       float64 `json:"p99"`
+	Max       float64 `json:"max"`
+}
+
+// How far behind schedule the database fell in latency mode
+type JsonSaturationReport struct {
+	// Time units of work waited past their scheduled start
+	QueueTime JsonLatencyReport `json:"queue_time"`
+	// Units of work due behind each one as it started, per client
+	MaxBacklog  int64   `json:"max_backlog"`
+	MeanBacklog float64 `json:"mean_backlog"`
+	// Units of work skipped because the backlog was full, see --max-backlog
+	Dropped int64 `json:"dropped"`
+}
+
+type JsonScriptReport struct {
+	Name string `json:"name"`
+	// Percentage of all transactions in the run that were this script
+	Share     float64           `json:"share"`
+	Rate      float64           `json:"rate"`
+	Succeeded int64             `json:"succeeded"`
+	Failed    int64             `json:"failed"`
+	Latency   JsonLatencyReport `json:"latency"`
+	// Latency from when each transaction actually started rather than when it was scheduled to; only in latency mode
+	ServiceLatency *JsonLatencyReport `json:"service_latency,omitempty"`
+	// Only present if statement latencies were requested, or statements were profiled
+	Statements []JsonStatementReport `json:"statements,omitempty"`
+	// Attempts aborted by lock conflicts, including ones retried successfully
+	Deadlocks         int64   `json:"deadlocks"`
+	DeadlockRate      float64 `json:"deadlock_rate"`
+	LockWaitAborts    int64   `json:"lock_wait_aborts"`
+	LockWaitAbortRate float64 `json:"lock_wait_abort_rate"`
+	// Successful transactions that returned results an \expect in the script didn't, and the first mismatch
+	DataErrors       int64  `json:"data_errors"`
+	DataErrorExample string `json:"data_error_example,omitempty"`
+	// Only present for scripts with a metric column, eg. path lengths for builtin:traversal
+	Metric *JsonMetricReport `json:"metric,omitempty"`
+}
+
+// Distribution of a scripts metric column; unlike latencies, values are as returned by the query
+type JsonMetricReport struct {
+	Name        string                 `json:"name"`
+	Count       int64                  `json:"count"`
+	Min         int64                  `json:"min"`
+	Max         int64                  `json:"max"`
+	Mean        float64                `json:"mean"`
+	Percentiles []JsonPercentileReport `json:"percentiles"`
+}
+
+type JsonStatementReport struct {
+	Query   string            `json:"query"`
+	Failed  int64             `json:"failed"`
+	Latency JsonLatencyReport `json:"latency"`
+	// Only present if some runs of the statement were profiled
+	Profile *JsonProfileReport `json:"profile,omitempty"`
+}
+
+type JsonProfileReport struct {
+	Samples int64            `json:"samples"`
+	DbHits  JsonMetricReport `json:"db_hits"`
+	Rows    JsonMetricReport `json:"rows"`
+	Plans   []JsonPlanReport `json:"plans"`
+}
+
+type JsonPlanReport struct {
+	Digest    string `json:"digest"`
+	Operators string `json:"operators"`
+	Count     int64  `json:"count"`
+}
+
+type JsonLatencyReport struct {
+	Count       int64                  `json:"count"`
+	Min         float64                `json:"min"`
+	Max         float64                `json:"max"`
+	Mean        float64                `json:"mean"`
+	StdDev      float64                `json:"stddev"`
+	Percentiles []JsonPercentileReport `json:"percentiles"`
+}
+
+type JsonPercentileReport struct {
+	Percentile float64 `json:"percentile"`
+	Value      float64 `json:"value"`
+}
+
+type JsonEventReport struct {
+	Time time.Time `json:"time"`
+	// Seconds from the start of the run
+	Elapsed float64 `json:"elapsed"`
+	Name    string  `json:"name"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type JsonThresholdReport struct {
+	Threshold string `json:"threshold"`
+	// In the unit of the metric: milliseconds for latencies, percent for error_rate
+	Value  float64 `json:"value"`
+	Passed bool    `json:"passed"`
+}
+
+type JsonRecoveryReport struct {
+	// Seconds from the start of the run
+	Start float64 `json:"start"`
+	// Milliseconds, or null if the workload hadn't recovered when the run ended
+	TimeToRecover  *float64 `json:"time_to_recover"`
+	Failed         int64    `json:"failed"`
+	RoutingChanges int64    `json:"routing_changes"`
+	Cause          string   `json:"cause"`
+}
+
+type JsonErrorReport struct {
+	Group          string `json:"group"`
+	Classification string `json:"classification"`
+	// server if the database answered with this error, client if it didn't answer, see ErrorOriginServer
+	Origin  string `json:"origin"`
+	Count   int64  `json:"count"`
+	Example string `json:"example"`
+}
+
+func NewJsonReport(mode string, result Result, percentiles []float64, statementLatencies bool) JsonReport {
+	tags := result.Tags
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	report := JsonReport{
+		Mode:      mode,
+		Database:  result.DatabaseName,
+		Scenario:  result.Scenario,
+		Seed:      result.Seed,
+		Start:     result.Start,
+		End:       result.End,
+		Tags:      tags,
+		Server:    result.Server,
+		Succeeded: result.TotalSucceeded(),
+		Failed:    result.TotalFailed(),
+		Rate:      result.TotalRate(),
+		Scripts:   make([]JsonScriptReport, 0, len(result.Scripts)),
+		Errors:    make([]JsonErrorReport, 0, len(result.FailedByErrorGroup)),
+	}
+	if t := result.Throughput; t.Intervals >= 2 {
+		report.Throughput = &JsonThroughputReport{Intervals: t.Intervals, Mean: t.Mean(), Stddev: t.StdDev(), Min: t.Min, Max: t.Max, CV: t.CV()}
+	}
+	for _, w := range result.Workers {
+		report.Clients = append(report.Clients, JsonClientReport{
+			Id:        w.WorkerId,
+			Succeeded: w.Succeeded,
+			Failed:    w.Failed,
+			Mean:      w.Mean / 1000.0,
+			P50:       float64(w.P50) / 1000.0,
+			P99:       float64(w.P99) / 1000.0,
+			Max:       float64(w.Max) / 1000.0,
+		})
+	}
+	total := result.TotalSucceeded() + result.TotalFailed()
+	for _, script := range result.SortedScripts() {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(script.Succeeded+script.Failed) / float64(total)
+		}
+		scriptReport := JsonScriptReport{
+			Name:      script.ScriptName,
+			Share:     share,
+			Rate:      script.Rate,
+			Succeeded: script.Succeeded,
+			Failed:    script.Failed,
+			Latency:   newJsonLatencyReport(script.Latencies, percentiles),
+
+			Deadlocks:         script.Deadlocks,
+			DeadlockRate:      script.perSecond(script.Deadlocks),
+			LockWaitAborts:    script.LockWaitAborts,
+			LockWaitAbortRate: script.perSecond(script.LockWaitAborts),
+			DataErrors:        script.DataErrors,
+			DataErrorExample:  script.FirstDataError,
+		}
+		if mode == "latency" && script.ServiceLatencies != nil {
+			service := newJsonLatencyReport(script.ServiceLatencies, percentiles)
+			scriptReport.ServiceLatency = &service
+		}
+		if histo := script.MetricValues; histo != nil && histo.TotalCount() > 0 {
+			metric := newJsonMetricReport(script.Metric, histo, percentiles)
+			scriptReport.Metric = &metric
+		}
+		for _, statement := range script.Statements {
+			if statement == nil || (!statementLatencies && statement.Profile == nil) {
+				continue
+			}
+			statementReport := JsonStatementReport{
+				Query:   statement.Query,
+				Failed:  statement.Failed,
+				Latency: newJsonLatencyReport(statement.Latencies, percentiles),
+			}
+			if statement.Profile != nil {
+				statementReport.Profile = newJsonProfileReport(statement.Profile, percentiles)
+			}
+			scriptReport.Statements = append(scriptReport.Statements, statementReport)
+		}
+		report.Scripts = append(report.Scripts, scriptReport)
+	}
+	for name, group := range result.FailedByErrorGroup {
+		example := ""
+		if group.FirstFailure != nil {
+			example = group.FirstFailure.Error()
+		}
+		report.Errors = append(report.Errors, JsonErrorReport{
+			Group:          name,
+			Classification: ClassifyErrorGroup(name),
+			Origin:         ErrorGroupOrigin(name),
+			Count:          group.Count,
+			Example:        example,
+		})
+	}
+	sort.Slice(report.Errors, func(i, j int) bool { return report.Errors[i].Group < report.Errors[j].Group })
+	for _, window := range result.Recovery {
+		recovery := JsonRecoveryReport{
+			Start:          window.Start.Sub(result.Start).Seconds(),
+			Failed:         window.Failed,
+			RoutingChanges: window.RoutingChanges,
+			Cause:          window.Cause,
+		}
+		if !window.Recovered.IsZero() {
+			ms := float64(window.TimeToRecover().Microseconds()) / 1000.0
+			recovery.TimeToRecover = &ms
+		}
+		report.Recovery = append(report.Recovery, recovery)
+	}
+	for _, t := range result.Thresholds {
+		report.Thresholds = append(report.Thresholds, JsonThresholdReport{Threshold: t.Threshold.Spec, Value: t.Value, Passed: t.Passed})
+	}
+	for _, event := range result.Events {
+		report.Events = append(report.Events, JsonEventReport{
+			Time:    event.Time,
+			Elapsed: event.Time.Sub(result.Start).Seconds(),
+			Name:    event.Name,
+			Error:   event.Err,
+		})
+	}
+	if result.ConnectLatencies != nil && result.ConnectLatencies.TotalCount() > 0 {
+		connect := newJsonLatencyReport(result.ConnectLatencies, percentiles)
+		report.Connect = &connect
+	}
+	if mode == "latency" && result.QueueTimes != nil && result.QueueTimes.TotalCount() > 0 {
+		report.Saturation = &JsonSaturationReport{
+			QueueTime:   newJsonLatencyReport(result.QueueTimes, percentiles),
+			MaxBacklog:  result.Backlog.Max(),
+			MeanBacklog: result.Backlog.Mean(),
+			Dropped:     result.Dropped,
+		}
+	}
+	if result.AcquireTimes != nil && result.AcquireTimes.TotalCount() > 0 {
+		report.Pool = &JsonPoolReport{
+			AcquireTime:        newJsonLatencyReport(result.AcquireTimes, percentiles),
+			ConnectionsCreated: result.Pool.ConnectionsCreated,
+			ConnectionsClosed:  result.Pool.ConnectionsClosed,
+			Exhausted:          result.Pool.Exhausted,
+			AcquireTimeouts:    result.Pool.AcquireTimeouts,
+			SessionsOpened:     result.SessionsOpened,
+		}
+	}
+	return report
+}
+
+func newJsonMetricReport(name string, histo *hdrhistogram.Histogram, percentiles []float64) JsonMetricReport {
+	report := JsonMetricReport{
+		Name:  name,
+		Count: histo.TotalCount(),
+		Min:   histo.Min(),
+		Max:   histo.Max(),
+		Mean:  histo.Mean(),
+	}
+	for _, p := range percentiles {
+		report.Percentiles = append(report.Percentiles, JsonPercentileReport{
+			Percentile: p,
+			Value:      float64(valueAtPercentile(histo, p)),
+		})
+	}
+	return report
+}
+
+func newJsonProfileReport(profile *StatementProfile, percentiles []float64) *JsonProfileReport {
+	report := &JsonProfileReport{
+		Samples: profile.Samples,
+		DbHits:  newJsonMetricReport("db_hits", profile.DbHits, percentiles),
+		Rows:    newJsonMetricReport("rows", profile.Rows, percentiles),
+	}
+	for _, digest := range profile.SortedPlans() {
+		report.Plans = append(report.Plans, JsonPlanReport{
+			Digest:    digest,
+			Operators: profile.Plans[digest].Operators,
+			Count:     profile.Plans[digest].Count,
+		})
+	}
+	return report
+}
+
+func newJsonLatencyReport(histo *hdrhistogram.Histogram, percentiles []float64) JsonLatencyReport {
+	report := JsonLatencyReport{
+		Count:       histo.TotalCount(),
+		Min:         float64(histo.Min()) / 1000.0,
+		Max:         float64(histo.Max()) / 1000.0,
+		Mean:        histo.Mean() / 1000.0,
+		StdDev:      histo.StdDev() / 1000.0,
+		Percentiles: make([]JsonPercentileReport, 0, len(percentiles)),
+	}
+	for _, p := range percentiles {
+		report.Percentiles = append(report.Percentiles, JsonPercentileReport{
+			Percentile: p,
+			Value:      float64(valueAtPercentile(histo, p)) / 1000.0,
+		})
+	}
+	return report
+}
@@ -0,0 +1,198 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Creates an output that writes its report to out, see RegisterOutput. Progress and errors are expected to
+// go to stderr, as the builtin formats do, so they don't end up in report files.
+type OutputFactory func(out io.Writer, opts OutputOptions) (Output, error)
+
+var outputsMut sync.RWMutex
+var outputFormats = map[string]OutputFactory{
+	"interactive": func(out io.Writer, opts OutputOptions) (Output, error) {
+		return &InteractiveOutput{
+			ErrStream:          os.Stderr,
+			OutStream:          out,
+			Percentiles:        orPercentiles(opts.Percentiles, interactivePercentiles),
+			StatementLatencies: opts.StatementLatencies,
+			Latency:            opts.Latency,
+		}, nil
+	},
+	"dashboard": func(out io.Writer, opts OutputOptions) (Output, error) {
+		return &DashboardOutput{InteractiveOutput: InteractiveOutput{
+			ErrStream:          os.Stderr,
+			OutStream:          out,
+			Percentiles:        orPercentiles(opts.Percentiles, interactivePercentiles),
+			StatementLatencies: opts.StatementLatencies,
+			Latency:            opts.Latency,
+		}}, nil
+	},
+	"csv": func(out io.Writer, opts OutputOptions) (Output, error) {
+		return &CsvOutput{
+			ErrStream:   os.Stderr,
+			OutStream:   out,
+			Percentiles: orPercentiles(opts.Percentiles, csvPercentiles),
+			Latency:     opts.Latency,
+		}, nil
+	},
+	"json": func(out io.Writer, opts OutputOptions) (Output, error) {
+		return &JsonOutput{
+			ErrStream:          os.Stderr,
+			OutStream:          out,
+			Percentiles:        orPercentiles(opts.Percentiles, jsonPercentiles),
+			StatementLatencies: opts.StatementLatencies,
+		}, nil
+	},
+	"html": func(out io.Writer, opts OutputOptions) (Output, error) {
+		return &HtmlOutput{
+			ErrStream:   os.Stderr,
+			OutStream:   out,
+			Percentiles: orPercentiles(opts.Percentiles, jsonPercentiles),
+			Latency:     opts.Latency,
+		}, nil
+	},
+}
+
+func orPercentiles(percentiles, defaults []float64) []float64 {
+	if percentiles == nil {
+		return defaults
+	}
+	return percentiles
+}
+
+// Adds an output format, usable in NewOutput, NewOutputs and -o like the builtin ones; fails if the name
+// is taken
+func RegisterOutput(name string, factory OutputFactory) error {
+	if name == "" || name == "auto" || strings.ContainsAny(name, ",=") {
+		return fmt.Errorf("invalid output format name: '%s'", name)
+	}
+	outputsMut.Lock()
+	defer outputsMut.Unlock()
+	if _, found := outputFormats[name]; found {
+		return fmt.Errorf("output format %s is already registered", name)
+	}
+	outputFormats[name] = factory
+	return nil
+}
+
+// Names of the output formats, builtin and registered, in alphabetical order
+func OutputFormats() []string {
+	outputsMut.RLock()
+	defer outputsMut.RUnlock()
+	names := make([]string, 0, len(outputFormats))
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Passes everything on to each of several outputs, so one run can report in several formats at once, eg.
+// interactive on the terminal and csv to a file. Only the first output reports progress and errors, the
+// others would repeat them.
+type MultiOutput struct {
+	outputs []Output
+	files   []*os.File
+}
+
+func NewMultiOutput(first Output, rest ...Output) *MultiOutput {
+	m := &MultiOutput{outputs: []Output{first}}
+	for _, o := range rest {
+		m.outputs = append(m.outputs, NewQuietOutput(o))
+	}
+	return m
+}
+
+// Creates the outputs in spec, a comma separated list of formats, each writing its report to stdout, or to a
+// file if followed by =<path>, eg. "interactive,csv=results.csv". At most one can write to stdout; the first
+// in the list reports progress and errors, and opts.Quiet only applies to that one. Close the result once
+// the reports are written.
+func NewOutputs(spec string, opts OutputOptions) (*MultiOutput, error) {
+	m := &MultiOutput{}
+	toStdout := false
+	for i, part := range strings.Split(spec, ",") {
+		name, path := strings.TrimSpace(part), ""
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, path = strings.TrimSpace(name[:eq]), strings.TrimSpace(name[eq+1:])
+		}
+		var out io.Writer = os.Stdout
+		if path == "" {
+			if toStdout {
+				_ = m.Close()
+				return nil, fmt.Errorf("only one output can write to stdout, give the others a file, eg. %s=results.%s", name, name)
+			}
+			toStdout = true
+		} else {
+			f, err := os.Create(path)
+			if err != nil {
+				_ = m.Close()
+				return nil, fmt.Errorf("failed to create %s output at %s: %s", name, path, err)
+			}
+			m.files = append(m.files, f)
+			out = f
+		}
+		outputOpts := opts
+		outputOpts.Quiet = opts.Quiet || i > 0
+		output, err := NewOutputTo(name, out, outputOpts)
+		if err != nil {
+			_ = m.Close()
+			return nil, err
+		}
+		m.outputs = append(m.outputs, output)
+	}
+	return m, nil
+}
+
+func (m *MultiOutput) BenchmarkStart(databaseName, url string) {
+	for _, o := range m.outputs {
+		o.BenchmarkStart(databaseName, url)
+	}
+}
+
+func (m *MultiOutput) ReportProgress(report ProgressReport) {
+	for _, o := range m.outputs {
+		o.ReportProgress(report)
+	}
+}
+
+func (m *MultiOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	for _, o := range m.outputs {
+		o.ReportWorkloadProgress(completeness, checkpoint)
+	}
+}
+
+func (m *MultiOutput) ReportThroughput(result Result) {
+	for _, o := range m.outputs {
+		o.ReportThroughput(result)
+	}
+}
+
+func (m *MultiOutput) ReportLatency(result Result) {
+	for _, o := range m.outputs {
+		o.ReportLatency(result)
+	}
+}
+
+func (m *MultiOutput) Errorf(format string, a ...interface{}) {
+	if len(m.outputs) > 0 {
+		m.outputs[0].Errorf(format, a...)
+	}
+}
+
+// Closes the files the outputs write to, if any
+func (m *MultiOutput) Close() error {
+	var firstErr error
+	for _, f := range m.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to write output %s: %s", f.Name(), err)
+		}
+	}
+	m.files = nil
+	return firstErr
+}
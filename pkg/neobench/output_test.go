@@ -0,0 +1,200 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyFormat(t *testing.T) {
+	assert.Equal(t, "1.235ms", LatencyFormat{}.format(1234.6))
+	assert.Equal(t, "1.23ms", LatencyFormat{Unit: "ms", Precision: 2}.format(1234.5))
+	assert.Equal(t, "1234.5us", LatencyFormat{Unit: "us", Precision: 1}.format(1234.5))
+	assert.Equal(t, "250", LatencyFormat{Unit: "us", Precision: 0}.number(250))
+	assert.Equal(t, "0.250ms", DefaultLatencyFormat.FormatMillis(0.25))
+
+	_, err := NewLatencyFormat("s", 3)
+	assert.Error(t, err)
+	_, err = NewLatencyFormat("us", -1)
+	assert.Error(t, err)
+}
+
+func TestCsvLatencyUnit(t *testing.T) {
+	result := NewResult("neo4j", "test")
+	script := &ScriptResult{ScriptName: "read", Succeeded: 1, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+	assert.NoError(t, script.Latencies.RecordValue(250))
+	result.Scripts["read"] = script
+
+	out := strings.Builder{}
+	csv := &CsvOutput{OutStream: &out, ErrStream: &strings.Builder{}, Percentiles: []float64{50}, Latency: LatencyFormat{Unit: "us", Precision: 0}}
+	csv.ReportLatency(result)
+	assert.Equal(t, "\"neo4j\",\"read\",0.000,1.000,0.000,250,0,250\n", out.String())
+}
+
+func TestReportCallsOutUnsteadyThroughput(t *testing.T) {
+	result := NewResult("neo4j", "test")
+	for _, rate := range []float64{20, 180, 20, 180} {
+		result.Throughput.record(rate)
+	}
+	out := strings.Builder{}
+	report := &InteractiveOutput{OutStream: &out, ErrStream: &strings.Builder{}, Latency: DefaultLatencyFormat}
+	report.ReportThroughput(result)
+
+	assert.Contains(t, out.String(), "Throughput per interval: Mean: 100.000, Stddev: 80.000, Min: 20.000, Max: 180.000, CV: 80.0 % over 4 intervals\n")
+	assert.Contains(t, out.String(), "Throughput swung widely between intervals")
+}
+
+func TestOutputsReportToEachFormatAndFile(t *testing.T) {
+	var recorded []string
+	assert.NoError(t, RegisterOutput("recording", func(out io.Writer, opts OutputOptions) (Output, error) {
+		return &recordingOutput{calls: &recorded}, nil
+	}))
+	assert.Error(t, RegisterOutput("csv", nil))
+	assert.Contains(t, OutputFormats(), "recording")
+
+	dir, err := ioutil.TempDir("", "neobench-outputs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	csvPath := filepath.Join(dir, "results.csv")
+
+	out, err := NewOutputs("recording, csv="+csvPath, OutputOptions{Percentiles: []float64{50}})
+	assert.NoError(t, err)
+	result := NewResult("neo4j", "test")
+	script := &ScriptResult{ScriptName: "read", Succeeded: 1, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+	assert.NoError(t, script.Latencies.RecordValue(2000))
+	result.Scripts["read"] = script
+	out.BenchmarkStart("neo4j", "bolt://localhost:7687")
+	out.ReportWorkloadProgress(0.5, result)
+	out.Errorf("boom")
+	out.ReportLatency(result)
+	assert.NoError(t, out.Close())
+
+	assert.Equal(t, []string{"start", "progress", "error: boom", "latency"}, recorded)
+	content, err := ioutil.ReadFile(csvPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "db,script,rate,succeeded,failed,mean,stdev,p50\n\"neo4j\",\"read\",0.000,1.000,0.000,2.000,0.000,2.000\n", string(content))
+
+	_, err = NewOutputs("csv,json", OutputOptions{})
+	assert.EqualError(t, err, "only one output can write to stdout, give the others a file, eg. json=results.json")
+	_, err = NewOutputs("xml", OutputOptions{})
+	assert.Error(t, err)
+}
+
+func TestJsonOutputWritesReportThatLoadsBack(t *testing.T) {
+	result := NewResult("neo4j", "-w builtin:tpcb-like -c 2")
+	result.Seed = 1337
+	result.Start = time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	result.End = result.Start.Add(10 * time.Second)
+	result.Tags = map[string]string{"branch": "main"}
+	script := &ScriptResult{ScriptName: "read", Rate: 1.5, Succeeded: 3, Failed: 1, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+	for _, latency := range []int64{1000, 2000, 3000} {
+		assert.NoError(t, script.Latencies.RecordValue(latency))
+	}
+	result.Scripts["read"] = script
+	result.FailedByErrorGroup["Neo.TransientError.Transaction.DeadlockDetected"] = FailureGroup{Count: 1, FirstFailure: fmt.Errorf("deadlock")}
+
+	out := strings.Builder{}
+	output := &JsonOutput{OutStream: &out, ErrStream: &strings.Builder{}, Percentiles: []float64{50, 100}}
+	output.ReportThroughput(result)
+
+	// Latencies are in milliseconds
+	assert.Equal(t, `{
+  "mode": "throughput",
+  "database": "neo4j",
+  "scenario": "-w builtin:tpcb-like -c 2",
+  "seed": 1337,
+  "start": "2021-03-04T10:00:00Z",
+  "end": "2021-03-04T10:00:10Z",
+  "tags": {
+    "branch": "main"
+  },
+  "succeeded": 3,
+  "failed": 1,
+  "rate": 1.5,
+  "scripts": [
+    {
+      "name": "read",
+      "share": 100,
+      "rate": 1.5,
+      "succeeded": 3,
+      "failed": 1,
+      "latency": {
+        "count": 3,
+        "min": 1,
+        "max": 3.001,
+        "mean": 2.0003333333333333,
+        "stddev": 0.8169048632218783,
+        "percentiles": [
+          {
+            "percentile": 50,
+            "value": 2
+          },
+          {
+            "percentile": 100,
+            "value": 3.001
+          }
+        ]
+      },
+      "deadlocks": 0,
+      "deadlock_rate": 0,
+      "lock_wait_aborts": 0,
+      "lock_wait_abort_rate": 0,
+      "data_errors": 0
+    }
+  ],
+  "errors": [
+    {
+      "group": "Neo.TransientError.Transaction.DeadlockDetected",
+      "classification": "deadlock",
+      "origin": "server",
+      "count": 1,
+      "example": "deadlock"
+    }
+  ]
+}
+`, out.String())
+
+	dir, err := ioutil.TempDir("", "neobench-json")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(out.String()), 0644))
+	loaded, err := LoadReport(path)
+	assert.NoError(t, err)
+	assert.Equal(t, NewJsonReport("throughput", result, []float64{50, 100}, false), loaded)
+}
+
+type recordingOutput struct {
+	calls *[]string
+}
+
+func (o *recordingOutput) BenchmarkStart(databaseName, url string) {
+	*o.calls = append(*o.calls, "start")
+}
+
+func (o *recordingOutput) ReportProgress(report ProgressReport) {
+	*o.calls = append(*o.calls, "progress")
+}
+
+func (o *recordingOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	*o.calls = append(*o.calls, "progress")
+}
+
+func (o *recordingOutput) ReportThroughput(result Result) {
+	*o.calls = append(*o.calls, "throughput")
+}
+
+func (o *recordingOutput) ReportLatency(result Result) {
+	*o.calls = append(*o.calls, "latency")
+}
+
+func (o *recordingOutput) Errorf(format string, a ...interface{}) {
+	*o.calls = append(*o.calls, "error: "+fmt.Sprintf(format, a...))
+}
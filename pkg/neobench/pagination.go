@@ -0,0 +1,71 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Pages through articles newest first, like the list endpoints of a typical application: within a category, and
+// across all of them. Early pages are far more popular than later ones, but every so often someone pages deep,
+// and it is the deep pages that make SKIP expensive. Set the page size with -D pageSize=...
+var Pagination = []BuiltinQuery{
+	{Name: "category-page", Weight: 1, Readonly: true, Script: `
+\set category random(1, 100)
+\set page random_exponential(0, int(1000 * $scale / $pageSize) - 1, 3.0)
+\set skip $page * $pageSize
+MATCH (article:Article {category: $category})
+RETURN article.id, article.title, article.published
+ORDER BY article.published DESC
+SKIP $skip LIMIT $pageSize;
+`},
+	{Name: "latest-page", Weight: 1, Readonly: true, Script: `
+\set page random_exponential(0, int(100000 * $scale / $pageSize) - 1, 3.0)
+\set skip $page * $pageSize
+MATCH (article:Article)
+RETURN article.id, article.title, article.published
+ORDER BY article.published DESC
+SKIP $skip LIMIT $pageSize;
+`},
+}
+
+// Page size for builtin:pagination unless -D pageSize is given
+const DefaultPageSize = 25
+
+// Creates 100000 * scale articles spread over 100 categories, with indexes for the category lookup and the sort
+func InitPagination(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	numArticles := 100000 * scale
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	err := createSchema(session, opts, out,
+		"CREATE CONSTRAINT ON (a:Article) ASSERT a.id IS UNIQUE",
+		"CREATE INDEX ON :Article(category)",
+		"CREATE INDEX ON :Article(published)",
+	)
+	if err != nil {
+		return err
+	}
+
+	cp, err := startCheckpoint(session, "pagination", out)
+	if err != nil {
+		return err
+	}
+	existingArticleNum, err := cp.existingIds(session, "Article", "id")
+	if err != nil {
+		return err
+	}
+
+	err = loadBatches(driver, dbName, opts, cp, "create articles", existingArticleNum+1, numArticles, `UNWIND range($start, $end) AS articleId
+CREATE (article:Article {id: articleId, category: articleId % 100 + 1, title: "Article " + articleId,
+  published: timestamp() - toInteger(rand() * 100000000000)})
+SET article += coalesce($content[articleId - $start], {})
+`, map[string]interface{}{
+		"content": opts.Content.articles(),
+	}, out)
+	if err != nil {
+		return err
+	}
+	return cp.finish(session)
+}
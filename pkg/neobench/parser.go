@@ -16,24 +16,41 @@ func Parse(filename, script string, weight uint) (Script, error) {
 	s.Filename = filename
 	s.Whitespace ^= 1 << '\n' // don't skip newlines
 
-	c := &context{
+	c := &parseContext{
 		s: s,
 	}
 
 	commands := make([]Command, 0)
+	var initCommands []Command
 
 	for !c.done {
 		tok := c.Peek()
+		var cmd Command
 		if tok == scanner.EOF {
 			break
 		} else if tok == '\\' {
-			commands = append(commands, metaCommand(c))
+			cmd = metaCommand(c)
 		} else if tok == '\n' {
 			c.Next()
 		} else {
-			commands = append(commands, command(c))
+			cmd = command(c)
+		}
+		if cmd == nil {
+			continue
+		}
+		if _, isExpect := cmd.(ExpectCommand); isExpect && !followsStatement(commands) {
+			c.fail(fmt.Errorf("\\expect must directly follow the statement it checks"))
+			continue
+		}
+		if c.inInit {
+			initCommands = append(initCommands, cmd)
+		} else {
+			commands = append(commands, cmd)
 		}
 	}
+	if c.inInit && c.err == nil {
+		c.fail(fmt.Errorf("\\init section is missing its \\end"))
+	}
 
 	if c.err != nil {
 		return Script{}, c.err
@@ -43,24 +60,25 @@ func Parse(filename, script string, weight uint) (Script, error) {
 		Name:     filename,
 		Readonly: false, // TODO
 		Commands: commands,
+		Init:     initCommands,
 		Weight:   weight,
 	}, nil
 }
 
-func metaCommand(c *context) Command {
+func metaCommand(c *parseContext) Command {
 	expect(c, '\\')
 	cmd := ident(c)
 
 	switch cmd {
 	case "set":
 		varName := ident(c)
-		setExpr := expr(c)
+		setExpr := compile(expr(c))
 		return SetCommand{
 			VarName:    varName,
 			Expression: setExpr,
 		}
 	case "sleep":
-		durationBase := expr(c)
+		durationBase := compile(expr(c))
 		unit := time.Second
 		switch c.Peek() {
 		case '\n', scanner.EOF:
@@ -83,13 +101,76 @@ func metaCommand(c *context) Command {
 			Duration: durationBase,
 			Unit:     unit,
 		}
+	case "load_csv", "verify":
+		if !c.inInit {
+			c.fail(fmt.Errorf("\\%s can only be used in an \\init section", cmd))
+			return nil
+		}
+		if cmd == "verify" {
+			return VerifyCommand{Query: command(c).(QueryCommand).Query}
+		}
+		tok, content := c.Next()
+		if tok != scanner.String {
+			c.fail(fmt.Errorf("\\load_csv expects the URL of a CSV file in double quotes, got '%s'", content))
+			return nil
+		}
+		url, err := strconv.Unquote(content)
+		if err != nil {
+			c.fail(err)
+			return nil
+		}
+		return LoadCsvCommand{Url: url, Query: command(c).(QueryCommand).Query}
+	case "expect":
+		if c.inInit {
+			c.fail(fmt.Errorf("\\expect can't be used in an \\init section, use \\verify to check the dataset"))
+			return nil
+		}
+		column := ident(c)
+		if column == "rows" {
+			column = ""
+		}
+		expect(c, '=')
+		if c.Peek() == scanner.String {
+			_, content := c.Next()
+			text, err := strconv.Unquote(content)
+			if err != nil {
+				c.fail(err)
+				return nil
+			}
+			return ExpectCommand{Column: column, Text: &text}
+		}
+		return ExpectCommand{Column: column, Value: compile(expr(c))}
+	case "init":
+		if c.inInit {
+			c.fail(fmt.Errorf("\\init sections can't be nested"))
+		}
+		c.inInit = true
+		return nil
+	case "end":
+		if !c.inInit {
+			c.fail(fmt.Errorf("\\end without a preceding \\init"))
+		}
+		c.inInit = false
+		return nil
 	default:
 		c.fail(fmt.Errorf("unexpected meta command: '%s'", cmd))
 		return nil
 	}
 }
 
-func command(c *context) Command {
+// True if the last of commands is a statement, or an \expect following one
+func followsStatement(commands []Command) bool {
+	if len(commands) == 0 {
+		return false
+	}
+	switch commands[len(commands)-1].(type) {
+	case QueryCommand, ExpectCommand:
+		return true
+	}
+	return false
+}
+
+func command(c *parseContext) Command {
 	originalWhitespace := c.s.Whitespace
 	defer func() {
 		c.s.Whitespace = originalWhitespace
@@ -104,7 +185,7 @@ func command(c *context) Command {
 	}
 }
 
-func ident(c *context) string {
+func ident(c *parseContext) string {
 	tok, content := c.Next()
 	if tok != scanner.Ident {
 		c.fail(fmt.Errorf("expected identifier, got '%s'", scanner.TokenString(tok)))
@@ -112,7 +193,7 @@ func ident(c *context) string {
 	return content
 }
 
-func expr(c *context) Expression {
+func expr(c *parseContext) Expression {
 	lhs := term(c)
 	for {
 		tok := c.Peek()
@@ -142,7 +223,7 @@ func expr(c *context) Expression {
 	}
 }
 
-func term(c *context) Expression {
+func term(c *parseContext) Expression {
 	lhs := factor(c)
 	for {
 		tok := c.Peek()
@@ -172,7 +253,7 @@ func term(c *context) Expression {
 	}
 }
 
-func factor(c *context) Expression {
+func factor(c *parseContext) Expression {
 	tok, content := c.Next()
 	if tok == scanner.Ident {
 		funcName := content
@@ -242,7 +323,7 @@ func factor(c *context) Expression {
 	}
 }
 
-func expect(c *context, expected rune) {
+func expect(c *parseContext, expected rune) {
 	tok, _ := c.Next()
 	if tok != expected {
 		c.fail(fmt.Errorf("expected '%s', got '%s'", scanner.TokenString(expected), scanner.TokenString(tok)))
@@ -274,9 +355,15 @@ var exprKindNames = []string{
 type Expression struct {
 	Kind    ExprKind
 	Payload interface{}
+	// Set for expressions of parsed scripts, see compile; number only for numeric ones
+	eval   evalFunc
+	number numberFunc
 }
 
 func (e Expression) Eval(ctx *ScriptContext) (interface{}, error) {
+	if e.eval != nil {
+		return e.eval(ctx)
+	}
 	switch e.Kind {
 	case intExpr, floatExpr:
 		return e.Payload, nil
@@ -325,19 +412,36 @@ func (f CallExpr) argAsNumber(i int, ctx *ScriptContext) (Number, error) {
 	if len(f.args) <= i {
 		return Number{}, fmt.Errorf("expected at least %d arguments, got %d", i+1, len(f.args))
 	}
-	value, err := f.args[i].Eval(ctx)
+	return evalNumber(ctx, f.args[i])
+}
+
+func evalNumber(ctx *ScriptContext, e Expression) (Number, error) {
+	if e.number != nil {
+		return e.number(ctx)
+	}
+	value, err := e.Eval(ctx)
 	if err != nil {
 		return Number{}, err
 	}
-	switch value.(type) {
+	n, ok := toNumber(value)
+	if !ok {
+		return Number{}, fmt.Errorf("expected int64 or float64, got %s (which is %T)", e.String(), value)
+	}
+	return n, nil
+}
+
+func toNumber(value interface{}) (Number, bool) {
+	switch v := value.(type) {
 	case int64:
-		iVal := value.(int64)
-		return Number{isDouble: false, val: float64(iVal), iVal: iVal}, nil
+		return intNumber(v), true
 	case float64:
-		return Number{isDouble: true, val: value.(float64)}, nil
-	default:
-		return Number{}, fmt.Errorf("expected int64 or float64, got %s (which is %T)", f.args[i].String(), value)
+		return Number{isDouble: true, val: v}, true
 	}
+	return Number{}, false
+}
+
+func intNumber(v int64) Number {
+	return Number{isDouble: false, val: float64(v), iVal: v}
 }
 
 func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
@@ -479,6 +583,31 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 
 		min, max := lb.iVal, ub.iVal
 		return min + ctx.Rand.Int63n(max-min), nil
+	case "random_partitioned", "partition_lb", "partition_ub":
+		lb, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		ub, err := f.argAsNumber(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		if lb.isDouble || ub.isDouble {
+			return nil, fmt.Errorf("interval for %s() must be integers, not doubles, in %s", f.name, f.String())
+		}
+		min, max, err := clientPartition(ctx.Vars, lb.iVal, ub.iVal)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		switch {
+		case f.name == "partition_lb":
+			return min, nil
+		case f.name == "partition_ub":
+			return max, nil
+		case min == max:
+			return min, nil
+		}
+		return min + ctx.Rand.Int63n(max-min), nil
 	case "random_exponential":
 		lb, err := f.argAsNumber(0, ctx)
 		if err != nil {
@@ -527,6 +656,50 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 
 		min, max := lb.iVal, ub.iVal
 		return gaussianRand(ctx.Rand, min, max, param.val)
+	case "random_zipfian":
+		lb, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		ub, err := f.argAsNumber(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		param, err := f.argAsNumber(2, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+
+		if lb.isDouble || ub.isDouble {
+			return nil, fmt.Errorf("interval for random() must be integers, not doubles, in %s", f.String())
+		}
+
+		if lb.iVal == ub.iVal {
+			return lb.iVal, nil
+		}
+
+		min, max := lb.iVal, ub.iVal
+		return zipfianRand(ctx.Rand, min, max, param.val)
+	case "first_name":
+		return firstNames[skewed(ctx.Rand, len(firstNames))], nil
+	case "last_name":
+		return lastNames[skewed(ctx.Rand, len(lastNames))], nil
+	case "name":
+		return firstNames[skewed(ctx.Rand, len(firstNames))] + " " + lastNames[skewed(ctx.Rand, len(lastNames))], nil
+	case "city":
+		return cities[skewed(ctx.Rand, len(cities))], nil
+	case "text":
+		medianWords, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return contentText(ctx.Rand, medianWords.val, 1, 1000), nil
+	case "date":
+		years, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return contentDate(ctx.Rand, int(years.val)), nil
 	case "*":
 		a, err := f.argAsNumber(0, ctx)
 		if err != nil {
@@ -584,7 +757,23 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 			return a.iVal - b.iVal, nil
 		}
 	default:
-		return nil, fmt.Errorf("unknown function: %s", f.String())
+		custom, found := lookupFunction(f.name)
+		if !found {
+			return nil, fmt.Errorf("unknown function: %s", f.String())
+		}
+		args := make([]interface{}, len(f.args))
+		for i, arg := range f.args {
+			value, err := arg.Eval(ctx)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = value
+		}
+		value, err := custom(ctx, args)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return value, nil
 	}
 }
 
@@ -643,6 +832,26 @@ func gaussianRand(random *rand.Rand, min, max int64, parameter float64) (int64,
 	return min + int64(float64(max-min+1)*randVal), nil
 }
 
+// The part of min to max, as for random(), that belongs to the client the vars are of, going by $client_id and
+// $client_count; outside of a client, like in \init sections, that's all of it
+func clientPartition(vars map[string]interface{}, min, max int64) (int64, int64, error) {
+	id, _ := vars[clientIdVar].(int64)
+	count, _ := vars[clientCountVar].(int64)
+	if count < 1 {
+		return min, max, nil
+	}
+	if id < 0 || id >= count {
+		return 0, 0, fmt.Errorf("$client_id %d is out of range for $client_count %d", id, count)
+	}
+	size := max - min
+	if size < count {
+		return 0, 0, fmt.Errorf("can't split %d to %d across %d clients, each needs at least one value", min, max, count)
+	}
+	// Split so the parts differ by at most one, without overflowing for large ranges
+	part, rest := size/count, size%count
+	return min + part*id + rest*id/count, min + part*(id+1) + rest*(id+1)/count, nil
+}
+
 /* translated from pgbench.c */
 func exponentialRand(random *rand.Rand, min, max int64, parameter float64) (int64, error) {
 	/* abort if wrong parameter, but must really be checked beforehand */
@@ -664,6 +873,33 @@ func exponentialRand(random *rand.Rand, min, max int64, parameter float64) (int6
 	return min + int64(float64(max-min+1)*randVal), nil
 }
 
+/* translated from pgbench.c, which draws with the rejection method of Devroye, "Non-Uniform Random Variate
+ * Generation", p. 550 */
+func zipfianRand(random *rand.Rand, min, max int64, parameter float64) (int64, error) {
+	/* abort if wrong parameter, but must really be checked beforehand */
+	if parameter <= 1.0 {
+		return 0, fmt.Errorf("parameter argument to random_zipfian needs to be > 1")
+	}
+	/* the rejection loop below never ends for an empty range */
+	if max < min {
+		return 0, fmt.Errorf("empty range given to random_zipfian: %d to %d", min, max)
+	}
+	n := float64(max - min + 1)
+	b := math.Pow(2.0, parameter-1.0)
+	for {
+		/* random variates */
+		u := 1.0 - random.Float64()
+		v := random.Float64()
+
+		x := math.Floor(math.Pow(u, -1.0/(parameter-1.0)))
+		t := math.Pow(1.0+1.0/x, parameter-1.0)
+		/* reject if too large or out of bound */
+		if v*x*(t-1.0)/(b-1.0) <= t/b && x <= n {
+			return min + int64(x) - 1, nil
+		}
+	}
+}
+
 // Hacky first stab at dealing with runtime coercion, refactor as needed
 type Number struct {
 	isDouble bool
@@ -673,16 +909,18 @@ type Number struct {
 	iVal int64
 }
 
-type context struct {
+type parseContext struct {
 	s scanner.Scanner
 	// Next token returned by scanner, or 0
 	peek     rune
 	peekText string
 	done     bool
 	err      error
+	// True between \init and \end, where commands go to the scripts init section
+	inInit bool
 }
 
-func (t *context) Peek() rune {
+func (t *parseContext) Peek() rune {
 	if t.peek == 0 {
 		t.peek = t.s.Scan()
 		t.peekText = t.s.TokenText()
@@ -690,7 +928,7 @@ func (t *context) Peek() rune {
 	return t.peek
 }
 
-func (t *context) Next() (rune, string) {
+func (t *parseContext) Next() (rune, string) {
 	if t.peek != 0 {
 		next := t.peek
 		nextStr := t.peekText
@@ -707,7 +945,7 @@ func (t *context) Next() (rune, string) {
 	return next, t.s.TokenText()
 }
 
-func (t *context) fail(err error) {
+func (t *parseContext) fail(err error) {
 	t.done = true
 	if t.err != nil {
 		return
@@ -165,6 +165,7 @@ func TestExpressions(t *testing.T) {
 		"random(1, 5)":                   int64(3),
 		"random_gaussian(1, 10, 2.5)":    int64(3),
 		"random_exponential(1, 10, 2.5)": int64(4),
+		"random_zipfian(1, 10, 1.5)":     int64(7),
 		"sqrt(2.0)":                      1.414213562,
 	}
 
@@ -194,6 +195,18 @@ RETURN 1;`, expr), 1)
 	}
 }
 
+func TestZipfianFailsOnEmptyRange(t *testing.T) {
+	script, err := Parse("test", "\\set v random_zipfian(10, 1, 1.5)\nRETURN $v;", 1)
+	assert.NoError(t, err)
+
+	_, err = script.Eval(ScriptContext{
+		Vars: map[string]interface{}{"scale": int64(1)},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+
+	assert.EqualError(t, err, "empty range given to random_zipfian: 10 to 1")
+}
+
 func TestDebugFunction(t *testing.T) {
 	vars := map[string]interface{}{"scale": int64(1)}
 	script, err := Parse("test:debug(..)", "\\set blah debug(1337) * 10\nRETURN 1;", 1)
@@ -213,3 +226,254 @@ func TestDebugFunction(t *testing.T) {
 	assert.Equal(t, int64(13370), uow.Statements[0].Params["blah"])
 	assert.Equal(t, "1337\n", stderr.String())
 }
+
+func TestParseInsertHeavy(t *testing.T) {
+	script, err := Parse("builtin:insert-heavy", InsertHeavy, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{"scale": int64(3), "batch": int64(50)},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 1)
+	assert.Equal(t, int64(3000), uow.Statements[0].Params["deviceCount"])
+	assert.Equal(t, int64(50), uow.Statements[0].Params["batch"])
+}
+
+func TestParseSupernode(t *testing.T) {
+	script, err := Parse("builtin:supernode", Supernode, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{"scale": int64(1), "hot": 0.5, "hotspots": int64(5)},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 2)
+	params := uow.Statements[0].Params
+	assert.True(t, params["hotId"].(int64) >= 1 && params["hotId"].(int64) <= 5)
+	assert.True(t, params["coldId"].(int64) > 5 && params["coldId"].(int64) <= 100000)
+}
+
+func TestParseGds(t *testing.T) {
+	for _, query := range Gds {
+		script, err := Parse("builtin:gds/"+query.Name, query.Script, query.Weight)
+		assert.NoError(t, err, query.Name)
+
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{"scale": int64(1)},
+			Rand: rand.New(rand.NewSource(1337)),
+		})
+		assert.NoError(t, err, query.Name)
+		assert.Len(t, uow.Statements, 1, query.Name)
+		assert.Contains(t, uow.Statements[0].Query, `"`+GdsGraphName+`"`, query.Name)
+	}
+}
+
+func TestParseFulltext(t *testing.T) {
+	script, err := Parse("builtin:fulltext", Fulltext, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{"scale": int64(1)},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 1)
+	term := uow.Statements[0].Params["term"].(int64)
+	assert.True(t, term >= 1 && term <= 10000)
+}
+
+func TestParseVector(t *testing.T) {
+	script, err := Parse("builtin:vector", Vector, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{"scale": int64(2)},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 1)
+	assert.Contains(t, uow.Statements[0].Query, `db.index.vector.queryNodes("`+VectorIndexName+`", 10, queryVector)`)
+	chunkId := uow.Statements[0].Params["chunkId"].(int64)
+	assert.True(t, chunkId >= 1 && chunkId <= 20000)
+}
+
+func TestParseMerge(t *testing.T) {
+	script, err := Parse("builtin:merge", Merge, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{"scale": int64(1), "collision": 0.1},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 1)
+	params := uow.Statements[0].Params
+	assert.True(t, params["shared1"].(int64) >= 1 && params["shared1"].(int64) <= 100)
+	assert.True(t, params["fresh1"].(int64) > 100)
+}
+
+func TestParsePagination(t *testing.T) {
+	for _, query := range Pagination {
+		script, err := Parse("builtin:pagination/"+query.Name, query.Script, query.Weight)
+		assert.NoError(t, err, query.Name)
+
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{"scale": int64(1), "pageSize": int64(25)},
+			Rand: rand.New(rand.NewSource(1337)),
+		})
+		assert.NoError(t, err, query.Name)
+		skip := uow.Statements[0].Params["skip"].(int64)
+		assert.Equal(t, int64(0), skip%25, query.Name)
+		assert.True(t, skip >= 0 && skip < 100000, query.Name)
+	}
+}
+
+func TestParseAggregation(t *testing.T) {
+	for _, query := range Aggregation {
+		script, err := Parse("builtin:aggregation/"+query.Name, query.Script, query.Weight)
+		assert.NoError(t, err, query.Name)
+		assert.Len(t, script.Commands, 1, query.Name)
+	}
+}
+
+func TestParseInitSection(t *testing.T) {
+	script, err := Parse("test", `\init
+CREATE CONSTRAINT ON (p:Person) ASSERT p.id IS UNIQUE;
+\set n 1000 * $scale
+UNWIND range(1, $n) AS id CREATE (:Person {id: id});
+\end
+\set id random(1, 1000 * $scale)
+MATCH (p:Person {id: $id}) RETURN p;
+`, 1)
+	assert.NoError(t, err)
+	assert.Len(t, script.Init, 3)
+	assert.Len(t, script.Commands, 2)
+
+	_, err = Parse("test", "\\init\nRETURN 1;\n", 1)
+	assert.EqualError(t, err, "\\init section is missing its \\end (at test:3:1)")
+	_, err = Parse("test", "\\end\nRETURN 1;\n", 1)
+	assert.Error(t, err)
+	_, err = Parse("test", "\\init\n\\init\nRETURN 1;\n\\end\n", 1)
+	assert.Error(t, err)
+}
+
+func TestParseLoadCsvAndVerify(t *testing.T) {
+	script, err := Parse("test", `\init
+\load_csv "file:///people.csv"
+CREATE (:Person {id: toInteger(row.id), name: row.name});
+\verify
+MATCH (p:Person) RETURN count(p) >= 1000 * $scale;
+\end
+RETURN 1;
+`, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Command{
+		LoadCsvCommand{Url: "file:///people.csv", Query: "\nCREATE (:Person {id: toInteger(row.id), name: row.name})"},
+		VerifyCommand{Query: "\nMATCH (p:Person) RETURN count(p) >= 1000 * $scale"},
+	}, script.Init)
+
+	_, err = Parse("test", "\\verify\nRETURN true;\n", 1)
+	assert.EqualError(t, err, "\\verify can only be used in an \\init section (at test:1:8)")
+	_, err = Parse("test", "\\init\n\\load_csv people.csv\nRETURN 1;\n\\end\n", 1)
+	assert.EqualError(t, err, "\\load_csv expects the URL of a CSV file in double quotes, got 'people' (at test:2:17)")
+}
+
+func TestParseExpect(t *testing.T) {
+	script, err := Parse("test", `\set aid random(1, 10)
+MATCH (a:Account {aid: $aid}) RETURN a.aid AS aid, a.name AS name;
+\expect rows = 1
+\expect aid = $aid
+\expect name = "Alice"
+`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	aid := uow.Statements[0].Params["aid"]
+	assert.Equal(t, []Expectation{{Column: "", Value: int64(1)}, {Column: "aid", Value: aid}, {Column: "name", Value: "Alice"}}, uow.Statements[0].Expect)
+
+	_, err = Parse("test", "\\expect rows = 1\nRETURN 1;\n", 1)
+	assert.EqualError(t, err, "\\expect must directly follow the statement it checks (at test:2:1)")
+	_, err = Parse("test", "RETURN 1;\n\\set n 1\n\\expect rows = $n\n", 1)
+	assert.Error(t, err)
+}
+
+func TestCompiledExpressionsEvaluateLikeTheSyntaxTree(t *testing.T) {
+	for _, text := range []string{
+		"1 + 2 * 3 - 4 / 8", "$a * 2 + $b", "$a / 0", "greatest($a, 3 * 4, $b) - least(1, 2)", "abs($b) + int(2.7)",
+		"random(1, 100) + random_zipfian(1, 10, 1.5) * $a", "$missing + 1", "random(1.5, 3)", "unknown_function(1)",
+	} {
+		script, err := Parse("compiled", fmt.Sprintf("\\set v %s\nRETURN $v;", text), 1)
+		assert.NoError(t, err, text)
+		compiled := script.Commands[0].(SetCommand).Expression
+		tree := interpreted(compiled)
+
+		vars := map[string]interface{}{"a": int64(7), "b": -2.5}
+		compiledValue, compiledErr := compiled.Eval(&ScriptContext{Vars: vars, Rand: rand.New(rand.NewSource(1337))})
+		treeValue, treeErr := tree.Eval(&ScriptContext{Vars: vars, Rand: rand.New(rand.NewSource(1337))})
+		assert.Equal(t, treeValue, compiledValue, text)
+		assert.Equal(t, treeErr, compiledErr, text)
+	}
+}
+
+func TestConstantExpressionsAreFolded(t *testing.T) {
+	script, err := Parse("folded", "\\set v 1 + 2 * (3 - 1)\n\\set w random(1, 10 * 10)\nRETURN $v;", 1)
+	assert.NoError(t, err)
+	v := script.Commands[0].(SetCommand).Expression
+	assert.Equal(t, intExpr, v.Kind)
+	assert.Equal(t, int64(5), v.Payload)
+	w := script.Commands[1].(SetCommand).Expression.Payload.(CallExpr)
+	assert.Equal(t, "random", w.name)
+	assert.Equal(t, int64(100), w.args[1].Payload)
+}
+
+func BenchmarkExpressions(b *testing.B) {
+	script, err := Parse("expressions", `\set aid random(1, 100000 * $scale)
+\set tid random(1, 10 * $scale)
+\set delta random(-5000, 5000)
+\set x greatest($aid, $tid) * 2 + abs($delta) / 3 - least(5, $scale)`, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	expressions := make([]Expression, 0, len(script.Commands))
+	for _, cmd := range script.Commands {
+		expressions = append(expressions, cmd.(SetCommand).Expression)
+	}
+	run := func(b *testing.B, expressions []Expression) {
+		ctx := &ScriptContext{Vars: map[string]interface{}{"scale": int64(10)}, Rand: rand.New(rand.NewSource(1))}
+		for i := 0; i < b.N; i++ {
+			for j, e := range expressions {
+				value, err := e.Eval(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				ctx.Vars[script.Commands[j].(SetCommand).VarName] = value
+			}
+		}
+	}
+	b.Run("compiled", func(b *testing.B) {
+		run(b, expressions)
+	})
+	b.Run("tree", func(b *testing.B) {
+		tree := make([]Expression, len(expressions))
+		for i, e := range expressions {
+			tree[i] = interpreted(e)
+		}
+		run(b, tree)
+	})
+}
+
+// e without its compiled closures, evaluated by walking the syntax tree
+func interpreted(e Expression) Expression {
+	e.eval, e.number = nil, nil
+	if call, ok := e.Payload.(CallExpr); ok {
+		args := make([]Expression, len(call.args))
+		for i, arg := range call.args {
+			args[i] = interpreted(arg)
+		}
+		e.Payload = CallExpr{name: call.name, args: args}
+	}
+	return e
+}
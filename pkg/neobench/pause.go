@@ -0,0 +1,95 @@
+package neobench
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Lets a running benchmark be paused and resumed from another goroutine. While paused, workers
+// don't start new transactions, but keep their sessions and connections open.
+type PauseControl struct {
+	mut sync.Mutex
+	// nil when running; when paused, this is closed on resume
+	resumeCh chan struct{}
+	// Called with the new state each time it changes
+	onChange func(paused bool)
+}
+
+func NewPauseControl(onChange func(paused bool)) *PauseControl {
+	return &PauseControl{onChange: onChange}
+}
+
+func (p *PauseControl) Pause() {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.resumeCh != nil {
+		return
+	}
+	p.resumeCh = make(chan struct{})
+	p.notify(true)
+}
+
+func (p *PauseControl) Resume() {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.resumeCh == nil {
+		return
+	}
+	close(p.resumeCh)
+	p.resumeCh = nil
+	p.notify(false)
+}
+
+func (p *PauseControl) Toggle() {
+	if p.Paused() {
+		p.Resume()
+	} else {
+		p.Pause()
+	}
+}
+
+func (p *PauseControl) Paused() bool {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	return p.resumeCh != nil
+}
+
+// Blocks while paused. Returns false if stopCh was closed while waiting.
+func (p *PauseControl) Wait(stopCh <-chan struct{}) bool {
+	p.mut.Lock()
+	resumeCh := p.resumeCh
+	p.mut.Unlock()
+	if resumeCh == nil {
+		return true
+	}
+	select {
+	case <-resumeCh:
+		return true
+	case <-stopCh:
+		return false
+	}
+}
+
+func (p *PauseControl) notify(paused bool) {
+	if p.onChange != nil {
+		p.onChange(paused)
+	}
+}
+
+// Reads control commands, one per line, from r until it is exhausted: "pause" or "p" pauses,
+// "resume" or "r" resumes, and an empty line toggles.
+func WatchControlInput(r io.Reader, pause *PauseControl) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "pause", "p":
+			pause.Pause()
+		case "resume", "r":
+			pause.Resume()
+		case "":
+			pause.Toggle()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/log"
+	"strings"
+	"sync/atomic"
+)
+
+// Connection pool events, either since the start of a run or within one interval
+type PoolStats struct {
+	// Connections opened, including the ones used to fetch routing tables
+	ConnectionsCreated int64
+	// Connections dropped from the pool because they had died or outlived --max-connection-lifetime
+	ConnectionsClosed int64
+	// Times a transaction found the pool exhausted and queued for a connection
+	Exhausted int64
+	// Times a transaction gave up waiting for a connection, see --acquisition-timeout
+	AcquireTimeouts int64
+}
+
+func (s PoolStats) Add(other PoolStats) PoolStats {
+	return PoolStats{
+		ConnectionsCreated: s.ConnectionsCreated + other.ConnectionsCreated,
+		ConnectionsClosed:  s.ConnectionsClosed + other.ConnectionsClosed,
+		Exhausted:          s.Exhausted + other.Exhausted,
+		AcquireTimeouts:    s.AcquireTimeouts + other.AcquireTimeouts,
+	}
+}
+
+func (s PoolStats) Sub(earlier PoolStats) PoolStats {
+	return PoolStats{
+		ConnectionsCreated: s.ConnectionsCreated - earlier.ConnectionsCreated,
+		ConnectionsClosed:  s.ConnectionsClosed - earlier.ConnectionsClosed,
+		Exhausted:          s.Exhausted - earlier.Exhausted,
+		AcquireTimeouts:    s.AcquireTimeouts - earlier.AcquireTimeouts,
+	}
+}
+
+// Counts pool events by watching the drivers log, since the driver has no metrics API of its own. Pass it as the
+// drivers logger; everything is still passed on to inner, which may be nil.
+type PoolMetrics struct {
+	inner log.Logger
+	stats PoolStats
+	// func() called when the driver invalidates a routing table, see OnRoutingChange
+	routingChange atomic.Value
+}
+
+func NewPoolMetrics(inner log.Logger) *PoolMetrics {
+	return &PoolMetrics{inner: inner}
+}
+
+// Totals since the metrics were created; subtract an earlier snapshot to get the events in between
+// Zero if m is nil, so runs without pool metrics can report them all the same
+func (m *PoolMetrics) Stats() PoolStats {
+	if m == nil {
+		return PoolStats{}
+	}
+	return PoolStats{
+		ConnectionsCreated: atomic.LoadInt64(&m.stats.ConnectionsCreated),
+		ConnectionsClosed:  atomic.LoadInt64(&m.stats.ConnectionsClosed),
+		Exhausted:          atomic.LoadInt64(&m.stats.Exhausted),
+		AcquireTimeouts:    atomic.LoadInt64(&m.stats.AcquireTimeouts),
+	}
+}
+
+// Calls f whenever the driver throws away a routing table, eg. because the leader it listed stopped being one
+func (m *PoolMetrics) OnRoutingChange(f func()) {
+	m.routingChange.Store(f)
+}
+
+// These are the pool and router messages we count, by the format the driver logs them with
+func (m *PoolMetrics) count(name, message string) {
+	switch {
+	case name == log.Pool && message == "Connecting to %s":
+		atomic.AddInt64(&m.stats.ConnectionsCreated, 1)
+	case name == log.Pool && message == "Failed to connect to %s: %s":
+		// Counted as created when it started connecting
+		atomic.AddInt64(&m.stats.ConnectionsCreated, -1)
+	case name == log.Pool && message == "Unregistering dead or too old connection to %s":
+		atomic.AddInt64(&m.stats.ConnectionsClosed, 1)
+	case name == log.Pool && message == "Borrow queued":
+		atomic.AddInt64(&m.stats.Exhausted, 1)
+	case name == log.Pool && message == "Borrow time-out":
+		atomic.AddInt64(&m.stats.AcquireTimeouts, 1)
+	case name == log.Router && strings.HasPrefix(message, "Invalidating routing table"):
+		if f, ok := m.routingChange.Load().(func()); ok {
+			f()
+		}
+	}
+}
+
+func (m *PoolMetrics) Error(name string, id string, err error) {
+	if m.inner != nil {
+		m.inner.Error(name, id, err)
+	}
+}
+
+func (m *PoolMetrics) Warnf(name string, id string, message string, args ...interface{}) {
+	m.count(name, message)
+	if m.inner != nil {
+		m.inner.Warnf(name, id, message, args...)
+	}
+}
+
+func (m *PoolMetrics) Infof(name string, id string, message string, args ...interface{}) {
+	m.count(name, message)
+	if m.inner != nil {
+		m.inner.Infof(name, id, message, args...)
+	}
+}
+
+func (m *PoolMetrics) Debugf(name string, id string, message string, args ...interface{}) {
+	if m.inner != nil {
+		m.inner.Debugf(name, id, message, args...)
+	}
+}
@@ -0,0 +1,44 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/log"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPoolMetricsCountsDriverLogEvents(t *testing.T) {
+	metrics := NewPoolMetrics(nil)
+	// As the driver logs them, by component and format string
+	metrics.Infof(log.Pool, "1", "Connecting to %s", "localhost:7687")
+	metrics.Infof(log.Pool, "1", "Connecting to %s", "localhost:7687")
+	metrics.Warnf(log.Pool, "1", "Borrow queued")
+	metrics.Warnf(log.Pool, "1", "Borrow time-out")
+	metrics.Infof(log.Pool, "1", "Unregistering dead or too old connection to %s", "localhost:7687")
+	metrics.Infof(log.Pool, "1", "Created")
+
+	before := PoolStats{ConnectionsCreated: 1}
+	assert.Equal(t, PoolStats{
+		ConnectionsCreated: 1,
+		ConnectionsClosed:  1,
+		Exhausted:          1,
+		AcquireTimeouts:    1,
+	}, metrics.Stats().Sub(before))
+}
+
+func TestPoolMetricsDoesNotCountFailedConnectionsAsCreated(t *testing.T) {
+	metrics := NewPoolMetrics(nil)
+	metrics.Infof(log.Pool, "1", "Connecting to %s", "localhost:7687")
+	metrics.Warnf(log.Pool, "1", "Failed to connect to %s: %s", "localhost:7687", "refused")
+
+	assert.Equal(t, PoolStats{}, metrics.Stats())
+}
+
+func TestPoolMetricsReportsRoutingTableInvalidation(t *testing.T) {
+	metrics := NewPoolMetrics(nil)
+	changes := 0
+	metrics.OnRoutingChange(func() { changes++ })
+	metrics.Infof(log.Router, "1", "Invalidating routing table for '%s'", "neo4j")
+	metrics.Infof(log.Router, "1", "Reading routing table from initial router: %s", "localhost:7687")
+
+	assert.Equal(t, 1, changes)
+}
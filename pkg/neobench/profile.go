@@ -0,0 +1,196 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// What PROFILE told us about the sampled runs of a statement, see Worker.ProfileSampleRate
+type StatementProfile struct {
+	Samples int64
+	// Db hits summed over all operators of the plan, and rows the statement returned, per sampled run
+	DbHits *hdrhistogram.Histogram
+	Rows   *hdrhistogram.Histogram
+	// Plans the statement ran with, by digest; more than one means the planner changed its mind during the run
+	Plans map[string]*PlanSample
+}
+
+type PlanSample struct {
+	// Operator tree, eg. ProduceResults(Filter(NodeIndexSeek))
+	Operators string
+	Count     int64
+}
+
+// One profiled run of a statement
+type profileSample struct {
+	dbHits    int64
+	rows      int64
+	operators string
+}
+
+func newStatementProfile() *StatementProfile {
+	return &StatementProfile{
+		DbHits: hdrhistogram.New(0, 1000*1000*1000*1000, 2),
+		Rows:   hdrhistogram.New(0, 1000*1000*1000*1000, 2),
+		Plans:  make(map[string]*PlanSample),
+	}
+}
+
+func (p *StatementProfile) record(sample profileSample) error {
+	if err := p.DbHits.RecordValue(sample.dbHits); err != nil {
+		return fmt.Errorf("failed to record db hits: %d: %s", sample.dbHits, err)
+	}
+	if err := p.Rows.RecordValue(sample.rows); err != nil {
+		return fmt.Errorf("failed to record rows: %d: %s", sample.rows, err)
+	}
+	p.Samples++
+	p.plan(planDigest(sample.operators), sample.operators).Count++
+	return nil
+}
+
+func (p *StatementProfile) plan(digest, operators string) *PlanSample {
+	plan, found := p.Plans[digest]
+	if !found {
+		plan = &PlanSample{Operators: operators}
+		p.Plans[digest] = plan
+	}
+	return plan
+}
+
+func (p *StatementProfile) merge(other *StatementProfile) {
+	p.Samples += other.Samples
+	p.DbHits.Merge(other.DbHits)
+	p.Rows.Merge(other.Rows)
+	for digest, plan := range other.Plans {
+		p.plan(digest, plan.Operators).Count += plan.Count
+	}
+}
+
+func (p *StatementProfile) copy() *StatementProfile {
+	out := &StatementProfile{
+		Samples: p.Samples,
+		DbHits:  hdrhistogram.Import(p.DbHits.Export()),
+		Rows:    hdrhistogram.Import(p.Rows.Export()),
+		Plans:   make(map[string]*PlanSample, len(p.Plans)),
+	}
+	for digest, plan := range p.Plans {
+		out.Plans[digest] = &PlanSample{Operators: plan.Operators, Count: plan.Count}
+	}
+	return out
+}
+
+// Digests of the plans seen, most used first
+func (p *StatementProfile) SortedPlans() []string {
+	digests := make([]string, 0, len(p.Plans))
+	for digest := range p.Plans {
+		digests = append(digests, digest)
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		a, b := p.Plans[digests[i]], p.Plans[digests[j]]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return digests[i] < digests[j]
+	})
+	return digests
+}
+
+// Prefixes query with PROFILE, unless it already asks for a plan
+func profileQuery(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) > 0 {
+		switch strings.ToUpper(fields[0]) {
+		case "PROFILE", "EXPLAIN":
+			return query
+		}
+	}
+	return "PROFILE " + query
+}
+
+// Summarizes the plan of a profiled statement; nil if the server didn't return one, eg. for EXPLAIN
+func newProfileSample(plan neo4j.ProfiledPlan) *profileSample {
+	if plan == nil {
+		return nil
+	}
+	return &profileSample{
+		dbHits:    totalDbHits(plan),
+		rows:      plan.Records(),
+		operators: planOperators(plan),
+	}
+}
+
+func totalDbHits(plan neo4j.ProfiledPlan) int64 {
+	hits := plan.DbHits()
+	for _, child := range plan.Children() {
+		hits += totalDbHits(child)
+	}
+	return hits
+}
+
+// Writes the operator tree of plan, leaving out the database name 4.0 servers append to each operator
+func planOperators(plan neo4j.ProfiledPlan) string {
+	operator := plan.Operator()
+	if at := strings.Index(operator, "@"); at >= 0 {
+		operator = operator[:at]
+	}
+	children := plan.Children()
+	if len(children) == 0 {
+		return operator
+	}
+	operands := make([]string, len(children))
+	for i, child := range children {
+		operands[i] = planOperators(child)
+	}
+	return fmt.Sprintf("%s(%s)", operator, strings.Join(operands, ", "))
+}
+
+// Short stable name for an operator tree, so plans can be compared across runs and reports
+func planDigest(operators string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(operators))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+func writeProfileReport(result Result, s *strings.Builder) {
+	for _, script := range result.SortedScripts() {
+		profiled := false
+		for _, statement := range script.Statements {
+			profiled = profiled || (statement != nil && statement.Profile != nil)
+		}
+		if !profiled {
+			continue
+		}
+		s.WriteString(fmt.Sprintf("-- Profile: %s --\n\n", script.ScriptName))
+		w := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "  #\tSamples\tDb hits P50\tP99\tMax\tRows P50\tMax\tPlans\n")
+		var plans []string
+		for i, statement := range script.Statements {
+			if statement == nil || statement.Profile == nil {
+				continue
+			}
+			p := statement.Profile
+			var digests []string
+			for _, digest := range p.SortedPlans() {
+				digests = append(digests, fmt.Sprintf("%s (%d)", digest, p.Plans[digest].Count))
+				plans = append(plans, fmt.Sprintf("  %s  %s\n", digest, p.Plans[digest].Operators))
+			}
+			_, _ = fmt.Fprintf(w, "  %d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n", i+1, p.Samples,
+				p.DbHits.ValueAtQuantile(50), p.DbHits.ValueAtQuantile(99), p.DbHits.Max(),
+				p.Rows.ValueAtQuantile(50), p.Rows.Max(),
+				strings.Join(digests, ", "))
+		}
+		if err := w.Flush(); err != nil {
+			panic(err)
+		}
+		s.WriteString("\n  Plans:\n")
+		for _, plan := range plans {
+			s.WriteString(plan)
+		}
+		s.WriteString("\n")
+	}
+}
@@ -0,0 +1,62 @@
+package neobench
+
+import (
+	"fmt"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Starts the pprof HTTP endpoint and CPU profiling as requested by the given options. Returns a func that
+// must be called before exiting, which stops CPU profiling and writes the heap profile, if requested.
+func StartProfiling(pprofAddr, cpuProfilePath, memProfilePath string, out *Logger) (stop func(), err error) {
+	if pprofAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(pprofAddr, nil); err != nil {
+				out.Errorf("pprof endpoint failed: %s", err)
+			}
+		}()
+	}
+
+	var cpuProfile *os.File
+	if cpuProfilePath != "" {
+		cpuProfile, err = os.Create(cpuProfilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cpu profile file at %s: %s", cpuProfilePath, err)
+		}
+		if err = pprof.StartCPUProfile(cpuProfile); err != nil {
+			cpuProfile.Close()
+			return nil, fmt.Errorf("failed to start cpu profiling: %s", err)
+		}
+	}
+
+	return func() {
+		if cpuProfile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuProfile.Close(); err != nil {
+				out.Errorf("failed to write cpu profile: %s", err)
+			}
+		}
+		if memProfilePath != "" {
+			if err := writeHeapProfile(memProfilePath); err != nil {
+				out.Errorf("%s", err)
+			}
+		}
+	}, nil
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create heap profile file at %s: %s", path, err)
+	}
+	defer f.Close()
+	// Get up-to-date statistics
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write heap profile: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProfilingWritesProfilesWhenStopped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-profiling")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cpuPath, memPath := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof")
+	logger, err := NewLogger(LogNormal, "text", ioutil.Discard)
+	assert.NoError(t, err)
+
+	stop, err := StartProfiling("", cpuPath, memPath, logger)
+	assert.NoError(t, err)
+	_, err = os.Stat(memPath)
+	assert.True(t, os.IsNotExist(err), "heap profile is only written when profiling stops")
+	stop()
+
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.NotZero(t, info.Size(), path)
+	}
+
+	_, err = StartProfiling("", filepath.Join(dir, "missing", "cpu.pprof"), "", logger)
+	assert.Error(t, err)
+}
+
+func TestProfilingServesPprofEndpoint(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	assert.NoError(t, listener.Close())
+	logger, err := NewLogger(LogNormal, "text", ioutil.Discard)
+	assert.NoError(t, err)
+
+	stop, err := StartProfiling(addr, "", "", logger)
+	assert.NoError(t, err)
+	defer stop()
+
+	// The endpoint starts in the background
+	var response *http.Response
+	for i := 0; i < 50; i++ {
+		if response, err = http.Get("http://" + addr + "/debug/pprof/"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
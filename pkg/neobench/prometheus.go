@@ -0,0 +1,134 @@
+package neobench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds, in seconds, of the latency histogram buckets we expose
+var prometheusLatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Serves live metrics from the given recorders in the Prometheus text exposition format
+func NewPrometheusHandler(databaseName string, recorders []*ResultRecorder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		total := NewResult(databaseName, "")
+		inFlight := 0
+		for _, rec := range recorders {
+			total.Add(rec.Snapshot())
+			if rec.InFlight() {
+				inFlight++
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := WritePrometheusMetrics(w, total, inFlight); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Serves live metrics for the whole of a run, from the recorders of whichever phase it is in; a server per phase
+// would fail to bind the address again for the next one
+type prometheusEndpoint struct {
+	mut          sync.Mutex
+	databaseName string
+	recorders    []*ResultRecorder
+	server       *http.Server
+}
+
+// Listens on addr right away, so a taken address fails the run rather than being found out after it started
+func startPrometheusEndpoint(addr, databaseName string, logger *Logger) (*prometheusEndpoint, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus endpoint failed: %s", err)
+	}
+	e := &prometheusEndpoint{databaseName: databaseName}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	e.server = &http.Server{Handler: mux}
+	go func() {
+		if err := e.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("prometheus endpoint failed: %s", err)
+		}
+	}()
+	return e, nil
+}
+
+func (e *prometheusEndpoint) startPhase(recorders []*ResultRecorder) {
+	if e == nil {
+		return
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.recorders = recorders
+}
+
+func (e *prometheusEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mut.Lock()
+	recorders := e.recorders
+	e.mut.Unlock()
+	NewPrometheusHandler(e.databaseName, recorders).ServeHTTP(w, r)
+}
+
+// Stops serving, waiting up to timeout for scrapes in progress
+func (e *prometheusEndpoint) Close(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return e.server.Shutdown(ctx)
+}
+
+func WritePrometheusMetrics(w io.Writer, result Result, inFlight int) error {
+	s := strings.Builder{}
+
+	scripts := result.SortedScripts()
+
+	s.WriteString("# HELP neobench_transactions_total Transactions that completed successfully.\n")
+	s.WriteString("# TYPE neobench_transactions_total counter\n")
+	for _, script := range scripts {
+		s.WriteString(fmt.Sprintf("neobench_transactions_total{%s} %d\n", promLabels(result, script), script.Succeeded))
+	}
+
+	s.WriteString("# HELP neobench_transaction_failures_total Transactions that failed.\n")
+	s.WriteString("# TYPE neobench_transaction_failures_total counter\n")
+	for _, script := range scripts {
+		s.WriteString(fmt.Sprintf("neobench_transaction_failures_total{%s} %d\n", promLabels(result, script), script.Failed))
+	}
+
+	s.WriteString("# HELP neobench_transaction_latency_seconds Latency of successful transactions.\n")
+	s.WriteString("# TYPE neobench_transaction_latency_seconds histogram\n")
+	for _, script := range scripts {
+		labels := promLabels(result, script)
+		bars := script.Latencies.Distribution()
+		for _, bound := range prometheusLatencyBuckets {
+			boundMicros := int64(bound * 1000000)
+			cumulative := int64(0)
+			for _, bar := range bars {
+				if bar.To <= boundMicros {
+					cumulative += bar.Count
+				}
+			}
+			s.WriteString(fmt.Sprintf("neobench_transaction_latency_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bound, cumulative))
+		}
+		count := script.Latencies.TotalCount()
+		s.WriteString(fmt.Sprintf("neobench_transaction_latency_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, count))
+		s.WriteString(fmt.Sprintf("neobench_transaction_latency_seconds_sum{%s} %f\n", labels, script.Latencies.Mean()*float64(count)/1000000.0))
+		s.WriteString(fmt.Sprintf("neobench_transaction_latency_seconds_count{%s} %d\n", labels, count))
+	}
+
+	s.WriteString("# HELP neobench_transactions_in_flight Workers currently executing a transaction.\n")
+	s.WriteString("# TYPE neobench_transactions_in_flight gauge\n")
+	s.WriteString(fmt.Sprintf("neobench_transactions_in_flight %d\n", inFlight))
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+func promLabels(result Result, script *ScriptResult) string {
+	escape := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+	return fmt.Sprintf("db=\"%s\",script=\"%s\"", escape.Replace(result.DatabaseName), escape.Replace(script.ScriptName))
+}
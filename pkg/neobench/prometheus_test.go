@@ -0,0 +1,56 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusHandlerServesRecordedInterval(t *testing.T) {
+	recorder := NewResultRecorder(0)
+	assert.NoError(t, recorder.record("pay", 2*time.Millisecond, uowOutcome{succeeded: true, failedStatement: -1}))
+	assert.NoError(t, recorder.record("pay", 20*time.Millisecond, uowOutcome{succeeded: true, failedStatement: -1}))
+	assert.NoError(t, recorder.record("pay", time.Millisecond, uowOutcome{failureGroup: "Timeout", failedStatement: 0}))
+	recorder.ProgressReport(time.Now())
+	recorder.begin()
+
+	body := scrape(NewPrometheusHandler("neo4j", []*ResultRecorder{recorder}))
+
+	for _, line := range []string{
+		`neobench_transactions_total{db="neo4j",script="pay"} 2`,
+		`neobench_transaction_failures_total{db="neo4j",script="pay"} 1`,
+		`neobench_transaction_latency_seconds_bucket{db="neo4j",script="pay",le="0.001"} 0`,
+		`neobench_transaction_latency_seconds_bucket{db="neo4j",script="pay",le="0.0025"} 1`,
+		`neobench_transaction_latency_seconds_bucket{db="neo4j",script="pay",le="0.025"} 2`,
+		`neobench_transaction_latency_seconds_bucket{db="neo4j",script="pay",le="+Inf"} 2`,
+		`neobench_transaction_latency_seconds_count{db="neo4j",script="pay"} 2`,
+		`neobench_transactions_in_flight 1`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+	assert.Contains(t, body, "# TYPE neobench_transaction_latency_seconds histogram\n")
+}
+
+func TestPrometheusEndpointServesTheCurrentPhase(t *testing.T) {
+	endpoint := &prometheusEndpoint{databaseName: "neo4j"}
+	first, second := NewResultRecorder(0), NewResultRecorder(0)
+	assert.NoError(t, first.record("warmup", time.Millisecond, uowOutcome{succeeded: true, failedStatement: -1}))
+	assert.NoError(t, second.record("steady", time.Millisecond, uowOutcome{succeeded: true, failedStatement: -1}))
+
+	endpoint.startPhase([]*ResultRecorder{first})
+	assert.Contains(t, scrape(endpoint), `script="warmup"`)
+
+	endpoint.startPhase([]*ResultRecorder{second})
+	body := scrape(endpoint)
+	assert.Contains(t, body, `neobench_transactions_total{db="neo4j",script="steady"} 1`)
+	assert.NotContains(t, body, `script="warmup"`)
+}
+
+func scrape(handler http.Handler) string {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return strings.TrimSpace(rec.Body.String()) + "\n"
+}
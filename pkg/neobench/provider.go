@@ -0,0 +1,136 @@
+package neobench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Units of work supplied by another process, so workload generators written in other languages can use the
+// scheduling, measurement and reporting of neobench. For each unit of work, the process gets a line on stdin,
+// {"seq": <n>}, counting requests from 0, and answers with a line of its own:
+//
+//	{"script": "checkout", "readonly": false, "statements": [{"query": "...", "params": {...}}]}
+//
+// script names the unit of work in the results, defaulting to the name of the workload; an answer of
+// {"error": "..."} stops the run. Requests go one at a time, so the process must answer faster than the rate
+// of the benchmark, or it becomes what is measured.
+type ExternalWorkload struct {
+	Command string
+
+	mut    sync.Mutex
+	closer func() error
+	in     io.WriteCloser
+	out    *bufio.Reader
+	served int64
+}
+
+type externalWorkRequest struct {
+	Seq int64 `json:"seq"`
+}
+
+type externalWork struct {
+	Script     string `json:"script"`
+	Readonly   bool   `json:"readonly"`
+	Statements []struct {
+		Query  string                 `json:"query"`
+		Params map[string]interface{} `json:"params"`
+	} `json:"statements"`
+	Error string `json:"error"`
+}
+
+func NewExternalWorkload(command string) *ExternalWorkload {
+	return &ExternalWorkload{Command: command}
+}
+
+// Starts the process; its stderr goes to ours
+func (p *ExternalWorkload) Start() error {
+	cmd := shellCommand(p.Command)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start workload %s: %s", p.Command, err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start workload %s: %s", p.Command, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start workload %s: %s", p.Command, err)
+	}
+	p.in, p.out, p.closer = in, bufio.NewReader(out), cmd.Wait
+	return nil
+}
+
+// A script drawing its units of work from the process, so it can be weighted and rate limited like any other
+func (p *ExternalWorkload) Script(name string, weight uint) Script {
+	return Script{Name: name, Weight: weight, Commands: []Command{externalWorkCommand{workload: p}}}
+}
+
+// Asks the process for the next unit of work, and fills uow in with it
+func (p *ExternalWorkload) next(uow *UnitOfWork) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	request, err := json.Marshal(externalWorkRequest{Seq: p.served})
+	if err != nil {
+		return err
+	}
+	p.served++
+	if _, err := p.in.Write(append(request, '\n')); err != nil {
+		return fmt.Errorf("failed to ask %s for work: %s", p.Command, err)
+	}
+	line, err := p.out.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read work from %s: %s", p.Command, err)
+	}
+	var work externalWork
+	decoder := json.NewDecoder(strings.NewReader(string(line)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&work); err != nil {
+		return fmt.Errorf("%s answered with invalid json: %s", p.Command, err)
+	}
+	if work.Error != "" {
+		return fmt.Errorf("%s: %s", p.Command, work.Error)
+	}
+	if len(work.Statements) == 0 {
+		return fmt.Errorf("%s answered with a unit of work without statements", p.Command)
+	}
+	if work.Script != "" {
+		uow.ScriptName = work.Script
+	}
+	uow.Readonly = work.Readonly
+	for _, statement := range work.Statements {
+		params := make(map[string]interface{}, len(statement.Params))
+		for k, v := range statement.Params {
+			params[k] = fromJsonValue(v)
+		}
+		uow.Statements = append(uow.Statements, Statement{Query: statement.Query, Params: params})
+	}
+	return nil
+}
+
+// Ends the process by closing its stdin, and waits for it to exit
+func (p *ExternalWorkload) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	_ = p.in.Close()
+	if err := p.closer(); err != nil {
+		return fmt.Errorf("workload %s: %s", p.Command, err)
+	}
+	return nil
+}
+
+type externalWorkCommand struct {
+	workload *ExternalWorkload
+}
+
+func (c externalWorkCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	return c.workload.next(uow)
+}
@@ -0,0 +1,46 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestExternalWorkloadSuppliesUnitsOfWork(t *testing.T) {
+	// Answers each request with a read of the account numbered by its sequence number
+	provider := NewExternalWorkload(`while read request; do
+  seq=$(echo "$request" | sed 's/[^0-9]//g')
+  echo "{\"script\": \"read-account\", \"readonly\": true, \"statements\": [{\"query\": \"MATCH (a:Account {aid: \$aid}) RETURN a\", \"params\": {\"aid\": $seq, \"scale\": 1.5}}]}"
+done`)
+	assert.NoError(t, provider.Start())
+	wrk := Workload{Scripts: NewScripts(provider.Script("exec:accounts", 1)), Rand: rand.New(rand.NewSource(1337))}
+	client := wrk.NewClient()
+
+	for i := int64(0); i < 3; i++ {
+		uow, err := client.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, "read-account", uow.ScriptName)
+		assert.True(t, uow.Readonly)
+		assert.Equal(t, []Statement{{
+			Query:  "MATCH (a:Account {aid: $aid}) RETURN a",
+			Params: map[string]interface{}{"aid": i, "scale": 1.5},
+		}}, uow.Statements)
+	}
+	assert.NoError(t, provider.Close())
+}
+
+func TestExternalWorkloadErrors(t *testing.T) {
+	provider := NewExternalWorkload(`read request; echo '{"error": "out of customers"}'`)
+	assert.NoError(t, provider.Start())
+	script := provider.Script("exec:customers", 1)
+	_, err := script.Eval(ScriptContext{})
+	assert.EqualError(t, err, `read request; echo '{"error": "out of customers"}': out of customers`)
+	assert.NoError(t, provider.Close())
+
+	provider = NewExternalWorkload(`read request; echo '{"statements": []}'`)
+	assert.NoError(t, provider.Start())
+	script = provider.Script("exec:empty", 1)
+	_, err = script.Eval(ScriptContext{})
+	assert.Error(t, err)
+	assert.NoError(t, provider.Close())
+}
@@ -0,0 +1,491 @@
+package neobench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Distinct queries read from Neo4j query logs, with how often each ran and the parameters they ran with, to
+// turn a production workload into scripts; see LoggedQuery.Script
+type QueryLog struct {
+	// Completed queries read, including ones left out of Queries as driver housekeeping
+	Total   int64
+	queries map[string]*LoggedQuery
+}
+
+// A query as logged, with whitespace collapsed, and what was seen of its parameters
+type LoggedQuery struct {
+	Query  string
+	Count  int64
+	params map[string]*loggedParam
+}
+
+// Values seen for one parameter: the range of numbers, and counts of anything else by its Cypher literal
+type loggedParam struct {
+	ints, floats int64
+	min, max     float64
+	literals     map[string]int64
+	// Values that weren't numbers, and of those, ones we couldn't read, eg. nodes, which can't be written
+	// back into a script
+	others, unreadable int64
+}
+
+// Keeps memory bounded for parameters with a different value every time, like ids in strings
+const maxLoggedLiterals = 100
+
+// Queries drivers run by themselves, which aren't part of the workload
+var driverQueries = regexp.MustCompile(`(?i)^(CALL\s+dbms\.(routing|cluster\.routing|components)|CALL\s+db\.ping|SHOW\s+)`)
+
+var logTimestamp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}`)
+var logRuntime = regexp.MustCompile(` - runtime=\w+$`)
+var logPrefixField = regexp.MustCompile(`^\S* - `)
+
+func NewQueryLog() *QueryLog {
+	return &QueryLog{queries: make(map[string]*LoggedQuery)}
+}
+
+// Reads a query log, in the text format of Neo4j 3.5 and later, or the json format; queries that span lines
+// are joined up, and lines other than completed queries are skipped
+func (l *QueryLog) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var entry strings.Builder
+	flush := func() {
+		if entry.Len() > 0 {
+			l.readTextEntry(entry.String())
+			entry.Reset()
+		}
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "{"):
+			flush()
+			if err := l.readJsonEntry(line); err != nil {
+				return err
+			}
+		case logTimestamp.MatchString(line):
+			flush()
+			entry.WriteString(line)
+		case entry.Len() > 0:
+			entry.WriteString("\n")
+			entry.WriteString(line)
+		}
+	}
+	flush()
+	return scanner.Err()
+}
+
+// Eg. 2020-11-03 10:14:25.123+0000 INFO  id:1 - 23 ms: ... - bolt-session	bolt	...	server/127.0.0.1:7687>	neo4j - neo4j - MATCH (n) RETURN n - {} - runtime=pipelined - {}
+func (l *QueryLog) readTextEntry(entry string) {
+	firstLine := strings.SplitN(entry, "\n", 2)[0]
+	if !strings.Contains(firstLine, " INFO ") || !strings.Contains(firstLine, " ms: ") || strings.Contains(firstLine, "Query started:") {
+		return
+	}
+	// Session details are tab separated, and the query follows the last of them, after the database and user
+	rest := entry[strings.LastIndex(firstLine, "\t")+1:]
+	for i := 0; i < 2 && logPrefixField.MatchString(rest); i++ {
+		rest = rest[strings.Index(rest, " - ")+3:]
+	}
+
+	var maps []string
+	for len(maps) < 2 {
+		rest = logRuntime.ReplaceAllString(strings.TrimRight(rest, " "), "")
+		before, m, ok := cutTrailingMap(rest)
+		if !ok {
+			break
+		}
+		rest, maps = before, append(maps, m)
+	}
+	if len(maps) == 0 {
+		return
+	}
+	// The transaction metadata comes last, if the log has it
+	params, err := parseCypherMap(maps[len(maps)-1])
+	if err != nil {
+		params = nil
+	}
+	l.add(rest, params)
+}
+
+func (l *QueryLog) readJsonEntry(line string) error {
+	var entry struct {
+		Level           string          `json:"level"`
+		Event           string          `json:"event"`
+		Query           string          `json:"query"`
+		QueryParameters json.RawMessage `json:"queryParameters"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&entry); err != nil {
+		return fmt.Errorf("failed to read query log line %q: %s", line, err)
+	}
+	if entry.Query == "" || (entry.Level != "" && entry.Level != "INFO") || (entry.Event != "" && entry.Event != "success") {
+		return nil
+	}
+	var params map[string]interface{}
+	var text string
+	if json.Unmarshal(entry.QueryParameters, &text) == nil {
+		params, _ = parseCypherMap(text)
+	} else {
+		decoder = json.NewDecoder(strings.NewReader(string(entry.QueryParameters)))
+		decoder.UseNumber()
+		if decoder.Decode(&params) == nil {
+			params = fromJsonValue(params).(map[string]interface{})
+		}
+	}
+	l.add(entry.Query, params)
+	return nil
+}
+
+func (l *QueryLog) add(query string, params map[string]interface{}) {
+	query = strings.TrimRight(strings.Join(strings.Fields(query), " "), "; ")
+	if query == "" {
+		return
+	}
+	l.Total++
+	if driverQueries.MatchString(query) {
+		return
+	}
+	q, found := l.queries[query]
+	if !found {
+		q = &LoggedQuery{Query: query, params: make(map[string]*loggedParam)}
+		l.queries[query] = q
+	}
+	q.Count++
+	for name, value := range params {
+		p, found := q.params[name]
+		if !found {
+			p = &loggedParam{literals: make(map[string]int64)}
+			q.params[name] = p
+		}
+		p.add(value)
+	}
+}
+
+func (p *loggedParam) add(value interface{}) {
+	var f float64
+	switch v := value.(type) {
+	case int64:
+		p.ints++
+		f = float64(v)
+	case float64:
+		p.floats++
+		f = v
+	default:
+		p.others++
+		literal, ok := cypherLiteral(value)
+		if !ok {
+			p.unreadable++
+			return
+		}
+		if _, seen := p.literals[literal]; seen || len(p.literals) < maxLoggedLiterals {
+			p.literals[literal]++
+		}
+		return
+	}
+	if p.ints+p.floats == 1 || f < p.min {
+		p.min = f
+	}
+	if p.ints+p.floats == 1 || f > p.max {
+		p.max = f
+	}
+}
+
+// Queries that aren't driver housekeeping, most frequent first
+func (l *QueryLog) Queries() []*LoggedQuery {
+	queries := make([]*LoggedQuery, 0, len(l.queries))
+	for _, q := range l.queries {
+		queries = append(queries, q)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		if queries[i].Count != queries[j].Count {
+			return queries[i].Count > queries[j].Count
+		}
+		return queries[i].Query < queries[j].Query
+	})
+	return queries
+}
+
+// A neobench script running the query with parameters like those in the log: numbers are drawn uniformly from
+// the range seen, and anything else is written into the query as its most common value
+func (q *LoggedQuery) Script(total int64) string {
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("// %d of %d queries in the log (%.1f%%)\n", q.Count, total, 100*float64(q.Count)/float64(total)))
+	query := q.Query
+	names := make([]string, 0, len(q.params))
+	for name := range q.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := q.params[name]
+		numbers := p.ints + p.floats
+		switch {
+		case numbers > 0 && p.min == p.max:
+			s.WriteString(fmt.Sprintf("\\set %s %s\n", name, formatFloat(p.min)))
+		case numbers > 0 && p.floats == 0:
+			s.WriteString(fmt.Sprintf("\\set %s random(%d, %d)\n", name, int64(p.min), int64(p.max)))
+		case numbers > 0:
+			s.WriteString(fmt.Sprintf("\\set %s %s + random(0, 1000000) * %s\n", name, formatFloat(p.min), formatFloat((p.max-p.min)/1000000)))
+		case len(p.literals) > 0:
+			literal := p.mostCommonLiteral()
+			query = regexp.MustCompile(`\$`+regexp.QuoteMeta(name)+`\b`).ReplaceAllLiteralString(query, literal)
+			s.WriteString(fmt.Sprintf("// $%s was %s in %d of %d queries, written into the query\n", name, literal, p.literals[literal], q.Count))
+		}
+		if numbers > 0 && p.others > 0 {
+			s.WriteString(fmt.Sprintf("// $%s was not a number in %d of %d queries\n", name, p.others, q.Count))
+		} else if numbers == 0 && len(p.literals) == 0 {
+			s.WriteString(fmt.Sprintf("// $%s could not be read from the log, define it with -D or write it into the query\n", name))
+		}
+	}
+	s.WriteString(query)
+	s.WriteString(";\n")
+	return s.String()
+}
+
+func (p *loggedParam) mostCommonLiteral() string {
+	best := ""
+	for literal, count := range p.literals {
+		if best == "" || count > p.literals[best] || (count == p.literals[best] && literal < best) {
+			best = literal
+		}
+	}
+	return best
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Writes value as Cypher; false for values that have no literal form, like nodes
+func cypherLiteral(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "null", true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return formatFloat(v), true
+	case string:
+		// Double quotes, as the script reader takes single quoted text for character literals
+		return strconv.Quote(v), true
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			literal, ok := cypherLiteral(item)
+			if !ok {
+				return "", false
+			}
+			items[i] = literal
+		}
+		return "[" + strings.Join(items, ", ") + "]", true
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			literal, ok := cypherLiteral(v[k])
+			if !ok {
+				return "", false
+			}
+			entries[i] = fmt.Sprintf("`%s`: %s", k, literal)
+		}
+		return "{" + strings.Join(entries, ", ") + "}", true
+	}
+	return "", false
+}
+
+// Splits "<text> - {<map>}" into text and the map, matching braces from the end, and skipping any in quotes
+func cutTrailingMap(s string) (string, string, bool) {
+	if !strings.HasSuffix(s, "}") {
+		return s, "", false
+	}
+	depth := 0
+	var quote byte
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote && (i == 0 || s[i-1] != '\\') {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '}':
+			depth++
+		case c == '{':
+			depth--
+			if depth == 0 {
+				if !strings.HasSuffix(s[:i], " - ") {
+					return s, "", false
+				}
+				return s[:i-3], s[i:], true
+			}
+		}
+	}
+	return s, "", false
+}
+
+// Values in query logs are written as Cypher; what can't be read, like nodes, is kept as an unreadable value
+type unreadableValue string
+
+// Parses a map as written in query logs, eg. {id: 5, name: 'Bob', tags: ['a', 'b']}
+func parseCypherMap(s string) (map[string]interface{}, error) {
+	p := &cypherParser{s: s}
+	value := p.value()
+	p.skipSpace()
+	if p.err == nil && p.pos < len(p.s) {
+		p.err = fmt.Errorf("unexpected '%s' after parameters", p.s[p.pos:])
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a map of parameters, got %s", s)
+	}
+	return m, nil
+}
+
+type cypherParser struct {
+	s   string
+	pos int
+	err error
+}
+
+func (p *cypherParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\n' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *cypherParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *cypherParser) expect(c byte) {
+	if p.peek() != c {
+		if p.err == nil {
+			p.err = fmt.Errorf("expected '%c' at %d in %s", c, p.pos, p.s)
+		}
+		p.pos = len(p.s)
+		return
+	}
+	p.pos++
+}
+
+func (p *cypherParser) value() interface{} {
+	switch c := p.peek(); {
+	case c == '{':
+		p.pos++
+		m := make(map[string]interface{})
+		for p.err == nil && p.peek() != '}' {
+			if len(m) > 0 {
+				p.expect(',')
+			}
+			key := p.key()
+			p.expect(':')
+			m[key] = p.value()
+		}
+		p.expect('}')
+		return m
+	case c == '[':
+		p.pos++
+		list := make([]interface{}, 0)
+		for p.err == nil && p.peek() != ']' {
+			if len(list) > 0 {
+				p.expect(',')
+			}
+			list = append(list, p.value())
+		}
+		p.expect(']')
+		return list
+	case c == '\'' || c == '"':
+		return p.quoted(c)
+	}
+	// Numbers, booleans and null, or something we can't read, up to the next separator at this depth
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.s); p.pos++ {
+		c := p.s[p.pos]
+		if c == '(' || c == '[' || c == '{' {
+			depth++
+		} else if (c == ')' || c == ']' || c == '}') && depth > 0 {
+			depth--
+		} else if depth == 0 && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+	}
+	text := strings.TrimSpace(p.s[start:p.pos])
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f
+	}
+	switch strings.ToLower(text) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null", "<null>":
+		return nil
+	}
+	return unreadableValue(text)
+}
+
+func (p *cypherParser) key() string {
+	p.skipSpace()
+	if p.pos < len(p.s) && (p.s[p.pos] == '`' || p.s[p.pos] == '\'' || p.s[p.pos] == '"') {
+		return p.quoted(p.s[p.pos])
+	}
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != ':' && p.s[p.pos] != ' ' {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *cypherParser) quoted(quote byte) string {
+	p.pos++
+	var b strings.Builder
+	for ; p.pos < len(p.s); p.pos++ {
+		c := p.s[p.pos]
+		if c == '\\' && p.pos+1 < len(p.s) {
+			p.pos++
+			switch p.s[p.pos] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(p.s[p.pos])
+			}
+			continue
+		}
+		if c == quote {
+			p.pos++
+			return b.String()
+		}
+		b.WriteByte(c)
+	}
+	if p.err == nil {
+		p.err = fmt.Errorf("unterminated string in %s", p.s)
+	}
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestQueryLogScriptsReplayLoggedQueries(t *testing.T) {
+	log := NewQueryLog()
+	assert.NoError(t, log.Read(strings.NewReader(`2020-11-03 10:14:25.123+0000 INFO  id:1 - 3 ms: (planning: 1, waiting: 0) - 312 B - bolt-session	bolt	neo4j-java/4.0	client/127.0.0.1:53104	server/127.0.0.1:7687>	neo4j - app - MATCH (a:Account {aid: $aid}) RETURN a.balance - {aid: 12} - runtime=pipelined - {}
+2020-11-03 10:14:25.456+0000 INFO  id:2 - 2 ms: (planning: 0, waiting: 0) - 312 B - bolt-session	bolt	neo4j-java/4.0	client/127.0.0.1:53104	server/127.0.0.1:7687>	neo4j - app - MATCH (a:Account {aid: $aid})
+RETURN a.balance - {aid: 150} - runtime=pipelined - {app: 'billing'}
+2020-11-03 10:14:26.001+0000 INFO  id:3 - 9 ms: (planning: 2, waiting: 0) - 96 B - bolt-session	bolt	neo4j-java/4.0	client/127.0.0.1:53104	server/127.0.0.1:7687>	neo4j - app - MATCH (p:Person {name: $name}) SET p.score = $score - {name: 'Bob O\'Neill', score: 0.5} - runtime=slotted - {}
+2020-11-03 10:14:26.002+0000 INFO  id:4 - 1 ms: (planning: 0, waiting: 0) - 0 B - bolt-session	bolt	neo4j-java/4.0	client/127.0.0.1:53104	server/127.0.0.1:7687>	system - app - CALL dbms.routing.getRoutingTable($context, $database) - {context: {}, database: 'neo4j'} - runtime=pipelined - {}
+2020-11-03 10:14:26.003+0000 INFO  Query started: id:5 - 0 ms: (planning: 0, waiting: 0) - 0 B - bolt-session	bolt	neo4j-java/4.0	client/127.0.0.1:53104	server/127.0.0.1:7687>	neo4j - app - MATCH (n) RETURN n - {} - runtime=null - {}
+{"time": "2023-01-01 10:00:00.000+0000", "level": "INFO", "event": "success", "query": "MATCH (p:Person {name: $name}) SET p.score = $score", "queryParameters": "{name: 'Bob O\\'Neill', score: 2.5}"}
+{"time": "2023-01-01 10:00:01.000+0000", "level": "INFO", "event": "start", "query": "MATCH (n) RETURN n", "queryParameters": "{}"}
+`)))
+
+	assert.Equal(t, int64(5), log.Total)
+	queries := log.Queries()
+	assert.Len(t, queries, 2)
+	assert.Equal(t, "// 2 of 5 queries in the log (40.0%)\n\\set aid random(12, 150)\nMATCH (a:Account {aid: $aid}) RETURN a.balance;\n", queries[0].Script(log.Total))
+	assert.Equal(t, "// 2 of 5 queries in the log (40.0%)\n"+
+		"// $name was \"Bob O'Neill\" in 2 of 2 queries, written into the query\n"+
+		"\\set score 0.5 + random(0, 1000000) * 0.000002\n"+
+		"MATCH (p:Person {name: \"Bob O'Neill\"}) SET p.score = $score;\n", queries[1].Script(log.Total))
+
+	script, err := Parse("replay", queries[1].Script(log.Total), 1)
+	assert.NoError(t, err)
+	assert.Len(t, script.Commands, 2)
+}
+
+func TestParseCypherMap(t *testing.T) {
+	params, err := parseCypherMap(`{ids: [1, 2.5, "x"], nested: {flag: true, none: null}, node: Node[12]{name:"a"}, ` + "`odd key`" + `: -3}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"ids":     []interface{}{int64(1), 2.5, "x"},
+		"nested":  map[string]interface{}{"flag": true, "none": nil},
+		"node":    unreadableValue(`Node[12]{name:"a"}`),
+		"odd key": int64(-3),
+	}, params)
+
+	_, err = parseCypherMap(`{name: 'unterminated}`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package neobench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Latency objective for --find-max-rate, eg. p99=20ms
+type LatencyTarget struct {
+	Percentile float64
+	Limit      time.Duration
+}
+
+func (t LatencyTarget) String() string {
+	return fmt.Sprintf("p%s<=%s", strconv.FormatFloat(t.Percentile, 'f', -1, 64), t.Limit)
+}
+
+func ParseLatencyTarget(spec string) (LatencyTarget, error) {
+	invalid := fmt.Errorf("invalid latency target '%s', expected eg. p99=20ms", spec)
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "p") {
+		return LatencyTarget{}, invalid
+	}
+	p, err := strconv.ParseFloat(parts[0][1:], 64)
+	if err != nil || p <= 0 || p > 100 {
+		return LatencyTarget{}, invalid
+	}
+	limit, err := time.ParseDuration(parts[1])
+	if err != nil || limit <= 0 {
+		return LatencyTarget{}, invalid
+	}
+	return LatencyTarget{Percentile: p, Limit: limit}, nil
+}
+
+// Checks every script in the result against the target; returns the worst latency at the target percentile.
+// A result with no successful transactions never meets the target.
+func (t LatencyTarget) Met(result Result) (bool, time.Duration) {
+	worst := time.Duration(0)
+	for _, script := range result.Scripts {
+		if script.Succeeded == 0 {
+			return false, worst
+		}
+		latency := time.Duration(valueAtPercentile(script.Latencies, t.Percentile)) * time.Microsecond
+		if latency > worst {
+			worst = latency
+		}
+	}
+	return len(result.Scripts) > 0 && worst <= t.Limit, worst
+}
+
+// Searches for the highest rate that meets a latency target: the rate doubles from the initial guess until
+// a step misses the target, or halves until one meets it, and then bisects between the highest passing and
+// lowest failing rate until they are within tolerance of each other, or maxSteps have run.
+type RateSearch struct {
+	maxSteps  int
+	tolerance float64
+	steps     int
+	next      float64
+	// Highest rate that met the target and lowest that didn't, 0 until we have one
+	pass float64
+	fail float64
+}
+
+// Tolerance is relative, eg. 0.05 stops once the bounds are within 5% of each other
+func NewRateSearch(initial float64, maxSteps int, tolerance float64) *RateSearch {
+	return &RateSearch{maxSteps: maxSteps, tolerance: tolerance, next: initial}
+}
+
+// The rate to try next, or false if the search is done
+func (s *RateSearch) Next() (float64, bool) {
+	if s.steps >= s.maxSteps {
+		return 0, false
+	}
+	if s.pass > 0 && s.fail > 0 && (s.fail-s.pass)/s.fail <= s.tolerance {
+		return 0, false
+	}
+	return s.next, true
+}
+
+// Records whether the rate returned by Next met the target
+func (s *RateSearch) Record(rate float64, met bool) {
+	s.steps++
+	if met && rate > s.pass {
+		s.pass = rate
+	}
+	if !met && (s.fail == 0 || rate < s.fail) {
+		s.fail = rate
+	}
+	switch {
+	case s.fail == 0:
+		s.next = s.pass * 2
+	case s.pass == 0:
+		s.next = s.fail / 2
+	default:
+		s.next = (s.pass + s.fail) / 2
+	}
+}
+
+// Highest rate that met the target, 0 if none did
+func (s *RateSearch) Best() float64 {
+	return s.pass
+}
@@ -0,0 +1,62 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRateSearchConvergesOnCapacity(t *testing.T) {
+	for _, initial := range []float64{10, 370, 5000} {
+		search := NewRateSearch(initial, 30, 0.05)
+		steps := 0
+		for {
+			rate, ok := search.Next()
+			if !ok {
+				break
+			}
+			steps++
+			search.Record(rate, rate <= 370)
+		}
+		assert.LessOrEqual(t, search.Best(), 370.0)
+		assert.Greater(t, search.Best(), 370*0.95)
+		assert.Less(t, steps, 30)
+	}
+}
+
+func TestRateSearchStopsAfterMaxSteps(t *testing.T) {
+	search := NewRateSearch(100, 3, 0.01)
+	for i := 0; i < 3; i++ {
+		rate, ok := search.Next()
+		assert.True(t, ok)
+		search.Record(rate, false)
+	}
+	_, ok := search.Next()
+	assert.False(t, ok)
+	assert.Equal(t, 0.0, search.Best())
+}
+
+func TestLatencyTarget(t *testing.T) {
+	target, err := ParseLatencyTarget("p99=20ms")
+	assert.NoError(t, err)
+	assert.Equal(t, LatencyTarget{Percentile: 99, Limit: 20 * time.Millisecond}, target)
+	assert.Equal(t, "p99<=20ms", target.String())
+	for _, invalid := range []string{"p99", "99=20ms", "p99=fast", "p101=1ms"} {
+		_, err = ParseLatencyTarget(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	result := NewResult("neo4j", "")
+	histo := hdrhistogram.New(0, 60*60*1000000, 3)
+	for i := int64(1); i <= 100; i++ {
+		assert.NoError(t, histo.RecordValue(i*1000))
+	}
+	result.Scripts["s"] = &ScriptResult{ScriptName: "s", Succeeded: 100, Latencies: histo}
+
+	met, worst := LatencyTarget{Percentile: 99, Limit: 100 * time.Millisecond}.Met(result)
+	assert.True(t, met)
+	assert.InDelta(t, float64(99*time.Millisecond), float64(worst), float64(100*time.Microsecond))
+	met, _ = LatencyTarget{Percentile: 99, Limit: 50 * time.Millisecond}.Met(result)
+	assert.False(t, met)
+}
@@ -0,0 +1,124 @@
+package neobench
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// A stretch of a run where the cluster was changing under the workload, eg. the leader switched or a server
+// went away, from the first error saying so to when transactions started succeeding again
+type RecoveryWindow struct {
+	Start time.Time
+	// First transaction to succeed after the last disruption error, zero if the run ended before any did
+	Recovered time.Time
+	// Transactions that failed in the window, for any reason
+	Failed int64
+	// Times the driver threw away its routing table in the window, eg. after writing to a former leader
+	RoutingChanges int64
+	// Error group that opened the window, eg. Neo.ClientError.Cluster.NotALeader
+	Cause string
+
+	lastDisruption time.Time
+}
+
+// Zero if the window never closed
+func (w RecoveryWindow) TimeToRecover() time.Duration {
+	if w.Recovered.IsZero() {
+		return 0
+	}
+	return w.Recovered.Sub(w.Start)
+}
+
+// Finds the windows where the workload was disrupted by leader switches and servers failing, by watching
+// transaction outcomes and routing table changes. A window closes once transactions have gone on succeeding
+// for a quiet period without further disruption errors, so a leader election that takes a few rounds is
+// reported as one window. Times are when transactions completed, so windows start up to one transaction
+// latency after the disruption did.
+type RecoveryTracker struct {
+	quiet time.Duration
+	now   func() time.Time
+
+	mut     sync.Mutex
+	current *RecoveryWindow
+	// First success since the last disruption error in the current window, zero if there's been none
+	firstSuccess time.Time
+	windows      []RecoveryWindow
+}
+
+func NewRecoveryTracker(quiet time.Duration) *RecoveryTracker {
+	return &RecoveryTracker{quiet: quiet, now: time.Now}
+}
+
+// Errors meaning the cluster changed under the workload, rather than that the query or the data was wrong
+func isDisruption(group string) bool {
+	switch ClassifyErrorGroup(group) {
+	case ErrorClassConnectivity, ErrorClassTimeout:
+		return true
+	}
+	return strings.HasPrefix(group, "Neo.ClientError.Cluster.") ||
+		group == "Neo.ClientError.General.ForbiddenOnReadOnlyDatabase" ||
+		group == "Neo.TransientError.General.DatabaseUnavailable"
+}
+
+func (t *RecoveryTracker) ObserveTransaction(workerId int64, scriptName string, latency time.Duration, outcome TransactionOutcome) {
+	now := t.now()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.closeIfRecovered(now)
+	if outcome.Succeeded {
+		if t.current != nil && t.firstSuccess.IsZero() {
+			t.firstSuccess = now
+		}
+		return
+	}
+	if isDisruption(outcome.FailureGroup) {
+		t.disrupted(now, outcome.FailureGroup)
+	}
+	if t.current != nil {
+		t.current.Failed++
+	}
+}
+
+// Called when the driver invalidates its routing table, see PoolMetrics.OnRoutingChange
+func (t *RecoveryTracker) RoutingChanged() {
+	now := t.now()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.closeIfRecovered(now)
+	t.disrupted(now, "routing table invalidated")
+	t.current.RoutingChanges++
+}
+
+func (t *RecoveryTracker) disrupted(now time.Time, cause string) {
+	if t.current == nil {
+		t.current = &RecoveryWindow{Start: now, Cause: cause}
+	}
+	t.current.lastDisruption = now
+	t.firstSuccess = time.Time{}
+}
+
+func (t *RecoveryTracker) closeIfRecovered(now time.Time) {
+	if t.current == nil || t.firstSuccess.IsZero() || now.Sub(t.firstSuccess) < t.quiet {
+		return
+	}
+	t.current.Recovered = t.firstSuccess
+	t.windows = append(t.windows, *t.current)
+	t.current, t.firstSuccess = nil, time.Time{}
+}
+
+// Closes the last window, if the workload had recovered by now, and returns all windows found; a window
+// still open at the end of the run is returned with a zero Recovered
+func (t *RecoveryTracker) Finish() []RecoveryWindow {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.current != nil && !t.firstSuccess.IsZero() {
+		// The run ended before the quiet period did, but nothing disrupted it again
+		t.current.Recovered = t.firstSuccess
+	}
+	if t.current != nil {
+		t.windows = append(t.windows, *t.current)
+		t.current = nil
+	}
+	return t.windows
+}
@@ -0,0 +1,56 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRecoveryTrackerMeasuresLeaderSwitch(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	tracker := NewRecoveryTracker(2 * time.Second)
+	tracker.now = func() time.Time { return now }
+	at := func(offset time.Duration) { now = start.Add(offset) }
+	ok := TransactionOutcome{Succeeded: true}
+	notALeader := TransactionOutcome{FailureGroup: "Neo.ClientError.Cluster.NotALeader"}
+
+	at(time.Second)
+	tracker.ObserveTransaction(0, "write", time.Millisecond, ok)
+	// The leader goes away: writes fail, the driver reroutes, and the first success doesn't stick
+	at(10 * time.Second)
+	tracker.ObserveTransaction(0, "write", time.Millisecond, notALeader)
+	tracker.RoutingChanged()
+	at(11 * time.Second)
+	tracker.ObserveTransaction(0, "write", time.Millisecond, TransactionOutcome{FailureGroup: "Neo.ClientError.Statement.SyntaxError"})
+	tracker.ObserveTransaction(0, "write", time.Millisecond, ok)
+	at(12 * time.Second)
+	tracker.ObserveTransaction(0, "write", time.Millisecond, TransactionOutcome{FailureGroup: "ServiceUnavailable"})
+	at(13 * time.Second)
+	tracker.ObserveTransaction(0, "write", time.Millisecond, ok)
+	at(16 * time.Second)
+	tracker.ObserveTransaction(0, "write", time.Millisecond, ok)
+	// A second switch the run ends in the middle of
+	at(20 * time.Second)
+	tracker.ObserveTransaction(0, "write", time.Millisecond, notALeader)
+
+	windows := tracker.Finish()
+	assert.Equal(t, []RecoveryWindow{
+		{
+			Start:          start.Add(10 * time.Second),
+			Recovered:      start.Add(13 * time.Second),
+			Failed:         3,
+			RoutingChanges: 1,
+			Cause:          "Neo.ClientError.Cluster.NotALeader",
+			lastDisruption: start.Add(12 * time.Second),
+		},
+		{
+			Start:          start.Add(20 * time.Second),
+			Failed:         1,
+			Cause:          "Neo.ClientError.Cluster.NotALeader",
+			lastDisruption: start.Add(20 * time.Second),
+		},
+	}, windows)
+	assert.Equal(t, 3*time.Second, windows[0].TimeToRecover())
+	assert.Equal(t, time.Duration(0), windows[1].TimeToRecover())
+}
@@ -0,0 +1,149 @@
+package neobench
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// One condition from --fail-if, eg. p99>+10% fails the run if p99 is more than 10% higher than in the baseline
+type RegressionThreshold struct {
+	// tps, mean, min, max or p<percentile>
+	Metric string
+	// Only set for percentile metrics
+	Percentile float64
+	// If true, fail when the change is above Limit, otherwise when it is below
+	Above bool
+	// Change relative to the baseline, in percent
+	Limit float64
+}
+
+func (t RegressionThreshold) String() string {
+	op := "<"
+	if t.Above {
+		op = ">"
+	}
+	return fmt.Sprintf("%s%s%+g%%", t.Metric, op, t.Limit)
+}
+
+// Parses a comma separated list of thresholds, eg. "p99>+10%,tps<-5%"
+func ParseRegressionThresholds(spec string) ([]RegressionThreshold, error) {
+	var out []RegressionThreshold
+	for _, raw := range strings.Split(spec, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		i := strings.IndexAny(raw, "<>")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid --fail-if condition '%s', expected eg. p99>+10%% or tps<-5%%", raw)
+		}
+		t := RegressionThreshold{Metric: strings.ToLower(raw[:i]), Above: raw[i] == '>'}
+		limit, err := strconv.ParseFloat(strings.TrimSuffix(raw[i+1:], "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit in --fail-if condition '%s', expected a percentage, eg. +10%%: %s", raw, err)
+		}
+		t.Limit = limit
+		switch {
+		case t.Metric == "tps" || t.Metric == "mean" || t.Metric == "min" || t.Metric == "max":
+		case strings.HasPrefix(t.Metric, "p"):
+			p, err := strconv.ParseFloat(t.Metric[1:], 64)
+			if err != nil || p < 0 || p > 100 {
+				return nil, fmt.Errorf("invalid percentile in --fail-if condition '%s', expected eg. p99 or p99.9", raw)
+			}
+			t.Percentile = p
+		default:
+			return nil, fmt.Errorf("unknown metric in --fail-if condition '%s', supported metrics are tps, mean, min, max and p<percentile>", raw)
+		}
+		out = append(out, t)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no conditions in --fail-if '%s'", spec)
+	}
+	return out, nil
+}
+
+// The percentiles a report must include for thresholds to be checked against it, on top of the defaults
+func RegressionPercentiles(thresholds []RegressionThreshold) []float64 {
+	percentiles := append([]float64{}, jsonPercentiles...)
+	for _, t := range thresholds {
+		if !strings.HasPrefix(t.Metric, "p") {
+			continue
+		}
+		found := false
+		for _, p := range percentiles {
+			found = found || p == t.Percentile
+		}
+		if !found {
+			percentiles = append(percentiles, t.Percentile)
+		}
+	}
+	sort.Float64s(percentiles)
+	return percentiles
+}
+
+// Checks the baseline has every metric the thresholds need, so we can tell before running the benchmark
+func ValidateRegressionBaseline(baseline JsonReport, thresholds []RegressionThreshold) error {
+	if len(baseline.Scripts) == 0 {
+		return fmt.Errorf("baseline has no scripts to compare against")
+	}
+	for _, script := range baseline.Scripts {
+		for _, t := range thresholds {
+			if _, err := regressionMetric(script, t); err != nil {
+				return fmt.Errorf("baseline: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Checks each script found in both reports against the thresholds; returns a description of each violation
+func CheckRegressions(baseline, current JsonReport, thresholds []RegressionThreshold) ([]string, error) {
+	var violations []string
+	for _, b := range baseline.Scripts {
+		for _, c := range current.Scripts {
+			if b.Name != c.Name {
+				continue
+			}
+			for _, t := range thresholds {
+				before, err := regressionMetric(b, t)
+				if err != nil {
+					return nil, fmt.Errorf("baseline: %s", err)
+				}
+				after, err := regressionMetric(c, t)
+				if err != nil {
+					return nil, err
+				}
+				if before == 0 {
+					continue
+				}
+				delta := 100 * (after - before) / before
+				if (t.Above && delta > t.Limit) || (!t.Above && delta < t.Limit) {
+					violations = append(violations, fmt.Sprintf("%s: %s went from %.3f to %.3f (%+.2f%%), failing %s",
+						c.Name, t.Metric, before, after, delta, t))
+				}
+			}
+		}
+	}
+	return violations, nil
+}
+
+func regressionMetric(script JsonScriptReport, t RegressionThreshold) (float64, error) {
+	switch t.Metric {
+	case "tps":
+		return script.Rate, nil
+	case "mean":
+		return script.Latency.Mean, nil
+	case "min":
+		return script.Latency.Min, nil
+	case "max":
+		return script.Latency.Max, nil
+	}
+	for _, p := range script.Latency.Percentiles {
+		if p.Percentile == t.Percentile {
+			return p.Value, nil
+		}
+	}
+	return 0, fmt.Errorf("no %s latency recorded for script %s", t.Metric, script.Name)
+}
@@ -0,0 +1,50 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseRegressionThresholds(t *testing.T) {
+	thresholds, err := ParseRegressionThresholds("p99>+10%, tps<-5%,p99.9>20")
+	assert.NoError(t, err)
+	assert.Equal(t, []RegressionThreshold{
+		{Metric: "p99", Percentile: 99, Above: true, Limit: 10},
+		{Metric: "tps", Above: false, Limit: -5},
+		{Metric: "p99.9", Percentile: 99.9, Above: true, Limit: 20},
+	}, thresholds)
+	assert.Equal(t, "p99>+10%", thresholds[0].String())
+
+	for _, invalid := range []string{"", "p99", "p99>x%", "latency>10%", "p101>10%"} {
+		_, err := ParseRegressionThresholds(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCheckRegressions(t *testing.T) {
+	report := func(rate, p99 float64) JsonReport {
+		return JsonReport{Scripts: []JsonScriptReport{{
+			Name: "read",
+			Rate: rate,
+			Latency: JsonLatencyReport{
+				Percentiles: []JsonPercentileReport{{Percentile: 99, Value: p99}},
+			},
+		}}}
+	}
+	thresholds, err := ParseRegressionThresholds("p99>+10%,tps<-5%")
+	assert.NoError(t, err)
+	assert.NoError(t, ValidateRegressionBaseline(report(1000, 10), thresholds))
+
+	violations, err := CheckRegressions(report(1000, 10), report(980, 10.5), thresholds)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = CheckRegressions(report(1000, 10), report(900, 12), thresholds)
+	assert.NoError(t, err)
+	assert.Len(t, violations, 2)
+	assert.Contains(t, violations[0], "p99 went from 10.000 to 12.000 (+20.00%)")
+
+	p999, err := ParseRegressionThresholds("p99.9>+10%")
+	assert.NoError(t, err)
+	assert.Error(t, ValidateRegressionBaseline(report(1000, 10), p999))
+}
@@ -0,0 +1,171 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"time"
+)
+
+// Percentiles stored on Histogram nodes
+var resultStorePercentiles = []float64{0, 25, 50, 75, 90, 95, 99, 99.9, 99.99, 99.999, 100}
+
+// Writes benchmark runs as a graph to a Neo4j database, so historical results can be queried with Cypher:
+//
+//	(:Run)-[:RAN]->(:Workload)-[:HAS_INTERVAL]->(:Interval)
+//	                          -[:HAS_HISTOGRAM]->(:Histogram)
+//	(:Run)-[:HAD_EVENT]->(:Event)
+//
+// The Run node is created up front, intervals and events are added as the benchmark runs and totals and
+// histograms are written by WriteResult at the end. Latencies are in milliseconds.
+type ResultStore struct {
+	driver   neo4j.Driver
+	database string
+	RunId    string
+}
+
+// Takes ownership of driver, which is closed by Close
+func NewResultStore(driver neo4j.Driver, database string, start time.Time, seed int64, scenario string, tags map[string]string) (*ResultStore, error) {
+	s := &ResultStore{
+		driver:   driver,
+		database: database,
+		RunId:    fmt.Sprintf("%s-%d", start.UTC().Format("20060102T150405"), seed),
+	}
+	props := map[string]interface{}{
+		"start":    start.UTC().Format(time.RFC3339Nano),
+		"seed":     seed,
+		"scenario": scenario,
+	}
+	for k, v := range tags {
+		props["tag_"+k] = v
+	}
+	err := s.write("CREATE (r:Run {id: $run}) SET r += $props", map[string]interface{}{
+		"run":   s.RunId,
+		"props": props,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ResultStore) WriteInterval(start, end time.Time, interval Result) error {
+	workloads := make([]interface{}, 0, len(interval.Scripts))
+	for _, script := range interval.SortedScripts() {
+		histo := script.Latencies
+		workloads = append(workloads, map[string]interface{}{
+			"name": script.ScriptName,
+			"props": map[string]interface{}{
+				"start":     start.UTC().Format(time.RFC3339Nano),
+				"end":       end.UTC().Format(time.RFC3339Nano),
+				"succeeded": script.Succeeded,
+				"failed":    script.Failed,
+				"rate":      script.Rate,
+				"p50":       float64(histo.ValueAtQuantile(50)) / 1000.0,
+				"p95":       float64(histo.ValueAtQuantile(95)) / 1000.0,
+				"p99":       float64(histo.ValueAtQuantile(99)) / 1000.0,
+				"max":       float64(histo.Max()) / 1000.0,
+			},
+		})
+	}
+	return s.write(`MATCH (r:Run {id: $run})
+UNWIND $workloads AS workload
+MERGE (r)-[:RAN]->(w:Workload {name: workload.name})
+CREATE (w)-[:HAS_INTERVAL]->(i:Interval)
+SET i = workload.props`, map[string]interface{}{
+		"run":       s.RunId,
+		"workloads": workloads,
+	})
+}
+
+// Records a hook firing during the run
+func (s *ResultStore) WriteEvent(event RunEvent) error {
+	return s.write(`MATCH (r:Run {id: $run})
+CREATE (r)-[:HAD_EVENT]->(e:Event)
+SET e = $props`, map[string]interface{}{
+		"run": s.RunId,
+		"props": map[string]interface{}{
+			"time":  event.Time.UTC().Format(time.RFC3339Nano),
+			"name":  event.Name,
+			"error": event.Err,
+		},
+	})
+}
+
+// Records the totals for the run, and a latency histogram for each workload script
+func (s *ResultStore) WriteResult(result Result) error {
+	workloads := make([]interface{}, 0, len(result.Scripts))
+	for _, script := range result.SortedScripts() {
+		workloads = append(workloads, map[string]interface{}{
+			"name": script.ScriptName,
+			"props": map[string]interface{}{
+				"succeeded": script.Succeeded,
+				"failed":    script.Failed,
+				"rate":      script.Rate,
+			},
+			"histogram": resultStoreHistogram(script.Latencies),
+		})
+	}
+	props := map[string]interface{}{
+		"end":       result.End.UTC().Format(time.RFC3339Nano),
+		"database":  result.DatabaseName,
+		"succeeded": result.TotalSucceeded(),
+		"failed":    result.TotalFailed(),
+		"rate":      result.TotalRate(),
+	}
+	if result.Server != nil {
+		props["server_version"], props["server_edition"] = result.Server.Version, result.Server.Edition
+		props["server_members"] = len(result.Server.Topology)
+	}
+	return s.write(`MATCH (r:Run {id: $run})
+SET r += $props
+WITH r
+UNWIND $workloads AS workload
+MERGE (r)-[:RAN]->(w:Workload {name: workload.name})
+SET w += workload.props
+CREATE (w)-[:HAS_HISTOGRAM]->(h:Histogram)
+SET h = workload.histogram`, map[string]interface{}{
+		"run":       s.RunId,
+		"props":     props,
+		"workloads": workloads,
+	})
+}
+
+func resultStoreHistogram(histo *hdrhistogram.Histogram) map[string]interface{} {
+	values := make([]float64, 0, len(resultStorePercentiles))
+	for _, p := range resultStorePercentiles {
+		values = append(values, float64(valueAtPercentile(histo, p))/1000.0)
+	}
+	return map[string]interface{}{
+		"count":       histo.TotalCount(),
+		"min":         float64(histo.Min()) / 1000.0,
+		"max":         float64(histo.Max()) / 1000.0,
+		"mean":        histo.Mean() / 1000.0,
+		"stddev":      histo.StdDev() / 1000.0,
+		"percentiles": resultStorePercentiles,
+		"values":      values,
+	}
+}
+
+func (s *ResultStore) write(cypher string, params map[string]interface{}) error {
+	session := s.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: s.database,
+	})
+	defer session.Close()
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run(cypher, params)
+		if err != nil {
+			return nil, err
+		}
+		return res.Consume()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write results to neo4j: %s", err)
+	}
+	return nil
+}
+
+func (s *ResultStore) Close() error {
+	return s.driver.Close()
+}
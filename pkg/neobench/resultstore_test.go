@@ -0,0 +1,88 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestResultStoreWritesRunIntervalsAndTotals(t *testing.T) {
+	driver := &statementDriver{}
+	start := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	store, err := NewResultStore(driver, "results", start, 1337, "-w builtin:tpcb-like", map[string]string{"branch": "main"})
+	assert.NoError(t, err)
+	assert.Equal(t, "20210304T100000-1337", store.RunId)
+
+	result := NewResult("neo4j", "")
+	script := &ScriptResult{ScriptName: "read", Rate: 20, Succeeded: 2, Failed: 1, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+	assert.NoError(t, script.Latencies.RecordValue(2000))
+	result.Scripts["read"] = script
+	result.End = start.Add(time.Minute)
+	assert.NoError(t, store.WriteInterval(start, start.Add(time.Second), result))
+	assert.NoError(t, store.WriteEvent(RunEvent{Time: start.Add(2 * time.Second), Name: "2s: exec ./kill-leader.sh"}))
+	assert.NoError(t, store.WriteResult(result))
+	assert.NoError(t, store.Close())
+
+	assert.Equal(t, []string{"results", "results", "results", "results"}, driver.databases)
+	assert.Len(t, driver.params, 4)
+	assert.Equal(t, map[string]interface{}{
+		"start":      "2021-03-04T10:00:00Z",
+		"seed":       int64(1337),
+		"scenario":   "-w builtin:tpcb-like",
+		"tag_branch": "main",
+	}, driver.params[0]["props"])
+	interval := driver.params[1]["workloads"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "read", interval["name"])
+	assert.Equal(t, 2.0, interval["props"].(map[string]interface{})["p99"])
+	assert.Equal(t, map[string]interface{}{"time": "2021-03-04T10:00:02Z", "name": "2s: exec ./kill-leader.sh", "error": ""}, driver.params[2]["props"])
+	totals := driver.params[3]["props"].(map[string]interface{})
+	assert.Equal(t, "2021-03-04T10:01:00Z", totals["end"])
+	assert.Equal(t, int64(2), totals["succeeded"])
+	histogram := driver.params[3]["workloads"].([]interface{})[0].(map[string]interface{})["histogram"].(map[string]interface{})
+	assert.Equal(t, int64(1), histogram["count"])
+	assert.Len(t, histogram["values"], len(resultStorePercentiles))
+	assert.True(t, driver.closed)
+}
+
+// Records the database and parameters of each statement written through it
+type statementDriver struct {
+	neo4j.Driver
+	databases []string
+	params    []map[string]interface{}
+	closed    bool
+}
+
+func (d *statementDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	d.databases = append(d.databases, config.DatabaseName)
+	return &statementSession{driver: d}
+}
+
+func (d *statementDriver) Close() error {
+	d.closed = true
+	return nil
+}
+
+type statementSession struct {
+	neo4j.Session
+	driver *statementDriver
+}
+
+func (s *statementSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(&statementTx{driver: s.driver})
+}
+
+func (s *statementSession) Close() error {
+	return nil
+}
+
+type statementTx struct {
+	neo4j.Transaction
+	driver *statementDriver
+}
+
+func (tx *statementTx) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	tx.driver.params = append(tx.driver.params, params)
+	return consumedResult{}, nil
+}
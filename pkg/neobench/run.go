@@ -0,0 +1,393 @@
+package neobench
+
+import (
+	"context"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A benchmark run, for running neobench from Go programs and tests rather than the command line; see Run.
+// The neobench command is a thin layer over this, so most fields match a flag, and their zero values match
+// leaving the flag out.
+type BenchmarkConfig struct {
+	Driver neo4j.Driver
+	// If set, each transaction runs on a new connection from Dial rather than a session from Driver, like -C
+	Dial         DriverFactory
+	DatabaseName string
+	// Describes the run in results, eg. the command line that started it
+	Scenario string
+	Workload Workload
+
+	Clients  int
+	Duration time.Duration
+	// If set and below Clients, clients are run as virtual users on this many executors rather than a goroutine
+	// each, for more clients than the machine could run goroutines and connections for; see runVirtualUsers
+	Executors int
+	// Transactions per second across all clients; if set the run measures latency, otherwise throughput
+	Rate    float64
+	Arrival Arrival
+	// Only applies when measuring throughput, Rate sets when transactions start otherwise
+	ThinkTime ThinkTime
+	// Phases to run in turn, each with its own clients, duration and rate; see ParseSchedule and RateSteps
+	Schedule []Phase
+	// If set, searches for the highest rate meeting the target, starting from Rate; see RateSearch
+	LatencyTarget *LatencyTarget
+	SearchSteps   int
+	// How long to run at each rate the search tries, before measuring it
+	Settle time.Duration
+
+	// See the Worker fields of the same name
+	MaxBacklog        int64
+	FailOnDeadlock    bool
+	ForceWriteRouting bool
+	Bookmarks         BookmarkMode
+	ProfileSampleRate float64
+	Consume           ConsumeMode
+	SessionReuse      int
+
+	// How long to wait for workers still in a transaction once the run is over, before interrupting the
+	// transaction; defaults to 10s
+	ShutdownTimeout time.Duration
+	// If set, serves live metrics on this address at /metrics while the run goes on
+	PrometheusAddr string
+	// If set, OnProgress is called every ProgressInterval with the results since the last call, completeness
+	// being how far into the current run or phase it is, from 0 to 1
+	ProgressInterval time.Duration
+	OnProgress       func(completeness float64, checkpoint Result)
+	// Get the results of each progress interval, and each transaction as it completes
+	Sinks     []IntervalSink
+	Observers []TransactionObserver
+	// If set, workers don't start new transactions while it is paused
+	Pause *PauseControl
+	// If set, follows the run so its stats can be read and its rate changed while it goes on
+	Control *RunControl
+	// If set, the result includes the connection pool stats it counted during the run
+	PoolMetrics *PoolMetrics
+	// Defaults to discarding log messages
+	Logger *Logger
+
+	// Serves PrometheusAddr for the whole run, set by Run
+	prometheus *prometheusEndpoint
+}
+
+// Runs the benchmark cfg describes until it is done or ctx is cancelled, whichever comes first; cancelling
+// stops the run early, but still returns the results up to that point
+func Run(ctx context.Context, cfg BenchmarkConfig) (Result, error) {
+	if cfg.Driver == nil && cfg.Dial == nil {
+		return Result{}, fmt.Errorf("benchmark needs a driver, or a factory for them")
+	}
+	if cfg.Schedule == nil && (cfg.Clients < 1 || cfg.Duration <= 0) {
+		return Result{}, fmt.Errorf("benchmark needs at least one client and a duration, got %d clients for %s", cfg.Clients, cfg.Duration)
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 10 * time.Second
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = &Logger{Level: LogQuiet, Stream: ioutil.Discard, now: time.Now}
+	}
+
+	if cfg.PrometheusAddr != "" {
+		endpoint, err := startPrometheusEndpoint(cfg.PrometheusAddr, cfg.DatabaseName, cfg.Logger)
+		if err != nil {
+			return Result{}, err
+		}
+		defer endpoint.Close(cfg.ShutdownTimeout)
+		cfg.prometheus = endpoint
+	}
+
+	start := time.Now()
+	var result Result
+	var err error
+	switch {
+	case cfg.Schedule != nil:
+		result, err = runSchedule(ctx, cfg)
+	case cfg.LatencyTarget != nil:
+		result, err = runRateSearch(ctx, cfg)
+	default:
+		result, _, err = runPhase(ctx, cfg, cfg.Duration, cfg.Clients, cfg.Rate, cfg.ThinkTime, cfg.Sinks, cfg.Observers)
+	}
+	result.Start, result.End = start, time.Now()
+	return result, err
+}
+
+// Runs each phase of the schedule in turn, stopping early if cancelled; the result has each phase's scripts
+// reported separately
+func runSchedule(ctx context.Context, cfg BenchmarkConfig) (Result, error) {
+	total := NewResult(cfg.DatabaseName, cfg.Scenario)
+	for _, phase := range cfg.Schedule {
+		cfg.Logger.Infof("starting %s, %s", phase.Name, phase)
+		cfg.Workload.Clients = phase.Clients
+		result, interrupted, err := runPhase(ctx, cfg, phase.Duration(), phase.Clients, phase.Rate, cfg.ThinkTime, cfg.Sinks, cfg.Observers)
+		if err != nil {
+			return total, err
+		}
+		total.AddPhase(phase, result)
+		if interrupted {
+			break
+		}
+	}
+	return total, nil
+}
+
+// Searches for the highest rate meeting the latency target; returns the result measured at that rate
+func runRateSearch(ctx context.Context, cfg BenchmarkConfig) (Result, error) {
+	target := *cfg.LatencyTarget
+	search := NewRateSearch(cfg.Rate, cfg.SearchSteps, 0.05)
+	var best Result
+	for {
+		rate, ok := search.Next()
+		if !ok {
+			break
+		}
+		if cfg.Settle > 0 {
+			cfg.Logger.Infof("settling at %.3f tx/s for %s", rate, cfg.Settle)
+			_, interrupted, err := runPhase(ctx, cfg, cfg.Settle, cfg.Clients, rate, ThinkTime{}, nil, nil)
+			if err != nil {
+				return best, err
+			}
+			if interrupted {
+				break
+			}
+		}
+		cfg.Logger.Infof("measuring at %.3f tx/s for %s", rate, cfg.Duration)
+		result, interrupted, err := runPhase(ctx, cfg, cfg.Duration, cfg.Clients, rate, ThinkTime{}, cfg.Sinks, cfg.Observers)
+		if err != nil {
+			return best, err
+		}
+		if interrupted {
+			break
+		}
+		met, worst := target.Met(result)
+		verdict := "missed"
+		if met {
+			verdict = "met"
+		}
+		cfg.Logger.Infof("%.3f tx/s %s %s, worst script at p%g was %s", rate, verdict, target, target.Percentile, worst)
+		search.Record(rate, met)
+		if met && rate == search.Best() {
+			best = result
+		}
+	}
+	if search.Best() == 0 {
+		return best, fmt.Errorf("no rate tried met %s", target)
+	}
+	cfg.Logger.Infof("highest rate meeting %s: %.3f tx/s", target, search.Best())
+	return best, nil
+}
+
+// Runs numClients workers for runtime, in latency mode if rate is set. Returns true if the run was cancelled,
+// or a worker crashed, before runtime was up.
+func runPhase(ctx context.Context, cfg BenchmarkConfig, runtime time.Duration, numClients int, rate float64, thinkTime ThinkTime,
+	sinks []IntervalSink, observers []TransactionObserver) (Result, bool, error) {
+	stopCh := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(stopCh) }) }
+	defer stop()
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-stopCh:
+		}
+	}()
+	poolAtStart := cfg.PoolMetrics.Stats()
+
+	ratePerWorkerDuration := time.Duration(0)
+	var rateControl *RateControl
+	if rate > 0 {
+		ratePerWorkerDuration = TotalRatePerSecondToDurationPerClient(numClients, rate)
+		rateControl = NewRateControl(numClients, rate)
+	}
+
+	// Transactions still running at the deadline are timed out by the server, see Worker.TxDeadline
+	deadline := time.Now().Add(runtime)
+	numRecorders := numClients
+	virtualUsers := cfg.Executors > 0 && cfg.Executors < numClients
+	if virtualUsers {
+		numRecorders = cfg.Executors
+	}
+	resultChan := make(chan WorkerResult, numRecorders)
+	recorders := make([]*ResultRecorder, 0, numRecorders)
+	for i := 0; i < numRecorders; i++ {
+		recorder := NewResultRecorder(int64(i))
+		for _, observer := range observers {
+			recorder.Observe(observer)
+		}
+		recorders = append(recorders, recorder)
+	}
+	sharedBookmarks := NewSharedBookmarks()
+	var wg sync.WaitGroup
+	workers := make([]*Worker, 0, numClients)
+	var users []*Worker
+	var userClients []clientState
+	for i := 0; i < numClients; i++ {
+		worker := NewWorker(cfg.Driver, int64(i))
+		if cfg.Dial != nil {
+			worker = NewConnectPerTransactionWorker(cfg.Dial, int64(i))
+		}
+		worker.Arrival = cfg.Arrival
+		worker.ThinkTime = thinkTime
+		worker.MaxBacklog = cfg.MaxBacklog
+		worker.FailOnDeadlock = cfg.FailOnDeadlock
+		worker.TxDeadline = deadline
+		worker.ForceWriteRouting = cfg.ForceWriteRouting
+		worker.Bookmarks = cfg.Bookmarks
+		worker.SharedBookmarks = sharedBookmarks
+		worker.ProfileSampleRate = cfg.ProfileSampleRate
+		worker.Consume = cfg.Consume
+		worker.SessionReuse = cfg.SessionReuse
+		worker.Rate = rateControl
+		workers = append(workers, worker)
+		workerId := i
+		clientWork := cfg.Workload.NewClientAt(i)
+		if virtualUsers {
+			users = append(users, worker)
+			userClients = append(userClients, clientState{
+				wrk:             clientWork,
+				databaseName:    cfg.DatabaseName,
+				transactionRate: ratePerWorkerDuration,
+			})
+			continue
+		}
+		recorder := recorders[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg.Logger.Debugf("worker %d started", workerId)
+			result := worker.RunBenchmark(clientWork, cfg.DatabaseName, ratePerWorkerDuration, 0, stopCh, cfg.Pause, recorder)
+			cfg.Logger.Debugf("worker %d stopped", workerId)
+			resultChan <- result
+			if result.Error != nil {
+				cfg.Logger.Errorf("worker %d crashed: %s", workerId, result.Error)
+				stop()
+			}
+		}()
+	}
+
+	if virtualUsers {
+		cfg.Logger.Debugf("running %d clients as virtual users on %d executors", numClients, cfg.Executors)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runVirtualUsers(users, userClients, recorders, stopCh, cfg.Pause, resultChan, func(executor int, err error) {
+				cfg.Logger.Errorf("executor %d crashed: %s", executor, err)
+				stop()
+			})
+		}()
+	}
+
+	cfg.Control.startPhase(cfg, recorders, rateControl)
+	cfg.prometheus.startPhase(recorders)
+
+	interrupted, throughput := awaitCompletion(cfg, stopCh, deadline, recorders, sinks)
+	stop()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(cfg.ShutdownTimeout):
+		cfg.Logger.Warningf("workers still in a transaction %s after stopping, interrupting them and reporting without their in-flight transactions", cfg.ShutdownTimeout)
+		for _, worker := range workers {
+			worker.Abort()
+		}
+		select {
+		case <-done:
+		case <-time.After(cfg.ShutdownTimeout):
+			// Only sessions of drivers other than ours can't be interrupted
+			cfg.Logger.Errorf("workers still in a transaction %s after interrupting them, leaving them behind", cfg.ShutdownTimeout)
+		}
+	}
+
+	result := collectResults(cfg, recorders, resultChan)
+	result.Pool = cfg.PoolMetrics.Stats().Sub(poolAtStart)
+	result.Throughput = throughput
+	return result, interrupted, nil
+}
+
+// Combines the results from workers that have stopped; workers that are still stuck in a transaction get
+// their results taken from their recorder instead
+func collectResults(cfg BenchmarkConfig, recorders []*ResultRecorder, resultChan chan WorkerResult) Result {
+	results := make([]WorkerResult, 0, len(recorders))
+	stopped := make(map[int64]bool)
+	for len(resultChan) > 0 {
+		res := <-resultChan
+		stopped[res.WorkerId] = true
+		results = append(results, res)
+	}
+	now := time.Now()
+	for i, recorder := range recorders {
+		if !stopped[int64(i)] {
+			results = append(results, recorder.Complete(now))
+		}
+	}
+
+	total := NewResult(cfg.DatabaseName, cfg.Scenario)
+	for _, res := range results {
+		if res.Error != nil {
+			cfg.Logger.Errorf("Worker failed: %v", res.Error)
+			continue
+		}
+		total.Add(res)
+		total.Workers = append(total.Workers, summarizeWorker(res))
+	}
+	sort.Slice(total.Workers, func(i, j int) bool { return total.Workers[i].WorkerId < total.Workers[j].WorkerId })
+	return total
+}
+
+// Waits for the deadline, reporting progress along the way; returns true if stopped before then, and the
+// throughput of each progress interval
+func awaitCompletion(cfg BenchmarkConfig, stopCh <-chan struct{}, deadline time.Time, recorders []*ResultRecorder,
+	sinks []IntervalSink) (interrupted bool, throughput ThroughputStats) {
+	lastProgressReport := time.Now()
+	lastPool := cfg.PoolMetrics.Stats()
+	nextProgressReport := lastProgressReport.Add(cfg.ProgressInterval)
+	originalDelta := deadline.Sub(time.Now()).Seconds()
+	for {
+		select {
+		case <-stopCh:
+			return true, throughput
+		default:
+		}
+
+		now := time.Now()
+		delta := deadline.Sub(now)
+		if delta < 2*time.Second {
+			select {
+			case <-stopCh:
+				return true, throughput
+			case <-time.After(delta):
+				return false, throughput
+			}
+		}
+
+		if cfg.ProgressInterval > 0 && now.After(nextProgressReport) {
+			nextProgressReport = nextProgressReport.Add(cfg.ProgressInterval)
+			checkpointTime := time.Now()
+			checkpoint := NewResult(cfg.DatabaseName, cfg.Scenario)
+			for _, r := range recorders {
+				checkpoint.Add(r.ProgressReport(checkpointTime))
+			}
+			pool := cfg.PoolMetrics.Stats()
+			checkpoint.Pool, lastPool = pool.Sub(lastPool), pool
+			throughput.record(checkpoint.TotalRate())
+
+			if cfg.OnProgress != nil {
+				cfg.OnProgress(1-delta.Seconds()/originalDelta, checkpoint)
+			}
+			for _, sink := range sinks {
+				if err := sink.WriteInterval(lastProgressReport, checkpointTime, checkpoint); err != nil {
+					cfg.Logger.Errorf("failed to record interval: %s", err)
+				}
+			}
+			lastProgressReport = checkpointTime
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+}
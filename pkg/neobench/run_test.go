@@ -0,0 +1,238 @@
+package neobench
+
+import (
+	"context"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunReportsProgressAndStopsWhenCancelled(t *testing.T) {
+	script, err := Parse("runtest", `RETURN 1;`, 1)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var progress []float64
+
+	result, err := Run(ctx, BenchmarkConfig{
+		Driver:       instantDriver{},
+		DatabaseName: "neo4j",
+		Workload:     Workload{Scripts: NewScripts(script), Rand: rand.New(rand.NewSource(1337))},
+		Clients:      2,
+		Duration:     time.Minute,
+		Rate:         100,
+		// Stops the run at the first progress report, well before the duration is up
+		ProgressInterval: 50 * time.Millisecond,
+		OnProgress: func(completeness float64, checkpoint Result) {
+			progress = append(progress, completeness)
+			cancel()
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, progress, 1)
+	assert.Less(t, progress[0], 0.1)
+	assert.Greater(t, result.TotalSucceeded(), int64(0))
+	assert.Equal(t, int64(0), result.TotalFailed())
+	assert.True(t, result.End.Sub(result.Start) < 10*time.Second)
+}
+
+func TestRunNeedsClientsAndDuration(t *testing.T) {
+	_, err := Run(context.Background(), BenchmarkConfig{Driver: instantDriver{}, Clients: 1})
+	assert.Error(t, err)
+	_, err = Run(context.Background(), BenchmarkConfig{Clients: 1, Duration: time.Second})
+	assert.Error(t, err)
+}
+
+func TestPrometheusEndpointLastsTheWholeSchedule(t *testing.T) {
+	script, err := Parse("runtest", `RETURN 1;`, 1)
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	assert.NoError(t, listener.Close())
+	// One scrape in each phase
+	scrapes := make(chan int, 2)
+	go func() {
+		for _, wait := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+			time.Sleep(wait)
+			resp, err := http.Get("http://" + addr + "/metrics")
+			if err != nil {
+				scrapes <- 0
+				continue
+			}
+			resp.Body.Close()
+			scrapes <- resp.StatusCode
+		}
+	}()
+
+	_, err = Run(context.Background(), BenchmarkConfig{
+		Driver:         instantDriver{},
+		DatabaseName:   "neo4j",
+		Workload:       Workload{Scripts: NewScripts(script), Rand: rand.New(rand.NewSource(1337))},
+		PrometheusAddr: addr,
+		Schedule: []Phase{
+			{Name: "phase 1", Start: 0, End: 200 * time.Millisecond, Clients: 1, Rate: 100},
+			{Name: "phase 2", Start: 200 * time.Millisecond, End: 400 * time.Millisecond, Clients: 2, Rate: 100},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, <-scrapes)
+	assert.Equal(t, http.StatusOK, <-scrapes)
+	// Shut down with the run, so the address is free again
+	listener, err = net.Listen("tcp", addr)
+	assert.NoError(t, err)
+	listener.Close()
+}
+
+func TestRunInterruptsWorkersStuckInATransaction(t *testing.T) {
+	script, err := Parse("runtest", `CREATE (:Stuck);`, 1)
+	assert.NoError(t, err)
+	driver := &stuckDriver{}
+
+	result, err := Run(context.Background(), BenchmarkConfig{
+		Driver:          driver,
+		DatabaseName:    "neo4j",
+		Workload:        Workload{Scripts: NewScripts(script), Rand: rand.New(rand.NewSource(1337))},
+		Clients:         2,
+		Duration:        50 * time.Millisecond,
+		ShutdownTimeout: 50 * time.Millisecond,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.TotalFailed())
+	// Every worker left its transaction and closed its session, so none is still running
+	assert.Equal(t, int32(0), atomic.LoadInt32(&driver.inTransaction))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&driver.opened))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&driver.closed))
+}
+
+// Never answers; transactions only end when interrupted
+type stuckDriver struct {
+	neo4j.Driver
+	inTransaction, opened, closed int32
+}
+
+func (d *stuckDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	atomic.AddInt32(&d.opened, 1)
+	return &stuckSession{driver: d, interrupted: make(chan struct{})}
+}
+
+type stuckSession struct {
+	neo4j.Session
+	driver      *stuckDriver
+	interrupted chan struct{}
+	once        sync.Once
+}
+
+func (s *stuckSession) Interrupt() {
+	s.once.Do(func() { close(s.interrupted) })
+}
+
+func (s *stuckSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	atomic.AddInt32(&s.driver.inTransaction, 1)
+	defer atomic.AddInt32(&s.driver.inTransaction, -1)
+	<-s.interrupted
+	return nil, context.Canceled
+}
+
+func (s *stuckSession) Close() error {
+	atomic.AddInt32(&s.driver.closed, 1)
+	return nil
+}
+
+// Completes every transaction right away, returning one row
+type instantDriver struct {
+	neo4j.Driver
+}
+
+func (instantDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return &instantSession{rowsSession{rows: []int64{1}}}
+}
+
+type instantSession struct {
+	rowsSession
+}
+
+func (s *instantSession) Close() error {
+	return nil
+}
+
+func (s *instantSession) LastBookmark() string {
+	return ""
+}
+
+func TestVirtualUsersShareExecutors(t *testing.T) {
+	script, err := Parse("read", "RETURN $client_id;", 1)
+	assert.NoError(t, err)
+	driver := &concurrencyDriver{clients: make(map[interface{}]bool)}
+
+	result, err := Run(context.Background(), BenchmarkConfig{
+		Driver:    driver,
+		Workload:  Workload{Scripts: NewScripts(script), Rand: rand.New(rand.NewSource(1337))},
+		Clients:   2000,
+		Executors: 8,
+		Duration:  500 * time.Millisecond,
+		ThinkTime: ThinkTime{Distribution: "fixed", Min: 100 * time.Millisecond},
+	})
+
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, driver.maxActive, int64(8))
+	assert.Len(t, driver.clients, 2000)
+	// Each user runs every 100ms or so, so roughly 5 times each
+	succeeded := result.Scripts["read"].Succeeded
+	assert.Greater(t, succeeded, int64(2000*2))
+	assert.Less(t, succeeded, int64(2000*7))
+}
+
+// Records how many transactions run at once, and the clients that ran them
+type concurrencyDriver struct {
+	neo4j.Driver
+	mut       sync.Mutex
+	active    int64
+	maxActive int64
+	clients   map[interface{}]bool
+}
+
+func (d *concurrencyDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return &concurrencySession{driver: d}
+}
+
+type concurrencySession struct {
+	instantSession
+	driver *concurrencyDriver
+}
+
+func (s *concurrencySession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	s.driver.mut.Lock()
+	s.driver.active++
+	if s.driver.active > s.driver.maxActive {
+		s.driver.maxActive = s.driver.active
+	}
+	s.driver.mut.Unlock()
+	defer func() {
+		s.driver.mut.Lock()
+		s.driver.active--
+		s.driver.mut.Unlock()
+	}()
+	return work(&concurrencyTx{driver: s.driver})
+}
+
+type concurrencyTx struct {
+	neo4j.Transaction
+	driver *concurrencyDriver
+}
+
+func (tx *concurrencyTx) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	tx.driver.mut.Lock()
+	tx.driver.clients[params["client_id"]] = true
+	tx.driver.mut.Unlock()
+	return &rowsResult{rows: []int64{1}, next: -1}, nil
+}
@@ -0,0 +1,121 @@
+package neobench
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Columns of a SampleFile, in order
+const sampleHeader = "time_us\tworker\tscript\tlatency_us\tattempts\tsucceeded\tfailure_group\n"
+
+// Writes every transaction as a row of one tab separated file with a header, for analysis with pandas, duckdb and
+// the like rather than reading the histograms. Each row is:
+//
+//	<unix time it completed, microseconds> <worker id> <script> <latency in microseconds> <attempts> <true|false> <failure group>
+//
+// Unlike TransactionLog, all workers share the one file: each fills a buffer of its own and only takes turns
+// with the others to write it out once it's full, so workers rarely wait on each other.
+type SampleFile struct {
+	samplingRate float64
+	mut          sync.Mutex
+	file         *os.File
+	// First write error, reported on Close rather than slowing the workers down
+	err error
+	// Indexed by worker id; each entry is only touched by its own worker, until Close
+	workers []*sampleWorker
+}
+
+type sampleWorker struct {
+	buf  []byte
+	rand *rand.Rand
+}
+
+const sampleBufferSize = 64 * 1024
+
+func NewSampleFile(path string, numWorkers int, samplingRate float64, seed int64) (*SampleFile, error) {
+	if samplingRate <= 0 || samplingRate > 1 {
+		return nil, fmt.Errorf("sampling rate must be greater than 0 and at most 1, got %f", samplingRate)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sample file at %s: %s", path, err)
+	}
+	if _, err := f.WriteString(sampleHeader); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to write sample file %s: %s", path, err)
+	}
+	s := &SampleFile{samplingRate: samplingRate, file: f}
+	for i := 0; i < numWorkers; i++ {
+		s.workers = append(s.workers, &sampleWorker{
+			buf:  make([]byte, 0, sampleBufferSize),
+			rand: rand.New(rand.NewSource(seed + int64(i))),
+		})
+	}
+	return s, nil
+}
+
+func (s *SampleFile) ObserveTransaction(workerId int64, scriptName string, latency time.Duration, outcome TransactionOutcome) {
+	w := s.workers[workerId]
+	if s.samplingRate < 1 && w.rand.Float64() >= s.samplingRate {
+		return
+	}
+
+	// Built by hand rather than with fmt to keep the cost on the worker low
+	buf := w.buf
+	buf = strconv.AppendInt(buf, time.Now().UnixNano()/1000, 10)
+	buf = append(buf, '\t')
+	buf = strconv.AppendInt(buf, workerId, 10)
+	buf = append(buf, '\t')
+	buf = appendSampleField(buf, scriptName)
+	buf = append(buf, '\t')
+	buf = strconv.AppendInt(buf, latency.Microseconds(), 10)
+	buf = append(buf, '\t')
+	buf = strconv.AppendInt(buf, int64(outcome.Attempts), 10)
+	buf = append(buf, '\t')
+	buf = strconv.AppendBool(buf, outcome.Succeeded)
+	buf = append(buf, '\t')
+	buf = appendSampleField(buf, outcome.FailureGroup)
+	buf = append(buf, '\n')
+	w.buf = buf
+
+	if len(w.buf) >= sampleBufferSize-1024 {
+		s.write(w)
+	}
+}
+
+// Keeps values from breaking up rows or columns
+func appendSampleField(buf []byte, value string) []byte {
+	if strings.ContainsAny(value, "\t\n\r") {
+		value = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(value)
+	}
+	return append(buf, value...)
+}
+
+func (s *SampleFile) write(w *sampleWorker) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.err == nil {
+		_, s.err = s.file.Write(w.buf)
+	}
+	w.buf = w.buf[:0]
+}
+
+// Writes out what the workers have buffered and closes the file; must only be called once the workers have stopped
+func (s *SampleFile) Close() error {
+	for _, w := range s.workers {
+		s.write(w)
+	}
+	err := s.err
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write sample file %s: %s", s.file.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-samples")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "samples.tsv")
+
+	samples, err := NewSampleFile(path, 2, 1, 1337)
+	assert.NoError(t, err)
+	samples.ObserveTransaction(1, "read", 1500*time.Microsecond, TransactionOutcome{Succeeded: true, Attempts: 1})
+	samples.ObserveTransaction(0, "write", 20*time.Microsecond, TransactionOutcome{FailureGroup: "Neo.TransientError\twith tab", Attempts: 3})
+	assert.NoError(t, samples.Close())
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"time_us", "worker", "script", "latency_us", "attempts", "succeeded", "failure_group"}, strings.Split(lines[0], "\t"))
+	// Workers write out their buffers in turn as the file closes
+	assert.Equal(t, []string{"0", "write", "20", "3", "false", "Neo.TransientError with tab"}, strings.Split(lines[1], "\t")[1:])
+	assert.Equal(t, []string{"1", "read", "1500", "1", "true", ""}, strings.Split(lines[2], "\t")[1:])
+}
+
+func TestSampleFileWritesFullBuffersAsItGoes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-samples")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "samples.tsv")
+
+	samples, err := NewSampleFile(path, 1, 1, 1337)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		samples.ObserveTransaction(0, "read", time.Millisecond, TransactionOutcome{Succeeded: true, Attempts: 1})
+	}
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Greater(t, info.Size(), int64(len(sampleHeader)))
+	assert.NoError(t, samples.Close())
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 10001, strings.Count(string(content), "\n"))
+}
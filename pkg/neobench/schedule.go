@@ -0,0 +1,232 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// One phase of a load schedule, eg. "5m-15m: 50 clients @500tps"
+type Phase struct {
+	Name    string
+	Start   time.Duration
+	End     time.Duration
+	Clients int
+	// Total transactions per second across all clients, 0 means as fast as possible
+	Rate float64
+}
+
+func (p Phase) Duration() time.Duration {
+	return p.End - p.Start
+}
+
+func (p Phase) String() string {
+	s := fmt.Sprintf("%s-%s: %d clients", p.Start, p.End, p.Clients)
+	if p.Rate > 0 {
+		s += fmt.Sprintf(" @%gtps", p.Rate)
+	}
+	return s
+}
+
+// Parses a schedule of phases separated by semicolons or newlines, like "0-5m: 10 clients @100tps; 5m-15m: 50 clients @500tps".
+// If spec is the path to a file, the schedule is read from that file. Phases must follow each other without gaps,
+// and either all or none of them must set a rate.
+func ParseSchedule(spec string) ([]Phase, error) {
+	if info, err := os.Stat(spec); err == nil && !info.IsDir() {
+		content, err := ioutil.ReadFile(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schedule: %s", err)
+		}
+		spec = string(content)
+	}
+	var phases []Phase
+	for _, raw := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+		phase, err := parsePhase(raw)
+		if err != nil {
+			return nil, err
+		}
+		phase.Name = fmt.Sprintf("phase %d", len(phases)+1)
+		expectedStart := time.Duration(0)
+		if len(phases) > 0 {
+			expectedStart = phases[len(phases)-1].End
+		}
+		if phase.Start != expectedStart {
+			return nil, fmt.Errorf("schedule phase '%s' should start at %s, phases must follow each other without gaps", raw, expectedStart)
+		}
+		if len(phases) > 0 && (phase.Rate > 0) != (phases[0].Rate > 0) {
+			return nil, fmt.Errorf("schedule phase '%s': either all phases or none must set a rate", raw)
+		}
+		phases = append(phases, phase)
+	}
+	if len(phases) == 0 {
+		return nil, fmt.Errorf("schedule has no phases, expected eg. \"0-5m: 10 clients @100tps; 5m-15m: 50 clients @500tps\"")
+	}
+	return phases, nil
+}
+
+func parsePhase(raw string) (Phase, error) {
+	invalid := fmt.Errorf("invalid schedule phase '%s', expected eg. \"0-5m: 10 clients @100tps\"", raw)
+	parts := strings.SplitN(raw, ":", 2)
+	if len(parts) != 2 {
+		return Phase{}, invalid
+	}
+	bounds := strings.SplitN(strings.TrimSpace(parts[0]), "-", 2)
+	if len(bounds) != 2 {
+		return Phase{}, invalid
+	}
+	start, err := parsePhaseOffset(bounds[0])
+	if err != nil {
+		return Phase{}, invalid
+	}
+	end, err := parsePhaseOffset(bounds[1])
+	if err != nil || end <= start {
+		return Phase{}, invalid
+	}
+	phase := Phase{Start: start, End: end}
+
+	fields := strings.Fields(parts[1])
+	if len(fields) < 2 || (fields[1] != "clients" && fields[1] != "client") {
+		return Phase{}, invalid
+	}
+	phase.Clients, err = strconv.Atoi(fields[0])
+	if err != nil || phase.Clients < 1 {
+		return Phase{}, invalid
+	}
+	switch len(fields) {
+	case 2:
+	case 3:
+		if !strings.HasPrefix(fields[2], "@") || !strings.HasSuffix(fields[2], "tps") {
+			return Phase{}, invalid
+		}
+		phase.Rate, err = strconv.ParseFloat(strings.TrimSuffix(fields[2][1:], "tps"), 64)
+		if err != nil || phase.Rate <= 0 {
+			return Phase{}, invalid
+		}
+	default:
+		return Phase{}, invalid
+	}
+	return phase, nil
+}
+
+// Offsets are go durations, except a bare 0 is allowed for the start of the run
+func parsePhaseOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Adds the results of one phase, with script names prefixed by the phase name so each phase is reported separately
+func (r *Result) AddPhase(phase Phase, phaseResult Result) {
+	for _, script := range phaseResult.Scripts {
+		name := fmt.Sprintf("%s/%s", phase.Name, script.ScriptName)
+		renamed := *script
+		renamed.ScriptName = name
+		r.Scripts[name] = &renamed
+	}
+	for name, group := range phaseResult.FailedByErrorGroup {
+		existing, found := r.FailedByErrorGroup[name]
+		if found {
+			group = FailureGroup{
+				Count:        existing.Count + group.Count,
+				FirstFailure: existing.FirstFailure,
+			}
+		}
+		r.FailedByErrorGroup[name] = group
+	}
+	if phaseResult.ConnectLatencies != nil {
+		if r.ConnectLatencies == nil {
+			r.ConnectLatencies = hdrhistogram.New(0, 60*60*1000000, 3)
+		}
+		r.ConnectLatencies.Merge(phaseResult.ConnectLatencies)
+	}
+	if phaseResult.QueueTimes != nil {
+		r.QueueTimes.Merge(phaseResult.QueueTimes)
+		r.Backlog.Merge(phaseResult.Backlog)
+	}
+	r.Dropped += phaseResult.Dropped
+	r.SessionsOpened += phaseResult.SessionsOpened
+	if phaseResult.AcquireTimes != nil {
+		r.AcquireTimes.Merge(phaseResult.AcquireTimes)
+	}
+	r.Pool = r.Pool.Add(phaseResult.Pool)
+	r.Throughput = r.Throughput.Add(phaseResult.Throughput)
+}
+
+// Builds a schedule of fixed-rate steps with the same number of clients, for --rate-steps
+func RateSteps(rates []float64, clients int, stepDuration time.Duration) ([]Phase, error) {
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no rates given for --rate-steps")
+	}
+	phases := make([]Phase, 0, len(rates))
+	for i, rate := range rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("--rate-steps must all be greater than 0, got %g", rate)
+		}
+		start := time.Duration(i) * stepDuration
+		phases = append(phases, Phase{
+			Name:    fmt.Sprintf("step %d", i+1),
+			Start:   start,
+			End:     start + stepDuration,
+			Clients: clients,
+			Rate:    rate,
+		})
+	}
+	return phases, nil
+}
+
+// Writes a table with one row per phase, combining the scripts in each, so the throughput-latency curve of
+// a stepped run can be read off directly
+func WriteStepSummary(w io.Writer, phases []Phase, result Result, f LatencyFormat) error {
+	s := strings.Builder{}
+	s.WriteString("Step summary:\n")
+	tw := tabwriter.NewWriter(&s, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "  Step\tTarget\tAchieved\tP50\tP95\tP99\tFailed\n")
+	for _, phase := range phases {
+		totals := phaseTotals(phase, result)
+		if totals.succeeded+totals.failed == 0 {
+			// Interrupted before this step ran
+			continue
+		}
+		histo := totals.latencies
+		_, _ = fmt.Fprintf(tw, "  %s\t%.3f\t%.3f\t%s\t%s\t%s\t%.3f %%\n", phase.Name, phase.Rate, totals.rate,
+			f.format(float64(histo.ValueAtQuantile(50))), f.format(float64(histo.ValueAtQuantile(95))),
+			f.format(float64(histo.ValueAtQuantile(99))), 100*float64(totals.failed)/float64(totals.succeeded+totals.failed))
+	}
+	_ = tw.Flush()
+	_, err := fmt.Fprint(w, s.String())
+	return err
+}
+
+// The scripts of one phase of a scheduled run combined, see AddPhase
+type phaseTotal struct {
+	latencies         *hdrhistogram.Histogram
+	rate              float64
+	succeeded, failed int64
+}
+
+func phaseTotals(phase Phase, result Result) phaseTotal {
+	prefix := phase.Name + "/"
+	totals := phaseTotal{latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+	for name, script := range result.Scripts {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		totals.latencies.Merge(script.Latencies)
+		totals.rate += script.Rate
+		totals.succeeded += script.Succeeded
+		totals.failed += script.Failed
+	}
+	return totals
+}
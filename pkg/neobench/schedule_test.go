@@ -0,0 +1,101 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	phases, err := ParseSchedule("0-5m: 10 clients @100tps; 5m-15m: 50 clients @500tps;\n15m-16m: 1 client @2.5tps")
+	assert.NoError(t, err)
+	assert.Equal(t, []Phase{
+		{Name: "phase 1", Start: 0, End: 5 * time.Minute, Clients: 10, Rate: 100},
+		{Name: "phase 2", Start: 5 * time.Minute, End: 15 * time.Minute, Clients: 50, Rate: 500},
+		{Name: "phase 3", Start: 15 * time.Minute, End: 16 * time.Minute, Clients: 1, Rate: 2.5},
+	}, phases)
+	assert.Equal(t, 10*time.Minute, phases[1].Duration())
+
+	phases, err = ParseSchedule("0-30s: 4 clients")
+	assert.NoError(t, err)
+	assert.Equal(t, []Phase{{Name: "phase 1", Start: 0, End: 30 * time.Second, Clients: 4}}, phases)
+
+	for _, invalid := range []string{
+		"",
+		"0-5m",
+		"0-5m: ten clients",
+		"0-5m: 10 clients 100tps",
+		"5m-0: 10 clients",
+		"0-5m: 10 clients; 6m-10m: 10 clients",
+		"0-5m: 10 clients; 5m-10m: 10 clients @10tps",
+	} {
+		_, err = ParseSchedule(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseScheduleFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-schedule")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schedule")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("# ramp\n0-1m: 1 clients\n1m-2m: 8 clients\n"), 0644))
+
+	phases, err := ParseSchedule(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(phases))
+	assert.Equal(t, 8, phases[1].Clients)
+}
+
+func TestAddPhaseKeepsPhasesSeparate(t *testing.T) {
+	total := NewResult("neo4j", "")
+	for i, phase := range []Phase{{Name: "phase 1"}, {Name: "phase 2"}} {
+		result := NewResult("neo4j", "")
+		result.Scripts["reads"] = &ScriptResult{
+			ScriptName: "reads",
+			Succeeded:  int64(10 * (i + 1)),
+			Latencies:  hdrhistogram.New(0, 60*60*1000000, 3),
+		}
+		result.FailedByErrorGroup["ServiceUnavailable"] = FailureGroup{Count: 1}
+		total.AddPhase(phase, result)
+	}
+
+	assert.Equal(t, int64(10), total.Scripts["phase 1/reads"].Succeeded)
+	assert.Equal(t, int64(20), total.Scripts["phase 2/reads"].Succeeded)
+	assert.Equal(t, "phase 2/reads", total.Scripts["phase 2/reads"].ScriptName)
+	assert.Equal(t, int64(2), total.FailedByErrorGroup["ServiceUnavailable"].Count)
+}
+
+func TestStepSummaryCombinesScriptsPerStep(t *testing.T) {
+	steps, err := RateSteps([]float64{100, 200}, 4, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, Phase{Name: "step 2", Start: time.Minute, End: 2 * time.Minute, Clients: 4, Rate: 200}, steps[1])
+	_, err = RateSteps([]float64{100, 0}, 4, time.Minute)
+	assert.Error(t, err)
+
+	total := NewResult("neo4j", "")
+	for i, step := range steps {
+		result := NewResult("neo4j", "")
+		for _, name := range []string{"reads", "writes"} {
+			histo := hdrhistogram.New(0, 60*60*1000000, 3)
+			assert.NoError(t, histo.RecordValue(int64((i+1)*1000)))
+			result.Scripts[name] = &ScriptResult{ScriptName: name, Rate: step.Rate / 2, Succeeded: 1, Latencies: histo}
+		}
+		total.AddPhase(step, result)
+	}
+
+	out := strings.Builder{}
+	assert.NoError(t, WriteStepSummary(&out, steps, total, DefaultLatencyFormat))
+
+	assert.Equal(t, `Step summary:
+  Step    Target   Achieved  P50      P95      P99      Failed
+  step 1  100.000  100.000   1.000ms  1.000ms  1.000ms  0.000 %
+  step 2  200.000  200.000   2.000ms  2.000ms  2.000ms  0.000 %
+`, out.String())
+}
@@ -0,0 +1,174 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"sort"
+	"strings"
+)
+
+// What a run was against, kept with its results so they can be made sense of long after the servers are gone
+type ServerInfo struct {
+	Version string `json:"version"`
+	Edition string `json:"edition"`
+	// Members of the cluster, or the one server if it isn't one, as of the start of the run
+	Topology []ServerMember `json:"topology,omitempty"`
+}
+
+type ServerMember struct {
+	Address string `json:"address"`
+	// Role of the member for the database of the run, eg. LEADER or FOLLOWER; where the cluster overview isn't
+	// available, what the routing table lists it for, eg. WRITE, READ
+	Role string `json:"role"`
+}
+
+func (s *ServerInfo) String() string {
+	out := fmt.Sprintf("Neo4j %s %s", s.Version, s.Edition)
+	if len(s.Topology) > 1 {
+		members := make([]string, 0, len(s.Topology))
+		for _, member := range s.Topology {
+			members = append(members, fmt.Sprintf("%s %s", member.Address, member.Role))
+		}
+		out += fmt.Sprintf(", %d members: %s", len(s.Topology), strings.Join(members, ", "))
+	}
+	return out
+}
+
+// Reads the version and edition of the server and the members of its cluster. Only failing to read the version
+// is an error; servers that aren't clustered, or don't let the user see the topology, just have none.
+func ReadServerInfo(driver neo4j.Driver, databaseName string) (ServerInfo, error) {
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeRead,
+		DatabaseName: databaseName,
+	})
+	defer session.Close()
+
+	var info ServerInfo
+	result, err := session.Run("CALL dbms.components() YIELD name, versions, edition RETURN name, versions, edition", nil)
+	if err != nil {
+		return info, fmt.Errorf("failed to read server version: %s", err)
+	}
+	for result.Next() {
+		record := result.Record()
+		if name, _ := record.Get("name"); name != "Neo4j Kernel" {
+			continue
+		}
+		versions, _ := record.Get("versions")
+		if list := stringList(versions); len(list) > 0 {
+			info.Version = list[0]
+		}
+		edition, _ := record.Get("edition")
+		info.Edition, _ = edition.(string)
+	}
+	if err = result.Err(); err != nil {
+		return info, fmt.Errorf("failed to read server version: %s", err)
+	}
+
+	if info.Topology = readClusterOverview(session, databaseName); info.Topology == nil {
+		info.Topology = readRoutingTable(session, databaseName)
+	}
+	return info, nil
+}
+
+// Members and their roles from dbms.cluster.overview, which only clustered enterprise servers have. Before 4.0
+// each member has a role of its own, since then one for each database.
+func readClusterOverview(session neo4j.Session, databaseName string) []ServerMember {
+	result, err := session.Run("CALL dbms.cluster.overview()", nil)
+	if err != nil {
+		return nil
+	}
+	var members []ServerMember
+	for result.Next() {
+		record := result.Record()
+		addresses, _ := record.Get("addresses")
+		member := ServerMember{Address: boltAddress(stringList(addresses))}
+		if role, ok := record.Get("role"); ok {
+			member.Role, _ = role.(string)
+		} else if databases, ok := record.Get("databases"); ok {
+			member.Role = databaseRole(databases, databaseName)
+		}
+		members = append(members, member)
+	}
+	if result.Err() != nil {
+		return nil
+	}
+	return members
+}
+
+// Role of a member for databaseName, from the databases column of the 4.x cluster overview; without a
+// database name, the default database isn't known, so it's the role for each of them
+func databaseRole(databases interface{}, databaseName string) string {
+	roles, _ := databases.(map[string]interface{})
+	if databaseName != "" {
+		role, _ := roles[databaseName].(string)
+		return role
+	}
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	described := make([]string, 0, len(names))
+	for _, name := range names {
+		described = append(described, fmt.Sprintf("%s=%v", name, roles[name]))
+	}
+	return strings.Join(described, " ")
+}
+
+// Members from the routing table the server gives drivers, with what each is routed to for, in the order listed
+func readRoutingTable(session neo4j.Session, databaseName string) []ServerMember {
+	var database interface{}
+	if databaseName != "" {
+		database = databaseName
+	}
+	var list []interface{}
+	// The second is the procedure before 4.0; errors may only show once the result is read
+	for _, query := range []string{"CALL dbms.routing.getRoutingTable({}, $database)", "CALL dbms.cluster.routing.getRoutingTable({})"} {
+		result, err := session.Run(query, map[string]interface{}{"database": database})
+		if err != nil || !result.Next() {
+			continue
+		}
+		servers, _ := result.Record().Get("servers")
+		list, _ = servers.([]interface{})
+		break
+	}
+	var members []ServerMember
+	index := make(map[string]int)
+	for _, server := range list {
+		entry, _ := server.(map[string]interface{})
+		role, _ := entry["role"].(string)
+		for _, address := range stringList(entry["addresses"]) {
+			if i, found := index[address]; found {
+				members[i].Role += "," + role
+				continue
+			}
+			index[address] = len(members)
+			members = append(members, ServerMember{Address: address, Role: role})
+		}
+	}
+	return members
+}
+
+// The bolt address among the ones a member listens on, or the first if none is
+func boltAddress(addresses []string) string {
+	for _, address := range addresses {
+		if strings.HasPrefix(address, "bolt://") || strings.HasPrefix(address, "neo4j://") {
+			return address
+		}
+	}
+	if len(addresses) > 0 {
+		return addresses[0]
+	}
+	return ""
+}
+
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestReadsServerInfoFromClusterOverview(t *testing.T) {
+	driver := &procedureDriver{procedures: map[string][]map[string]interface{}{
+		"CALL dbms.components()": {
+			{"name": "Neo4j Kernel", "versions": []interface{}{"4.2.1"}, "edition": "enterprise"},
+		},
+		"CALL dbms.cluster.overview()": {
+			{"addresses": []interface{}{"http://core1:7474", "bolt://core1:7687"}, "databases": map[string]interface{}{"neo4j": "LEADER", "system": "FOLLOWER"}},
+			{"addresses": []interface{}{"bolt://core2:7687"}, "databases": map[string]interface{}{"neo4j": "FOLLOWER", "system": "LEADER"}},
+		},
+	}}
+
+	info, err := ReadServerInfo(driver, "neo4j")
+
+	assert.NoError(t, err)
+	assert.Equal(t, ServerInfo{
+		Version: "4.2.1",
+		Edition: "enterprise",
+		Topology: []ServerMember{
+			{Address: "bolt://core1:7687", Role: "LEADER"},
+			{Address: "bolt://core2:7687", Role: "FOLLOWER"},
+		},
+	}, info)
+	assert.Equal(t, "Neo4j 4.2.1 enterprise, 2 members: bolt://core1:7687 LEADER, bolt://core2:7687 FOLLOWER", info.String())
+}
+
+func TestReadsServerInfoFromRoutingTableWithoutClusterOverview(t *testing.T) {
+	driver := &procedureDriver{procedures: map[string][]map[string]interface{}{
+		"CALL dbms.components()": {
+			{"name": "Neo4j Kernel", "versions": []interface{}{"4.0.0"}, "edition": "community"},
+		},
+		"CALL dbms.routing.getRoutingTable({}, $database)": {
+			{"servers": []interface{}{
+				map[string]interface{}{"role": "WRITE", "addresses": []interface{}{"db:7687"}},
+				map[string]interface{}{"role": "READ", "addresses": []interface{}{"db:7687"}},
+				map[string]interface{}{"role": "ROUTE", "addresses": []interface{}{"db:7687"}},
+			}},
+		},
+	}}
+
+	info, err := ReadServerInfo(driver, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ServerMember{{Address: "db:7687", Role: "WRITE,READ,ROUTE"}}, info.Topology)
+	assert.Equal(t, "Neo4j 4.0.0 community", info.String())
+}
+
+func TestFailsToReadServerInfoWithoutComponents(t *testing.T) {
+	_, err := ReadServerInfo(&procedureDriver{}, "")
+
+	assert.Error(t, err)
+}
+
+func TestDatabaseRoleWithoutDatabaseNameListsEveryDatabase(t *testing.T) {
+	databases := map[string]interface{}{"system": "FOLLOWER", "neo4j": "LEADER"}
+
+	assert.Equal(t, "neo4j=LEADER system=FOLLOWER", databaseRole(databases, ""))
+	assert.Equal(t, "", databaseRole(databases, "movies"))
+}
+
+// Answers the procedures it knows with fixed rows, and fails any other statement like an unknown procedure
+type procedureDriver struct {
+	neo4j.Driver
+	procedures map[string][]map[string]interface{}
+}
+
+func (d *procedureDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return &procedureSession{procedures: d.procedures}
+}
+
+type procedureSession struct {
+	neo4j.Session
+	procedures map[string][]map[string]interface{}
+}
+
+func (s *procedureSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	for procedure, rows := range s.procedures {
+		if strings.HasPrefix(cypher, procedure) {
+			return &procedureResult{rows: rows, next: -1}, nil
+		}
+	}
+	return nil, fmt.Errorf("There is no procedure with the name `%s` registered for this database instance", cypher)
+}
+
+func (s *procedureSession) Close() error {
+	return nil
+}
+
+type procedureResult struct {
+	neo4j.Result
+	rows []map[string]interface{}
+	next int
+}
+
+func (r *procedureResult) Next() bool {
+	r.next++
+	return r.next < len(r.rows)
+}
+
+func (r *procedureResult) Record() *neo4j.Record {
+	record := &neo4j.Record{}
+	for key, value := range r.rows[r.next] {
+		record.Keys = append(record.Keys, key)
+		record.Values = append(record.Values, value)
+	}
+	return record
+}
+
+func (r *procedureResult) Err() error {
+	return nil
+}
@@ -46,3 +46,23 @@ func SetupSignalHandler() (stopCh chan struct{}, stopFunc func()) {
 
 	return stopCh, stopFunc
 }
+
+// Toggles pause on the given control each time SIGUSR1 is received, until stopCh is closed.
+func SetupPauseSignalHandler(pause *PauseControl, stopCh <-chan struct{}) {
+	if len(pauseSignals) == 0 {
+		return
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, pauseSignals...)
+	go func() {
+		defer signal.Stop(sigCh)
+		for {
+			select {
+			case <-sigCh:
+				pause.Toggle()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
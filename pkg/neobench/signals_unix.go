@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package neobench
+
+import (
+	"os"
+	"syscall"
+)
+
+var pauseSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package neobench
+
+import "os"
+
+// There's no SIGUSR1 on windows; pausing there is only available via control input
+var pauseSignals []os.Signal
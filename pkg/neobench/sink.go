@@ -0,0 +1,33 @@
+package neobench
+
+import "time"
+
+// Receives the result of each progress interval while a benchmark runs, for recording metrics over time
+type IntervalSink interface {
+	// interval holds what happened between start and end; rates are per second over that time
+	WriteInterval(start, end time.Time, interval Result) error
+	Close() error
+}
+
+// Told about events during a run as they happen, eg. hooks firing, so they can be lined up with the metrics
+// around them; called from other goroutines than WriteInterval, so must be thread safe
+type EventSink interface {
+	WriteEvent(event RunEvent) error
+}
+
+// Notified of every unit of work a worker completes; called from the worker goroutines, so must be
+// thread safe and fast
+type TransactionObserver interface {
+	ObserveTransaction(workerId int64, scriptName string, latency time.Duration, outcome TransactionOutcome)
+}
+
+type TransactionOutcome struct {
+	Succeeded bool
+	// Set if the unit of work failed; see FailedByErrorGroup on results
+	FailureGroup string
+	// Number of times the driver ran the transaction, more than 1 if it retried
+	Attempts int
+	// Statements the unit of work ran, and how long each took if it succeeded
+	Statements         []Statement
+	StatementLatencies []time.Duration
+}
@@ -0,0 +1,95 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// How much one client, or executor when running virtual users, did and how fast, to spot a client that is much
+// slower than the others, eg. because its connection was routed to a struggling or far away cluster member.
+// Latencies are in microseconds, across all scripts the client ran.
+type WorkerSummary struct {
+	WorkerId  int64
+	Succeeded int64
+	Failed    int64
+	Mean      float64
+	P50       int64
+	P99       int64
+	Max       int64
+}
+
+func summarizeWorker(res WorkerResult) WorkerSummary {
+	summary := WorkerSummary{WorkerId: res.WorkerId}
+	latencies := hdrhistogram.New(0, 60*60*1000000, 3)
+	for _, script := range res.Scripts {
+		summary.Succeeded += script.Succeeded
+		summary.Failed += script.Failed
+		latencies.Merge(script.Latencies)
+	}
+	summary.Mean = latencies.Mean()
+	summary.P50 = latencies.ValueAtQuantile(50)
+	summary.P99 = latencies.ValueAtQuantile(99)
+	summary.Max = latencies.Max()
+	return summary
+}
+
+// Lowest and highest of values, and the ratio of the highest to the median, 1 when they are all the same or
+// 0 if the median is 0; values must not be empty
+func spread(values []int64) (lowest, highest int64, imbalance float64) {
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	lowest, highest = sorted[0], sorted[len(sorted)-1]
+	if median := sorted[len(sorted)/2]; median > 0 {
+		imbalance = float64(highest) / float64(median)
+	}
+	return
+}
+
+// Clients whose p99 is more than this many times that of the median client are called out in the report
+const skewedLatencyImbalance = 2.0
+
+// How many of the slowest clients the report lists
+const slowestWorkersShown = 5
+
+// Compares the clients of the run with each other, listing the slowest
+func writeSkewReport(result Result, s *strings.Builder, f LatencyFormat) {
+	workers := make([]WorkerSummary, 0, len(result.Workers))
+	for _, w := range result.Workers {
+		if w.Succeeded > 0 {
+			workers = append(workers, w)
+		}
+	}
+	if len(workers) < 2 {
+		return
+	}
+	transactions := make([]int64, len(workers))
+	p99s := make([]int64, len(workers))
+	for i, w := range workers {
+		transactions[i], p99s[i] = w.Succeeded+w.Failed, w.P99
+	}
+	sort.SliceStable(workers, func(i, j int) bool { return workers[i].P99 > workers[j].P99 })
+
+	s.WriteString("Client skew:\n")
+	lowest, highest, ratio := spread(transactions)
+	s.WriteString(fmt.Sprintf("  Transactions per client: Min: %d, Max: %d, Max / median: %.2f\n", lowest, highest, ratio))
+	lowest, highest, latencyImbalance := spread(p99s)
+	s.WriteString(fmt.Sprintf("  P99 per client: Min: %s, Max: %s, Max / median: %.2f\n",
+		f.format(float64(lowest)), f.format(float64(highest)), latencyImbalance))
+	tw := tabwriter.NewWriter(s, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "  Slowest\tTransactions\tFailed\tP50\tP99\tMax\n")
+	for i, w := range workers {
+		if i == slowestWorkersShown {
+			break
+		}
+		_, _ = fmt.Fprintf(tw, "  %d\t%d\t%d\t%s\t%s\t%s\n", w.WorkerId, w.Succeeded+w.Failed, w.Failed,
+			f.format(float64(w.P50)), f.format(float64(w.P99)), f.format(float64(w.Max)))
+	}
+	_ = tw.Flush()
+	if latencyImbalance > skewedLatencyImbalance {
+		s.WriteString("  Some clients are much slower than the others; check where their connections were routed\n")
+	}
+	s.WriteString("\n")
+}
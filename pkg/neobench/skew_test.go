@@ -0,0 +1,63 @@
+package neobench
+
+import (
+	"context"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSummarizesEachClient(t *testing.T) {
+	script, err := Parse("skewtest", `RETURN 1;`, 1)
+	assert.NoError(t, err)
+
+	result, err := Run(context.Background(), BenchmarkConfig{
+		Driver:   instantDriver{},
+		Workload: Workload{Scripts: NewScripts(script), Rand: rand.New(rand.NewSource(1337))},
+		Clients:  3,
+		Duration: 100 * time.Millisecond,
+		Rate:     300,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Workers, 3)
+	var succeeded int64
+	for i, w := range result.Workers {
+		assert.Equal(t, int64(i), w.WorkerId)
+		succeeded += w.Succeeded
+	}
+	assert.Equal(t, result.TotalSucceeded(), succeeded)
+}
+
+func TestSkewReportCallsOutSlowClients(t *testing.T) {
+	worker := func(id int64, latency int64) WorkerSummary {
+		res := NewWorkerResult(id)
+		script := &ScriptResult{ScriptName: "read", Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+		for i := 0; i < 100; i++ {
+			script.Succeeded++
+			assert.NoError(t, script.Latencies.RecordValue(latency))
+		}
+		res.Scripts["read"] = script
+		return summarizeWorker(res)
+	}
+	result := NewResult("neo4j", "test")
+	result.Workers = []WorkerSummary{worker(0, 100), worker(1, 100), worker(2, 1000)}
+
+	s := strings.Builder{}
+	writeSkewReport(result, &s, DefaultLatencyFormat)
+
+	assert.Contains(t, s.String(), "P99 per client: Min: 0.100ms, Max: 1.000ms, Max / median: 10.00\n")
+	lines := strings.Split(s.String(), "\n")
+	// The slowest client comes first
+	assert.Equal(t, []string{"2", "100", "0", "1.000ms", "1.000ms", "1.000ms"}, strings.Fields(lines[4]))
+	assert.Contains(t, s.String(), "Some clients are much slower than the others")
+
+	steady := NewResult("neo4j", "test")
+	steady.Workers = []WorkerSummary{worker(0, 1000), worker(1, 1100)}
+	s.Reset()
+	writeSkewReport(steady, &s, DefaultLatencyFormat)
+	assert.NotContains(t, s.String(), "much slower")
+}
@@ -0,0 +1,113 @@
+package neobench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Writes each unit of work that took longer than a threshold to a file, one json object per line, with the
+// statements it ran and their parameters, so outliers can be replayed and investigated after the run. Slow
+// units of work should be rare, so unlike TransactionLog, all workers share one file.
+type SlowLog struct {
+	threshold time.Duration
+	now       func() time.Time
+
+	mut  sync.Mutex
+	file *os.File
+	out  *bufio.Writer
+	// First write error, reported on Close rather than slowing the workers down
+	err error
+}
+
+type slowLogEntry struct {
+	// When the unit of work was scheduled to start; latency counts from then
+	Time       time.Time          `json:"time"`
+	Worker     int64              `json:"worker"`
+	Script     string             `json:"script"`
+	LatencyMs  float64            `json:"latency_ms"`
+	Attempts   int                `json:"attempts"`
+	Outcome    string             `json:"outcome"`
+	Statements []slowLogStatement `json:"statements"`
+}
+
+type slowLogStatement struct {
+	Query  string                 `json:"query"`
+	Params map[string]interface{} `json:"params"`
+	// Only known if the unit of work succeeded
+	LatencyMs *float64 `json:"latency_ms,omitempty"`
+}
+
+func NewSlowLog(path string, threshold time.Duration) (*SlowLog, error) {
+	if threshold <= 0 {
+		return nil, fmt.Errorf("slow log threshold must be greater than 0, got %s", threshold)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create slow log at %s: %s", path, err)
+	}
+	return &SlowLog{
+		threshold: threshold,
+		now:       time.Now,
+		file:      f,
+		out:       bufio.NewWriter(f),
+	}, nil
+}
+
+func (l *SlowLog) ObserveTransaction(workerId int64, scriptName string, latency time.Duration, outcome TransactionOutcome) {
+	if latency < l.threshold {
+		return
+	}
+	entry := slowLogEntry{
+		Time:      l.now().Add(-latency),
+		Worker:    workerId,
+		Script:    scriptName,
+		LatencyMs: float64(latency.Microseconds()) / 1000.0,
+		Attempts:  outcome.Attempts,
+		Outcome:   "ok",
+	}
+	if !outcome.Succeeded {
+		entry.Outcome = outcome.FailureGroup
+	}
+	for i, statement := range outcome.Statements {
+		logged := slowLogStatement{Query: statement.Query, Params: statement.Params}
+		if i < len(outcome.StatementLatencies) {
+			ms := float64(outcome.StatementLatencies[i].Microseconds()) / 1000.0
+			logged.LatencyMs = &ms
+		}
+		entry.Statements = append(entry.Statements, logged)
+	}
+	line, err := json.Marshal(entry)
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if l.err != nil {
+		return
+	}
+	if err != nil {
+		l.err = fmt.Errorf("failed to encode slow %s transaction: %s", scriptName, err)
+		return
+	}
+	line = append(line, '\n')
+	_, l.err = l.out.Write(line)
+}
+
+// Flushes and closes the file; must only be called once the workers have stopped
+func (l *SlowLog) Close() error {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	err := l.err
+	if flushErr := l.out.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write slow log %s: %s", l.file.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowLogOnlyWritesTransactionsOverThreshold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-slowlog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+
+	log, err := NewSlowLog(path, 100*time.Millisecond)
+	assert.NoError(t, err)
+	log.now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC) }
+	statements := []Statement{{Query: "MATCH (a:Account {aid: $aid}) RETURN a", Params: map[string]interface{}{"aid": int64(7)}}}
+	log.ObserveTransaction(0, "read", 50*time.Millisecond, TransactionOutcome{Succeeded: true, Attempts: 1, Statements: statements, StatementLatencies: []time.Duration{50 * time.Millisecond}})
+	log.ObserveTransaction(1, "read", 250*time.Millisecond, TransactionOutcome{Succeeded: true, Attempts: 1, Statements: statements, StatementLatencies: []time.Duration{200 * time.Millisecond}})
+	log.ObserveTransaction(1, "read", 150*time.Millisecond, TransactionOutcome{FailureGroup: "Neo.TransientError", Attempts: 2, Statements: statements})
+	assert.NoError(t, log.Close())
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"time": "2020-01-01T00:00:00.75Z", "worker": 1, "script": "read", "latency_ms": 250, "attempts": 1, "outcome": "ok",
+		"statements": [{"query": "MATCH (a:Account {aid: $aid}) RETURN a", "params": {"aid": 7}, "latency_ms": 200}]}`, lines[0])
+	assert.JSONEq(t, `{"time": "2020-01-01T00:00:00.85Z", "worker": 1, "script": "read", "latency_ms": 150, "attempts": 2, "outcome": "Neo.TransientError",
+		"statements": [{"query": "MATCH (a:Account {aid: $aid}) RETURN a", "params": {"aid": 7}}]}`, lines[1])
+}
@@ -0,0 +1,352 @@
+package neobench
+
+import (
+	"database/sql"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// Schema for the SQLite results store; see the "Storing results in SQLite" section of the README.
+// Latencies are in milliseconds, times are RFC3339 strings in UTC.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+  id        INTEGER PRIMARY KEY AUTOINCREMENT,
+  mode      TEXT NOT NULL,
+  database  TEXT NOT NULL,
+  scenario  TEXT NOT NULL,
+  seed      INTEGER NOT NULL,
+  start     TEXT NOT NULL,
+  end       TEXT,
+  succeeded INTEGER,
+  failed    INTEGER,
+  rate      REAL
+);
+CREATE TABLE IF NOT EXISTS run_tags (
+  run_id INTEGER NOT NULL REFERENCES runs(id),
+  key    TEXT NOT NULL,
+  value  TEXT NOT NULL,
+  PRIMARY KEY (run_id, key)
+);
+CREATE TABLE IF NOT EXISTS scripts (
+  run_id    INTEGER NOT NULL REFERENCES runs(id),
+  script    TEXT NOT NULL,
+  succeeded INTEGER NOT NULL,
+  failed    INTEGER NOT NULL,
+  rate      REAL NOT NULL,
+  count     INTEGER NOT NULL,
+  mean      REAL NOT NULL,
+  stddev    REAL NOT NULL,
+  min       REAL NOT NULL,
+  p50       REAL NOT NULL,
+  p90       REAL NOT NULL,
+  p95       REAL NOT NULL,
+  p99       REAL NOT NULL,
+  p99_9     REAL NOT NULL,
+  max       REAL NOT NULL,
+  PRIMARY KEY (run_id, script)
+);
+CREATE TABLE IF NOT EXISTS intervals (
+  run_id    INTEGER NOT NULL REFERENCES runs(id),
+  script    TEXT NOT NULL,
+  start     TEXT NOT NULL,
+  end       TEXT NOT NULL,
+  succeeded INTEGER NOT NULL,
+  failed    INTEGER NOT NULL,
+  rate      REAL NOT NULL,
+  p50       REAL NOT NULL,
+  p95       REAL NOT NULL,
+  p99       REAL NOT NULL,
+  max       REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS statements (
+  run_id   INTEGER NOT NULL REFERENCES runs(id),
+  script   TEXT NOT NULL,
+  position INTEGER NOT NULL,
+  query    TEXT NOT NULL,
+  failed   INTEGER NOT NULL,
+  count    INTEGER NOT NULL,
+  mean     REAL NOT NULL,
+  p50      REAL NOT NULL,
+  p95      REAL NOT NULL,
+  p99      REAL NOT NULL,
+  max      REAL NOT NULL,
+  PRIMARY KEY (run_id, script, position)
+);
+CREATE TABLE IF NOT EXISTS errors (
+  run_id         INTEGER NOT NULL REFERENCES runs(id),
+  error_group    TEXT NOT NULL,
+  classification TEXT NOT NULL,
+  count          INTEGER NOT NULL,
+  example        TEXT NOT NULL,
+  PRIMARY KEY (run_id, error_group)
+);
+CREATE TABLE IF NOT EXISTS events (
+  run_id INTEGER NOT NULL REFERENCES runs(id),
+  time   TEXT NOT NULL,
+  name   TEXT NOT NULL,
+  error  TEXT NOT NULL
+);
+`
+
+// Stores runs, one row per run, in a local SQLite file, created if it does not exist. Like ResultStore, the run
+// row is created up front, intervals are added as the benchmark runs and WriteResult fills in the totals.
+type SqliteStore struct {
+	db    *sql.DB
+	RunId int64
+}
+
+func NewSqliteStore(path, mode string, start time.Time, seed int64, database, scenario string, tags map[string]string) (*SqliteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite results file %s: %s", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema in sqlite results file %s: %s", path, err)
+	}
+	res, err := db.Exec("INSERT INTO runs (mode, database, scenario, seed, start) VALUES (?, ?, ?, ?, ?)",
+		mode, database, scenario, seed, start.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to store run in %s: %s", path, err)
+	}
+	runId, err := res.LastInsertId()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to store run in %s: %s", path, err)
+	}
+	for k, v := range tags {
+		if _, err := db.Exec("INSERT INTO run_tags (run_id, key, value) VALUES (?, ?, ?)", runId, k, v); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to store run tags in %s: %s", path, err)
+		}
+	}
+	return &SqliteStore{db: db, RunId: runId}, nil
+}
+
+func (s *SqliteStore) WriteInterval(start, end time.Time, interval Result) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		for _, script := range interval.SortedScripts() {
+			histo := script.Latencies
+			_, err := tx.Exec(`INSERT INTO intervals (run_id, script, start, end, succeeded, failed, rate, p50, p95, p99, max)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.RunId, script.ScriptName,
+				start.UTC().Format(time.RFC3339Nano), end.UTC().Format(time.RFC3339Nano),
+				script.Succeeded, script.Failed, script.Rate,
+				millis(histo.ValueAtQuantile(50)), millis(histo.ValueAtQuantile(95)),
+				millis(histo.ValueAtQuantile(99)), millis(histo.Max()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Records a hook firing during the run
+func (s *SqliteStore) WriteEvent(event RunEvent) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO events (run_id, time, name, error) VALUES (?, ?, ?, ?)",
+			s.RunId, event.Time.UTC().Format(time.RFC3339Nano), event.Name, event.Err)
+		return err
+	})
+}
+
+// Records totals for the run, each script, each statement and each error group
+func (s *SqliteStore) WriteResult(result Result) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE runs SET end = ?, succeeded = ?, failed = ?, rate = ? WHERE id = ?",
+			result.End.UTC().Format(time.RFC3339Nano), result.TotalSucceeded(), result.TotalFailed(), result.TotalRate(), s.RunId)
+		if err != nil {
+			return err
+		}
+		for _, script := range result.SortedScripts() {
+			h := script.Latencies
+			_, err := tx.Exec(`INSERT INTO scripts (run_id, script, succeeded, failed, rate, count, mean, stddev, min, p50, p90, p95, p99, p99_9, max)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.RunId, script.ScriptName,
+				script.Succeeded, script.Failed, script.Rate, h.TotalCount(), h.Mean()/1000.0, h.StdDev()/1000.0,
+				millis(h.Min()), millis(h.ValueAtQuantile(50)), millis(h.ValueAtQuantile(90)), millis(h.ValueAtQuantile(95)),
+				millis(h.ValueAtQuantile(99)), millis(h.ValueAtQuantile(99.9)), millis(h.Max()))
+			if err != nil {
+				return err
+			}
+			for i, statement := range script.Statements {
+				if statement == nil {
+					continue
+				}
+				if err := insertStatement(tx, s.RunId, script.ScriptName, i, statement); err != nil {
+					return err
+				}
+			}
+		}
+		for name, group := range result.FailedByErrorGroup {
+			example := ""
+			if group.FirstFailure != nil {
+				example = group.FirstFailure.Error()
+			}
+			_, err := tx.Exec("INSERT INTO errors (run_id, error_group, classification, count, example) VALUES (?, ?, ?, ?, ?)",
+				s.RunId, name, ClassifyErrorGroup(name), group.Count, example)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func insertStatement(tx *sql.Tx, runId int64, script string, position int, statement *StatementResult) error {
+	h := statement.Latencies
+	_, err := tx.Exec(`INSERT INTO statements (run_id, script, position, query, failed, count, mean, p50, p95, p99, max)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, runId, script, position, statement.Query, statement.Failed,
+		h.TotalCount(), h.Mean()/1000.0, millis(h.ValueAtQuantile(50)), millis(h.ValueAtQuantile(95)),
+		millis(h.ValueAtQuantile(99)), millis(h.Max()))
+	return err
+}
+
+func (s *SqliteStore) inTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write results to sqlite: %s", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to write results to sqlite: %s", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write results to sqlite: %s", err)
+	}
+	return nil
+}
+
+func (s *SqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// Converts a histogram value in microseconds to milliseconds
+func millis(micros int64) float64 {
+	return float64(micros) / 1000.0
+}
+
+// Percentiles, and the scripts table columns that hold them, used to load a run back as a report
+var sqlitePercentileColumns = []struct {
+	percentile float64
+	column     string
+}{{0, "min"}, {50, "p50"}, {90, "p90"}, {95, "p95"}, {99, "p99"}, {99.9, "p99_9"}, {100, "max"}}
+
+// Loads a run stored by SqliteStore, in the same shape as the -o json report; runId 0 means the latest run
+func LoadSqliteReport(path string, runId int64) (JsonReport, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return JsonReport{}, fmt.Errorf("failed to open sqlite results file %s: %s", path, err)
+	}
+	defer db.Close()
+	if runId == 0 {
+		var latest sql.NullInt64
+		if err := db.QueryRow("SELECT max(id) FROM runs WHERE end IS NOT NULL").Scan(&latest); err != nil {
+			return JsonReport{}, fmt.Errorf("failed to find latest run in %s: %s", path, err)
+		}
+		if !latest.Valid {
+			return JsonReport{}, fmt.Errorf("no completed runs in %s", path)
+		}
+		runId = latest.Int64
+	}
+
+	report := JsonReport{Tags: make(map[string]string)}
+	var start, end string
+	err = db.QueryRow("SELECT mode, database, scenario, seed, start, end, succeeded, failed, rate FROM runs WHERE id = ? AND end IS NOT NULL", runId).
+		Scan(&report.Mode, &report.Database, &report.Scenario, &report.Seed, &start, &end, &report.Succeeded, &report.Failed, &report.Rate)
+	if err == sql.ErrNoRows {
+		return JsonReport{}, fmt.Errorf("no completed run with id %d in %s", runId, path)
+	}
+	if err != nil {
+		return JsonReport{}, fmt.Errorf("failed to load run %d from %s: %s", runId, path, err)
+	}
+	if report.Start, err = time.Parse(time.RFC3339Nano, start); err != nil {
+		return JsonReport{}, fmt.Errorf("invalid start time for run %d in %s: %s", runId, path, err)
+	}
+	if report.End, err = time.Parse(time.RFC3339Nano, end); err != nil {
+		return JsonReport{}, fmt.Errorf("invalid end time for run %d in %s: %s", runId, path, err)
+	}
+
+	err = sqliteQuery(db, func(rows *sql.Rows) error {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return err
+		}
+		report.Tags[k] = v
+		return nil
+	}, "SELECT key, value FROM run_tags WHERE run_id = ?", runId)
+	if err != nil {
+		return JsonReport{}, fmt.Errorf("failed to load tags for run %d from %s: %s", runId, path, err)
+	}
+
+	err = sqliteQuery(db, func(rows *sql.Rows) error {
+		script := JsonScriptReport{}
+		values := make([]float64, len(sqlitePercentileColumns))
+		dest := []interface{}{&script.Name, &script.Succeeded, &script.Failed, &script.Rate,
+			&script.Latency.Count, &script.Latency.Mean, &script.Latency.StdDev}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		script.Latency.Min, script.Latency.Max = values[0], values[len(values)-1]
+		for i, p := range sqlitePercentileColumns {
+			script.Latency.Percentiles = append(script.Latency.Percentiles, JsonPercentileReport{Percentile: p.percentile, Value: values[i]})
+		}
+		if total := report.Succeeded + report.Failed; total > 0 {
+			script.Share = 100 * float64(script.Succeeded+script.Failed) / float64(total)
+		}
+		report.Scripts = append(report.Scripts, script)
+		return nil
+	}, "SELECT script, succeeded, failed, rate, count, mean, stddev, min, p50, p90, p95, p99, p99_9, max FROM scripts WHERE run_id = ? ORDER BY script", runId)
+	if err != nil {
+		return JsonReport{}, fmt.Errorf("failed to load scripts for run %d from %s: %s", runId, path, err)
+	}
+
+	err = sqliteQuery(db, func(rows *sql.Rows) error {
+		e := JsonErrorReport{}
+		if err := rows.Scan(&e.Group, &e.Classification, &e.Count, &e.Example); err != nil {
+			return err
+		}
+		e.Origin = ErrorGroupOrigin(e.Group)
+		report.Errors = append(report.Errors, e)
+		return nil
+	}, "SELECT error_group, classification, count, example FROM errors WHERE run_id = ? ORDER BY error_group", runId)
+	if err != nil {
+		return JsonReport{}, fmt.Errorf("failed to load errors for run %d from %s: %s", runId, path, err)
+	}
+
+	err = sqliteQuery(db, func(rows *sql.Rows) error {
+		e := JsonEventReport{}
+		var at string
+		if err := rows.Scan(&at, &e.Name, &e.Error); err != nil {
+			return err
+		}
+		if e.Time, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return err
+		}
+		e.Elapsed = e.Time.Sub(report.Start).Seconds()
+		report.Events = append(report.Events, e)
+		return nil
+	}, "SELECT time, name, error FROM events WHERE run_id = ? ORDER BY time", runId)
+	if err != nil {
+		return JsonReport{}, fmt.Errorf("failed to load events for run %d from %s: %s", runId, path, err)
+	}
+	return report, nil
+}
+
+func sqliteQuery(db *sql.DB, fn func(rows *sql.Rows) error, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,58 @@
+package neobench
+
+import (
+	"database/sql"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSqliteStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-sqlite")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.db")
+
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	result := NewResult("neo4j", "-c 4")
+	script := &ScriptResult{ScriptName: "read", Succeeded: 2, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+	assert.NoError(t, script.Latencies.RecordValue(1000))
+	assert.NoError(t, script.Latencies.RecordValue(3000))
+	script.statement(0, "RETURN 1").Latencies.RecordValue(500)
+	result.Scripts["read"] = script
+	result.End = start.Add(time.Minute)
+
+	// Two runs in the same file, to check the schema is only created once
+	for i := 0; i < 2; i++ {
+		store, err := NewSqliteStore(path, "throughput", start, 1337, "neo4j", "-c 4", map[string]string{"heap": "8g"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(i+1), store.RunId)
+		assert.NoError(t, store.WriteInterval(start, start.Add(time.Second), result))
+		assert.NoError(t, store.WriteEvent(RunEvent{Time: start.Add(30 * time.Second), Name: "30s: exec ./kill-leader.sh", Err: "exit status 1"}))
+		assert.NoError(t, store.WriteResult(result))
+		assert.NoError(t, store.Close())
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	assert.NoError(t, err)
+	defer db.Close()
+	var count int64
+	var p99 float64
+	assert.NoError(t, db.QueryRow("SELECT count, p99 FROM scripts WHERE run_id = 2 AND script = 'read'").Scan(&count, &p99))
+	assert.Equal(t, int64(2), count)
+	assert.InDelta(t, 3.0, p99, 0.01)
+	var query string
+	assert.NoError(t, db.QueryRow("SELECT query FROM statements WHERE run_id = 2").Scan(&query))
+	assert.Equal(t, "RETURN 1", query)
+	var heap string
+	assert.NoError(t, db.QueryRow("SELECT value FROM run_tags WHERE run_id = 2 AND key = 'heap'").Scan(&heap))
+	assert.Equal(t, "8g", heap)
+
+	report, err := LoadSqliteReport(path, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []JsonEventReport{{Time: start.Add(30 * time.Second), Elapsed: 30, Name: "30s: exec ./kill-leader.sh", Error: "exit status 1"}}, report.Events)
+}
@@ -0,0 +1,57 @@
+package neobench
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sends per-transaction metrics over UDP in the StatsD format, with DogStatsD style tags
+type StatsdEmitter struct {
+	conn   net.Conn
+	prefix string
+	// Pre-rendered tag suffix, eg. ",env:test,run:a"
+	tags string
+}
+
+func NewStatsdEmitter(addr, prefix string, tags map[string]string) (*StatsdEmitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up statsd emitter for %s: %s", addr, err)
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	rendered := strings.Builder{}
+	for _, k := range keys {
+		rendered.WriteString(fmt.Sprintf(",%s:%s", sanitizeStatsdTag(k), sanitizeStatsdTag(tags[k])))
+	}
+	return &StatsdEmitter{conn: conn, prefix: prefix, tags: rendered.String()}, nil
+}
+
+func (s *StatsdEmitter) ObserveTransaction(workerId int64, scriptName string, latency time.Duration, outcome TransactionOutcome) {
+	tags := fmt.Sprintf("#script:%s,worker:%d%s", sanitizeStatsdTag(scriptName), workerId, s.tags)
+	var packet string
+	if outcome.Succeeded {
+		packet = fmt.Sprintf("%stransaction.latency:%.3f|ms|%s\n%stransaction.succeeded:1|c|%s",
+			s.prefix, float64(latency.Microseconds())/1000.0, tags, s.prefix, tags)
+	} else {
+		packet = fmt.Sprintf("%stransaction.failed:1|c|%s,error:%s", s.prefix, tags, sanitizeStatsdTag(outcome.FailureGroup))
+	}
+	// This is best-effort telemetry; if the agent isn't listening, we don't want to fail the benchmark
+	_, _ = s.conn.Write([]byte(packet))
+}
+
+func (s *StatsdEmitter) Close() error {
+	return s.conn.Close()
+}
+
+var statsdTagSanitizer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_", " ", "_")
+
+func sanitizeStatsdTag(v string) string {
+	return statsdTagSanitizer.Replace(v)
+}
@@ -0,0 +1,128 @@
+package neobench
+
+import (
+	"context"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// One of several databases benchmarked side by side in one run, eg. two versions of Neo4j; see RunTargets
+type Target struct {
+	Name         string
+	Address      string
+	DatabaseName string
+	// Clients of the benchmark to run against this target; 0 for the number the benchmark has
+	Clients int
+	Driver  neo4j.Driver
+}
+
+// Parses a --target spec, NAME=ADDRESS[,database=DB][,clients=N]; the driver is left to the caller
+func ParseTarget(spec string) (Target, error) {
+	eq := strings.Index(spec, "=")
+	if eq <= 0 {
+		return Target{}, fmt.Errorf("invalid target '%s', expected NAME=ADDRESS[,database=DB][,clients=N]", spec)
+	}
+	target := Target{Name: strings.TrimSpace(spec[:eq])}
+	if strings.ContainsAny(target.Name, "/{}") {
+		return Target{}, fmt.Errorf("invalid target '%s', names can't contain /, { or }", spec)
+	}
+	parts := strings.Split(spec[eq+1:], ",")
+	target.Address = strings.TrimSpace(parts[0])
+	if target.Address == "" {
+		return Target{}, fmt.Errorf("invalid target '%s', the address is missing", spec)
+	}
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return Target{}, fmt.Errorf("invalid target '%s', expected key=value, got '%s'", spec, part)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "database":
+			target.DatabaseName = value
+		case "clients":
+			clients, err := strconv.Atoi(value)
+			if err != nil || clients < 1 {
+				return Target{}, fmt.Errorf("invalid target '%s', clients must be a positive integer, got '%s'", spec, value)
+			}
+			target.Clients = clients
+		default:
+			return Target{}, fmt.Errorf("invalid target '%s', unknown option '%s', expected database or clients", spec, key)
+		}
+	}
+	return target, nil
+}
+
+// Runs the benchmark against all targets at once. Each gets its own clients and a copy of the workload seeded
+// with seed, so they are sent the same generated load, at the full rate of cfg if it has one. Only the first
+// target reports progress, and sinks, observers, control and the prometheus endpoint are left out, as they'd
+// mix up the targets. Returns the results in the order of targets.
+func RunTargets(ctx context.Context, cfg BenchmarkConfig, seed int64, targets []Target) ([]Result, error) {
+	if cfg.Schedule != nil || cfg.LatencyTarget != nil || cfg.Dial != nil {
+		return nil, fmt.Errorf("targets can't be combined with schedules, rate searches or a connection per transaction")
+	}
+	results := make([]Result, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		targetCfg := cfg
+		targetCfg.Driver = target.Driver
+		targetCfg.DatabaseName = target.DatabaseName
+		if target.Clients > 0 {
+			targetCfg.Clients = target.Clients
+		}
+		targetCfg.Workload.Clients = targetCfg.Clients
+		targetCfg.Workload.Rand = rand.New(rand.NewSource(seed))
+		targetCfg.Sinks, targetCfg.Observers, targetCfg.Control = nil, nil, nil
+		targetCfg.PrometheusAddr, targetCfg.PoolMetrics = "", nil
+		if i > 0 {
+			targetCfg.OnProgress = nil
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = Run(ctx, targetCfg)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return results, fmt.Errorf("target %s: %s", targets[i].Name, err)
+		}
+	}
+	return results, nil
+}
+
+// Combines the results of RunTargets into one, with each script and error group named after its target,
+// eg. a/read, so all outputs report the targets side by side
+func CombineTargets(databaseName, scenario string, targets []Target, results []Result) Result {
+	combined := NewResult(databaseName, scenario)
+	for i, result := range results {
+		prefix := targets[i].Name + "/"
+		scripts := make(map[string]*ScriptResult, len(result.Scripts))
+		for name, script := range result.Scripts {
+			renamed := *script
+			renamed.ScriptName = prefix + name
+			scripts[renamed.ScriptName] = &renamed
+		}
+		failures := make(map[string]FailureGroup, len(result.FailedByErrorGroup))
+		for name, group := range result.FailedByErrorGroup {
+			failures[prefix+name] = group
+		}
+		combined.Merge(Result{
+			Scripts:            scripts,
+			FailedByErrorGroup: failures,
+			ConnectLatencies:   result.ConnectLatencies,
+			QueueTimes:         result.QueueTimes,
+			Backlog:            result.Backlog,
+			Dropped:            result.Dropped,
+			SessionsOpened:     result.SessionsOpened,
+			AcquireTimes:       result.AcquireTimes,
+		})
+	}
+	return combined
+}
@@ -0,0 +1,101 @@
+package neobench
+
+import (
+	"context"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("new=neo4j://db2:7687,database=movies,clients=4")
+	assert.NoError(t, err)
+	assert.Equal(t, Target{Name: "new", Address: "neo4j://db2:7687", DatabaseName: "movies", Clients: 4}, target)
+
+	target, err = ParseTarget("old=bolt://db1:7687")
+	assert.NoError(t, err)
+	assert.Equal(t, Target{Name: "old", Address: "bolt://db1:7687"}, target)
+
+	for _, invalid := range []string{"bolt://db1:7687", "old=", "a/b=bolt://db1", "old=bolt://db1,clients=0", "old=bolt://db1,pool=3"} {
+		_, err = ParseTarget(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestTargetsGetTheSameGeneratedLoad(t *testing.T) {
+	script, err := Parse("read", "\\set n random(1, 1000000000)\nRETURN $n;", 1)
+	assert.NoError(t, err)
+	oldDriver, newDriver := &paramsDriver{}, &paramsDriver{}
+	targets := []Target{{Name: "old", DatabaseName: "neo4j", Driver: oldDriver}, {Name: "new", DatabaseName: "neo4j", Driver: newDriver}}
+
+	results, err := RunTargets(context.Background(), BenchmarkConfig{
+		Workload: Workload{Scripts: NewScripts(script)},
+		Clients:  1,
+		Duration: 100 * time.Millisecond,
+	}, 1337, targets)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	seen := len(oldDriver.seen())
+	if len(newDriver.seen()) < seen {
+		seen = len(newDriver.seen())
+	}
+	assert.Greater(t, seen, 10)
+	assert.NotNil(t, oldDriver.seen()[0])
+	assert.Equal(t, oldDriver.seen()[:seen], newDriver.seen()[:seen])
+
+	combined := CombineTargets("neo4j", "", targets, results)
+	assert.Len(t, combined.Scripts, 2)
+	assert.Equal(t, results[0].Scripts["read"].Succeeded, combined.Scripts["old/read"].Succeeded)
+	assert.Equal(t, "new/read", combined.Scripts["new/read"].ScriptName)
+	// The target results are left as they were, for comparing them
+	assert.Equal(t, "read", results[1].Scripts["read"].ScriptName)
+}
+
+// Records the params each transaction is run with
+type paramsDriver struct {
+	neo4j.Driver
+	mut    sync.Mutex
+	params []interface{}
+}
+
+func (d *paramsDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return &paramsSession{driver: d}
+}
+
+func (d *paramsDriver) seen() []interface{} {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return append([]interface{}(nil), d.params...)
+}
+
+type paramsSession struct {
+	neo4j.Session
+	driver *paramsDriver
+}
+
+func (s *paramsSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(&paramsTx{driver: s.driver})
+}
+
+func (s *paramsSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(&paramsTx{driver: s.driver})
+}
+
+func (s *paramsSession) Close() error {
+	return nil
+}
+
+type paramsTx struct {
+	neo4j.Transaction
+	driver *paramsDriver
+}
+
+func (tx *paramsTx) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	tx.driver.mut.Lock()
+	tx.driver.params = append(tx.driver.params, params["n"])
+	tx.driver.mut.Unlock()
+	return &rowsResult{rows: []int64{1}, next: -1}, nil
+}
@@ -0,0 +1,194 @@
+package neobench
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A pass/fail condition on the final result, eg. p95<50ms, error_rate<0.1% or tps>1000; see ParseThreshold
+type Threshold struct {
+	Spec string
+	// tps, error_rate, failed, mean, max or p<percentile>
+	Metric string
+	// Only set for percentile metrics
+	Percentile float64
+	// If set, only this script is checked, otherwise the whole run
+	Script string
+	// One of <, <=, > and >=
+	Op string
+	// Milliseconds for latencies, percent for error_rate, transactions per second for tps
+	Limit float64
+}
+
+// How a threshold fared, with the value it was checked against
+type ThresholdResult struct {
+	Threshold Threshold
+	Value     float64
+	Passed    bool
+}
+
+// Parses "<metric>[{<script>}]<op><limit>". Latency limits take a unit, eg. 50ms or 1.5s, and are
+// milliseconds without one; error_rate limits are percentages, with or without the %.
+func ParseThreshold(spec string) (Threshold, error) {
+	t := Threshold{Spec: strings.TrimSpace(spec)}
+	opAt := strings.IndexAny(t.Spec, "<>")
+	if opAt <= 0 {
+		return t, fmt.Errorf("invalid threshold '%s', expected eg. p95<50ms, error_rate<0.1%% or tps>1000", spec)
+	}
+	metric, rest := t.Spec[:opAt], t.Spec[opAt:]
+	t.Op = rest[:1]
+	if strings.HasPrefix(rest[1:], "=") {
+		t.Op = rest[:2]
+	}
+	limit := strings.TrimSpace(rest[len(t.Op):])
+	if brace := strings.Index(metric, "{"); brace >= 0 {
+		if !strings.HasSuffix(metric, "}") {
+			return t, fmt.Errorf("invalid threshold '%s', the script should be in braces after the metric, eg. p95{checkout}<50ms", spec)
+		}
+		metric, t.Script = metric[:brace], metric[brace+1:len(metric)-1]
+	}
+	t.Metric = strings.ToLower(strings.TrimSpace(metric))
+
+	var err error
+	switch {
+	case t.Metric == "tps" || t.Metric == "failed":
+		t.Limit, err = strconv.ParseFloat(limit, 64)
+	case t.Metric == "error_rate":
+		t.Limit, err = strconv.ParseFloat(strings.TrimSuffix(limit, "%"), 64)
+	case t.Metric == "mean" || t.Metric == "max" || strings.HasPrefix(t.Metric, "p"):
+		if t.Metric != "mean" && t.Metric != "max" {
+			t.Percentile, err = strconv.ParseFloat(t.Metric[1:], 64)
+			if err != nil || t.Percentile < 0 || t.Percentile > 100 {
+				return t, fmt.Errorf("invalid percentile in threshold '%s', expected eg. p95 or p99.9", spec)
+			}
+		}
+		t.Limit, err = parseMillis(limit)
+	default:
+		return t, fmt.Errorf("unknown metric in threshold '%s', supported metrics are tps, error_rate, failed, mean, max and p<percentile>", spec)
+	}
+	if err != nil {
+		return t, fmt.Errorf("invalid limit in threshold '%s': %s", spec, err)
+	}
+	return t, nil
+}
+
+// Milliseconds from a duration like 50ms, or a plain number of them
+func parseMillis(s string) (float64, error) {
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		return ms, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return float64(d.Microseconds()) / 1000.0, nil
+}
+
+// Reads thresholds from a file, one per line; blank lines and lines starting with # are skipped
+func ReadThresholds(path string) ([]Threshold, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read thresholds: %s", err)
+	}
+	defer f.Close()
+	return readThresholds(f)
+}
+
+func readThresholds(r io.Reader) ([]Threshold, error) {
+	var thresholds []Threshold
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, err := ParseThreshold(line)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, scanner.Err()
+}
+
+// Checks each threshold against the result; fails if a threshold names a script the result doesn't have, as
+// that is most likely a typo that would otherwise pass silently
+func CheckThresholds(result Result, thresholds []Threshold) ([]ThresholdResult, error) {
+	out := make([]ThresholdResult, 0, len(thresholds))
+	for _, t := range thresholds {
+		scripts := result.SortedScripts()
+		if t.Script != "" {
+			script, found := result.Scripts[t.Script]
+			if !found {
+				return nil, fmt.Errorf("threshold %s is for script %s, which didn't run", t.Spec, t.Script)
+			}
+			scripts = []*ScriptResult{script}
+		}
+		value := thresholdValue(t, scripts)
+		out = append(out, ThresholdResult{Threshold: t, Value: value, Passed: t.passes(value)})
+	}
+	return out, nil
+}
+
+func thresholdValue(t Threshold, scripts []*ScriptResult) float64 {
+	var succeeded, failed int64
+	var rate float64
+	latencies := hdrhistogram.New(0, 60*60*1000000, 5)
+	for _, script := range scripts {
+		succeeded += script.Succeeded
+		failed += script.Failed
+		rate += script.Rate
+		latencies.Merge(script.Latencies)
+	}
+	switch t.Metric {
+	case "tps":
+		return rate
+	case "failed":
+		return float64(failed)
+	case "error_rate":
+		if succeeded+failed == 0 {
+			return 0
+		}
+		return 100 * float64(failed) / float64(succeeded+failed)
+	case "mean":
+		return latencies.Mean() / 1000.0
+	case "max":
+		return float64(latencies.Max()) / 1000.0
+	}
+	return float64(latencies.ValueAtQuantile(t.Percentile)) / 1000.0
+}
+
+func (t Threshold) passes(value float64) bool {
+	if math.IsNaN(value) {
+		return false
+	}
+	switch t.Op {
+	case "<":
+		return value < t.Limit
+	case "<=":
+		return value <= t.Limit
+	case ">":
+		return value > t.Limit
+	}
+	return value >= t.Limit
+}
+
+// The value in the unit of the metric, eg. 12.300ms or 0.05%
+func (r ThresholdResult) FormatValue() string {
+	switch r.Threshold.Metric {
+	case "tps":
+		return fmt.Sprintf("%.3f/s", r.Value)
+	case "failed":
+		return fmt.Sprintf("%.0f", r.Value)
+	case "error_rate":
+		return fmt.Sprintf("%.3f%%", r.Value)
+	}
+	return fmt.Sprintf("%.3fms", r.Value)
+}
@@ -0,0 +1,72 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseThreshold(t *testing.T) {
+	threshold, err := ParseThreshold("p95<50ms")
+	assert.NoError(t, err)
+	assert.Equal(t, Threshold{Spec: "p95<50ms", Metric: "p95", Percentile: 95, Op: "<", Limit: 50}, threshold)
+
+	threshold, err = ParseThreshold("p99.9{builtin:tpcb-like}<=1.5s")
+	assert.NoError(t, err)
+	assert.Equal(t, "builtin:tpcb-like", threshold.Script)
+	assert.Equal(t, "<=", threshold.Op)
+	assert.Equal(t, 1500.0, threshold.Limit)
+
+	threshold, err = ParseThreshold("error_rate < 0.1%")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.1, threshold.Limit)
+
+	threshold, err = ParseThreshold("tps>=1000")
+	assert.NoError(t, err)
+	assert.Equal(t, Threshold{Spec: "tps>=1000", Metric: "tps", Op: ">=", Limit: 1000}, threshold)
+
+	for _, invalid := range []string{"", "p95", "p95<fast", "latency<50ms", "p101<5ms", "p95{read<5ms", "tps>1k"} {
+		_, err := ParseThreshold(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	result := NewResult("neo4j", "")
+	for name, latencyMs := range map[string]int64{"read": 10, "write": 40} {
+		script := &ScriptResult{ScriptName: name, Rate: 500, Succeeded: 990, Failed: 10, Latencies: hdrhistogram.New(0, 60*60*1000000, 5)}
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, script.Latencies.RecordValue(latencyMs*1000))
+		}
+		result.Scripts[name] = script
+	}
+	thresholds, err := readThresholds(strings.NewReader(`
+# whole run
+tps>900
+error_rate<0.5%
+p95<30ms
+p95{read}<30ms
+failed<=20
+`))
+	assert.NoError(t, err)
+
+	checked, err := CheckThresholds(result, thresholds)
+	assert.NoError(t, err)
+	var passed []bool
+	for _, c := range checked {
+		passed = append(passed, c.Passed)
+	}
+	assert.Equal(t, []bool{true, false, false, true, true}, passed)
+	assert.Equal(t, "1.000%", checked[1].FormatValue())
+	assert.InDelta(t, 40, checked[2].Value, 0.01)
+
+	_, err = CheckThresholds(result, []Threshold{{Spec: "p95{reed}<1ms", Metric: "p95", Percentile: 95, Script: "reed", Op: "<", Limit: 1}})
+	assert.Error(t, err)
+
+	result.Thresholds = checked
+	s := strings.Builder{}
+	writeThresholdReport(result, &s)
+	assert.Contains(t, s.String(), "FAIL  p95<30ms")
+	assert.Contains(t, s.String(), "FAILED: 2 of 5 thresholds not met")
+}
@@ -0,0 +1,91 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Writes one CSV row per script for each progress interval, so throughput and latency can be
+// plotted over the lifetime of a run. Latencies are in milliseconds. Events, like hooks firing, get a
+// row of their own with the event in the script column and the metrics left empty.
+type TimeSeriesWriter struct {
+	out        io.WriteCloser
+	runStart   time.Time
+	mut        sync.Mutex
+	wroteFirst bool
+}
+
+var timeSeriesColumns = []string{"timestamp", "elapsed_seconds", "script", "rate", "succeeded", "failed", "p50", "p95", "p99", "max"}
+
+// Creates the file at path, or appends to it if it already exists
+func NewTimeSeriesFile(path string, runStart time.Time) (*TimeSeriesWriter, error) {
+	info, statErr := os.Stat(path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open time series file %s: %s", path, err)
+	}
+	w := NewTimeSeriesWriter(f, runStart)
+	// Don't repeat the header when appending to an existing series
+	w.wroteFirst = statErr == nil && info.Size() > 0
+	return w, nil
+}
+
+func NewTimeSeriesWriter(out io.WriteCloser, runStart time.Time) *TimeSeriesWriter {
+	return &TimeSeriesWriter{out: out, runStart: runStart}
+}
+
+func (t *TimeSeriesWriter) WriteInterval(start, end time.Time, interval Result) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	s := strings.Builder{}
+	t.writeHeader(&s)
+	for _, script := range interval.SortedScripts() {
+		histo := script.Latencies
+		s.WriteString(fmt.Sprintf("%s,%.3f,\"%s\",%.3f,%d,%d,%.3f,%.3f,%.3f,%.3f\n",
+			end.UTC().Format(time.RFC3339Nano),
+			end.Sub(t.runStart).Seconds(),
+			script.ScriptName,
+			script.Rate,
+			script.Succeeded,
+			script.Failed,
+			float64(histo.ValueAtQuantile(50))/1000.0,
+			float64(histo.ValueAtQuantile(95))/1000.0,
+			float64(histo.ValueAtQuantile(99))/1000.0,
+			float64(histo.Max())/1000.0))
+	}
+	_, err := io.WriteString(t.out, s.String())
+	return err
+}
+
+func (t *TimeSeriesWriter) WriteEvent(event RunEvent) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	s := strings.Builder{}
+	t.writeHeader(&s)
+	name := "event: " + event.Name
+	if event.Err != "" {
+		name += " (failed: " + event.Err + ")"
+	}
+	s.WriteString(fmt.Sprintf("%s,%.3f,\"%s\",,,,,,,\n",
+		event.Time.UTC().Format(time.RFC3339Nano),
+		event.Time.Sub(t.runStart).Seconds(),
+		strings.ReplaceAll(name, "\"", "\"\"")))
+	_, err := io.WriteString(t.out, s.String())
+	return err
+}
+
+func (t *TimeSeriesWriter) writeHeader(s *strings.Builder) {
+	if !t.wroteFirst {
+		s.WriteString(strings.Join(timeSeriesColumns, ","))
+		s.WriteString("\n")
+		t.wroteFirst = true
+	}
+}
+
+func (t *TimeSeriesWriter) Close() error {
+	return t.out.Close()
+}
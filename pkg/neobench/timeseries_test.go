@@ -0,0 +1,44 @@
+package neobench
+
+import (
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimeSeriesWritesIntervalsAndEventsAndAppends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-timeseries")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "series.csv")
+	start := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	interval := NewResult("neo4j", "")
+	script := &ScriptResult{ScriptName: "read", Rate: 20, Succeeded: 2, Failed: 1, Latencies: hdrhistogram.New(0, 60*60*1000000, 3)}
+	assert.NoError(t, script.Latencies.RecordValue(1000))
+	assert.NoError(t, script.Latencies.RecordValue(3000))
+	interval.Scripts["read"] = script
+
+	series, err := NewTimeSeriesFile(path, start)
+	assert.NoError(t, err)
+	assert.NoError(t, series.WriteInterval(start, start.Add(time.Second), interval))
+	assert.NoError(t, series.WriteEvent(RunEvent{Time: start.Add(1500 * time.Millisecond), Name: `kill "leader"`, Err: "timed out"}))
+	assert.NoError(t, series.Close())
+
+	// A second run appending to the same file doesn't repeat the header
+	series, err = NewTimeSeriesFile(path, start.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.NoError(t, series.WriteInterval(start.Add(time.Minute), start.Add(time.Minute+2*time.Second), interval))
+	assert.NoError(t, series.Close())
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `timestamp,elapsed_seconds,script,rate,succeeded,failed,p50,p95,p99,max
+2021-03-04T10:00:01Z,1.000,"read",20.000,2,1,1.000,3.001,3.001,3.001
+2021-03-04T10:00:01.5Z,1.500,"event: kill ""leader"" (failed: timed out)",,,,,,,
+2021-03-04T10:01:02Z,2.000,"read",20.000,2,1,1.000,3.001,3.001,3.001
+`, string(content))
+}
@@ -0,0 +1,278 @@
+package neobench
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Most spans kept waiting for export; when the collector can't keep up, further spans are dropped and
+// counted rather than slowing the workers down
+const otlpMaxPending = 20000
+
+// Spans sent in one request
+const otlpBatchSize = 512
+
+// Sends a span for each unit of work to an OpenTelemetry collector, as OTLP over HTTP with json encoding, so
+// benchmark traffic can be lined up with server side and infrastructure traces. Spans are sent in batches from
+// a goroutine of its own; export errors are reported on Close.
+type OtlpTracer struct {
+	url        string
+	client     *http.Client
+	sampleRate float64
+	// Resource attributes describing the run, sent with every batch
+	resource []otlpAttribute
+	database string
+	now      func() time.Time
+
+	mut     sync.Mutex
+	rand    *rand.Rand
+	pending []otlpSpan
+	dropped int64
+	err     error
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	done    chan struct{}
+}
+
+type otlpAttribute struct {
+	Key   string        `json:"key"`
+	Value otlpAttrValue `json:"value"`
+}
+
+type otlpAttrValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	// OTLP json encodes 64 bit integers as strings
+	IntValue *string `json:"intValue,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpExport struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+const (
+	otlpSpanKindClient = 3
+	otlpStatusOk       = 1
+	otlpStatusError    = 2
+)
+
+// endpoint is the collector, eg. http://localhost:4318; /v1/traces is added if it has no path. sampleRate is
+// the fraction of units of work to trace, and tags are added to the resource of every span, next to
+// service.name=neobench.
+func NewOtlpTracer(endpoint string, databaseName string, sampleRate float64, tags map[string]string) (*OtlpTracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("otlp endpoint should be a url like http://localhost:4318, got '%s'", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("otlp sample rate must be greater than 0 and at most 1, got %v", sampleRate)
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	resource := []otlpAttribute{stringAttribute("service.name", "neobench")}
+	for _, k := range keys {
+		if tags[k] != "" {
+			resource = append(resource, stringAttribute("neobench."+k, tags[k]))
+		}
+	}
+	t := &OtlpTracer{
+		url:        u.String(),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		sampleRate: sampleRate,
+		resource:   resource,
+		database:   databaseName,
+		now:        time.Now,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		flushCh:    make(chan struct{}, 1),
+		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+func (t *OtlpTracer) ObserveTransaction(workerId int64, scriptName string, latency time.Duration, outcome TransactionOutcome) {
+	end := t.now()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.sampleRate < 1 && t.rand.Float64() >= t.sampleRate {
+		return
+	}
+	if len(t.pending) >= otlpMaxPending {
+		t.dropped++
+		return
+	}
+	span := otlpSpan{
+		TraceId:           t.randomId(16),
+		SpanId:            t.randomId(8),
+		Name:              scriptName,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(end.Add(-latency).UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes: []otlpAttribute{
+			stringAttribute("db.system", "neo4j"),
+			stringAttribute("db.name", t.database),
+			stringAttribute("neobench.script", scriptName),
+			intAttribute("neobench.worker", workerId),
+			intAttribute("neobench.statements", int64(len(outcome.Statements))),
+			intAttribute("neobench.attempts", int64(outcome.Attempts)),
+		},
+		Status: otlpStatus{Code: otlpStatusOk},
+	}
+	if outcome.Succeeded {
+		span.Attributes = append(span.Attributes, stringAttribute("neobench.outcome", "ok"))
+	} else {
+		span.Attributes = append(span.Attributes, stringAttribute("neobench.outcome", outcome.FailureGroup))
+		span.Status = otlpStatus{Code: otlpStatusError, Message: outcome.FailureGroup}
+	}
+	t.pending = append(t.pending, span)
+	if len(t.pending) >= otlpBatchSize {
+		select {
+		case t.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *OtlpTracer) randomId(bytes int) string {
+	id := make([]byte, bytes)
+	t.rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func (t *OtlpTracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopCh:
+			t.flush()
+			return
+		case <-ticker.C:
+		case <-t.flushCh:
+		}
+		t.flush()
+	}
+}
+
+// Sends everything pending, in batches
+func (t *OtlpTracer) flush() {
+	for {
+		t.mut.Lock()
+		n := len(t.pending)
+		if n > otlpBatchSize {
+			n = otlpBatchSize
+		}
+		batch := t.pending[:n]
+		t.pending = t.pending[n:]
+		if len(t.pending) == 0 {
+			t.pending = nil
+		}
+		t.mut.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			t.mut.Lock()
+			if t.err == nil {
+				t.err = err
+			}
+			t.mut.Unlock()
+		}
+	}
+}
+
+func (t *OtlpTracer) export(spans []otlpSpan) error {
+	body, err := json.Marshal(otlpExport{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: t.resource},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "neobench"}, Spans: spans}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %s", err)
+	}
+	resp, err := t.client.Post(t.url, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("failed to send spans to %s: %s", t.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s rejected spans with status %s: %s", t.url, resp.Status, msg)
+	}
+	return nil
+}
+
+// Sends the spans still pending and stops; must only be called once the workers have stopped
+func (t *OtlpTracer) Close() error {
+	close(t.stopCh)
+	<-t.done
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	if t.dropped > 0 {
+		return fmt.Errorf("dropped %d spans, the collector at %s did not keep up", t.dropped, t.url)
+	}
+	return nil
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAttrValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int64) otlpAttribute {
+	v := strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpAttrValue{IntValue: &v}}
+}
@@ -0,0 +1,68 @@
+package neobench
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOtlpTracerSendsSpanPerTransaction(t *testing.T) {
+	var mut sync.Mutex
+	var exports []otlpExport
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var export otlpExport
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&export))
+		mut.Lock()
+		exports = append(exports, export)
+		mut.Unlock()
+	}))
+	defer collector.Close()
+
+	tracer, err := NewOtlpTracer(collector.URL, "neo4j", 1, map[string]string{"run": "nightly"})
+	assert.NoError(t, err)
+	tracer.now = func() time.Time { return time.Unix(100, 0) }
+	tracer.ObserveTransaction(3, "checkout", 20*time.Millisecond, TransactionOutcome{
+		Succeeded: true, Attempts: 1, Statements: []Statement{{Query: "RETURN 1"}, {Query: "RETURN 2"}},
+	})
+	tracer.ObserveTransaction(4, "checkout", time.Millisecond, TransactionOutcome{FailureGroup: "Deadlock", Attempts: 3})
+	assert.NoError(t, tracer.Close())
+
+	assert.Len(t, exports, 1)
+	resource := exports[0].ResourceSpans[0]
+	assert.Equal(t, []otlpAttribute{stringAttribute("service.name", "neobench"), stringAttribute("neobench.run", "nightly")},
+		resource.Resource.Attributes)
+	spans := resource.ScopeSpans[0].Spans
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "checkout", spans[0].Name)
+	assert.Len(t, spans[0].TraceId, 32)
+	assert.Len(t, spans[0].SpanId, 16)
+	assert.Equal(t, "99980000000", spans[0].StartTimeUnixNano)
+	assert.Equal(t, "100000000000", spans[0].EndTimeUnixNano)
+	assert.Contains(t, spans[0].Attributes, intAttribute("neobench.statements", 2))
+	assert.Equal(t, otlpStatusOk, spans[0].Status.Code)
+	assert.Contains(t, spans[1].Attributes, intAttribute("neobench.attempts", 3))
+	assert.Equal(t, otlpStatus{Code: otlpStatusError, Message: "Deadlock"}, spans[1].Status)
+}
+
+func TestOtlpTracerReportsRejectedSpans(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer collector.Close()
+
+	tracer, err := NewOtlpTracer(collector.URL+"/custom/traces", "neo4j", 1, nil)
+	assert.NoError(t, err)
+	tracer.ObserveTransaction(0, "s", time.Millisecond, TransactionOutcome{Succeeded: true})
+	assert.Error(t, tracer.Close())
+
+	_, err = NewOtlpTracer("localhost:4318", "neo4j", 1, nil)
+	assert.Error(t, err)
+	_, err = NewOtlpTracer("http://localhost:4318", "neo4j", 0, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Shortest paths and variable-length expansions between random places in the road network InitTraversal
+// creates. Both report the lengths of the paths they find, see Script.Metric.
+var Traversal = []BuiltinQuery{
+	{Name: "shortest-path", Weight: 1, Readonly: true, Metric: "pathLength", Script: `
+\set from random(1, 10000 * $scale)
+\set to random(1, 10000 * $scale)
+MATCH (from:Place {id: $from}), (to:Place {id: $to})
+MATCH path = shortestPath((from)-[:ROAD*..20]-(to))
+RETURN length(path) AS pathLength;
+`},
+	{Name: "expand", Weight: 1, Readonly: true, Metric: "pathLength", Script: `
+\set from random(1, 10000 * $scale)
+MATCH path = (:Place {id: $from})-[:ROAD*1..3]->(:Place)
+RETURN length(path) AS pathLength;
+`},
+}
+
+// Creates 10000 * scale places, each with a road to the next so the network is connected, and 3 more roads to
+// random places so paths between any two are short. Run again at a larger scale, it adds the missing places; the
+// new ones chain on from the old ones and back to the first, so the network stays connected.
+func InitTraversal(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	numPlaces := 10000 * scale
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	if err := createSchema(session, opts, out, "CREATE CONSTRAINT ON (p:Place) ASSERT p.id IS UNIQUE"); err != nil {
+		return err
+	}
+
+	cp, err := startCheckpoint(session, "traversal", out)
+	if err != nil {
+		return err
+	}
+	existingPlaceNum, err := cp.existingIds(session, "Place", "id")
+	if err != nil {
+		return err
+	}
+
+	err = loadBatches(driver, dbName, opts, cp, "create places", existingPlaceNum+1, numPlaces, `UNWIND range($start, $end) AS placeId
+CREATE (:Place {id: placeId})
+`, nil, out)
+	if err != nil {
+		return err
+	}
+	// Once every place exists, so roads can lead anywhere
+	err = loadBatches(driver, dbName, opts, cp, "create roads", existingPlaceNum+1, numPlaces, `UNWIND range($start, $end) AS placeId
+MATCH (place:Place {id: placeId}), (next:Place {id: placeId % $numPlaces + 1})
+CREATE (place)-[:ROAD]->(next)
+WITH place
+UNWIND range(1, 3) AS i
+MATCH (other:Place {id: toInteger(rand() * $numPlaces) + 1})
+CREATE (place)-[:ROAD]->(other)
+`, map[string]interface{}{
+		"numPlaces": numPlaces,
+	}, out)
+	if err != nil {
+		return err
+	}
+	return cp.finish(session)
+}
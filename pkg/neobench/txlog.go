@@ -0,0 +1,169 @@
+package neobench
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Writes one line per transaction, similar to pgbench -l, to one buffered file per worker so workers
+// never contend on a shared writer. Each line is:
+//
+//	<unix time in microseconds> <worker id> <script> <latency in microseconds> <retries> <outcome>
+//
+// where outcome is "ok" or the failure group of the error.
+//
+// If an aggregate interval is set, each file instead gets one line per interval, in the same layout as
+// pgbench --aggregate-interval, with the failure count and a few percentiles added at the end:
+//
+//	<interval start, unix seconds> <count> <sum latency> <sum latency^2> <min latency> <max latency> <failed> <p50> <p95> <p99>
+//
+// Latencies cover successful transactions and are in microseconds.
+type TransactionLog struct {
+	// Fraction, 0 to 1, of transactions to log
+	samplingRate float64
+	// If non-zero, write one summary line per interval rather than a line per transaction
+	aggregateInterval time.Duration
+	// Indexed by worker id; each entry is only touched by its own worker
+	workers []*transactionLogWorker
+}
+
+type transactionLogWorker struct {
+	file *os.File
+	out  *bufio.Writer
+	rand *rand.Rand
+	line []byte
+	// First write error, reported on Close rather than slowing the worker down
+	err error
+
+	// Current interval, when aggregating
+	intervalStart time.Time
+	count         int64
+	failed        int64
+	sum           float64
+	sum2          float64
+	latencies     *hdrhistogram.Histogram
+}
+
+// Creates <prefix>.<worker id> for each worker; aggregateInterval is 0 for a line per transaction
+func NewTransactionLog(prefix string, numWorkers int, samplingRate float64, aggregateInterval time.Duration, seed int64) (*TransactionLog, error) {
+	if samplingRate <= 0 || samplingRate > 1 {
+		return nil, fmt.Errorf("sampling rate must be greater than 0 and at most 1, got %f", samplingRate)
+	}
+	if aggregateInterval > 0 && samplingRate < 1 {
+		return nil, fmt.Errorf("sampling rate can't be combined with aggregate logging")
+	}
+	l := &TransactionLog{samplingRate: samplingRate, aggregateInterval: aggregateInterval}
+	for i := 0; i < numWorkers; i++ {
+		path := fmt.Sprintf("%s.%d", prefix, i)
+		f, err := os.Create(path)
+		if err != nil {
+			_ = l.Close()
+			return nil, fmt.Errorf("failed to create transaction log at %s: %s", path, err)
+		}
+		l.workers = append(l.workers, &transactionLogWorker{
+			file:      f,
+			out:       bufio.NewWriterSize(f, 64*1024),
+			rand:      rand.New(rand.NewSource(seed + int64(i))),
+			latencies: hdrhistogram.New(0, 60*60*1000000, 3),
+		})
+	}
+	return l, nil
+}
+
+func (l *TransactionLog) ObserveTransaction(workerId int64, scriptName string, latency time.Duration, outcome TransactionOutcome) {
+	w := l.workers[workerId]
+	if w.err != nil {
+		return
+	}
+	if l.aggregateInterval > 0 {
+		l.aggregate(w, time.Now(), latency, outcome)
+		return
+	}
+	if l.samplingRate < 1 && w.rand.Float64() >= l.samplingRate {
+		return
+	}
+	retries := outcome.Attempts - 1
+	if retries < 0 {
+		retries = 0
+	}
+	result := "ok"
+	if !outcome.Succeeded {
+		result = outcome.FailureGroup
+	}
+
+	// Built by hand rather than with fmt to keep the cost on the worker low
+	line := w.line[:0]
+	line = strconv.AppendInt(line, time.Now().UnixNano()/1000, 10)
+	line = append(line, ' ')
+	line = strconv.AppendInt(line, workerId, 10)
+	line = append(line, ' ')
+	line = append(line, scriptName...)
+	line = append(line, ' ')
+	line = strconv.AppendInt(line, latency.Microseconds(), 10)
+	line = append(line, ' ')
+	line = strconv.AppendInt(line, int64(retries), 10)
+	line = append(line, ' ')
+	line = append(line, result...)
+	line = append(line, '\n')
+	w.line = line
+	_, w.err = w.out.Write(line)
+}
+
+func (l *TransactionLog) aggregate(w *transactionLogWorker, now time.Time, latency time.Duration, outcome TransactionOutcome) {
+	if w.intervalStart.IsZero() {
+		w.intervalStart = now.Truncate(l.aggregateInterval)
+	}
+	if now.Sub(w.intervalStart) >= l.aggregateInterval {
+		w.writeInterval()
+		w.intervalStart = now.Truncate(l.aggregateInterval)
+	}
+	if !outcome.Succeeded {
+		w.failed++
+		return
+	}
+	micros := latency.Microseconds()
+	w.count++
+	w.sum += float64(micros)
+	w.sum2 += float64(micros) * float64(micros)
+	if err := w.latencies.RecordValue(micros); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *transactionLogWorker) writeInterval() {
+	if w.intervalStart.IsZero() || w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.out, "%d %d %.0f %.0f %d %d %d %d %d %d\n",
+		w.intervalStart.Unix(), w.count, w.sum, w.sum2, w.latencies.Min(), w.latencies.Max(), w.failed,
+		w.latencies.ValueAtQuantile(50), w.latencies.ValueAtQuantile(95), w.latencies.ValueAtQuantile(99))
+	w.count, w.failed, w.sum, w.sum2 = 0, 0, 0, 0
+	w.latencies.Reset()
+}
+
+// Flushes and closes all worker files; must only be called once the workers have stopped
+func (l *TransactionLog) Close() error {
+	var firstErr error
+	for _, w := range l.workers {
+		if l.aggregateInterval > 0 {
+			// Write out the final, partial, interval
+			w.writeInterval()
+		}
+		err := w.err
+		if flushErr := w.out.Flush(); err == nil {
+			err = flushErr
+		}
+		if closeErr := w.file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to write transaction log %s: %s", w.file.Name(), err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,55 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransactionLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-txlog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prefix := filepath.Join(dir, "tx")
+
+	log, err := NewTransactionLog(prefix, 2, 1, 0, 1337)
+	assert.NoError(t, err)
+	log.ObserveTransaction(1, "read", 1500*time.Microsecond, TransactionOutcome{Succeeded: true, Attempts: 1})
+	log.ObserveTransaction(1, "read", 20*time.Microsecond, TransactionOutcome{FailureGroup: "Neo.TransientError", Attempts: 3})
+	assert.NoError(t, log.Close())
+
+	content, err := ioutil.ReadFile(prefix + ".1")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"1", "read", "1500", "0", "ok"}, strings.Fields(lines[0])[1:])
+	assert.Equal(t, []string{"1", "read", "20", "2", "Neo.TransientError"}, strings.Fields(lines[1])[1:])
+
+	empty, err := ioutil.ReadFile(prefix + ".0")
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestAggregateTransactionLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench-txlog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	prefix := filepath.Join(dir, "tx")
+
+	log, err := NewTransactionLog(prefix, 1, 1, time.Hour, 1337)
+	assert.NoError(t, err)
+	log.ObserveTransaction(0, "read", 1000*time.Microsecond, TransactionOutcome{Succeeded: true, Attempts: 1})
+	log.ObserveTransaction(0, "read", 3000*time.Microsecond, TransactionOutcome{Succeeded: true, Attempts: 1})
+	log.ObserveTransaction(0, "read", 20*time.Microsecond, TransactionOutcome{FailureGroup: "Neo.TransientError", Attempts: 3})
+	assert.NoError(t, log.Close())
+
+	content, err := ioutil.ReadFile(prefix + ".0")
+	assert.NoError(t, err)
+	fields := strings.Fields(string(content))
+	assert.Len(t, fields, 10)
+	assert.Equal(t, []string{"2", "4000", "10000000", "1000", "3001", "1"}, fields[1:7])
+}
@@ -0,0 +1,75 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Name of the vector index InitVector creates over :Chunk(embedding), and the size of the embeddings in it
+const (
+	VectorIndexName  = "neobenchEmbeddings"
+	VectorDimensions = 128
+)
+
+// Approximate nearest-neighbor search: the query vector is a stored embedding with some noise added, so each
+// query has a known-close neighborhood, like a search for text similar to a stored chunk would.
+var Vector = fmt.Sprintf(`
+\set chunkId random(1, 10000 * $scale)
+MATCH (chunk:Chunk {id: $chunkId})
+WITH [x IN chunk.embedding | x + (rand() - 0.5) * 0.1] AS queryVector
+CALL db.index.vector.queryNodes("%s", 10, queryVector)
+YIELD node, score
+RETURN node.id, score;
+`, VectorIndexName)
+
+// Creates 10000 * scale chunks with random embeddings and the vector index over them, which needs Neo4j 5.13 or
+// later. Like the full-text index, it is awaited so the run does not measure index population.
+func InitVector(scale int64, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	numChunks := 10000 * scale
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	if err := createSchema(session, opts, out, "CREATE CONSTRAINT IF NOT EXISTS FOR (c:Chunk) REQUIRE c.id IS UNIQUE"); err != nil {
+		return err
+	}
+
+	cp, err := startCheckpoint(session, "vector", out)
+	if err != nil {
+		return err
+	}
+	existingChunkNum, err := cp.existingIds(session, "Chunk", "id")
+	if err != nil {
+		return err
+	}
+
+	// Embeddings make each node much larger, so batches are a fifth of the size
+	chunkOpts := opts
+	chunkOpts.BatchSize = max(1, opts.BatchSize/5)
+	err = loadBatches(driver, dbName, chunkOpts, cp, "create embeddings", existingChunkNum+1, numChunks, `UNWIND range($start, $end) AS chunkId
+CREATE (:Chunk {id: chunkId, embedding: [i IN range(1, $dimensions) | rand()]})
+`, map[string]interface{}{
+		"dimensions": VectorDimensions,
+	}, out)
+	if err != nil {
+		return err
+	}
+
+	out.ReportProgress(ProgressReport{
+		Section:      "init",
+		Step:         "create vector index",
+		Completeness: 0,
+	})
+	_, err = session.Run(fmt.Sprintf("CREATE VECTOR INDEX %s IF NOT EXISTS FOR (c:Chunk) ON (c.embedding) "+
+		"OPTIONS {indexConfig: {`vector.dimensions`: %d, `vector.similarity_function`: \"cosine\"}}",
+		VectorIndexName, VectorDimensions), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create vector index, which needs Neo4j 5.13 or later: %s", err)
+	}
+	if _, err = session.Run("CALL db.awaitIndexes(3600)", nil); err != nil {
+		return err
+	}
+	return cp.finish(session)
+}
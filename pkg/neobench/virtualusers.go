@@ -0,0 +1,117 @@
+package neobench
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// A client run as a virtual user: rather than having a goroutine of its own, it waits in the scheduler until its
+// next unit of work is due, and borrows an executor to run it. Virtual users run each unit of work in a session of
+// its own, so they don't hold on to anything between them.
+type virtualUser struct {
+	worker *Worker
+	client clientState
+	due    time.Time
+}
+
+// Runs the workers, each with the client of the same index, as virtual users on an executor per recorder, so tens
+// of thousands of mostly idle clients, eg. users with think time, need no more goroutines or connections than
+// there are executors. Each executor records the units of work it runs with the recorder of the same index,
+// and sends its result to results once stopped, or as soon as one of its users crashes, after calling crashed.
+// Returns once all executors are done.
+func runVirtualUsers(workers []*Worker, clients []clientState, recorders []*ResultRecorder, stopCh <-chan struct{},
+	pause *PauseControl, results chan<- WorkerResult, crashed func(executor int, err error)) {
+	users := make(userQueue, 0, len(workers))
+	start := time.Now()
+	for i, worker := range workers {
+		clients[i].nextStart = start
+		users = append(users, &virtualUser{worker: worker, client: clients[i], due: start})
+	}
+	heap.Init(&users)
+
+	ready := make(chan *virtualUser)
+	// Each executor returns at most one user at a time, so they never wait on the scheduler to take them back
+	returned := make(chan *virtualUser, len(recorders))
+	var executors sync.WaitGroup
+	defer executors.Wait()
+	for i, recorder := range recorders {
+		recorder.totalStart, recorder.currentStart = start, start
+		executors.Add(1)
+		go func(executor int, recorder *ResultRecorder) {
+			defer executors.Done()
+			for {
+				select {
+				case <-stopCh:
+					results <- recorder.Complete(time.Now())
+					return
+				case user := <-ready:
+					wait, result := user.worker.step(&user.client, stopCh, pause, recorder)
+					if result != nil {
+						// The result is the executors, stopped or crashed along with the user
+						result.WorkerId = int64(executor)
+						if result.Error != nil {
+							crashed(executor, result.Error)
+						}
+						results <- *result
+						return
+					}
+					if user.client.restart {
+						// Time waiting for an executor counts towards the latency of the next unit of work
+						user.client.nextStart, user.client.restart = time.Now().Add(wait), false
+					}
+					user.due = time.Now().Add(wait)
+					returned <- user
+				}
+			}
+		}(i, recorder)
+	}
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		var next *virtualUser
+		var readyCh chan *virtualUser
+		if len(users) > 0 {
+			if wait := time.Until(users[0].due); wait > 0 {
+				resetTimer(timer, wait)
+			} else {
+				next, readyCh = users[0], ready
+			}
+		}
+		select {
+		case <-stopCh:
+			return
+		case readyCh <- next:
+			heap.Pop(&users)
+		case user := <-returned:
+			heap.Push(&users, user)
+		case <-timer.C:
+		}
+	}
+}
+
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// Virtual users waiting for their next unit of work, soonest due first
+type userQueue []*virtualUser
+
+func (q userQueue) Len() int            { return len(q) }
+func (q userQueue) Less(i, j int) bool  { return q[i].due.Before(q[j].due) }
+func (q userQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *userQueue) Push(x interface{}) { *q = append(*q, x.(*virtualUser)) }
+func (q *userQueue) Pop() interface{} {
+	old := *q
+	user := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return user
+}
@@ -1,19 +1,287 @@
 package neobench
 
 import (
+	"fmt"
 	"github.com/codahale/hdrhistogram"
-	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/pkg/errors"
+	"math/rand"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Worker struct {
 	workerId int64
 	driver   neo4j.Driver
-	now      func() time.Time
-	sleep    func(duration time.Duration)
+	// If set, each unit of work gets a new driver and connection from this, rather than using driver
+	dial  DriverFactory
+	now   func() time.Time
+	sleep func(duration time.Duration)
+	// How transaction start times are spaced when running at a set rate
+	Arrival Arrival
+	// Pause between units of work when running without a set rate, to model users interacting with a UI
+	ThinkTime ThinkTime
+	// When running at a set rate, the most scheduled units of work allowed to queue up behind the one
+	// running; if the database falls further behind, the oldest are dropped. 0 means no bound.
+	MaxBacklog int64
+	// If set, units of work that hit a deadlock fail at once, rather than being retried by the driver
+	FailOnDeadlock bool
+	// If set, each transaction gets a server side timeout so it can't run past this time; this is the end
+	// of the benchmark, so stuck queries don't hold up shutdown
+	TxDeadline time.Time
+	// If set, read-only units of work run in write transactions, so they go to the leader rather than to
+	// read replicas
+	ForceWriteRouting bool
+	// Which earlier writes each unit of work waits for, and with BookmarksShared, where the bookmarks of all
+	// clients are kept
+	Bookmarks       BookmarkMode
+	SharedBookmarks *SharedBookmarks
+	// Fraction of units of work whose statements run with PROFILE, to see db hits and plans; 0 profiles none
+	ProfileSampleRate float64
+	// If set, the rate to run at is read from this before each unit of work, so it can change during the run
+	Rate *RateControl
+	// How much of the rows of each statement are read before it counts as done
+	Consume ConsumeMode
+	// Units of work run in a session before it's closed and another opened, when units of work share a session;
+	// 0 keeps one session for the lifetime of the worker, see ParseSessionReuse
+	SessionReuse int
+	// Bookmark of the last write this worker committed, when it runs units of work in sessions of their own
+	lastBookmark string
+
+	// The session of the unit of work being run, for Abort to interrupt
+	mut     sync.Mutex
+	running neo4j.Session
+	aborted bool
+}
+
+// Interrupts the unit of work the worker is running, and any it starts after, for workers still stuck in a
+// transaction once the run was stopped; sessions that aren't interruptibleSession run on regardless
+func (w *Worker) Abort() {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	w.aborted = true
+	interrupt(w.running)
+}
+
+// Runs uow in session, where Abort can interrupt it
+func (w *Worker) runUnitInterruptibly(session neo4j.Session, uow UnitOfWork) uowOutcome {
+	w.mut.Lock()
+	w.running = session
+	if w.aborted {
+		interrupt(session)
+	}
+	w.mut.Unlock()
+	defer func() {
+		w.mut.Lock()
+		w.running = nil
+		w.mut.Unlock()
+	}()
+	return w.runUnit(session, uow)
+}
+
+// Which earlier writes a unit of work waits for a cluster member to have applied before it runs
+type BookmarkMode int
+
+const (
+	// Each client waits for its own earlier writes, like a user reading what they just wrote; this is what the
+	// driver does within a session
+	BookmarksClient BookmarkMode = 0
+	// Nothing waits for earlier writes, so reads routed to a read replica may not see them yet
+	BookmarksNone BookmarkMode = 1
+	// Each client waits for the latest writes of every client, see SharedBookmarks
+	BookmarksShared BookmarkMode = 2
+)
+
+func ParseBookmarks(name string) (BookmarkMode, error) {
+	switch name {
+	case "client":
+		return BookmarksClient, nil
+	case "none":
+		return BookmarksNone, nil
+	case "shared":
+		return BookmarksShared, nil
+	}
+	return BookmarksClient, fmt.Errorf("unknown bookmark mode: %s, supported modes are 'client', 'none' and 'shared'", name)
+}
+
+// Bookmark of the latest write of each client, so units of work can wait for the writes of all of them. Bookmarks
+// aren't ordered, so rather than only the latest one overall, each unit of work is given one per client.
+type SharedBookmarks struct {
+	mu     sync.RWMutex
+	latest map[int64]string
+}
+
+func NewSharedBookmarks() *SharedBookmarks {
+	return &SharedBookmarks{latest: make(map[int64]string)}
+}
+
+func (b *SharedBookmarks) all() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bookmarks := make([]string, 0, len(b.latest))
+	for _, bookmark := range b.latest {
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks
+}
+
+func (b *SharedBookmarks) set(workerId int64, bookmark string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.latest[workerId] = bookmark
+}
+
+// How much of what a statement returns workers read. This changes both what the client spends on each statement and
+// what the latency measures: with less read, the server may still be streaming rows when the statement is done.
+type ConsumeMode int
+
+const (
+	// Only the summary is read; the driver discards the rows as they arrive
+	ConsumeSummary ConsumeMode = 0
+	// The first row is read, like an application showing the top result, then the summary
+	ConsumeFirst ConsumeMode = 1
+	// Every row is read and counted, without being kept
+	ConsumeCount ConsumeMode = 2
+	// Every row is read and kept until the statement is done, like an application building a response from them
+	ConsumeAll ConsumeMode = 3
+)
+
+func ParseConsumeMode(name string) (ConsumeMode, error) {
+	switch name {
+	case "summary":
+		return ConsumeSummary, nil
+	case "first":
+		return ConsumeFirst, nil
+	case "count":
+		return ConsumeCount, nil
+	case "all":
+		return ConsumeAll, nil
+	}
+	return ConsumeSummary, fmt.Errorf("unknown consume mode: %s, supported modes are 'summary', 'first', 'count' and 'all'", name)
+}
+
+// Parses how many units of work share a session: lifetime for one session per client for the whole run,
+// transaction for a session per unit of work, or a number of units of work per session
+func ParseSessionReuse(spec string) (int, error) {
+	switch spec {
+	case "lifetime":
+		return 0, nil
+	case "transaction":
+		return 1, nil
+	}
+	n, err := strconv.Atoi(spec)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid session reuse: %s, expected 'lifetime', 'transaction' or a number of transactions per session", spec)
+	}
+	return n, nil
+}
+
+// Reads the rows of res as the mode says, returning how many it read; the summary is left to the caller
+func (m ConsumeMode) read(res neo4j.Result) int64 {
+	var rows int64
+	switch m {
+	case ConsumeFirst:
+		if res.Next() {
+			rows++
+		}
+	case ConsumeCount:
+		for res.Next() {
+			rows++
+		}
+	case ConsumeAll:
+		var records []*neo4j.Record
+		for res.Next() {
+			records = append(records, res.Record())
+		}
+		rows = int64(len(records))
+	}
+	return rows
+}
+
+type Arrival int
+
+const (
+	// Transactions are scheduled at a fixed interval
+	ArrivalUniform Arrival = 0
+	// Intervals between transactions are drawn from an exponential distribution, so arrivals are a poisson
+	// process; this is closer to how independent users show up in production
+	ArrivalPoisson Arrival = 1
+)
+
+func ParseArrival(name string) (Arrival, error) {
+	switch name {
+	case "uniform":
+		return ArrivalUniform, nil
+	case "poisson":
+		return ArrivalPoisson, nil
+	}
+	return ArrivalUniform, fmt.Errorf("unknown arrival process: %s, supported processes are 'uniform' and 'poisson'", name)
+}
+
+// Time until the next transaction should start, given the mean interval between transactions
+func (a Arrival) nextInterval(mean time.Duration, r *rand.Rand) time.Duration {
+	if a == ArrivalPoisson {
+		return time.Duration(r.ExpFloat64() * float64(mean))
+	}
+	return mean
+}
+
+// Distribution of pauses between units of work; the zero value means no pause
+type ThinkTime struct {
+	Distribution string
+	// Fixed pause, or mean for exponential, or lower bound for uniform
+	Min time.Duration
+	// Upper bound for uniform, otherwise unused
+	Max time.Duration
+}
+
+// Parses a think time spec: a fixed duration like 500ms, exp:<mean> or uniform:<min>-<max>
+func ParseThinkTime(spec string) (ThinkTime, error) {
+	invalid := fmt.Errorf("invalid think time '%s', expected a duration like 500ms, exp:<mean> or uniform:<min>-<max>", spec)
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) == 1 {
+		d, err := time.ParseDuration(spec)
+		if err != nil || d < 0 {
+			return ThinkTime{}, invalid
+		}
+		return ThinkTime{Distribution: "fixed", Min: d}, nil
+	}
+	switch parts[0] {
+	case "exp":
+		d, err := time.ParseDuration(parts[1])
+		if err != nil || d < 0 {
+			return ThinkTime{}, invalid
+		}
+		return ThinkTime{Distribution: "exp", Min: d}, nil
+	case "uniform":
+		bounds := strings.SplitN(parts[1], "-", 2)
+		if len(bounds) != 2 {
+			return ThinkTime{}, invalid
+		}
+		min, err := time.ParseDuration(bounds[0])
+		if err != nil || min < 0 {
+			return ThinkTime{}, invalid
+		}
+		max, err := time.ParseDuration(bounds[1])
+		if err != nil || max < min {
+			return ThinkTime{}, invalid
+		}
+		return ThinkTime{Distribution: "uniform", Min: min, Max: max}, nil
+	}
+	return ThinkTime{}, invalid
+}
+
+func (t ThinkTime) next(r *rand.Rand) time.Duration {
+	switch t.Distribution {
+	case "exp":
+		return time.Duration(r.ExpFloat64() * float64(t.Min))
+	case "uniform":
+		return t.Min + time.Duration(r.Int63n(int64(t.Max-t.Min)+1))
+	}
+	return t.Min
 }
 
 // transactionRate is Time between transactions; this defines the workload rate
@@ -23,73 +291,204 @@ type Worker struct {
 //
 // If transactionRate is 0, we go as fast as we can, this is used to measure throughput
 // If numTransactions is 0, we go until stopCh tells us to stop
+// If pause is set, we hold off on starting new transactions while it is paused
 func (w *Worker) RunBenchmark(wrk ClientWorkload, databaseName string, transactionRate time.Duration,
-	numTransactions uint64, stopCh <-chan struct{}, recorder *ResultRecorder) WorkerResult {
-	session, err := w.driver.NewSession(neo4j.SessionConfig{
-		AccessMode:   neo4j.AccessModeWrite,
-		DatabaseName: databaseName,
-	})
-	if err != nil {
-		return WorkerResult{WorkerId: w.workerId, Error: err}
-	}
-	defer session.Close()
-
+	numTransactions uint64, stopCh <-chan struct{}, pause *PauseControl, recorder *ResultRecorder) WorkerResult {
 	workStartTime := w.now()
 	recorder.totalStart = workStartTime
 	recorder.currentStart = workStartTime
 
-	nextStart := workStartTime
-
-	transactionCounter := uint64(0)
-
+	client := clientState{
+		wrk: wrk,
+		// Units of work share sessions, and with them the drivers bookmark chaining, unless they need bookmarks
+		// of their own or connections of their own
+		keepSession:     w.dial == nil && w.Bookmarks == BookmarksClient,
+		databaseName:    databaseName,
+		transactionRate: transactionRate,
+		numTransactions: numTransactions,
+		nextStart:       workStartTime,
+	}
+	defer client.closeSession()
 	for {
-		select {
-		case <-stopCh:
-			return recorder.Complete(w.now())
-		default:
+		wait, result := w.step(&client, stopCh, pause, recorder)
+		if result != nil {
+			return *result
 		}
-
-		uow, err := wrk.Next()
-		if err != nil {
-			return WorkerResult{WorkerId: w.workerId, Error: err}
+		if wait > 0 {
+			w.sleep(wait)
 		}
+	}
+}
 
-		outcome := w.runUnit(session, uow)
+// Where a client is between its units of work, see Worker.step
+type clientState struct {
+	wrk ClientWorkload
+	// If set, units of work run in session, which is opened as needed and replaced after Worker.SessionReuse of them
+	keepSession     bool
+	session         neo4j.Session
+	sessionUnits    int
+	databaseName    string
+	transactionRate time.Duration
+	numTransactions uint64
+	transactions    uint64
+	// When the next unit of work was due to start
+	nextStart time.Time
+	// Set when the next unit of work is due whenever the client gets to it, rather than at nextStart
+	restart bool
+}
+
+func (c *clientState) closeSession() {
+	if c.session != nil {
+		_ = c.session.Close()
+		c.session = nil
+	}
+}
 
-		uowLatency := w.now().Sub(nextStart)
+// Runs the next unit of work of client. Returns how long until the one after that is due, or the result of the
+// client if it's done, because it was stopped, crashed or ran numTransactions.
+func (w *Worker) step(client *clientState, stopCh <-chan struct{}, pause *PauseControl, recorder *ResultRecorder) (time.Duration, *WorkerResult) {
+	complete := func() (time.Duration, *WorkerResult) {
+		result := recorder.Complete(w.now())
+		return 0, &result
+	}
+	crash := func(err error) (time.Duration, *WorkerResult) {
+		return 0, &WorkerResult{WorkerId: w.workerId, Error: err}
+	}
+	select {
+	case <-stopCh:
+		return complete()
+	default:
+	}
+	if client.restart {
+		client.nextStart, client.restart = w.now(), false
+	}
 
-		if err = recorder.record(uow.ScriptName, uowLatency, outcome); err != nil {
-			return WorkerResult{WorkerId: w.workerId, Error: err}
+	if pause != nil && pause.Paused() {
+		if !pause.Wait(stopCh) {
+			return complete()
 		}
+		// Don't count the time we spent paused as the database falling behind the target rate
+		client.nextStart = w.now()
+	}
+	transactionRate := client.transactionRate
+	if w.Rate != nil {
+		transactionRate = w.Rate.Interval()
+	}
 
-		transactionCounter++
-		if numTransactions != 0 && transactionCounter >= numTransactions {
-			return recorder.Complete(w.now())
+	wrk := &client.wrk
+	uow, wait, err := wrk.NextAt(w.now())
+	if err != nil {
+		return crash(err)
+	}
+	if wait > 0 {
+		// Every script is held back by its rate limit; that's our doing, not the database falling behind
+		client.restart = true
+		return wait, nil
+	}
+	if w.ProfileSampleRate > 0 {
+		uow.Profile = wrk.Rand.Float64() < w.ProfileSampleRate
+	}
+
+	var backlog int64
+	if transactionRate > 0 {
+		if behind := w.now().Sub(client.nextStart); behind > 0 {
+			// Units of work scheduled after this one that are also due by now; with poisson arrivals
+			// this is an estimate based on the mean interval
+			backlog = int64(behind / transactionRate)
+			if w.MaxBacklog > 0 && backlog > w.MaxBacklog {
+				dropped := backlog - w.MaxBacklog
+				client.nextStart = client.nextStart.Add(time.Duration(dropped) * transactionRate)
+				backlog = w.MaxBacklog
+				recorder.recordDropped(dropped)
+			}
 		}
+	}
 
-		if transactionRate > 0 {
-			// Note something critical here: We don't add the actual time the unit took,
-			// we add the *max* time it *should* have taken. This means that if the database
-			// is not keeping up with the workload, nextStart will drift further and further
-			// behind wall clock time. This is what corrects for coordinated omission; we're measuring
-			// the start time given a rate of users showing up and making request that is independent
-			// of the rate the database processes them at.
-			//
-			// If the database isn't keeping up,
-			// then the latency numbers will grow extremely large, showing the actual wait time
-			// real users would see from when they ask the system to do something to when they get service.
-			if uowLatency < transactionRate {
-				w.sleep(transactionRate - uowLatency)
+	if client.keepSession {
+		if client.session != nil && w.SessionReuse > 0 && client.sessionUnits >= w.SessionReuse {
+			// The next session carries on from the writes of this one, as if it had been one session all along
+			if bookmark := client.session.LastBookmark(); bookmark != "" {
+				w.lastBookmark = bookmark
 			}
-			nextStart = nextStart.Add(transactionRate)
-		} else {
-			// No rate limit set, so just track when each transaction started; this effectively
-			// makes us coordinate with the database such that our workload rate exactly matches
-			// the databases ability to process - eg. this measures throughput, but makes the
-			// latencies useless
-			nextStart = time.Now()
+			client.closeSession()
+		}
+		if client.session == nil {
+			var bookmarks []string
+			if w.lastBookmark != "" {
+				bookmarks = []string{w.lastBookmark}
+			}
+			client.session = w.driver.NewSession(neo4j.SessionConfig{
+				AccessMode:   w.accessMode(wrk.Readonly),
+				DatabaseName: client.databaseName,
+				Bookmarks:    bookmarks,
+			})
+			client.sessionUnits = 0
+			recorder.recordSession()
 		}
 	}
+
+	dispatchStart := w.now()
+	recorder.begin()
+	var outcome uowOutcome
+	if client.keepSession {
+		outcome = w.runUnitInterruptibly(client.session, uow)
+		client.sessionUnits++
+	} else if w.dial == nil {
+		recorder.recordSession()
+		outcome = w.runUnitOnNewSession(w.driver, client.databaseName, uow)
+	} else {
+		recorder.recordSession()
+		var connectLatency time.Duration
+		outcome, connectLatency = w.runUnitOnNewConnection(client.databaseName, uow)
+		if err = recorder.recordConnect(connectLatency); err != nil {
+			return crash(err)
+		}
+	}
+
+	select {
+	case <-stopCh:
+		if !outcome.succeeded {
+			// Most likely cancelled by TxDeadline as we were shutting down, rather than the database failing
+			recorder.end()
+			return complete()
+		}
+	default:
+	}
+
+	uowLatency := w.now().Sub(client.nextStart)
+	outcome.serviceLatency = w.now().Sub(dispatchStart)
+	outcome.queueTime = dispatchStart.Sub(client.nextStart)
+	outcome.backlog = backlog
+
+	if err = recorder.record(uow.ScriptName, uowLatency, outcome); err != nil {
+		return crash(err)
+	}
+
+	client.transactions++
+	if client.numTransactions != 0 && client.transactions >= client.numTransactions {
+		return complete()
+	}
+
+	if transactionRate > 0 {
+		// Note something critical here: We don't add the actual time the unit took,
+		// we add the *max* time it *should* have taken. This means that if the database
+		// is not keeping up with the workload, nextStart will drift further and further
+		// behind wall clock time. This is what corrects for coordinated omission; we're measuring
+		// the start time given a rate of users showing up and making request that is independent
+		// of the rate the database processes them at.
+		//
+		// If the database isn't keeping up,
+		// then the latency numbers will grow extremely large, showing the actual wait time
+		// real users would see from when they ask the system to do something to when they get service.
+		client.nextStart = client.nextStart.Add(w.Arrival.nextInterval(transactionRate, wrk.Rand))
+		return client.nextStart.Sub(w.now()), nil
+	}
+	// No rate limit set, so just track when each transaction started; this effectively
+	// makes us coordinate with the database such that our workload rate exactly matches
+	// the databases ability to process - eg. this measures throughput, but makes the
+	// latencies useless
+	client.restart = true
+	return w.ThinkTime.next(wrk.Rand), nil
 }
 
 func (w *Worker) gatherResults(workloadStats map[string]*ScriptResult, workStartTime time.Time) []ScriptResult {
@@ -107,47 +506,220 @@ func (w *Worker) gatherResults(workloadStats map[string]*ScriptResult, workStart
 }
 
 func (w *Worker) runUnit(session neo4j.Session, uow UnitOfWork) uowOutcome {
+	// The driver may retry the transaction function; we only keep timings from the last attempt
+	var statementLatencies []time.Duration
+	failedStatement := -1
+	attempts := 0
+	// Counted across attempts, so conflicts the driver retried past are still visible
+	deadlocks, lockWaitAborts := 0, 0
+	var acquireTime time.Duration
+	var metricValues []int64
+	var profiles []*profileSample
+	var dataError string
+	txStart := w.now()
+	fail := func(i int, err error) (interface{}, error) {
+		failedStatement = i
+		switch ClassifyErrorGroup(groupError(err)) {
+		case ErrorClassDeadlock:
+			deadlocks++
+			if w.FailOnDeadlock {
+				return nil, nonRetriableError{err}
+			}
+		case ErrorClassLockContention:
+			lockWaitAborts++
+		}
+		return nil, err
+	}
 	transaction := func(tx neo4j.Transaction) (interface{}, error) {
-		for _, s := range uow.Statements {
-			res, err := tx.Run(s.Query, s.Params)
+		attempts++
+		if attempts == 1 {
+			acquireTime = w.now().Sub(txStart)
+		}
+		statementLatencies = statementLatencies[:0]
+		metricValues = metricValues[:0]
+		profiles = profiles[:0]
+		dataError = ""
+		failedStatement = -1
+		for i, s := range uow.Statements {
+			query := s.Query
+			if uow.Profile {
+				query = profileQuery(query)
+			}
+			statementStart := w.now()
+			res, err := tx.Run(query, s.Params)
 			if err != nil {
-				return nil, err
+				return fail(i, err)
+			}
+			collectMetric := uow.Metric != "" && i == len(uow.Statements)-1
+			if collectMetric || len(s.Expect) > 0 {
+				var rows int64
+				mismatch := ""
+				for res.Next() {
+					rows++
+					if collectMetric {
+						if value, ok := res.Record().Get(uow.Metric); ok {
+							if n, ok := value.(int64); ok {
+								metricValues = append(metricValues, n)
+							}
+						}
+					}
+					if len(s.Expect) > 0 && mismatch == "" {
+						mismatch = checkRow(s.Expect, res.Record(), rows)
+					}
+				}
+				if len(s.Expect) > 0 && mismatch == "" {
+					mismatch = checkRowCount(s.Expect, rows)
+				}
+				if mismatch != "" && dataError == "" {
+					dataError = fmt.Sprintf("statement %d: %s", i+1, mismatch)
+				}
+			} else {
+				w.Consume.read(res)
 			}
-			_, err = res.Consume()
+			summary, err := res.Consume()
 			if err != nil {
-				return nil, err
+				return fail(i, err)
 			}
+			if uow.Profile {
+				profiles = append(profiles, newProfileSample(summary.Profile()))
+			}
+			statementLatencies = append(statementLatencies, w.now().Sub(statementStart))
 		}
 		return nil, nil
 	}
 
+	var config []func(*neo4j.TransactionConfig)
+	if !w.TxDeadline.IsZero() {
+		remaining := w.TxDeadline.Sub(w.now())
+		if remaining < time.Millisecond {
+			remaining = time.Millisecond
+		}
+		config = append(config, neo4j.WithTxTimeout(remaining))
+	}
+
 	var err error
-	if uow.Readonly {
-		_, err = session.ReadTransaction(transaction)
+	if w.accessMode(uow.Readonly) == neo4j.AccessModeRead {
+		_, err = session.ReadTransaction(transaction, config...)
 	} else {
-		_, err = session.WriteTransaction(transaction)
+		_, err = session.WriteTransaction(transaction, config...)
 	}
 
 	if err != nil {
 		return uowOutcome{
-			succeeded:    false,
-			failureGroup: groupError(err),
-			err:          err,
+			succeeded:       false,
+			failureGroup:    groupError(err),
+			err:             err,
+			failedStatement: failedStatement,
+			statements:      uow.Statements,
+			attempts:        attempts,
+			deadlocks:       deadlocks,
+			lockWaitAborts:  lockWaitAborts,
+			acquireTime:     acquireTime,
 		}
 	}
 
-	return uowOutcome{succeeded: true}
+	return uowOutcome{
+		succeeded:          true,
+		failedStatement:    -1,
+		statements:         uow.Statements,
+		statementLatencies: statementLatencies,
+		attempts:           attempts,
+		deadlocks:          deadlocks,
+		lockWaitAborts:     lockWaitAborts,
+		acquireTime:        acquireTime,
+		metric:             uow.Metric,
+		metricValues:       metricValues,
+		profiles:           profiles,
+		dataError:          dataError,
+	}
+}
+
+// Read-only work is routed to read replicas in a cluster, unless ForceWriteRouting is set
+func (w *Worker) accessMode(readonly bool) neo4j.AccessMode {
+	if readonly && !w.ForceWriteRouting {
+		return neo4j.AccessModeRead
+	}
+	return neo4j.AccessModeWrite
+}
+
+// Hides an error from the drivers retry logic, which only retries its own error types; the message is
+// unchanged so the error is grouped the same way
+type nonRetriableError struct {
+	cause error
+}
+
+func (e nonRetriableError) Error() string {
+	return e.cause.Error()
+}
+
+func (e nonRetriableError) Cause() error {
+	return e.cause
+}
+
+// Opens a new driver and connection, runs the unit of work on it and closes it again. Returns the outcome
+// along with how long it took to establish the connection.
+func (w *Worker) runUnitOnNewConnection(databaseName string, uow UnitOfWork) (uowOutcome, time.Duration) {
+	connectStart := w.now()
+	driver, err := w.dial()
+	if err != nil {
+		return uowOutcome{succeeded: false, failureGroup: groupError(err), err: err, failedStatement: -1}, w.now().Sub(connectStart)
+	}
+	defer driver.Close()
+	err = driver.VerifyConnectivity()
+	connectLatency := w.now().Sub(connectStart)
+	if err != nil {
+		return uowOutcome{succeeded: false, failureGroup: groupError(err), err: err, failedStatement: -1}, connectLatency
+	}
+
+	return w.runUnitOnNewSession(driver, databaseName, uow), connectLatency
+}
+
+// Runs the unit of work in a session of its own, which waits for the earlier writes the workers Bookmarks call for
+func (w *Worker) runUnitOnNewSession(driver neo4j.Driver, databaseName string, uow UnitOfWork) uowOutcome {
+	var bookmarks []string
+	switch w.Bookmarks {
+	case BookmarksClient:
+		if w.lastBookmark != "" {
+			bookmarks = []string{w.lastBookmark}
+		}
+	case BookmarksShared:
+		bookmarks = w.SharedBookmarks.all()
+	}
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   w.accessMode(uow.Readonly),
+		DatabaseName: databaseName,
+		Bookmarks:    bookmarks,
+	})
+	defer session.Close()
+
+	outcome := w.runUnitInterruptibly(session, uow)
+	if outcome.succeeded && !uow.Readonly {
+		if bookmark := session.LastBookmark(); bookmark != "" {
+			w.lastBookmark = bookmark
+			if w.Bookmarks == BookmarksShared {
+				w.SharedBookmarks.set(w.workerId, bookmark)
+			}
+		}
+	}
+	return outcome
 }
 
 // Converts a total target rate into a per-client "pacing" duration, used to slow down workers to match
 // the target rate.
 func TotalRatePerSecondToDurationPerClient(numClients int, rate float64) time.Duration {
-	ratePerWorkerPerSecond := rate / float64(numClients)
-	return time.Duration(1000*1000/ratePerWorkerPerSecond) * time.Microsecond
+	return RatePerClientToDuration(rate / float64(numClients))
+}
+
+// Converts a per-client target rate into a pacing duration; rate may be well below 1, for clients that
+// should only trickle in the occasional transaction.
+func RatePerClientToDuration(ratePerClientPerSecond float64) time.Duration {
+	return time.Duration(float64(time.Second) / ratePerClientPerSecond)
 }
 
 // Concurrent data structure; used by the worker to record progress, accessible from other threads
-// to read progress checkpoints.
+// to read progress checkpoints. Each worker has its own, so workers never wait on each other, and each sample
+// is only recorded in the stats of the current progress interval, which are added to the total as the interval
+// ends; recording a sample takes around 100ns, see BenchmarkResultRecorder.
 type ResultRecorder struct {
 	mut sync.Mutex
 
@@ -155,9 +727,15 @@ type ResultRecorder struct {
 	current      WorkerResult
 	currentStart time.Time
 
-	// Total since the workload started
+	// Total since the workload started, up to the last progress report
 	total      WorkerResult
 	totalStart time.Time
+
+	// 1 while the worker is executing a unit of work, accessed atomically
+	inFlight int32
+
+	// Notified of each unit of work recorded
+	observers []TransactionObserver
 }
 
 func NewResultRecorder(workerId int64) *ResultRecorder {
@@ -167,14 +745,57 @@ func NewResultRecorder(workerId int64) *ResultRecorder {
 	}
 }
 
+func (t *ResultRecorder) begin() {
+	atomic.StoreInt32(&t.inFlight, 1)
+}
+
+// Marks the unit of work begun last as done, for when it's dropped rather than recorded
+func (t *ResultRecorder) end() {
+	atomic.StoreInt32(&t.inFlight, 0)
+}
+
+// Registers an observer to be notified of each unit of work as it is recorded; must be called before the
+// worker starts
+func (t *ResultRecorder) Observe(observer TransactionObserver) {
+	t.observers = append(t.observers, observer)
+}
+
 func (t *ResultRecorder) record(scriptName string, latency time.Duration, outcome uowOutcome) error {
+	t.end()
+	for _, observer := range t.observers {
+		observer.ObserveTransaction(t.total.WorkerId, scriptName, latency, TransactionOutcome{
+			Succeeded:          outcome.succeeded,
+			FailureGroup:       outcome.failureGroup,
+			Attempts:           outcome.attempts,
+			Statements:         outcome.statements,
+			StatementLatencies: outcome.statementLatencies,
+		})
+	}
 	t.mut.Lock()
 	defer t.mut.Unlock()
 
-	if err := t.current.record(scriptName, latency, outcome); err != nil {
-		return err
-	}
-	return t.total.record(scriptName, latency, outcome)
+	return t.current.record(scriptName, latency, outcome)
+}
+
+func (t *ResultRecorder) recordConnect(latency time.Duration) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	return t.current.recordConnect(latency)
+}
+
+func (t *ResultRecorder) recordDropped(n int64) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.current.Dropped += n
+}
+
+func (t *ResultRecorder) recordSession() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.current.SessionsOpened++
 }
 
 // Reports progress since last time you called this function
@@ -187,16 +808,38 @@ func (t *ResultRecorder) ProgressReport(now time.Time) WorkerResult {
 	delta := now.Sub(t.currentStart)
 	out.calculateRate(delta)
 
+	t.total.merge(out)
 	t.current = NewWorkerResult(out.WorkerId)
 	t.currentStart = now
 
 	return out
 }
 
+// True if the worker is currently executing a unit of work
+func (t *ResultRecorder) InFlight() bool {
+	return atomic.LoadInt32(&t.inFlight) == 1
+}
+
+// Copy of the totals recorded since the workload started, without resetting anything; rates are not calculated
+func (t *ResultRecorder) Snapshot() WorkerResult {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	out := NewWorkerResult(t.total.WorkerId)
+	out.merge(t.total)
+	out.merge(t.current)
+	for _, script := range out.Scripts {
+		script.Rate = 0
+	}
+	return out
+}
+
 func (t *ResultRecorder) Complete(now time.Time) WorkerResult {
 	t.mut.Lock()
 	defer t.mut.Unlock()
 
+	t.total.merge(t.current)
+	t.current = NewWorkerResult(t.total.WorkerId)
 	out := t.total
 
 	delta := now.Sub(t.totalStart)
@@ -215,6 +858,10 @@ func NewWorkerResult(workerId int64) WorkerResult {
 		WorkerId:           workerId,
 		Scripts:            make(map[string]*ScriptResult),
 		FailedByErrorGroup: make(map[string]FailureGroup),
+		ConnectLatencies:   hdrhistogram.New(0, 60*60*1000000, 3),
+		QueueTimes:         hdrhistogram.New(0, 60*60*1000000, 3),
+		Backlog:            hdrhistogram.New(0, 60*60*1000000, 3),
+		AcquireTimes:       hdrhistogram.New(0, 60*60*1000000, 3),
 	}
 }
 
@@ -230,6 +877,38 @@ type WorkerResult struct {
 
 	// Failure counts by cause
 	FailedByErrorGroup map[string]FailureGroup
+
+	// Time taken to establish connections, only recorded when running with a connection per transaction
+	ConnectLatencies *hdrhistogram.Histogram
+
+	// Time each unit of work waited past its scheduled start, and how many others were due behind it as it
+	// started; only meaningful when running at a set rate
+	QueueTimes *hdrhistogram.Histogram
+	Backlog    *hdrhistogram.Histogram
+	// Scheduled units of work skipped because the backlog was full
+	Dropped int64
+	// Sessions opened to run units of work in, see Worker.SessionReuse
+	SessionsOpened int64
+
+	// Time from asking the driver for a transaction until it was ready to run statements: borrowing or opening
+	// a connection and beginning the transaction
+	AcquireTimes *hdrhistogram.Histogram
+}
+
+// Adds the stats of other to these, copying what it has that these don't; rates are added up as well
+func (r *WorkerResult) merge(other WorkerResult) {
+	combined := Result{
+		Scripts:            r.Scripts,
+		FailedByErrorGroup: r.FailedByErrorGroup,
+		ConnectLatencies:   r.ConnectLatencies,
+		QueueTimes:         r.QueueTimes,
+		Backlog:            r.Backlog,
+		AcquireTimes:       r.AcquireTimes,
+		Dropped:            r.Dropped,
+		SessionsOpened:     r.SessionsOpened,
+	}
+	combined.Add(other)
+	r.Dropped, r.SessionsOpened = combined.Dropped, combined.SessionsOpened
 }
 
 func (r *WorkerResult) getOrCreateScriptResult(scriptName string) *ScriptResult {
@@ -249,19 +928,77 @@ func (r *WorkerResult) record(scriptName string, latency time.Duration, outcome
 	stats, found := r.Scripts[scriptName]
 	if !found {
 		stats = &ScriptResult{
-			ScriptName: scriptName,
-			Latencies:  hdrhistogram.New(0, 60*60*1000000, 3),
+			ScriptName:       scriptName,
+			Latencies:        hdrhistogram.New(0, 60*60*1000000, 3),
+			ServiceLatencies: hdrhistogram.New(0, 60*60*1000000, 3),
 		}
 		r.Scripts[scriptName] = stats
 	}
 
+	if err := r.QueueTimes.RecordValue(outcome.queueTime.Microseconds()); err != nil {
+		return errors.Wrapf(err, "failed to record time in queue: %s", outcome.queueTime)
+	}
+	if err := r.Backlog.RecordValue(outcome.backlog); err != nil {
+		return errors.Wrapf(err, "failed to record backlog: %d", outcome.backlog)
+	}
+	if outcome.attempts > 0 {
+		if err := r.AcquireTimes.RecordValue(outcome.acquireTime.Microseconds()); err != nil {
+			return errors.Wrapf(err, "failed to record connection acquisition time: %s", outcome.acquireTime)
+		}
+	}
+
+	stats.Deadlocks += int64(outcome.deadlocks)
+	stats.LockWaitAborts += int64(outcome.lockWaitAborts)
+
 	if outcome.succeeded {
 		stats.Succeeded++
 		if err := stats.Latencies.RecordValue(latency.Microseconds()); err != nil {
 			return errors.Wrapf(err, "failed to record latency: %s", latency)
 		}
+		if err := stats.ServiceLatencies.RecordValue(outcome.serviceLatency.Microseconds()); err != nil {
+			return errors.Wrapf(err, "failed to record service latency: %s", outcome.serviceLatency)
+		}
+		if outcome.metric != "" {
+			if stats.MetricValues == nil {
+				stats.Metric = outcome.metric
+				stats.MetricValues = hdrhistogram.New(0, 60*60*1000000, 3)
+			}
+			for _, value := range outcome.metricValues {
+				if err := stats.MetricValues.RecordValue(value); err != nil {
+					return errors.Wrapf(err, "failed to record %s: %d", outcome.metric, value)
+				}
+			}
+		}
+		for i, statementLatency := range outcome.statementLatencies {
+			statement := stats.statement(i, outcome.statements[i].Query)
+			if err := statement.Latencies.RecordValue(statementLatency.Microseconds()); err != nil {
+				return errors.Wrapf(err, "failed to record statement latency: %s", statementLatency)
+			}
+		}
+		if outcome.dataError != "" {
+			stats.DataErrors++
+			if stats.FirstDataError == "" {
+				stats.FirstDataError = outcome.dataError
+			}
+		}
+		for i, sample := range outcome.profiles {
+			if sample == nil {
+				continue
+			}
+			statement := stats.statement(i, outcome.statements[i].Query)
+			if statement.Profile == nil {
+				statement.Profile = newStatementProfile()
+			}
+			if err := statement.Profile.record(*sample); err != nil {
+				return err
+			}
+		}
 	} else {
 		stats.Failed++
+		// Failing to connect or open a session fails the unit of work before any of its statements ran
+		if outcome.failedStatement >= 0 && outcome.failedStatement < len(outcome.statements) {
+			stats.statement(outcome.failedStatement, outcome.statements[outcome.failedStatement].Query).Failed++
+		}
 		failedGroup, found := r.FailedByErrorGroup[outcome.failureGroup]
 		if !found {
 			r.FailedByErrorGroup[outcome.failureGroup] = FailureGroup{
@@ -278,6 +1015,13 @@ func (r *WorkerResult) record(scriptName string, latency time.Duration, outcome
 	return nil
 }
 
+func (r *WorkerResult) recordConnect(latency time.Duration) error {
+	if err := r.ConnectLatencies.RecordValue(latency.Microseconds()); err != nil {
+		return errors.Wrapf(err, "failed to record connection latency: %s", latency)
+	}
+	return nil
+}
+
 // Calculates the throughput rate for each script in this result, given the delta time it took the
 // workload to run.
 func (r *WorkerResult) calculateRate(delta time.Duration) {
@@ -292,19 +1036,36 @@ type FailureGroup struct {
 	FirstFailure error
 }
 
-func groupError(err error) string {
-	msg := err.Error()
-	if strings.HasPrefix(msg, "Server error: [") {
-		return strings.Split(strings.Split(msg, "[")[1], "]")[0]
-	}
-	return "unknown"
-}
-
 type uowOutcome struct {
 	succeeded bool
 	// An opaque string used to group errors; we track counts for each unique string
 	failureGroup string
 	err          error
+	// Statements in the unit of work, and how long each took if the unit succeeded
+	statements         []Statement
+	statementLatencies []time.Duration
+	// Index into statements of the statement that failed, or -1
+	failedStatement int
+	// Number of times the driver ran the transaction, more than 1 if it retried
+	attempts int
+	// Attempts aborted by a deadlock or by giving up waiting for a lock
+	deadlocks      int
+	lockWaitAborts int
+	// Time from when the unit of work actually started to when it completed; unlike the latency we
+	// record, this does not include time spent waiting for earlier units of work when behind schedule
+	serviceLatency time.Duration
+	// Time from when the unit of work was scheduled to when it started, and how many others were due behind it
+	queueTime time.Duration
+	backlog   int64
+	// Time until the driver first ran the transaction function, see WorkerResult.AcquireTimes
+	acquireTime time.Duration
+	// Values of the scripts metric column from the last attempt, see Script.Metric
+	metric       string
+	metricValues []int64
+	// Plans of each statement from the last attempt, if the unit of work was profiled
+	profiles []*profileSample
+	// First statement that returned something its \expect didn't, if any; see Statement.Expect
+	dataError string
 }
 
 func NewWorker(driver neo4j.Driver, workerId int64) *Worker {
@@ -315,3 +1076,14 @@ func NewWorker(driver neo4j.Driver, workerId int64) *Worker {
 		sleep:    time.Sleep,
 	}
 }
+
+// Creates a worker that opens a new driver and connection for each unit of work it runs, and closes it
+// once the unit of work is done, similar to pgbench -C
+func NewConnectPerTransactionWorker(dial DriverFactory, workerId int64) *Worker {
+	return &Worker{
+		workerId: workerId,
+		dial:     dial,
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
@@ -2,10 +2,12 @@ package neobench
 
 import (
 	"fmt"
-	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -33,13 +35,179 @@ func TestMaintainsRateInFaceOfFailure(t *testing.T) {
 	targetRatePerSecond := float64(1)
 	txDuration := TotalRatePerSecondToDurationPerClient(1, targetRatePerSecond)
 
-	result := w.RunBenchmark(newTestWorkload(r), "", txDuration, 100, stopCh, rec)
+	result := w.RunBenchmark(newTestWorkload(r), "", txDuration, 100, stopCh, nil, rec)
 
 	assert.NoError(t, result.Error)
 	sr := result.Scripts["workertest"]
 	assert.InDelta(t, targetRatePerSecond, sr.Rate, 0.1)
 }
 
+func TestRecordsServiceLatencySeparatelyWhenBehindSchedule(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	// Every transaction takes longer than the 1s between scheduled starts, so we fall further and further behind
+	driver := &fakeDriver{
+		clock:      clock,
+		r:          r,
+		minLatency: 1500 * time.Millisecond,
+		maxLatency: 2000 * time.Millisecond,
+	}
+	w := Worker{
+		workerId: 0,
+		driver:   driver,
+		now:      clock.now,
+		sleep:    clock.sleep,
+	}
+
+	result := w.RunBenchmark(newTestWorkload(r), "", time.Second, 20, make(chan struct{}), nil, NewResultRecorder(0))
+
+	assert.NoError(t, result.Error)
+	sr := result.Scripts["workertest"]
+	assert.Equal(t, int64(20), sr.ServiceLatencies.TotalCount())
+	assert.LessOrEqual(t, sr.ServiceLatencies.Max(), int64(2010*1000))
+	// Scheduled latency includes the time queued behind the earlier, slow, transactions
+	assert.Greater(t, sr.Latencies.Max(), int64(5*1000*1000))
+}
+
+func TestBoundedBacklogDropsOldestScheduledTransactions(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	// Each transaction takes about twice the 1s between scheduled starts
+	driver := &fakeDriver{
+		clock:      clock,
+		r:          r,
+		minLatency: 1500 * time.Millisecond,
+		maxLatency: 2500 * time.Millisecond,
+	}
+	w := Worker{
+		workerId:   0,
+		driver:     driver,
+		now:        clock.now,
+		sleep:      clock.sleep,
+		MaxBacklog: 3,
+	}
+
+	result := w.RunBenchmark(newTestWorkload(r), "", time.Second, 100, make(chan struct{}), nil, NewResultRecorder(0))
+
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(100), result.QueueTimes.TotalCount())
+	assert.LessOrEqual(t, result.Backlog.Max(), int64(3))
+	assert.Greater(t, result.Dropped, int64(50))
+	// With at most 3 queued ahead, nothing waits much more than 4 scheduled intervals
+	assert.LessOrEqual(t, result.QueueTimes.Max(), int64(4100*1000))
+	assert.LessOrEqual(t, result.Scripts["workertest"].Latencies.Max(), int64(6600*1000))
+}
+
+func TestPoissonArrivalMaintainsMeanRate(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	driver := &fakeDriver{
+		clock:      clock,
+		r:          r,
+		minLatency: 1 * time.Millisecond,
+		maxLatency: 5 * time.Millisecond,
+	}
+	var intervals []time.Duration
+	w := Worker{
+		workerId: 0,
+		driver:   driver,
+		now:      clock.now,
+		sleep: func(d time.Duration) {
+			intervals = append(intervals, d)
+			clock.sleep(d)
+		},
+		Arrival: ArrivalPoisson,
+	}
+
+	result := w.RunBenchmark(newTestWorkload(r), "", 100*time.Millisecond, 2000, make(chan struct{}), nil, NewResultRecorder(0))
+
+	assert.NoError(t, result.Error)
+	assert.InDelta(t, 10, result.Scripts["workertest"].Rate, 0.5)
+	// Unlike uniform arrivals, the gaps between transactions vary
+	distinct := make(map[time.Duration]bool)
+	for _, d := range intervals {
+		distinct[d] = true
+	}
+	assert.Greater(t, len(distinct), 100)
+}
+
+func TestParseArrival(t *testing.T) {
+	arrival, err := ParseArrival("poisson")
+	assert.NoError(t, err)
+	assert.Equal(t, ArrivalPoisson, arrival)
+	_, err = ParseArrival("bursty")
+	assert.Error(t, err)
+}
+
+func TestThinkTimeIsExcludedFromLatency(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	driver := &fakeDriver{
+		clock:      clock,
+		r:          r,
+		minLatency: 1 * time.Millisecond,
+		maxLatency: 5 * time.Millisecond,
+	}
+	w := Worker{
+		workerId:  0,
+		driver:    driver,
+		now:       clock.now,
+		sleep:     clock.sleep,
+		ThinkTime: ThinkTime{Distribution: "fixed", Min: 95 * time.Millisecond},
+	}
+
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 1000, make(chan struct{}), nil, NewResultRecorder(0))
+
+	assert.NoError(t, result.Error)
+	script := result.Scripts["workertest"]
+	// Each client goes through one transaction every ~100ms, but only the ~3ms in the database counts as latency
+	assert.InDelta(t, 10, script.Rate, 0.5)
+	assert.Less(t, script.Latencies.Max(), int64(6000))
+}
+
+func TestCountsFailuresToConnectPerTransaction(t *testing.T) {
+	dials := 0
+	w := NewConnectPerTransactionWorker(func() (neo4j.Driver, error) {
+		dials++
+		return nil, fmt.Errorf("Connection error: dial tcp db:7687: connect: connection refused")
+	}, 0)
+
+	result := w.RunBenchmark(newTestWorkload(rand.New(rand.NewSource(1337))), "", 0, 3, make(chan struct{}), nil, NewResultRecorder(0))
+
+	assert.NoError(t, result.Error)
+	assert.Equal(t, 3, dials)
+	assert.Equal(t, int64(3), result.Scripts["workertest"].Failed)
+	assert.Empty(t, result.Scripts["workertest"].Statements)
+	assert.Len(t, result.FailedByErrorGroup, 1)
+}
+
+func TestParseThinkTime(t *testing.T) {
+	think, err := ParseThinkTime("500ms")
+	assert.NoError(t, err)
+	assert.Equal(t, ThinkTime{Distribution: "fixed", Min: 500 * time.Millisecond}, think)
+
+	think, err = ParseThinkTime("exp:2s")
+	assert.NoError(t, err)
+	assert.Equal(t, ThinkTime{Distribution: "exp", Min: 2 * time.Second}, think)
+
+	think, err = ParseThinkTime("uniform:100ms-1s")
+	assert.NoError(t, err)
+	assert.Equal(t, ThinkTime{Distribution: "uniform", Min: 100 * time.Millisecond, Max: time.Second}, think)
+	for i := 0; i < 100; i++ {
+		d := think.next(rand.New(rand.NewSource(int64(i))))
+		assert.True(t, d >= 100*time.Millisecond && d <= time.Second)
+	}
+
+	for _, invalid := range []string{"soon", "exp:", "uniform:1s", "uniform:2s-1s", "normal:1s"} {
+		_, err = ParseThinkTime(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
 func newTestWorkload(r *rand.Rand) ClientWorkload {
 	script, err := Parse("workertest", `RETURN 1;`, 1)
 	if err != nil {
@@ -81,18 +249,22 @@ func (d *fakeDriver) Target() url.URL {
 	panic("implement me")
 }
 
-func (d *fakeDriver) Session(accessMode neo4j.AccessMode, bookmarks ...string) (neo4j.Session, error) {
-	panic("implement me")
+func (d *fakeDriver) IsEncrypted() bool {
+	return false
 }
 
-func (d *fakeDriver) NewSession(config neo4j.SessionConfig) (neo4j.Session, error) {
-	return d, nil
+func (d *fakeDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return d
 }
 
 func (d *fakeDriver) Close() error {
 	return nil
 }
 
+func (d *fakeDriver) LastBookmarks() neo4j.Bookmarks {
+	panic("implement me")
+}
+
 func (d *fakeDriver) LastBookmark() string {
 	panic("implement me")
 }
@@ -125,3 +297,516 @@ func (d *fakeDriver) Run(cypher string, params map[string]interface{}, configure
 var _ neo4j.Driver = &fakeDriver{}
 
 var _ neo4j.Session = &fakeDriver{}
+
+func TestRatePerClientToDuration(t *testing.T) {
+	assert.Equal(t, 100*time.Second, RatePerClientToDuration(0.01))
+	assert.Equal(t, 250*time.Millisecond, RatePerClientToDuration(4))
+	assert.Equal(t, 400*time.Millisecond, TotalRatePerSecondToDurationPerClient(10, 25))
+}
+
+func TestRecordsStatementLatencies(t *testing.T) {
+	statements := []Statement{{Query: "MATCH (a) RETURN a"}, {Query: "CREATE (b)"}}
+	res := NewWorkerResult(0)
+
+	assert.NoError(t, res.record("s", 3*time.Millisecond, uowOutcome{
+		succeeded:          true,
+		failedStatement:    -1,
+		statements:         statements,
+		statementLatencies: []time.Duration{1 * time.Millisecond, 2 * time.Millisecond},
+	}))
+	assert.NoError(t, res.record("s", 0, uowOutcome{
+		succeeded:       false,
+		failureGroup:    "unknown",
+		err:             fmt.Errorf("boom"),
+		failedStatement: 1,
+		statements:      statements,
+	}))
+
+	stmts := res.Scripts["s"].Statements
+	assert.Len(t, stmts, 2)
+	assert.Equal(t, "CREATE (b)", stmts[1].Query)
+	assert.Equal(t, int64(1), stmts[0].Latencies.TotalCount())
+	assert.Equal(t, int64(0), stmts[0].Failed)
+	assert.Equal(t, int64(1), stmts[1].Failed)
+	assert.InDelta(t, 2000, stmts[1].Latencies.Max(), 5)
+}
+
+func TestCountsDeadlocksIncludingRetriedAttempts(t *testing.T) {
+	uow := UnitOfWork{ScriptName: "s", Statements: []Statement{{Query: "CREATE (a)"}}}
+	w := NewWorker(nil, 0)
+
+	outcome := w.runUnit(&deadlockingSession{deadlocks: 2}, uow)
+
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, 3, outcome.attempts)
+	assert.Equal(t, 2, outcome.deadlocks)
+
+	w.FailOnDeadlock = true
+	outcome = w.runUnit(&deadlockingSession{deadlocks: 2}, uow)
+
+	assert.False(t, outcome.succeeded)
+	assert.Equal(t, 1, outcome.attempts)
+	assert.Equal(t, 1, outcome.deadlocks)
+	assert.Equal(t, "Neo.TransientError.Transaction.DeadlockDetected", outcome.failureGroup)
+
+	res := NewWorkerResult(0)
+	assert.NoError(t, res.record("s", time.Millisecond, outcome))
+	assert.Equal(t, int64(1), res.Scripts["s"].Deadlocks)
+}
+
+// Fails the first few attempts with a deadlock, retrying them like the driver does with transient errors
+type deadlockingSession struct {
+	neo4j.Session
+	deadlocks int
+}
+
+type fakeTransientError struct {
+	msg string
+}
+
+func (e fakeTransientError) Error() string {
+	return e.msg
+}
+
+func (s *deadlockingSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	for {
+		res, err := work(&deadlockingTx{session: s})
+		if _, retriable := err.(fakeTransientError); !retriable {
+			return res, err
+		}
+	}
+}
+
+type deadlockingTx struct {
+	neo4j.Transaction
+	session *deadlockingSession
+}
+
+func (tx *deadlockingTx) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	if tx.session.deadlocks > 0 {
+		tx.session.deadlocks--
+		return nil, fakeTransientError{"Server error: [Neo.TransientError.Transaction.DeadlockDetected] ForsetiClient can't acquire lock"}
+	}
+	return consumedResult{}, nil
+}
+
+type consumedResult struct {
+	neo4j.Result
+}
+
+func (consumedResult) Consume() (neo4j.ResultSummary, error) {
+	return nil, nil
+}
+
+func TestRecordsMetricFromLastStatement(t *testing.T) {
+	uow := UnitOfWork{ScriptName: "s", Metric: "pathLength", Statements: []Statement{{Query: "MATCH (a) RETURN a"}, {Query: "MATCH p=() RETURN length(p) AS pathLength"}}}
+	w := NewWorker(nil, 0)
+
+	outcome := w.runUnit(&rowsSession{rows: []int64{2, 3, 3}}, uow)
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, []int64{2, 3, 3}, outcome.metricValues)
+
+	res := NewWorkerResult(0)
+	assert.NoError(t, res.record("s", time.Millisecond, outcome))
+	script := res.Scripts["s"]
+	assert.Equal(t, "pathLength", script.Metric)
+	assert.Equal(t, int64(3), script.MetricValues.TotalCount())
+	assert.Equal(t, int64(3), script.MetricValues.Max())
+
+	combined := NewResult("db", "")
+	combined.Add(res)
+	combined.Add(res)
+	assert.Equal(t, int64(6), combined.Scripts["s"].MetricValues.TotalCount())
+}
+
+// Every statement returns the same rows, each a single pathLength column
+type rowsSession struct {
+	neo4j.Session
+	rows []int64
+}
+
+func (s *rowsSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(&rowsTx{rows: s.rows})
+}
+
+type rowsTx struct {
+	neo4j.Transaction
+	rows []int64
+}
+
+func (tx *rowsTx) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	return &rowsResult{rows: tx.rows, next: -1}, nil
+}
+
+type rowsResult struct {
+	neo4j.Result
+	rows []int64
+	next int
+}
+
+func (r *rowsResult) Next() bool {
+	r.next++
+	return r.next < len(r.rows)
+}
+
+func (r *rowsResult) Record() *neo4j.Record {
+	return &neo4j.Record{Keys: []string{"pathLength"}, Values: []interface{}{r.rows[r.next]}}
+}
+
+func (r *rowsResult) Consume() (neo4j.ResultSummary, error) {
+	return nil, nil
+}
+
+func TestDoesNotCountTransactionsCancelledByShutdownAsFailures(t *testing.T) {
+	stopCh := make(chan struct{})
+	w := NewWorker(&stoppingDriver{stopCh: stopCh}, 0)
+	w.TxDeadline = time.Now()
+
+	recorder := NewResultRecorder(0)
+	result := w.RunBenchmark(newTestWorkload(rand.New(rand.NewSource(1337))), "", 0, 0, stopCh, nil, recorder)
+
+	assert.NoError(t, result.Error)
+	assert.Empty(t, result.Scripts)
+	assert.Empty(t, result.FailedByErrorGroup)
+	assert.False(t, recorder.InFlight())
+}
+
+// Stops the benchmark while a transaction is in flight, which then fails the way a timed out one would
+type stoppingDriver struct {
+	neo4j.Driver
+	stopCh chan struct{}
+}
+
+func (d *stoppingDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return &stoppingSession{stopCh: d.stopCh}
+}
+
+type stoppingSession struct {
+	neo4j.Session
+	stopCh chan struct{}
+}
+
+func (s *stoppingSession) Close() error {
+	return nil
+}
+
+func (s *stoppingSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	config := neo4j.TransactionConfig{}
+	for _, c := range configurers {
+		c(&config)
+	}
+	if config.Timeout <= 0 {
+		panic("expected a transaction timeout from TxDeadline")
+	}
+	close(s.stopCh)
+	return nil, fmt.Errorf("Server error: [Neo.ClientError.Transaction.TransactionTimedOut] timed out")
+}
+
+func TestRoutesReadOnlyWorkToReaders(t *testing.T) {
+	w := NewWorker(nil, 0)
+	assert.Equal(t, neo4j.AccessModeRead, w.accessMode(true))
+	assert.Equal(t, neo4j.AccessModeWrite, w.accessMode(false))
+
+	w.ForceWriteRouting = true
+	assert.Equal(t, neo4j.AccessModeWrite, w.accessMode(true))
+
+	reads := Workload{Scripts: NewScripts(Script{Name: "a", Weight: 1, Readonly: true}), Rand: rand.New(rand.NewSource(1))}
+	assert.True(t, reads.NewClient().Readonly)
+	mixed := Workload{Scripts: NewScripts(Script{Name: "a", Weight: 1, Readonly: true}, Script{Name: "b", Weight: 1}), Rand: rand.New(rand.NewSource(1))}
+	assert.False(t, mixed.NewClient().Readonly)
+}
+
+func TestChainsBookmarksFromWritesIntoLaterUnits(t *testing.T) {
+	driver := &bookmarkDriver{}
+	write := UnitOfWork{ScriptName: "write", Statements: []Statement{{Query: "CREATE ()"}}}
+	read := UnitOfWork{ScriptName: "read", Readonly: true, Statements: []Statement{{Query: "MATCH (n) RETURN n"}}}
+
+	client := NewWorker(driver, 0)
+	client.runUnitOnNewSession(driver, "neo4j", write)
+	client.runUnitOnNewSession(driver, "neo4j", read)
+	assert.Equal(t, [][]string{nil, {"bookmark-1"}}, driver.bookmarks)
+
+	driver = &bookmarkDriver{}
+	eventual := NewWorker(driver, 0)
+	eventual.Bookmarks = BookmarksNone
+	eventual.runUnitOnNewSession(driver, "neo4j", write)
+	eventual.runUnitOnNewSession(driver, "neo4j", read)
+	assert.Equal(t, [][]string{nil, nil}, driver.bookmarks)
+
+	driver = &bookmarkDriver{}
+	shared := NewSharedBookmarks()
+	writer, reader := NewWorker(driver, 0), NewWorker(driver, 1)
+	writer.Bookmarks, writer.SharedBookmarks = BookmarksShared, shared
+	reader.Bookmarks, reader.SharedBookmarks = BookmarksShared, shared
+	writer.runUnitOnNewSession(driver, "neo4j", write)
+	reader.runUnitOnNewSession(driver, "neo4j", read)
+	// Reads don't move the bookmarks on
+	reader.runUnitOnNewSession(driver, "neo4j", read)
+	assert.Equal(t, [][]string{{}, {"bookmark-1"}, {"bookmark-1"}}, driver.bookmarks)
+}
+
+func TestReplacesSessionsAfterSessionReuseUnitsOfWork(t *testing.T) {
+	driver := &bookmarkDriver{}
+	w := NewWorker(driver, 0)
+	w.SessionReuse = 2
+
+	result := w.RunBenchmark(newTestWorkload(rand.New(rand.NewSource(1337))), "neo4j", 0, 5, make(chan struct{}), nil, NewResultRecorder(0))
+
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(3), result.SessionsOpened)
+	// Each new session carries on from the writes of the one before
+	assert.Equal(t, [][]string{nil, {"bookmark-2"}, {"bookmark-4"}}, driver.bookmarks)
+}
+
+func TestParseSessionReuse(t *testing.T) {
+	for spec, expected := range map[string]int{"lifetime": 0, "transaction": 1, "50": 50} {
+		n, err := ParseSessionReuse(spec)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, n)
+	}
+	for _, spec := range []string{"0", "-1", "forever"} {
+		_, err := ParseSessionReuse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// Records the bookmarks each session starts from, and gives each write transaction a new bookmark
+type bookmarkDriver struct {
+	neo4j.Driver
+	bookmarks [][]string
+	commits   int
+}
+
+func (d *bookmarkDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	d.bookmarks = append(d.bookmarks, config.Bookmarks)
+	return &bookmarkSession{driver: d}
+}
+
+type bookmarkSession struct {
+	neo4j.Session
+	driver   *bookmarkDriver
+	bookmark string
+}
+
+func (s *bookmarkSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	s.bookmark = fmt.Sprintf("bookmark-%d", s.driver.commits)
+	return work(&rowsTx{})
+}
+
+func (s *bookmarkSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	s.driver.commits++
+	s.bookmark = fmt.Sprintf("bookmark-%d", s.driver.commits)
+	return work(&rowsTx{})
+}
+
+func (s *bookmarkSession) LastBookmark() string {
+	return s.bookmark
+}
+
+func (s *bookmarkSession) Close() error {
+	return nil
+}
+
+func TestProfilesSampledUnitsOfWork(t *testing.T) {
+	uow := UnitOfWork{ScriptName: "s", Profile: true, Statements: []Statement{{Query: "MATCH (a:Account {aid: $aid}) RETURN a"}, {Query: "EXPLAIN CREATE (b)"}}}
+	w := NewWorker(nil, 0)
+	session := &profilingSession{}
+
+	outcome := w.runUnit(session, uow)
+
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, []string{"PROFILE MATCH (a:Account {aid: $aid}) RETURN a", "EXPLAIN CREATE (b)"}, session.queries)
+
+	res := NewWorkerResult(0)
+	assert.NoError(t, res.record("s", time.Millisecond, outcome))
+	assert.NoError(t, res.record("s", time.Millisecond, outcome))
+	profile := res.Scripts["s"].Statements[0].Profile
+	assert.Equal(t, int64(2), profile.Samples)
+	assert.Equal(t, int64(5), profile.DbHits.Max())
+	assert.Equal(t, int64(1), profile.Rows.Max())
+	digest := planDigest("ProduceResults(Filter(NodeByLabelScan))")
+	assert.Equal(t, map[string]*PlanSample{digest: {Operators: "ProduceResults(Filter(NodeByLabelScan))", Count: 2}}, profile.Plans)
+	// EXPLAIN statements don't run, so there's nothing to profile
+	assert.Nil(t, res.Scripts["s"].Statements[1].Profile)
+
+	combined := NewResult("db", "")
+	combined.Add(res)
+	combined.Add(res)
+	assert.Equal(t, int64(4), combined.Scripts["s"].Statements[0].Profile.Plans[digest].Count)
+
+	s := strings.Builder{}
+	writeProfileReport(combined, &s)
+	assert.Contains(t, s.String(), digest+"  ProduceResults(Filter(NodeByLabelScan))")
+}
+
+// Returns a fixed plan for statements run with PROFILE, and none otherwise
+type profilingSession struct {
+	neo4j.Session
+	queries []string
+}
+
+func (s *profilingSession) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(&profilingTx{session: s})
+}
+
+type profilingTx struct {
+	neo4j.Transaction
+	session *profilingSession
+}
+
+func (tx *profilingTx) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	tx.session.queries = append(tx.session.queries, cypher)
+	if !strings.HasPrefix(cypher, "PROFILE") {
+		return profiledResult{}, nil
+	}
+	return profiledResult{plan: fakePlan{"ProduceResults@neo4j", 0, 1, []neo4j.ProfiledPlan{
+		fakePlan{"Filter@neo4j", 2, 1, []neo4j.ProfiledPlan{
+			fakePlan{"NodeByLabelScan@neo4j", 3, 3, nil},
+		}},
+	}}}, nil
+}
+
+type profiledResult struct {
+	neo4j.Result
+	neo4j.ResultSummary
+	plan neo4j.ProfiledPlan
+}
+
+func (r profiledResult) Consume() (neo4j.ResultSummary, error) {
+	return r, nil
+}
+
+func (r profiledResult) Profile() neo4j.ProfiledPlan {
+	return r.plan
+}
+
+type fakePlan struct {
+	operator string
+	dbHits   int64
+	records  int64
+	children []neo4j.ProfiledPlan
+}
+
+func (p fakePlan) Operator() string                  { return p.operator }
+func (p fakePlan) Arguments() map[string]interface{} { return nil }
+func (p fakePlan) Identifiers() []string             { return nil }
+func (p fakePlan) DbHits() int64                     { return p.dbHits }
+func (p fakePlan) Records() int64                    { return p.records }
+func (p fakePlan) Children() []neo4j.ProfiledPlan    { return p.children }
+func (p fakePlan) PageCacheMisses() int64            { return 0 }
+func (p fakePlan) PageCacheHits() int64              { return 0 }
+func (p fakePlan) PageCacheHitRatio() float64        { return 0 }
+func (p fakePlan) Time() int64                       { return 0 }
+
+func TestCountsUnexpectedResultsAsDataErrors(t *testing.T) {
+	w := NewWorker(nil, 0)
+	uow := UnitOfWork{ScriptName: "s", Statements: []Statement{
+		{Query: "MATCH (a) RETURN a", Expect: []Expectation{{Value: int64(3)}}},
+		{Query: "MATCH p=() RETURN length(p) AS pathLength", Expect: []Expectation{{Column: "pathLength", Value: 2.0}}},
+	}}
+
+	outcome := w.runUnit(&rowsSession{rows: []int64{2, 2, 2}}, uow)
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, "", outcome.dataError)
+
+	outcome = w.runUnit(&rowsSession{rows: []int64{2, 3}}, uow)
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, "statement 1: expected 3 rows, got 2", outcome.dataError)
+
+	uow.Statements[1].Expect = []Expectation{{Column: "length", Value: int64(2)}}
+	outcome = w.runUnit(&rowsSession{rows: []int64{2, 2, 2}}, uow)
+	assert.Equal(t, "statement 2: expected a column length, but it was not returned", outcome.dataError)
+
+	res := NewWorkerResult(0)
+	assert.NoError(t, res.record("s", time.Millisecond, outcome))
+	assert.NoError(t, res.record("s", time.Millisecond, uowOutcome{succeeded: true, failedStatement: -1}))
+	combined := NewResult("db", "")
+	combined.Add(res)
+	assert.Equal(t, int64(2), combined.Scripts["s"].Succeeded)
+	assert.Equal(t, int64(1), combined.TotalDataErrors())
+	assert.Equal(t, int64(0), combined.TotalFailed())
+	assert.Equal(t, "statement 2: expected a column length, but it was not returned", combined.Scripts["s"].FirstDataError)
+}
+
+func TestProgressReportsAddUpToTheTotal(t *testing.T) {
+	recorder := NewResultRecorder(1)
+	start := time.Now()
+	recorder.totalStart, recorder.currentStart = start, start
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, recorder.record("read", time.Duration(i+1)*time.Millisecond, uowOutcome{succeeded: true}))
+	}
+	interval := recorder.ProgressReport(start.Add(time.Second))
+	assert.Equal(t, int64(3), interval.Scripts["read"].Succeeded)
+	assert.NoError(t, recorder.record("read", time.Millisecond, uowOutcome{failureGroup: "oops", failedStatement: -1}))
+	assert.NoError(t, recorder.record("write", time.Millisecond, uowOutcome{succeeded: true}))
+
+	snapshot := recorder.Snapshot()
+	assert.Equal(t, int64(3), snapshot.Scripts["read"].Succeeded)
+	assert.Equal(t, int64(1), snapshot.Scripts["read"].Failed)
+	assert.Equal(t, 0.0, snapshot.Scripts["read"].Rate)
+
+	total := recorder.Complete(start.Add(2 * time.Second))
+	assert.Equal(t, int64(3), total.Scripts["read"].Succeeded)
+	assert.Equal(t, int64(1), total.Scripts["read"].Failed)
+	assert.Equal(t, int64(4), total.Scripts["read"].Latencies.TotalCount()+total.Scripts["read"].Failed)
+	assert.Equal(t, int64(3000), total.Scripts["read"].Latencies.Max()/1000*1000)
+	assert.Equal(t, int64(1), total.Scripts["write"].Succeeded)
+	assert.Equal(t, int64(1), total.FailedByErrorGroup["oops"].Count)
+	assert.Equal(t, int64(5), total.QueueTimes.TotalCount())
+	assert.InDelta(t, 2.0, total.Scripts["read"].Rate, 0.01)
+}
+
+// Cost of recording a sample, which workers pay for each unit of work
+func BenchmarkResultRecorder(b *testing.B) {
+	recorder := NewResultRecorder(1)
+	outcome := uowOutcome{succeeded: true, attempts: 1, failedStatement: -1}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = recorder.record("read", time.Duration(i%100000)*time.Microsecond, outcome)
+	}
+}
+
+// The same, with the progress of all workers read as often as a 100ms --progress would
+func BenchmarkResultRecorderParallel(b *testing.B) {
+	recorders := make([]*ResultRecorder, 64)
+	for i := range recorders {
+		recorders[i] = NewResultRecorder(int64(i))
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-time.After(100 * time.Millisecond):
+				for _, recorder := range recorders {
+					recorder.ProgressReport(now)
+				}
+			}
+		}
+	}()
+	var next int64
+	outcome := uowOutcome{succeeded: true, attempts: 1, failedStatement: -1}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		recorder := recorders[atomic.AddInt64(&next, 1)%int64(len(recorders))]
+		i := 0
+		for pb.Next() {
+			i++
+			_ = recorder.record("read", time.Duration(i%100000)*time.Microsecond, outcome)
+		}
+	})
+}
+
+func TestConsumeModes(t *testing.T) {
+	for mode, expected := range map[ConsumeMode]int64{ConsumeSummary: 0, ConsumeFirst: 1, ConsumeCount: 3, ConsumeAll: 3} {
+		res := &rowsResult{rows: []int64{1, 2, 3}, next: -1}
+		assert.Equal(t, expected, mode.read(res), mode)
+	}
+	mode, err := ParseConsumeMode("first")
+	assert.NoError(t, err)
+	assert.Equal(t, ConsumeFirst, mode)
+	_, err = ParseConsumeMode("some")
+	assert.Error(t, err)
+}
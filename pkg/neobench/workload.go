@@ -2,21 +2,35 @@ package neobench
 
 import (
 	"fmt"
-	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/pkg/errors"
 	"io"
 	"math/rand"
 	"os"
 	"sort"
+	"strings"
 	"time"
 )
 
+// Variables scripts of each client get, see Workload.NewClientAt
+const (
+	clientIdVar    = "client_id"
+	clientCountVar = "client_count"
+)
+
 type Workload struct {
 	// set on command line and built in
 	Variables map[string]interface{}
 
 	Scripts Scripts
 
+	// Number of clients the workload is split across, used to divide per-script rate limits between them
+	Clients int
+	// For a run that is one share of a benchmark across machines: the number of the first client here, and the
+	// clients across all machines, 0 if they're all here. Scripts see them as $client_id and $client_count.
+	ClientOffset int
+	ClientCount  int
+
 	Rand *rand.Rand
 }
 
@@ -91,13 +105,48 @@ type Script struct {
 	Name     string
 	Readonly bool
 	Weight   uint
+	// Max transactions per second for this script across all clients, 0 means no limit
+	Rate float64
+	// If set, an integer column returned by the last statement, whose values are reported as a distribution
+	// alongside latency; eg. the length of the paths a traversal found
+	Metric   string
 	Commands []Command
+	// Commands between \init and \end, run once by RunInit in -i mode rather than as part of the workload
+	Init []Command
 }
 
 type ScriptContext struct {
 	Stderr io.Writer
 	Vars   map[string]interface{}
 	Rand   *rand.Rand
+	// Set by ClientWorkload: Vars is shared, with the client or with statements given it as their params, and is
+	// copied before \set changes it, rather than copied for every statement
+	copyOnWrite bool
+	varsShared  bool
+}
+
+// The params for a statement run as of now
+func (ctx *ScriptContext) statementParams() map[string]interface{} {
+	if ctx.copyOnWrite {
+		ctx.varsShared = true
+		return ctx.Vars
+	}
+	params := make(map[string]interface{}, len(ctx.Vars))
+	for k, v := range ctx.Vars {
+		params[k] = v
+	}
+	return params
+}
+
+func (ctx *ScriptContext) setVar(name string, value interface{}) {
+	if ctx.varsShared {
+		vars := make(map[string]interface{}, len(ctx.Vars)+1)
+		for k, v := range ctx.Vars {
+			vars[k] = v
+		}
+		ctx.Vars, ctx.varsShared = vars, false
+	}
+	ctx.Vars[name] = value
 }
 
 // Evaluate this script in the given context
@@ -105,7 +154,8 @@ func (s *Script) Eval(ctx ScriptContext) (UnitOfWork, error) {
 	uow := UnitOfWork{
 		ScriptName: s.Name,
 		Readonly:   s.Readonly,
-		Statements: nil,
+		Metric:     s.Metric,
+		Statements: make([]Statement, 0, len(s.Commands)),
 	}
 
 	for _, cmd := range s.Commands {
@@ -117,47 +167,188 @@ func (s *Script) Eval(ctx ScriptContext) (UnitOfWork, error) {
 	return uow, nil
 }
 
+// Runs the scripts \init section, each statement in its own transaction so schema changes and data loading can
+// be mixed; a script without one does nothing. Statements of \load_csv are run in batches of rows as opts says.
+func (s *Script) RunInit(ctx ScriptContext, dbName string, driver neo4j.Driver, opts InitOptions, out Output) error {
+	if len(s.Init) == 0 {
+		return nil
+	}
+	uow := UnitOfWork{ScriptName: s.Name}
+	for _, cmd := range s.Init {
+		if err := cmd.Execute(&ctx, &uow); err != nil {
+			return err
+		}
+	}
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+	for i, statement := range uow.Statements {
+		out.ReportProgress(ProgressReport{
+			Section:      "init",
+			Step:         s.Name,
+			Completeness: float64(i) / float64(len(uow.Statements)),
+		})
+		if statement.LoadCsv != "" {
+			if err := loadCsv(session, driver, dbName, opts, s.Name, statement, out); err != nil {
+				return fmt.Errorf("%s: init statement %d failed: %s", s.Name, i+1, err)
+			}
+			continue
+		}
+		result, err := session.Run(statement.Query, statement.Params)
+		if err != nil {
+			return fmt.Errorf("%s: init statement %d failed: %s", s.Name, i+1, err)
+		}
+		if statement.Verify {
+			if !result.Next() {
+				return fmt.Errorf("%s: init check %d returned no result: %v", s.Name, i+1, result.Err())
+			}
+			if passed, _ := result.Record().Values[0].(bool); !passed {
+				return fmt.Errorf("%s: init check %d failed, the dataset is not what the script expects: %s", s.Name, i+1, strings.TrimSpace(statement.Query))
+			}
+		}
+		if _, err = result.Consume(); err != nil {
+			return fmt.Errorf("%s: init statement %d failed: %s", s.Name, i+1, err)
+		}
+	}
+	return nil
+}
+
+// Client workload for one client, or where which client doesn't matter; see NewClientAt
 func (s *Workload) NewClient() ClientWorkload {
+	return s.NewClientAt(0)
+}
+
+// Client workload for the client at index among the Clients of the workload, with $client_id and $client_count
+// set so scripts can give each client a part of the keyspace of its own
+func (s *Workload) NewClientAt(index int) ClientWorkload {
+	clients := s.Clients
+	if clients < 1 {
+		clients = 1
+	}
+	var pacing map[string]time.Duration
+	for _, script := range s.Scripts.Scripts {
+		if script.Rate <= 0 {
+			continue
+		}
+		if pacing == nil {
+			pacing = make(map[string]time.Duration)
+		}
+		// Each client gets an even share of the rate limit
+		pacing[script.Name] = time.Duration(float64(clients) * float64(time.Second) / script.Rate)
+	}
+	readonly := len(s.Scripts.Scripts) > 0
+	for _, script := range s.Scripts.Scripts {
+		readonly = readonly && script.Readonly
+	}
+	clientCount := s.ClientCount
+	if clientCount < 1 {
+		clientCount = clients
+	}
+	vars := make(map[string]interface{}, len(s.Variables)+2)
+	for k, v := range s.Variables {
+		vars[k] = v
+	}
+	vars[clientIdVar] = int64(s.ClientOffset + index)
+	vars[clientCountVar] = int64(clientCount)
 	return ClientWorkload{
-		Variables: s.Variables,
+		Readonly:  readonly,
+		Variables: vars,
 		Scripts:   s.Scripts,
 		Rand:      rand.New(rand.NewSource(s.Rand.Int63())),
 		Stderr:    os.Stderr,
+		Pacing:    pacing,
 	}
 }
 
 type ClientWorkload struct {
+	// True if every script is read-only, so sessions can be opened in read mode
 	Readonly bool
 	// variables set on command line and built-in
 	Variables map[string]interface{}
 	Scripts   Scripts
 	Rand      *rand.Rand
 	Stderr    io.Writer
+	// Min time between runs of rate limited scripts in this client, by script name
+	Pacing map[string]time.Duration
+	// When each rate limited script may next run
+	nextAllowed map[string]time.Time
 }
 
 func (s *ClientWorkload) Next() (UnitOfWork, error) {
-	vars := make(map[string]interface{})
-	for k, v := range s.Variables {
-		vars[k] = v
-	}
+	uow, _, err := s.NextAt(time.Now())
+	return uow, err
+}
 
+// Picks the next unit of work as of now, skipping rate limited scripts that are not due yet. If every
+// script is rate limited and none are due, returns how long to wait before asking again instead.
+func (s *ClientWorkload) NextAt(now time.Time) (UnitOfWork, time.Duration, error) {
 	script := s.Scripts.Choose(s.Rand)
-	return script.Eval(ScriptContext{
-		Stderr: s.Stderr,
-		Vars:   vars,
-		Rand:   s.Rand,
+	if len(s.Pacing) > 0 {
+		if s.nextAllowed == nil {
+			s.nextAllowed = make(map[string]time.Time)
+		}
+		if s.nextAllowed[script.Name].After(now) {
+			// Choose again among the scripts that are due, keeping their relative weights
+			eligible := make([]Script, 0, len(s.Scripts.Scripts))
+			var wait time.Duration
+			for _, candidate := range s.Scripts.Scripts {
+				until := s.nextAllowed[candidate.Name].Sub(now)
+				if until <= 0 {
+					if candidate.Weight > 0 {
+						eligible = append(eligible, candidate)
+					}
+				} else if wait == 0 || until < wait {
+					wait = until
+				}
+			}
+			if len(eligible) == 0 {
+				return UnitOfWork{}, wait, nil
+			}
+			remaining := NewScripts(eligible...)
+			script = remaining.Choose(s.Rand)
+		}
+		if interval, limited := s.Pacing[script.Name]; limited {
+			next := s.nextAllowed[script.Name]
+			if next.Before(now) {
+				// Don't let a script that fell behind burst to catch up
+				next = now
+			}
+			s.nextAllowed[script.Name] = next.Add(interval)
+		}
+	}
+
+	uow, err := script.Eval(ScriptContext{
+		Stderr:      s.Stderr,
+		Vars:        s.Variables,
+		Rand:        s.Rand,
+		copyOnWrite: true,
+		varsShared:  true,
 	})
+	return uow, 0, err
 }
 
 type UnitOfWork struct {
 	ScriptName string
 	Readonly   bool
+	// See Script.Metric
+	Metric     string
 	Statements []Statement
+	// If set, each statement runs with PROFILE, see Worker.ProfileSampleRate
+	Profile bool
 }
 
 type Statement struct {
 	Query  string
 	Params map[string]interface{}
+	// Set by \load_csv, in \init sections: RunInit runs Query for each row of the CSV file at this URL
+	LoadCsv string
+	// Set by \verify, in \init sections: RunInit fails unless Query returns true
+	Verify bool
+	// Set by \expect: the worker reads the rows Query returns and checks them against these, counting any
+	// mismatch as a data error rather than failing the unit of work
+	Expect []Expectation
 }
 
 type Command interface {
@@ -169,13 +360,38 @@ type QueryCommand struct {
 }
 
 func (c QueryCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
-	params := make(map[string]interface{})
-	for k, v := range ctx.Vars {
-		params[k] = v
-	}
 	uow.Statements = append(uow.Statements, Statement{
 		Query:  c.Query,
-		Params: params,
+		Params: ctx.statementParams(),
+	})
+	return nil
+}
+
+// Runs Query for each row of the CSV file at Url, given to it as row; see RunInit
+type LoadCsvCommand struct {
+	Url   string
+	Query string
+}
+
+func (c LoadCsvCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	uow.Statements = append(uow.Statements, Statement{
+		Query:   c.Query,
+		Params:  ctx.statementParams(),
+		LoadCsv: c.Url,
+	})
+	return nil
+}
+
+// Checks that Query returns true, eg. that a dataset imported ahead of time is what the script expects
+type VerifyCommand struct {
+	Query string
+}
+
+func (c VerifyCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	uow.Statements = append(uow.Statements, Statement{
+		Query:  c.Query,
+		Params: ctx.statementParams(),
+		Verify: true,
 	})
 	return nil
 }
@@ -190,7 +406,7 @@ func (c SetCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
 	if err != nil {
 		return err
 	}
-	ctx.Vars[c.VarName] = value
+	ctx.setVar(c.VarName, value)
 	return nil
 }
 
@@ -214,13 +430,10 @@ func (c SleepCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
 
 // Validates that a workload doesn't have syntax errors etc, and tells us if it is read-only
 func WorkloadPreflight(driver neo4j.Driver, dbName string, script Script, vars map[string]interface{}) (readonly bool, err error) {
-	session, err := driver.NewSession(neo4j.SessionConfig{
+	session := driver.NewSession(neo4j.SessionConfig{
 		AccessMode:   neo4j.AccessModeWrite,
 		DatabaseName: dbName,
 	})
-	if err != nil {
-		return false, err
-	}
 	r := rand.New(rand.NewSource(1337))
 	unitOfWork, err := script.Eval(ScriptContext{
 		Stderr: os.Stderr,
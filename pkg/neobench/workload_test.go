@@ -1,8 +1,11 @@
 package neobench
 
 import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 )
@@ -48,3 +51,200 @@ func TestChooseWeightedWorkload(t *testing.T) {
 	assert.InDelta(t, float64(b.Weight), bNorm, maxDiffOnB, "seed=%d", seed)
 	assert.InDelta(t, float64(c.Weight), cNorm, maxDiffOnC, "seed=%d", seed)
 }
+
+func TestRateLimitedScriptIsCappedWhileOthersRunFreely(t *testing.T) {
+	reads := Script{Name: "reads", Weight: 9}
+	writes := Script{Name: "writes", Weight: 1, Rate: 50}
+	wrk := Workload{
+		Scripts: NewScripts(reads, writes),
+		Clients: 2,
+		Rand:    rand.New(rand.NewSource(1337)),
+	}
+	client := wrk.NewClient()
+	assert.Equal(t, 40*time.Millisecond, client.Pacing["writes"])
+
+	// Ask for work every millisecond for ten seconds; writes should be capped at 25/s for this client
+	now := time.Unix(0, 0)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		uow, wait, err := client.NextAt(now)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), wait)
+		counts[uow.ScriptName]++
+		now = now.Add(time.Millisecond)
+	}
+	assert.LessOrEqual(t, counts["writes"], 250)
+	assert.Greater(t, counts["writes"], 200)
+	assert.Equal(t, 10000, counts["reads"]+counts["writes"])
+}
+
+func TestWaitsWhenAllScriptsAreRateLimited(t *testing.T) {
+	wrk := Workload{
+		Scripts: NewScripts(Script{Name: "writes", Weight: 1, Rate: 10}),
+		Rand:    rand.New(rand.NewSource(1337)),
+	}
+	client := wrk.NewClient()
+
+	now := time.Unix(0, 0)
+	uow, wait, err := client.NextAt(now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+	assert.Equal(t, "writes", uow.ScriptName)
+
+	_, wait, err = client.NextAt(now.Add(30 * time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, 70*time.Millisecond, wait)
+}
+
+func TestRunInitRunsEachStatementOnItsOwn(t *testing.T) {
+	script, err := Parse("test", `\init
+CREATE INDEX ON :Person(id);
+\set n 10 * $scale
+UNWIND range(1, $n) AS id CREATE (:Person {id: id});
+\end
+RETURN 1;
+`, 1)
+	assert.NoError(t, err)
+
+	session := &recordingSession{}
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+	err = script.RunInit(ScriptContext{Vars: map[string]interface{}{"scale": int64(2)}, Rand: rand.New(rand.NewSource(1))},
+		"neo4j", recordingDriver{session: session}, DefaultInitOptions, out)
+
+	assert.NoError(t, err)
+	assert.Len(t, session.ran, 2)
+	assert.Equal(t, "CREATE INDEX ON :Person(id)", session.ran[0].Query)
+	assert.Equal(t, int64(20), session.ran[1].Params["n"])
+
+	// Scripts without an init section have nothing to run
+	script, _ = Parse("test", "RETURN 1;", 1)
+	session = &recordingSession{}
+	assert.NoError(t, script.RunInit(ScriptContext{}, "neo4j", recordingDriver{session: session}, DefaultInitOptions, out))
+	assert.Empty(t, session.ran)
+}
+
+func TestRunInitVerifiesDataset(t *testing.T) {
+	script, err := Parse("people.script", `\init
+\verify
+MATCH (p:Person) RETURN count(p) >= 1000;
+\end
+`, 1)
+	assert.NoError(t, err)
+	out := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	session := &recordingSession{rows: [][]interface{}{{true}}}
+	assert.NoError(t, script.RunInit(ScriptContext{Vars: map[string]interface{}{}}, "neo4j", recordingDriver{session: session}, DefaultInitOptions, out))
+
+	session = &recordingSession{rows: [][]interface{}{{false}}}
+	err = script.RunInit(ScriptContext{Vars: map[string]interface{}{}}, "neo4j", recordingDriver{session: session}, DefaultInitOptions, out)
+	assert.EqualError(t, err, "people.script: init check 1 failed, the dataset is not what the script expects: MATCH (p:Person) RETURN count(p) >= 1000")
+}
+
+type recordingDriver struct {
+	neo4j.Driver
+	session *recordingSession
+}
+
+func (d recordingDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	return d.session
+}
+
+// Records auto-commit statements rather than running them
+type recordingSession struct {
+	neo4j.Session
+	ran []Statement
+	// Returned by every statement, if set
+	rows [][]interface{}
+}
+
+func (s *recordingSession) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	s.ran = append(s.ran, Statement{Query: cypher, Params: params})
+	if s.rows != nil {
+		return &rowResult{rows: s.rows, next: -1}, nil
+	}
+	return consumedResult{}, nil
+}
+
+func (s *recordingSession) Close() error {
+	return nil
+}
+
+func TestClientsDrawFromDisjointPartitions(t *testing.T) {
+	script, err := Parse("partitioned", `\set id random_partitioned(1, 1001)
+\set lb partition_lb(1, 1001)
+\set ub partition_ub(1, 1001)
+RETURN $id, $client_id, $client_count;`, 1)
+	assert.NoError(t, err)
+	// The second machine of a benchmark split across machines, running clients 3 to 5 of 6
+	wrk := Workload{Scripts: NewScripts(script), Clients: 3, ClientOffset: 3, ClientCount: 6, Rand: rand.New(rand.NewSource(1))}
+
+	lastUb := int64(501)
+	for i := 0; i < 3; i++ {
+		client := wrk.NewClientAt(i)
+		for n := 0; n < 100; n++ {
+			uow, err := client.Next()
+			assert.NoError(t, err)
+			params := uow.Statements[0].Params
+			assert.Equal(t, int64(3+i), params["client_id"])
+			assert.Equal(t, int64(6), params["client_count"])
+			assert.Equal(t, lastUb, params["lb"])
+			assert.GreaterOrEqual(t, params["id"], params["lb"])
+			assert.Less(t, params["id"], params["ub"])
+		}
+		uow, _ := client.Next()
+		lastUb = uow.Statements[0].Params["ub"].(int64)
+	}
+	assert.Equal(t, int64(1001), lastUb)
+
+	// Outside of a client, like in \init sections, the partition is the whole range
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1))})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), uow.Statements[0].Params["lb"])
+	assert.Equal(t, int64(1001), uow.Statements[0].Params["ub"])
+
+	tooSmall := Workload{Scripts: NewScripts(script), Clients: 2000, Rand: rand.New(rand.NewSource(1))}
+	client := tooSmall.NewClientAt(0)
+	_, err = client.Next()
+	assert.Error(t, err)
+}
+
+func TestStatementsKeepTheVariablesAsOfWhenTheyRan(t *testing.T) {
+	script, err := Parse("sets", `\set n 1
+RETURN $n;
+RETURN $n, $scale;
+\set n 2
+RETURN $n;`, 1)
+	assert.NoError(t, err)
+	wrk := Workload{Scripts: NewScripts(script), Variables: map[string]interface{}{"scale": int64(10)}, Rand: rand.New(rand.NewSource(1))}
+	client := wrk.NewClient()
+
+	for i := 0; i < 2; i++ {
+		uow, err := client.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), uow.Statements[0].Params["n"])
+		assert.Equal(t, int64(1), uow.Statements[1].Params["n"])
+		assert.Equal(t, int64(10), uow.Statements[1].Params["scale"])
+		assert.Equal(t, int64(2), uow.Statements[2].Params["n"])
+	}
+	// The variables of the client are left as they were
+	assert.NotContains(t, client.Variables, "n")
+}
+
+func TestScriptsWithoutSetDontCopyVariables(t *testing.T) {
+	script, err := Parse("read", "RETURN $v1;\nRETURN $v1;", 1)
+	assert.NoError(t, err)
+	allocs := func(variables int) float64 {
+		vars := make(map[string]interface{}, variables)
+		for i := 1; i <= variables; i++ {
+			vars[fmt.Sprintf("v%d", i)] = int64(i)
+		}
+		wrk := Workload{Scripts: NewScripts(script), Variables: vars, Rand: rand.New(rand.NewSource(1))}
+		client := wrk.NewClient()
+		return testing.AllocsPerRun(100, func() {
+			_, _ = client.Next()
+		})
+	}
+
+	// Statements are given the variables of the client as they are, however many there are
+	assert.Equal(t, allocs(1), allocs(100))
+}